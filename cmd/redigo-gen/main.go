@@ -0,0 +1,289 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Command redigo-gen generates reflection-free implementations of the
+// redis.StructScanner and redis.StructAppender interfaces for struct types.
+//
+// Use redigo-gen with go generate:
+//
+//  //go:generate redigo-gen -type=User,Album
+//
+// The generated RedisScanStruct and RedisAppendStruct methods follow the
+// 'redis' field tag conventions used by redis.ScanStruct and
+// redis.AppendStruct. Fields must have a string, []byte, boolean, integer or
+// floating point type or have the "json" tag flag. The generated code is
+// written to <type>_redis.go unless the -output flag is given.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of type names")
+	output    = flag.String("output", "", "output file name")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("redigo-gen: ")
+	flag.Parse()
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(pkgs) != 1 {
+		log.Fatalf("expected one package in %s, found %d", dir, len(pkgs))
+	}
+	var files []*ast.File
+	var pkgName string
+	for name, pkg := range pkgs {
+		pkgName = name
+		for _, f := range pkg.Files {
+			files = append(files, f)
+		}
+	}
+	types := strings.Split(*typeNames, ",")
+	src, err := generate(pkgName, files, types)
+	if err != nil {
+		log.Fatal(err)
+	}
+	name := *output
+	if name == "" {
+		name = strings.ToLower(types[0]) + "_redis.go"
+	}
+	if err := ioutil.WriteFile(name, src, 0666); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type field struct {
+	goName string
+	name   string
+	kind   string // Go type name of the field
+	json   bool
+}
+
+// generate returns the formatted source for the named types declared in files.
+func generate(pkgName string, files []*ast.File, types []string) ([]byte, error) {
+	structs := make(map[string]*ast.StructType)
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if ts, ok := n.(*ast.TypeSpec); ok {
+				if st, ok := ts.Type.(*ast.StructType); ok {
+					structs[ts.Name.Name] = st
+				}
+			}
+			return true
+		})
+	}
+
+	var buf bytes.Buffer
+	imports := make(map[string]bool)
+	for _, typeName := range types {
+		st := structs[typeName]
+		if st == nil {
+			return nil, errors.New("struct type " + typeName + " not found")
+		}
+		fields, err := structFields(typeName, st)
+		if err != nil {
+			return nil, err
+		}
+		writeScan(&buf, typeName, fields, imports)
+		writeAppend(&buf, typeName, fields, imports)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by redigo-gen. DO NOT EDIT.\n\npackage %s\n\n", pkgName)
+	out.WriteString("import (\n")
+	for _, path := range []string{"encoding/json", "errors", "fmt", "strconv"} {
+		if imports[path] {
+			fmt.Fprintf(&out, "\t%q\n", path)
+		}
+	}
+	out.WriteString(")\n")
+	out.Write(buf.Bytes())
+	return format.Source(out.Bytes())
+}
+
+func structFields(typeName string, st *ast.StructType) ([]field, error) {
+	var fields []field
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			return nil, errors.New(typeName + ": embedded fields are not supported")
+		}
+		var tag string
+		if f.Tag != nil {
+			s, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(s).Get("redis")
+		}
+		kind := typeString(f.Type)
+		for _, n := range f.Names {
+			if !n.IsExported() {
+				continue
+			}
+			fd := field{goName: n.Name, name: n.Name, kind: kind}
+			p := strings.Split(tag, ",")
+			if p[0] == "-" {
+				continue
+			}
+			if p[0] != "" {
+				fd.name = p[0]
+			}
+			for _, s := range p[1:] {
+				switch s {
+				case "json":
+					fd.json = true
+				default:
+					return nil, fmt.Errorf("%s.%s: field flag %s is not supported", typeName, n.Name, s)
+				}
+			}
+			if !fd.json && !supportedKinds[kind] {
+				return nil, fmt.Errorf("%s.%s: type %s is not supported", typeName, n.Name, kind)
+			}
+			fields = append(fields, fd)
+		}
+	}
+	return fields, nil
+}
+
+func typeString(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.ArrayType:
+		if e.Len == nil {
+			return "[]" + typeString(e.Elt)
+		}
+	case *ast.StarExpr:
+		return "*" + typeString(e.X)
+	case *ast.SelectorExpr:
+		return typeString(e.X) + "." + e.Sel.Name
+	}
+	return "?"
+}
+
+var supportedKinds = map[string]bool{
+	"string": true, "[]byte": true, "bool": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"float32": true, "float64": true,
+}
+
+func bits(kind string) string {
+	for _, n := range []string{"8", "16", "32", "64"} {
+		if strings.HasSuffix(kind, n) {
+			return n
+		}
+	}
+	return "0"
+}
+
+func writeScan(w *bytes.Buffer, typeName string, fields []field, imports map[string]bool) {
+	imports["errors"] = true
+	imports["fmt"] = true
+	fmt.Fprintf(w, "\n// RedisScanStruct implements the redis.StructScanner interface.\n")
+	fmt.Fprintf(w, "func (v *%s) RedisScanStruct(src []interface{}) error {\n", typeName)
+	w.WriteString(`if len(src)%2 != 0 {
+		return errors.New("redigo: ScanStruct expects even number of values in values")
+	}
+	for i := 0; i < len(src); i += 2 {
+		var name string
+		switch s := src[i].(type) {
+		case []byte:
+			name = string(s)
+		case string:
+			name = s
+		default:
+			return errors.New("redigo: ScanStruct key not a bulk value")
+		}
+		var p []byte
+		switch s := src[i+1].(type) {
+		case nil:
+			continue
+		case []byte:
+			p = s
+		case string:
+			p = []byte(s)
+		case int64:
+			p = strconv.AppendInt(nil, s, 10)
+		default:
+			return fmt.Errorf("redigo: ScanStruct cannot convert from %T for field %s", s, name)
+		}
+		switch name {
+`)
+	imports["strconv"] = true
+	for _, f := range fields {
+		fmt.Fprintf(w, "case %q:\n", f.name)
+		switch {
+		case f.json:
+			imports["encoding/json"] = true
+			fmt.Fprintf(w, "if err := json.Unmarshal(p, &v.%s); err != nil {\nreturn err\n}\n", f.goName)
+		case f.kind == "string":
+			fmt.Fprintf(w, "v.%s = string(p)\n", f.goName)
+		case f.kind == "[]byte":
+			fmt.Fprintf(w, "v.%s = append([]byte(nil), p...)\n", f.goName)
+		case f.kind == "bool":
+			fmt.Fprintf(w, "x, err := strconv.ParseBool(string(p))\nif err != nil {\nreturn err\n}\nv.%s = x\n", f.goName)
+		case strings.HasPrefix(f.kind, "int"):
+			fmt.Fprintf(w, "x, err := strconv.ParseInt(string(p), 10, %s)\nif err != nil {\nreturn err\n}\nv.%s = %s(x)\n", bits(f.kind), f.goName, f.kind)
+		case strings.HasPrefix(f.kind, "uint"):
+			fmt.Fprintf(w, "x, err := strconv.ParseUint(string(p), 10, %s)\nif err != nil {\nreturn err\n}\nv.%s = %s(x)\n", bits(f.kind), f.goName, f.kind)
+		case strings.HasPrefix(f.kind, "float"):
+			fmt.Fprintf(w, "x, err := strconv.ParseFloat(string(p), %s)\nif err != nil {\nreturn err\n}\nv.%s = %s(x)\n", bits(f.kind), f.goName, f.kind)
+		}
+	}
+	w.WriteString("}\n}\nreturn nil\n}\n")
+}
+
+func writeAppend(w *bytes.Buffer, typeName string, fields []field, imports map[string]bool) {
+	fmt.Fprintf(w, "\n// RedisAppendStruct implements the redis.StructAppender interface.\n")
+	fmt.Fprintf(w, "func (v %s) RedisAppendStruct(args []interface{}) ([]interface{}, error) {\n", typeName)
+	for _, f := range fields {
+		if f.json {
+			imports["encoding/json"] = true
+			fmt.Fprintf(w, "if p, err := json.Marshal(v.%s); err != nil {\nreturn nil, err\n} else {\nargs = append(args, %q, p)\n}\n", f.goName, f.name)
+		} else {
+			fmt.Fprintf(w, "args = append(args, %q, v.%s)\n", f.name, f.goName)
+		}
+	}
+	w.WriteString("return args, nil\n}\n")
+}
@@ -0,0 +1,69 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+const testSource = `package example
+
+type User struct {
+	Name    string ` + "`redis:\"name\"`" + `
+	Age     int8
+	Score   float64
+	Active  bool
+	Data    []byte
+	Tags    []string ` + "`redis:\"tags,json\"`" + `
+	Skip    int ` + "`redis:\"-\"`" + `
+	private int
+}
+`
+
+func TestGenerate(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "example.go", testSource, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate("example", []*ast.File{f}, []string{"User"})
+	if err != nil {
+		t.Fatalf("generate returned error %v", err)
+	}
+	g, err := parser.ParseFile(fset, "user_redis.go", src, 0)
+	if err != nil {
+		t.Fatalf("generated source does not parse: %v\n%s", err, src)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("example", fset, []*ast.File{f, g}, nil); err != nil {
+		t.Fatalf("generated source does not type check: %v\n%s", err, src)
+	}
+}
+
+func TestGenerateUnsupported(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "example.go", "package example\ntype T struct { M map[string]int }\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := generate("example", []*ast.File{f}, []string{"T"}); err == nil {
+		t.Fatalf("generate did not return error for unsupported field type")
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package exampletest is the test harness for the example programs. The
+// harness runs the examples against the server at the address in the
+// REDIGO_TEST_ADDR environment variable or ":6379" if the variable is not
+// set. The examples are skipped if the server is not available.
+package exampletest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+// Addr returns the address of the test server.
+func Addr() string {
+	if addr := os.Getenv("REDIGO_TEST_ADDR"); addr != "" {
+		return addr
+	}
+	return ":6379"
+}
+
+// Dial connects to database 9 of the test server. Dial returns an error if
+// the database is not empty.
+func Dial() (redis.Conn, error) {
+	c, err := redis.DialTimeout("tcp", Addr(), 0, 5*time.Second, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.Do("SELECT", 9); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// errUnavailable is the error from connecting to the test server or nil if
+// the server is available.
+var errUnavailable error
+
+// Main runs the tests. If the test server is not available, then Run skips
+// each test. Otherwise, Main checks that database 9 of the server is empty
+// and deletes the keys in database 9 after running the tests.
+func Main(m *testing.M) {
+	c, err := Dial()
+	if err != nil {
+		errUnavailable = err
+		os.Exit(m.Run())
+	}
+	n, err := redis.Int(c.Do("DBSIZE"))
+	if err == nil && n != 0 {
+		err = errors.New("database #9 is not empty")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "examples: %v\n", err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	c.Do("FLUSHDB")
+	c.Close()
+	os.Exit(code)
+}
+
+// Run runs an example with the Dial function and checks the output.
+func Run(t *testing.T, run func(dial func() (redis.Conn, error), w io.Writer) error, expected string) {
+	if errUnavailable != nil {
+		t.Skipf("test server not available: %v", errUnavailable)
+	}
+	var buf bytes.Buffer
+	if err := run(Dial, &buf); err != nil {
+		t.Fatalf("example returned error %v", err)
+	}
+	if buf.String() != expected {
+		t.Errorf("example output:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Command pipeline shows how to pipeline commands and transactions.
+package main
+
+import (
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"io"
+	"log"
+	"os"
+)
+
+func main() {
+	if err := run(func() (redis.Conn, error) { return redis.Dial("tcp", ":6379") }, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(dial func() (redis.Conn, error), w io.Writer) error {
+	c, err := dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	// Send writes commands to the connection's output buffer. Do with an
+	// empty command flushes the buffer and receives all pending replies.
+	c.Send("SET", "example:1", 1)
+	c.Send("SET", "example:2", 2)
+	c.Send("SET", "example:3", 3)
+	replies, err := redis.Strings(c.Do(""))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "SET replies: %v\n", replies)
+
+	values, err := redis.Ints(c.Do("MGET", "example:1", "example:2", "example:3"))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "MGET: %v\n", values)
+
+	// A pipelined transaction.
+	c.Send("MULTI")
+	c.Send("INCR", "example:3")
+	c.Send("INCR", "example:3")
+	values, err = redis.Ints(c.Do("EXEC"))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "EXEC: %v\n", values)
+	return nil
+}
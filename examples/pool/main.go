@@ -0,0 +1,58 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Command pool shows how to create a connection pool and use connections
+// from the pool.
+package main
+
+import (
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+func main() {
+	if err := run(func() (redis.Conn, error) { return redis.Dial("tcp", ":6379") }, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(dial func() (redis.Conn, error), w io.Writer) error {
+	pool := &redis.Pool{
+		MaxIdle:     3,
+		IdleTimeout: 240 * time.Second,
+		Dial:        dial,
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+	}
+	defer pool.Close()
+
+	for i := 0; i < 3; i++ {
+		// Get a connection from the pool and close the connection to return
+		// the connection to the pool.
+		c := pool.Get()
+		n, err := redis.Int(c.Do("INCR", "example:counter"))
+		c.Close()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "counter=%d\n", n)
+	}
+	return nil
+}
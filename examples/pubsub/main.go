@@ -0,0 +1,66 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Command pubsub shows how to subscribe to a channel and receive messages.
+package main
+
+import (
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+func main() {
+	if err := run(func() (redis.Conn, error) { return redis.Dial("tcp", ":6379") }, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(dial func() (redis.Conn, error), w io.Writer) error {
+	c, err := dial()
+	if err != nil {
+		return err
+	}
+	psc := redis.PubSubConn{Conn: c}
+	defer psc.Close()
+
+	// Wait for the subscription so that the message published below is not
+	// lost.
+	if _, err := psc.SubscribeWait(time.Second, "example:news"); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "subscribed to example:news")
+
+	pc, err := dial()
+	if err != nil {
+		return err
+	}
+	defer pc.Close()
+	if _, err := pc.Do("PUBLISH", "example:news", "hello"); err != nil {
+		return err
+	}
+
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Message:
+			fmt.Fprintf(w, "%s: %s\n", v.Channel, v.Data)
+			return psc.Unsubscribe()
+		case error:
+			return v
+		}
+	}
+}
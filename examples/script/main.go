@@ -0,0 +1,55 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Command script shows how to use the Script type to run Lua scripts.
+package main
+
+import (
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"io"
+	"log"
+	"os"
+)
+
+// getSet sets a key and returns the previous value of the key.
+var getSet = redis.NewScript(1, `
+local v = redis.call('GET', KEYS[1])
+redis.call('SET', KEYS[1], ARGV[1])
+return v`)
+
+func main() {
+	if err := run(func() (redis.Conn, error) { return redis.Dial("tcp", ":6379") }, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(dial func() (redis.Conn, error), w io.Writer) error {
+	c, err := dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	for _, v := range []string{"a", "b", "c"} {
+		// Do uses EVALSHA and falls back to EVAL if the script is not
+		// loaded.
+		old, err := redis.String(getSet.Do(c, "example:key", v))
+		if err != nil && err != redis.ErrNil {
+			return err
+		}
+		fmt.Fprintf(w, "GETSET: %s\n", old)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Command streams shows how to consume a stream with a consumer group.
+package main
+
+import (
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"io"
+	"log"
+	"os"
+)
+
+type album struct {
+	Title  string `redis:"title"`
+	Rating int    `redis:"rating"`
+}
+
+func main() {
+	if err := run(func() (redis.Conn, error) { return redis.Dial("tcp", ":6379") }, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(dial func() (redis.Conn, error), w io.Writer) error {
+	c, err := dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	const key = "example:albums"
+	if _, err := c.Do("XADD", key, "*", "title", "Red", "rating", 5); err != nil {
+		return err
+	}
+	if _, err := c.Do("XGROUP", "CREATE", key, "consumers", "0"); err != nil {
+		return err
+	}
+
+	streams, err := redis.Streams(c.Do("XREADGROUP", "GROUP", "consumers", "c1", "COUNT", 10, "STREAMS", key, ">"))
+	if err != nil {
+		return err
+	}
+	for _, s := range streams {
+		fmt.Fprintf(w, "%s %d entries\n", s.Key, len(s.Entries))
+		for _, e := range s.Entries {
+			var a album
+			if err := e.ScanStruct(&a); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s %d\n", a.Title, a.Rating)
+			n, err := redis.Int(c.Do("XACK", key, "consumers", e.ID))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "acknowledged %d\n", n)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// readOnlyCommands is the set of commands that do not modify data. All other
+// commands are recorded by an AuditLog.
+var readOnlyCommands = map[string]bool{
+	"BITCOUNT":         true,
+	"BITPOS":           true,
+	"DBSIZE":           true,
+	"DUMP":             true,
+	"ECHO":             true,
+	"EXISTS":           true,
+	"EXPIRETIME":       true,
+	"GEODIST":          true,
+	"GEOHASH":          true,
+	"GEOPOS":           true,
+	"GEOSEARCH":        true,
+	"GET":              true,
+	"GETBIT":           true,
+	"GETRANGE":         true,
+	"HEXISTS":          true,
+	"HGET":             true,
+	"HGETALL":          true,
+	"HKEYS":            true,
+	"HLEN":             true,
+	"HMGET":            true,
+	"HRANDFIELD":       true,
+	"HSCAN":            true,
+	"HSTRLEN":          true,
+	"HVALS":            true,
+	"INFO":             true,
+	"KEYS":             true,
+	"LINDEX":           true,
+	"LLEN":             true,
+	"LPOS":             true,
+	"LRANGE":           true,
+	"MGET":             true,
+	"OBJECT":           true,
+	"PEXPIRETIME":      true,
+	"PFCOUNT":          true,
+	"PING":             true,
+	"PTTL":             true,
+	"RANDOMKEY":        true,
+	"SCAN":             true,
+	"SCARD":            true,
+	"SDIFF":            true,
+	"SINTER":           true,
+	"SINTERCARD":       true,
+	"SISMEMBER":        true,
+	"SMEMBERS":         true,
+	"SMISMEMBER":       true,
+	"SRANDMEMBER":      true,
+	"SSCAN":            true,
+	"STRLEN":           true,
+	"SUNION":           true,
+	"TIME":             true,
+	"TTL":              true,
+	"TYPE":             true,
+	"XINFO":            true,
+	"XLEN":             true,
+	"XPENDING":         true,
+	"XRANGE":           true,
+	"XREAD":            true,
+	"XREVRANGE":        true,
+	"ZCARD":            true,
+	"ZCOUNT":           true,
+	"ZLEXCOUNT":        true,
+	"ZMSCORE":          true,
+	"ZRANDMEMBER":      true,
+	"ZRANGE":           true,
+	"ZRANGEBYLEX":      true,
+	"ZRANGEBYSCORE":    true,
+	"ZRANK":            true,
+	"ZREVRANGE":        true,
+	"ZREVRANGEBYLEX":   true,
+	"ZREVRANGEBYSCORE": true,
+	"ZREVRANK":         true,
+	"ZSCAN":            true,
+	"ZSCORE":           true,
+}
+
+// ReadOnlyCommand returns true if the command does not modify data. Commands
+// not known to be read-only, including MULTI, EXEC and EVAL, return false.
+func ReadOnlyCommand(commandName string) bool {
+	return readOnlyCommands[strings.ToUpper(commandName)]
+}
+
+// ErrAuditChain is returned by VerifyAuditChain when a record does not match
+// the hash chain.
+var ErrAuditChain = errors.New("redigo: audit record does not match hash chain")
+
+// AuditRecord is a record in an audit log.
+type AuditRecord struct {
+	// Seq is the position of the record in the log, starting at 1.
+	Seq int64 `json:"seq"`
+
+	Time time.Time `json:"time"`
+
+	// User identifies who issued the command.
+	User string `json:"user,omitempty"`
+
+	// Command is the command formatted as a line of text with credentials
+	// redacted.
+	Command string `json:"command"`
+
+	// Prev is the hash of the previous record or "" for the first record.
+	Prev string `json:"prev"`
+
+	// Hash is the hex encoded SHA-256 hash of the other fields.
+	Hash string `json:"hash"`
+}
+
+func (r *AuditRecord) hash() string {
+	h := sha256.New()
+	for _, s := range []string{
+		r.Prev,
+		strconv.FormatInt(r.Seq, 10),
+		r.Time.UTC().Format(time.RFC3339Nano),
+		r.User,
+		r.Command,
+	} {
+		io.WriteString(h, s)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// VerifyAuditChain returns ErrAuditChain if a record was altered, removed
+// or reordered. The records must be contiguous. The first record can be from
+// the middle of a log.
+func VerifyAuditChain(records []AuditRecord) error {
+	for i := range records {
+		r := &records[i]
+		if i > 0 && (r.Prev != records[i-1].Hash || r.Seq != records[i-1].Seq+1) {
+			return ErrAuditChain
+		}
+		if r.Hash != r.hash() {
+			return ErrAuditChain
+		}
+	}
+	return nil
+}
+
+// AuditLog appends every write command issued on connections dialed with the
+// DialAuditLog option to an audit trail. Each record includes the hash of the
+// previous record so that changes to the trail can be detected with
+// VerifyAuditChain.
+//
+// A command is recorded before the command is sent to the server. If the
+// record cannot be appended, then the command is not sent and the error is
+// returned to the application. A record written to Writer stays in the chain
+// when adding the record to Stream fails. Records from concurrent commands
+// can be added to Stream out of order; sort the records by Seq before calling
+// VerifyAuditChain. If Writer is nil, then records are added to Stream one at
+// a time and a record that cannot be added to Stream is not in the chain.
+type AuditLog struct {
+	// User identifies who issued the commands. If User is empty, then the
+	// username specified with DialUsername is recorded.
+	User string
+
+	// Writer, if not nil, receives each record as a line of JSON.
+	Writer io.Writer
+
+	// Pool and Stream, if set, specify a stream where each record is added
+	// with XADD. Connections dialed by the pool must not use DialAuditLog
+	// with the same log.
+	Pool   *Pool
+	Stream string
+
+	mu   sync.Mutex
+	seq  int64
+	prev string
+}
+
+// DialAuditLog specifies an audit log for write commands.
+func DialAuditLog(a *AuditLog) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.audit = a
+	}}
+}
+
+func (a *AuditLog) append(user, commandName string, args []interface{}) error {
+	if ReadOnlyCommand(commandName) {
+		return nil
+	}
+	if a.User != "" {
+		user = a.User
+	}
+
+	a.mu.Lock()
+	r := AuditRecord{
+		Seq:     a.seq + 1,
+		Time:    nowFunc(),
+		User:    user,
+		Command: FormatCommand(commandName, args),
+		Prev:    a.prev,
+	}
+	r.Hash = r.hash()
+	if a.Writer == nil {
+		// The stream is the only sink. Hold the lock during the round trip
+		// to the server so that the chain advances only after the record is
+		// added to the stream.
+		defer a.mu.Unlock()
+		if err := a.xadd(&r); err != nil {
+			return err
+		}
+		a.seq = r.Seq
+		a.prev = r.Hash
+		return nil
+	}
+
+	p, err := json.Marshal(&r)
+	if err == nil {
+		_, err = a.Writer.Write(append(p, '\n'))
+	}
+	if err != nil {
+		a.mu.Unlock()
+		return err
+	}
+	// Advance the chain before adding the record to the stream so that the
+	// lock is not held during the round trip to the server.
+	a.seq = r.Seq
+	a.prev = r.Hash
+	a.mu.Unlock()
+	return a.xadd(&r)
+}
+
+// xadd adds r to the stream if a stream is specified.
+func (a *AuditLog) xadd(r *AuditRecord) error {
+	if a.Pool == nil || a.Stream == "" {
+		return nil
+	}
+	c := a.Pool.Get()
+	defer c.Close()
+	_, err := c.Do("XADD", a.Stream, "*",
+		"seq", r.Seq,
+		"time", r.Time.UTC().Format(time.RFC3339Nano),
+		"user", r.User,
+		"command", r.Command,
+		"prev", r.Prev,
+		"hash", r.Hash)
+	return err
+}
+
+type auditConn struct {
+	connWrapper
+	a    *AuditLog
+	user string
+}
+
+func (c *auditConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if commandName != "" {
+		if err := c.a.append(c.user, commandName, args); err != nil {
+			return nil, err
+		}
+	}
+	return c.Conn.Do(commandName, args...)
+}
+
+func (c *auditConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	if commandName != "" {
+		if err := c.a.append(c.user, commandName, args); err != nil {
+			return nil, err
+		}
+	}
+	return DoWithTimeout(c.Conn, timeout, commandName, args...)
+}
+
+func (c *auditConn) Send(commandName string, args ...interface{}) error {
+	if err := c.a.append(c.user, commandName, args); err != nil {
+		return err
+	}
+	return c.Conn.Send(commandName, args...)
+}
+
+func (c *auditConn) doTo(w io.Writer, commandName string, args []interface{}) (int64, error) {
+	if commandName != "" {
+		if err := c.a.append(c.user, commandName, args); err != nil {
+			return 0, err
+		}
+	}
+	return DoTo(c.Conn, w, commandName, args...)
+}
+
+func (c *auditConn) rawConn() (*RawConn, error) {
+	return nil, errRawChecked
+}
@@ -0,0 +1,204 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDialAuditLog(t *testing.T) {
+	l := serveFake(t, func(args []string) string { return "+OK\r\n" })
+	defer l.Close()
+
+	var (
+		mu      sync.Mutex
+		streams []string
+	)
+	ls := serveFake(t, func(args []string) string {
+		mu.Lock()
+		streams = append(streams, strings.Join(args[:2], " "))
+		mu.Unlock()
+		return "$3\r\n1-0\r\n"
+	})
+	defer ls.Close()
+	p := &redis.Pool{Network: "tcp", Address: ls.Addr().String()}
+	defer p.Close()
+
+	var buf bytes.Buffer
+	a := &redis.AuditLog{Writer: &buf, Pool: p, Stream: "audit"}
+	c, err := redis.Dial("tcp", l.Addr().String(), redis.DialAuditLog(a), redis.DialUsername("alice"), redis.DialPassword("secret"))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+	c.Do("SET", "k", "v")
+	c.Do("GET", "k")
+	c.Send("DEL", "k")
+	c.Do("CONFIG", "SET", "requirepass", "x")
+
+	var records []redis.AuditRecord
+	s := bufio.NewScanner(&buf)
+	for s.Scan() {
+		var r redis.AuditRecord
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			t.Fatalf("Unmarshal returned %v", err)
+		}
+		if r.User != "alice" {
+			t.Errorf("record %d has user %q", r.Seq, r.User)
+		}
+		records = append(records, r)
+	}
+	var commands []string
+	for _, r := range records {
+		commands = append(commands, r.Command)
+	}
+	expected := []string{"SET k v", "DEL k", "CONFIG SET requirepass x"}
+	if !reflect.DeepEqual(commands, expected) {
+		t.Fatalf("recorded %q, want %q", commands, expected)
+	}
+	mu.Lock()
+	if len(streams) != 3 || streams[0] != "XADD audit" {
+		t.Errorf("stream received %q", streams)
+	}
+	mu.Unlock()
+
+	if err := redis.VerifyAuditChain(records); err != nil {
+		t.Errorf("VerifyAuditChain returned %v", err)
+	}
+	if err := redis.VerifyAuditChain(records[1:]); err != nil {
+		t.Errorf("VerifyAuditChain(records[1:]) returned %v", err)
+	}
+	if err := redis.VerifyAuditChain([]redis.AuditRecord{records[0], records[2]}); err != redis.ErrAuditChain {
+		t.Errorf("VerifyAuditChain with removed record returned %v", err)
+	}
+	records[1].Command = "DEL other"
+	if err := redis.VerifyAuditChain(records); err != redis.ErrAuditChain {
+		t.Errorf("VerifyAuditChain with altered record returned %v", err)
+	}
+}
+
+func TestAuditLogStreamError(t *testing.T) {
+	l := serveFake(t, func(args []string) string { return "+OK\r\n" })
+	defer l.Close()
+	ls := serveFake(t, func(args []string) string { return "-ERR stream unavailable\r\n" })
+	defer ls.Close()
+	p := &redis.Pool{Network: "tcp", Address: ls.Addr().String()}
+	defer p.Close()
+
+	var buf bytes.Buffer
+	a := &redis.AuditLog{Writer: &buf, Pool: p, Stream: "audit"}
+	c, err := redis.Dial("tcp", l.Addr().String(), redis.DialAuditLog(a))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := c.Do("SET", "k", i); err == nil {
+			t.Fatal("Do with failed XADD returned nil error")
+		}
+	}
+
+	var records []redis.AuditRecord
+	s := bufio.NewScanner(&buf)
+	for s.Scan() {
+		var r redis.AuditRecord
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			t.Fatalf("Unmarshal returned %v", err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 2 || records[1].Seq != 2 {
+		t.Fatalf("Writer received %+v, want records 1 and 2", records)
+	}
+	if err := redis.VerifyAuditChain(records); err != nil {
+		t.Errorf("VerifyAuditChain returned %v", err)
+	}
+}
+
+func TestAuditLogStreamOnlyError(t *testing.T) {
+	l := serveFake(t, func(args []string) string { return "+OK\r\n" })
+	defer l.Close()
+	var (
+		mu      sync.Mutex
+		records []redis.AuditRecord
+	)
+	ls := serveFake(t, func(args []string) string {
+		fields := make(map[string]string)
+		for i := 3; i+1 < len(args); i += 2 {
+			fields[args[i]] = args[i+1]
+		}
+		if strings.Contains(fields["command"], "fail") {
+			return "-ERR stream unavailable\r\n"
+		}
+		r := redis.AuditRecord{
+			User:    fields["user"],
+			Command: fields["command"],
+			Prev:    fields["prev"],
+			Hash:    fields["hash"],
+		}
+		r.Seq, _ = strconv.ParseInt(fields["seq"], 10, 64)
+		r.Time, _ = time.Parse(time.RFC3339Nano, fields["time"])
+		mu.Lock()
+		records = append(records, r)
+		mu.Unlock()
+		return "+1-1\r\n"
+	})
+	defer ls.Close()
+	p := &redis.Pool{Network: "tcp", Address: ls.Addr().String()}
+	defer p.Close()
+
+	a := &redis.AuditLog{Pool: p, Stream: "audit"}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c, err := redis.Dial("tcp", l.Addr().String(), redis.DialAuditLog(a))
+			if err != nil {
+				t.Errorf("Dial returned %v", err)
+				return
+			}
+			defer c.Close()
+			for j := 0; j < 10; j++ {
+				key := "ok"
+				if j%2 == i%2 {
+					key = "fail"
+				}
+				if _, err := c.Do("SET", key, j); (err != nil) != (key == "fail") {
+					t.Errorf("Do(SET %s) returned %v", key, err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	sort.Slice(records, func(i, j int) bool { return records[i].Seq < records[j].Seq })
+	if len(records) != 40 || records[0].Seq != 1 || records[0].Prev != "" {
+		t.Fatalf("stream received %d records starting at %+v, want 40 records starting at 1", len(records), records[0])
+	}
+	if err := redis.VerifyAuditChain(records); err != nil {
+		t.Errorf("VerifyAuditChain returned %v", err)
+	}
+}
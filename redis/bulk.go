@@ -0,0 +1,186 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"io"
+	"strconv"
+)
+
+// streamingConn is implemented by connections that copy bulk replies to a
+// writer without reading the reply into memory.
+type streamingConn interface {
+	doTo(w io.Writer, commandName string, args []interface{}) (int64, error)
+	receiveTo(w io.Writer) (int64, error)
+}
+
+// DoTo sends a command to the server and copies the reply to w as described
+// for ReceiveTo. Replies to pipelined commands are read and discarded as
+// described for the connection Do method.
+//
+//  f, err := os.Create("backup.rdb")
+//  ...
+//  n, err := redis.DoTo(c, f, "DUMP", "large-key")
+func DoTo(c Conn, w io.Writer, commandName string, args ...interface{}) (int64, error) {
+	if sc, ok := c.(streamingConn); ok {
+		return sc.doTo(w, commandName, args)
+	}
+	reply, err := c.Do(commandName, args...)
+	return writeReply(w, reply, err)
+}
+
+// ReceiveTo receives a single reply from the server and copies the reply to
+// w. A bulk string reply is copied to w without reading the entire reply into
+// memory. Use ReceiveTo to read large values returned by commands such as GET
+// and DUMP. Status replies are also copied to w.
+//
+// ReceiveTo returns the number of bytes copied. If the reply is nil, then
+// ReceiveTo returns ErrNil. If the reply is an error reply, then ReceiveTo
+// returns the error. Other reply types are read and discarded and an error is
+// returned.
+//
+// The connections created by Dial, DialTimeout, NewConn and Pool, including
+// the connections wrapped by dial options and pool options, stream the reply.
+// Other connections read the reply into memory before copying the reply to w.
+// The read timeout of the connection applies to the entire reply.
+func ReceiveTo(c Conn, w io.Writer) (int64, error) {
+	if sc, ok := c.(streamingConn); ok {
+		return sc.receiveTo(w)
+	}
+	reply, err := c.Receive()
+	return writeReply(w, reply, err)
+}
+
+// writeReply writes a reply read into memory to w.
+func writeReply(w io.Writer, reply interface{}, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	switch reply := reply.(type) {
+	case []byte:
+		n, err = w.Write(reply)
+	case string:
+		n, err = io.WriteString(w, reply)
+	case nil:
+		return 0, ErrNil
+	default:
+		return 0, errUnexpectedStreamReply
+	}
+	return int64(n), err
+}
+
+var errUnexpectedStreamReply = errors.New("redigo: ReceiveTo expects bulk string or status reply")
+
+func (c *conn) doTo(w io.Writer, commandName string, args []interface{}) (int64, error) {
+	if err := c.Send(commandName, args...); err != nil {
+		return 0, err
+	}
+	if err := c.Flush(); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = 1
+	c.mu.Unlock()
+
+	c.setReadDeadline(c.readTimeout)
+	var err error
+	for i := 1; i < pending; i++ {
+		reply, e := c.readReply()
+		if e != nil {
+			return 0, c.fatal(e)
+		}
+		if e, ok := reply.(Error); ok && err == nil {
+			err = e
+		}
+	}
+	n, e := c.receiveTo(w)
+	if e != nil {
+		err = e
+	}
+	return n, err
+}
+
+func (c *conn) receiveTo(w io.Writer) (int64, error) {
+	if c.raw {
+		return 0, errRawConn
+	}
+	c.mu.Lock()
+	if c.pending > 0 {
+		c.pending -= 1
+	}
+	c.mu.Unlock()
+	c.setReadDeadline(c.readTimeout)
+
+	line, err := c.readLine()
+	if err != nil {
+		return 0, c.fatal(err)
+	}
+	if len(line) == 0 {
+		return 0, c.fatal(errors.New("redigo: short response line"))
+	}
+	switch line[0] {
+	case '$':
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return 0, c.fatal(errors.New("redigo: bad bulk length"))
+		}
+		if size < 0 {
+			return 0, ErrNil
+		}
+		// The protocol state is not known after an error because the reply
+		// is partially read.
+		n, err := io.CopyN(w, c.br, int64(size))
+		if err != nil {
+			return n, c.fatal(err)
+		}
+		line, err := c.readLine()
+		if err != nil {
+			return n, c.fatal(err)
+		}
+		if len(line) != 0 {
+			return n, c.fatal(errors.New("redigo: bad bulk format"))
+		}
+		return n, nil
+	case '+':
+		n, err := w.Write(line[1:])
+		return int64(n), err
+	case '-':
+		return 0, Error(string(line[1:]))
+	}
+	// Read the other reply types as Receive does to keep the connection
+	// usable.
+	if _, err := c.readReplyLine(line); err != nil {
+		return 0, c.fatal(err)
+	}
+	return 0, errUnexpectedStreamReply
+}
+
+func (c *pooledConnection) doTo(w io.Writer, commandName string, args []interface{}) (int64, error) {
+	if err := c.get(); err != nil {
+		return 0, err
+	}
+	return DoTo(c.c, w, commandName, args...)
+}
+
+func (c *pooledConnection) receiveTo(w io.Writer) (int64, error) {
+	if err := c.get(); err != nil {
+		return 0, err
+	}
+	return ReceiveTo(c.c, w)
+}
@@ -0,0 +1,157 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDoTo(t *testing.T) {
+	large := strings.Repeat("x", 1<<20)
+	l := serveFake(t, func(args []string) string {
+		switch args[0] {
+		case "GET":
+			if args[1] == "missing" {
+				return "$-1\r\n"
+			}
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(large), large)
+		case "LRANGE":
+			return "*2\r\n$1\r\na\r\n$1\r\nb\r\n"
+		case "FAIL":
+			return "-ERR failed\r\n"
+		case "BIG":
+			return "(3492890328409238509324850943850943825024385\r\n"
+		case "VERBATIM":
+			return "=8\r\ntxt:text\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer l.Close()
+
+	p := &redis.Pool{Network: "tcp", Address: l.Addr().String()}
+	defer p.Close()
+	c := p.Get()
+	defer c.Close()
+
+	var buf bytes.Buffer
+	c.Send("SET", "k", "v")
+	if n, err := redis.DoTo(c, &buf, "GET", "k"); err != nil || n != int64(len(large)) || buf.String() != large {
+		t.Errorf("DoTo(GET) returned %d, %v", n, err)
+	}
+	buf.Reset()
+	if n, err := redis.DoTo(c, &buf, "PING"); err != nil || buf.String() != "OK" {
+		t.Errorf("DoTo(PING) returned %d, %v, %q", n, err, buf.String())
+	}
+	if _, err := redis.DoTo(c, &buf, "GET", "missing"); err != redis.ErrNil {
+		t.Errorf("DoTo(GET missing) returned %v, want ErrNil", err)
+	}
+	if _, err := redis.DoTo(c, &buf, "FAIL"); err == nil || err.Error() != "ERR failed" {
+		t.Errorf("DoTo(FAIL) returned %v", err)
+	}
+	if _, err := redis.DoTo(c, &buf, "LRANGE", "l", 0, -1); err == nil {
+		t.Errorf("DoTo(LRANGE) did not return error")
+	}
+	for _, cmd := range []string{"BIG", "VERBATIM"} {
+		if _, err := redis.DoTo(c, &buf, cmd); err == nil {
+			t.Errorf("DoTo(%s) did not return error", cmd)
+		}
+		if c.Err() != nil {
+			t.Fatalf("connection broken by DoTo(%s): %v", cmd, c.Err())
+		}
+	}
+
+	buf.Reset()
+	c.Send("GET", "k")
+	c.Send("PING")
+	c.Flush()
+	if n, err := redis.ReceiveTo(c, &buf); err != nil || n != int64(len(large)) {
+		t.Errorf("ReceiveTo returned %d, %v", n, err)
+	}
+	if s, err := redis.String(c.Receive()); err != nil || s != "OK" {
+		t.Errorf("Receive after ReceiveTo returned %q, %v", s, err)
+	}
+	if c.Err() != nil {
+		t.Errorf("connection broken: %v", c.Err())
+	}
+}
+
+// countingWriter counts the calls to Write.
+type countingWriter struct {
+	buf    bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.buf.Write(p)
+}
+
+func TestDoToWrapped(t *testing.T) {
+	large := strings.Repeat("x", 1<<20)
+	l := serveFake(t, func(args []string) string {
+		if args[0] == "GET" {
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(large), large)
+		}
+		return "+OK\r\n"
+	})
+	defer l.Close()
+
+	h := &recordingHook{}
+	p := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", l.Addr().String(),
+				redis.DialRetryLoading(time.Second),
+				redis.DialOOMHandler(&redis.OOMHandler{}),
+				redis.DialProxyCompat(),
+				redis.DialStrictRESP2(),
+				redis.DialRejectWrites())
+		},
+		Hook:    h,
+		Limiter: &redis.AdaptiveLimiter{InitialLimit: 1, MaxLimit: 1},
+		Guard:   &redis.Guard{},
+	}
+	defer p.Close()
+	c := p.Get()
+	defer c.Close()
+
+	var w countingWriter
+	if n, err := redis.DoTo(c, &w, "GET", "k"); err != nil || n != int64(len(large)) || w.buf.String() != large {
+		t.Fatalf("DoTo(GET) returned %d, %v", n, err)
+	}
+	if w.writes < 2 {
+		t.Errorf("DoTo(GET) wrote the reply in %d call, want streamed reply", w.writes)
+	}
+
+	w = countingWriter{}
+	c.Send("GET", "k")
+	c.Flush()
+	if n, err := redis.ReceiveTo(c, &w); err != nil || n != int64(len(large)) {
+		t.Fatalf("ReceiveTo returned %d, %v", n, err)
+	}
+	if w.writes < 2 {
+		t.Errorf("ReceiveTo wrote the reply in %d call, want streamed reply", w.writes)
+	}
+
+	want := []string{"send GET [k]", "reply GET <nil>", "send GET [k]", "reply GET <nil>"}
+	if !reflect.DeepEqual(h.events, want) {
+		t.Errorf("hook events = %q, want %q", h.events, want)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// ErrChecksum is returned when a value does not match the checksum trailer
+// appended to the value by AppendChecksum. The error indicates that the
+// value was truncated or corrupted between the application and the server.
+var ErrChecksum = errors.New("redigo: checksum mismatch")
+
+var checksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// checksumLen is the length of the trailer appended by AppendChecksum.
+const checksumLen = 4
+
+// AppendChecksum returns p with a trailer containing the CRC-32C checksum of
+// p. Use AppendChecksum to store large values through proxies or other
+// middleboxes that are not trusted to pass the values unmodified. Use
+// VerifyChecksum or CheckedBytes to verify and remove the trailer.
+func AppendChecksum(p []byte) []byte {
+	return binary.BigEndian.AppendUint32(p, crc32.Checksum(p, checksumTable))
+}
+
+// VerifyChecksum verifies the trailer appended to p by AppendChecksum and
+// returns p without the trailer. VerifyChecksum returns ErrChecksum if p is
+// too short or the checksum does not match.
+func VerifyChecksum(p []byte) ([]byte, error) {
+	if len(p) < checksumLen {
+		return nil, ErrChecksum
+	}
+	n := len(p) - checksumLen
+	if binary.BigEndian.Uint32(p[n:]) != crc32.Checksum(p[:n], checksumTable) {
+		return nil, ErrChecksum
+	}
+	return p[:n], nil
+}
+
+// CheckedBytes is a helper that converts a bulk reply containing a value
+// stored with AppendChecksum to a []byte without the trailer. If err is not
+// equal to nil, then CheckedBytes returns nil, err. CheckedBytes returns
+// ErrChecksum if the checksum does not match.
+func CheckedBytes(reply interface{}, err error) ([]byte, error) {
+	p, err := Bytes(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	return VerifyChecksum(p)
+}
@@ -0,0 +1,72 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	p := redis.AppendChecksum([]byte("hello"))
+	if len(p) != len("hello")+4 {
+		t.Fatalf("AppendChecksum returned %d bytes", len(p))
+	}
+	if v, err := redis.CheckedBytes(p, nil); err != nil || string(v) != "hello" {
+		t.Errorf("CheckedBytes returned %q, %v", v, err)
+	}
+	for _, bad := range [][]byte{p[:len(p)-1], p[1:], []byte("abc")} {
+		if _, err := redis.VerifyChecksum(bad); err != redis.ErrChecksum {
+			t.Errorf("VerifyChecksum(%q) returned %v, want %v", bad, err, redis.ErrChecksum)
+		}
+	}
+}
+
+type checkedStruct struct {
+	Body  string `redis:"body,checksum"`
+	Count int    `redis:"count,checksum"`
+	Tags  []int  `redis:"tags,json,checksum"`
+}
+
+func TestScanStructChecksum(t *testing.T) {
+	v := checkedStruct{Body: "hello", Count: 3, Tags: []int{1}}
+	args, err := redis.AppendStruct(nil, &v)
+	if err != nil {
+		t.Fatalf("AppendStruct returned error %v", err)
+	}
+	expected := []interface{}{
+		"body", redis.AppendChecksum([]byte("hello")),
+		"count", redis.AppendChecksum([]byte("3")),
+		"tags", redis.AppendChecksum([]byte("[1]")),
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("AppendStruct returned %q, want %q", args, expected)
+	}
+
+	var got checkedStruct
+	if err := redis.ScanStruct(args, &got); err != nil {
+		t.Fatalf("ScanStruct returned error %v", err)
+	}
+	if !reflect.DeepEqual(got, v) {
+		t.Errorf("ScanStruct returned %+v, want %+v", got, v)
+	}
+
+	body := args[1].([]byte)
+	args[1] = body[:len(body)-2]
+	if err := redis.ScanStruct(args, &got); err != redis.ErrChecksum {
+		t.Errorf("ScanStruct of truncated value returned %v, want %v", err, redis.ErrChecksum)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// ClusterNode is a node in the reply from CLUSTER SLOTS or CLUSTER SHARDS.
+type ClusterNode struct {
+	ID       string
+	IP       string
+	Port     int
+	Hostname string
+
+	// The following fields are set by ClusterShards only.
+	Endpoint          string
+	TLSPort           int
+	Role              string
+	ReplicationOffset int64
+	Health            string
+}
+
+// Addr returns the host and port of the node.
+func (n ClusterNode) Addr() string {
+	return net.JoinHostPort(n.IP, strconv.Itoa(n.Port))
+}
+
+// SlotRange is an inclusive range of hash slots.
+type SlotRange struct {
+	Start, End int
+}
+
+// ClusterSlot is an entry in the reply from CLUSTER SLOTS.
+type ClusterSlot struct {
+	SlotRange
+
+	// Nodes serving the slots. The first node is the master.
+	Nodes []ClusterNode
+}
+
+// ClusterShard is an entry in the reply from CLUSTER SHARDS.
+type ClusterShard struct {
+	Slots []SlotRange
+	Nodes []ClusterNode
+}
+
+// ClusterSlots is a helper that converts the reply from CLUSTER SLOTS to a
+// slice of ClusterSlot. If err is not equal to nil, then ClusterSlots returns
+// nil, err.
+func ClusterSlots(reply interface{}, err error) ([]ClusterSlot, error) {
+	var result []ClusterSlot
+	err = sliceHelper(reply, err, "ClusterSlots", func(n int) { result = make([]ClusterSlot, n) }, func(i int, v interface{}) error {
+		p, ok := v.([]interface{})
+		if !ok || len(p) < 3 {
+			return fmt.Errorf("redigo: unexpected cluster slot, got type %T", v)
+		}
+		s := &result[i]
+		var err error
+		if s.Start, err = Int(p[0], nil); err != nil {
+			return err
+		}
+		if s.End, err = Int(p[1], nil); err != nil {
+			return err
+		}
+		s.Nodes = make([]ClusterNode, len(p)-2)
+		for j, v := range p[2:] {
+			n, ok := v.([]interface{})
+			if !ok || len(n) < 2 {
+				return fmt.Errorf("redigo: unexpected cluster slot node, got type %T", v)
+			}
+			node := &s.Nodes[j]
+			if node.IP, err = String(n[0], nil); err != nil {
+				return err
+			}
+			if node.Port, err = Int(n[1], nil); err != nil {
+				return err
+			}
+			if len(n) > 2 {
+				if node.ID, err = String(n[2], nil); err != nil {
+					return err
+				}
+			}
+			if len(n) > 3 {
+				// Redis 7 adds a map of additional networking metadata.
+				metadata, err := StringMap(n[3], nil)
+				if err != nil {
+					return err
+				}
+				node.Hostname = metadata["hostname"]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ClusterShards is a helper that converts the reply from CLUSTER SHARDS to a
+// slice of ClusterShard. If err is not equal to nil, then ClusterShards
+// returns nil, err.
+func ClusterShards(reply interface{}, err error) ([]ClusterShard, error) {
+	var result []ClusterShard
+	err = sliceHelper(reply, err, "ClusterShards", func(n int) { result = make([]ClusterShard, n) }, func(i int, v interface{}) error {
+		p, err := Values(v, nil)
+		if err != nil {
+			return err
+		}
+		if len(p)%2 != 0 {
+			return errors.New("redigo: ClusterShards expects even number of values in shard")
+		}
+		shard := &result[i]
+		for j := 0; j < len(p); j += 2 {
+			name, err := String(p[j], nil)
+			if err != nil {
+				return err
+			}
+			switch name {
+			case "slots":
+				slots, err := Ints(p[j+1], nil)
+				if err != nil {
+					return err
+				}
+				for k := 0; k+1 < len(slots); k += 2 {
+					shard.Slots = append(shard.Slots, SlotRange{slots[k], slots[k+1]})
+				}
+			case "nodes":
+				nodes, err := Values(p[j+1], nil)
+				if err != nil {
+					return err
+				}
+				shard.Nodes = make([]ClusterNode, len(nodes))
+				for k, n := range nodes {
+					if err := shard.Nodes[k].scanShardNode(n); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (n *ClusterNode) scanShardNode(v interface{}) error {
+	p, err := Values(v, nil)
+	if err != nil {
+		return err
+	}
+	if len(p)%2 != 0 {
+		return errors.New("redigo: ClusterShards expects even number of values in node")
+	}
+	for i := 0; i < len(p); i += 2 {
+		name, err := String(p[i], nil)
+		if err != nil {
+			return err
+		}
+		switch name {
+		case "id":
+			n.ID, err = String(p[i+1], nil)
+		case "ip":
+			n.IP, err = String(p[i+1], nil)
+		case "port":
+			n.Port, err = Int(p[i+1], nil)
+		case "tls-port":
+			n.TLSPort, err = Int(p[i+1], nil)
+		case "hostname":
+			n.Hostname, err = String(p[i+1], nil)
+		case "endpoint":
+			n.Endpoint, err = String(p[i+1], nil)
+		case "role":
+			n.Role, err = String(p[i+1], nil)
+		case "replication-offset":
+			n.ReplicationOffset, err = Int64(p[i+1], nil)
+		case "health":
+			n.Health, err = String(p[i+1], nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"testing"
+)
+
+func TestClusterSlots(t *testing.T) {
+	reply := []interface{}{
+		[]interface{}{int64(0), int64(5460),
+			[]interface{}{[]byte("127.0.0.1"), int64(30001), []byte("a1")},
+			[]interface{}{[]byte("127.0.0.1"), int64(30004), []byte("a2"), []interface{}{[]byte("hostname"), []byte("r1.example.com")}},
+		},
+	}
+	slots, err := redis.ClusterSlots(reply, nil)
+	if err != nil {
+		t.Fatalf("ClusterSlots returned error %v", err)
+	}
+	expected := []redis.ClusterSlot{{
+		SlotRange: redis.SlotRange{Start: 0, End: 5460},
+		Nodes: []redis.ClusterNode{
+			{ID: "a1", IP: "127.0.0.1", Port: 30001},
+			{ID: "a2", IP: "127.0.0.1", Port: 30004, Hostname: "r1.example.com"},
+		},
+	}}
+	if !reflect.DeepEqual(slots, expected) {
+		t.Fatalf("ClusterSlots returned %+v, want %+v", slots, expected)
+	}
+	if addr := slots[0].Nodes[0].Addr(); addr != "127.0.0.1:30001" {
+		t.Errorf("Addr() = %q", addr)
+	}
+}
+
+func TestClusterShards(t *testing.T) {
+	reply := []interface{}{
+		[]interface{}{
+			[]byte("slots"), []interface{}{int64(0), int64(10), int64(20), int64(5460)},
+			[]byte("nodes"), []interface{}{
+				[]interface{}{
+					[]byte("id"), []byte("a1"), []byte("port"), int64(30001), []byte("ip"), []byte("127.0.0.1"),
+					[]byte("endpoint"), []byte("127.0.0.1"), []byte("role"), []byte("master"),
+					[]byte("replication-offset"), int64(72156), []byte("health"), []byte("online"),
+				},
+			},
+		},
+	}
+	shards, err := redis.ClusterShards(reply, nil)
+	if err != nil {
+		t.Fatalf("ClusterShards returned error %v", err)
+	}
+	expected := []redis.ClusterShard{{
+		Slots: []redis.SlotRange{{0, 10}, {20, 5460}},
+		Nodes: []redis.ClusterNode{{ID: "a1", IP: "127.0.0.1", Port: 30001, Endpoint: "127.0.0.1", Role: "master", ReplicationOffset: 72156, Health: "online"}},
+	}}
+	if !reflect.DeepEqual(shards, expected) {
+		t.Fatalf("ClusterShards returned %+v, want %+v", shards, expected)
+	}
+}
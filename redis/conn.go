@@ -17,11 +17,15 @@ package redis
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -43,34 +47,453 @@ type conn struct {
 	mu      sync.Mutex
 	pending int
 	err     error
+
+	// Expiration time of the credentials used to authenticate the connection.
+	expiration time.Time
+
+	// Deadline of the context passed to the current ConnV2 method call.
+	ctxDeadline time.Time
+
+	// raw is true when the connection is in use by a RawConn.
+	raw bool
+
+	// readDeadline is true if a read deadline is set on the net connection.
+	readDeadline bool
+
+	// replyOff and replySkip track the CLIENT REPLY mode of the connection.
+	replyOff  bool
+	replySkip bool
+}
+
+// DialOption specifies an option for dialing a Redis server.
+type DialOption struct {
+	f func(*dialOptions)
+}
+
+type dialOptions struct {
+	token       TokenProvider
+	username    string
+	password    string
+	proxy       bool
+	strictRESP2 bool
+	readOnly    bool
+	replica     bool
+	noEvict     bool
+	noTouch     bool
+	loadingWait time.Duration
+	oom         *OOMHandler
+	audit       *AuditLog
+	hook        Hook
+	useTLS      bool
+	tlsConfig   *tls.Config
+	skipVerify  bool
+
+	connectTimeout time.Duration
+	keepAlive      time.Duration
+	readTimeout    time.Duration
+	writeTimeout   time.Duration
+	readBufSize    int
+	writeBufSize   int
+	db             int
+	clientName     string
+}
+
+// Credentials are used to authenticate a connection.
+type Credentials struct {
+	// If Username is "", then the connection is authenticated with the
+	// password only.
+	Username string
+	Password string
+
+	// Expiration is the time when the credentials lapse or the zero time if
+	// the credentials do not expire. A pool closes a connection instead of
+	// returning it to the application after the connection's credentials
+	// expire. Providers of short-lived tokens should set Expiration before
+	// the server's deadline to allow time for commands in flight.
+	Expiration time.Time
+}
+
+// TokenProvider returns the credentials used to authenticate a new
+// connection. TokenProviders are used for short-lived tokens issued by cloud
+// identity services.
+type TokenProvider func(ctx context.Context) (Credentials, error)
+
+// CredentialsProvider returns the username and password used to authenticate
+// a new connection. If username is "", then the connection is authenticated
+// with the password only.
+type CredentialsProvider func(ctx context.Context) (username, password string, err error)
+
+// DialTokenProvider specifies a function that returns the credentials used to
+// authenticate the connection. The function is called each time a connection
+// is dialed. Use CachedTokenProvider to avoid fetching a token for every
+// connection.
+func DialTokenProvider(p TokenProvider) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.token = p
+	}}
+}
+
+// DialCredentialsProvider specifies a function that returns the credentials
+// used to authenticate the connection. The function is called each time a
+// connection is dialed, so credentials rotated by a secrets manager take
+// effect for new connections. Use the pool Recycle method to replace pooled
+// connections after a rotation.
+func DialCredentialsProvider(p CredentialsProvider) DialOption {
+	return DialTokenProvider(func(ctx context.Context) (Credentials, error) {
+		username, password, err := p(ctx)
+		return Credentials{Username: username, Password: password}, err
+	})
+}
+
+// DialPassword specifies the password to use when connecting to the Redis
+// server.
+func DialPassword(password string) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.password = password
+	}}
+}
+
+// DialUsername specifies the ACL username to use with the password specified
+// by DialPassword. The connection authenticates with the two argument form of
+// AUTH added in Redis 6. If the server does not support the two argument
+// form, then the connection authenticates with the password only.
+func DialUsername(username string) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.username = username
+	}}
+}
+
+// DialClientNoEvict specifies that the connection is excluded from client
+// eviction using the CLIENT NO-EVICT command. Use the option for critical
+// control connections. The option requires Redis 7.
+func DialClientNoEvict() DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.noEvict = true
+	}}
+}
+
+// DialClientNoTouch specifies that commands on the connection do not alter
+// the LRU or LFU information of keys using the CLIENT NO-TOUCH command. Use
+// the option for monitoring connections. The option requires Redis 7.2.
+func DialClientNoTouch() DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.noTouch = true
+	}}
+}
+
+// DialReplicaReads specifies that the connection sends the READONLY command
+// after connecting. A cluster replica redirects commands to the primary of
+// the hash slot unless the connection is in read-only mode. Use the option
+// to read from cluster replicas.
+func DialReplicaReads() DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.replica = true
+	}}
+}
+
+// DialUseTLS specifies whether TLS should be used when connecting to the
+// server.
+func DialUseTLS(useTLS bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.useTLS = useTLS
+	}}
+}
+
+// DialTLSConfig specifies the config to use when a TLS connection is dialed.
+// The option has no effect unless DialUseTLS(true) is also specified. If the
+// config does not specify a server name, then the host from the dialed
+// address is used.
+func DialTLSConfig(c *tls.Config) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.tlsConfig = c
+	}}
+}
+
+// DialTLSSkipVerify disables server name verification when connecting over
+// TLS. The option has no effect unless DialUseTLS(true) is also specified.
+func DialTLSSkipVerify(skip bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.skipVerify = skip
+	}}
+}
+
+// DialConnectTimeout specifies the timeout for connecting to the server,
+// including the TLS handshake.
+func DialConnectTimeout(d time.Duration) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.connectTimeout = d
+	}}
+}
+
+// DialKeepAlive specifies the interval between TCP keep-alive probes. If
+// zero, probes are sent with the default interval of the net package. If
+// negative, keep-alive probes are disabled.
+func DialKeepAlive(d time.Duration) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.keepAlive = d
+	}}
+}
+
+// DialReadTimeout specifies the timeout for reading a single command reply.
+func DialReadTimeout(d time.Duration) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.readTimeout = d
+	}}
+}
+
+// DialWriteTimeout specifies the timeout for writing a single command.
+func DialWriteTimeout(d time.Duration) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.writeTimeout = d
+	}}
+}
+
+// DialReadBufferSize specifies the size of the buffer used to read replies
+// from the server. Lines longer than the buffer are read into an allocated
+// buffer. If zero, the default size of the bufio package is used.
+func DialReadBufferSize(size int) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.readBufSize = size
+	}}
+}
+
+// DialWriteBufferSize specifies the size of the buffer used to write commands
+// to the server. A larger buffer reduces the number of writes to the network
+// for large pipelines. If zero, the default size of the bufio package is used.
+func DialWriteBufferSize(size int) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.writeBufSize = size
+	}}
+}
+
+// DialDatabase specifies the database to select when dialing a connection.
+// The SELECT command is sent after authentication. A pool applies the option
+// to every connection dialed by the pool when the option is included in the
+// pool's DialOptions.
+func DialDatabase(db int) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.db = db
+	}}
+}
+
+// DialClientName specifies a client name to set with the CLIENT SETNAME
+// command when dialing a connection. The name identifies the application in
+// the output of CLIENT LIST and in slow log entries. The command is sent after
+// authentication. A pool applies the option to every connection dialed by the
+// pool when the option is included in the pool's DialOptions.
+func DialClientName(name string) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.clientName = name
+	}}
+}
+
+// CachedTokenProvider returns a provider that calls p for new credentials
+// when there are no cached credentials or when the cached credentials expire
+// within the refresh duration. Credentials without an expiration are cached
+// forever.
+func CachedTokenProvider(p TokenProvider, refresh time.Duration) TokenProvider {
+	var (
+		mu     sync.Mutex
+		cached *Credentials
+	)
+	return func(ctx context.Context) (Credentials, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if cached != nil && (cached.Expiration.IsZero() || nowFunc().Add(refresh).Before(cached.Expiration)) {
+			return *cached, nil
+		}
+		cred, err := p(ctx)
+		if err != nil {
+			return cred, err
+		}
+		cached = &cred
+		return cred, nil
+	}
 }
 
-// Dial connects to the Redis server at the given network and address.
-func Dial(network, address string) (Conn, error) {
-	c, err := net.Dial(network, address)
+// Dial connects to the Redis server at the given network and address using
+// the specified options.
+func Dial(network, address string, options ...DialOption) (Conn, error) {
+	return DialContext(context.Background(), network, address, options...)
+}
+
+// DialContext is like Dial, but the context bounds the time spent connecting
+// to the server and setting up the connection. The context does not apply to
+// the returned connection. Use DoContext and ReceiveContext to apply a
+// context to commands.
+func DialContext(ctx context.Context, network, address string, options ...DialOption) (Conn, error) {
+	do := dialOptions{}
+	for _, option := range options {
+		option.f(&do)
+	}
+	dialCtx := ctx
+	if do.connectTimeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, do.connectTimeout)
+		defer cancel()
+	}
+	d := net.Dialer{KeepAlive: do.keepAlive}
+	netConn, err := d.DialContext(dialCtx, network, address)
 	if err != nil {
 		return nil, errors.New("Could not connect to Redis server: " + err.Error())
 	}
-	return NewConn(c, 0, 0), nil
+	if do.useTLS {
+		tlsConn := tls.Client(netConn, do.tlsClientConfig(address))
+		if err := tlsConn.HandshakeContext(dialCtx); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+		netConn = tlsConn
+	}
+	c := NewConn(netConn, do.readTimeout, do.writeTimeout).(*conn)
+	if do.readBufSize > 0 {
+		c.br = bufio.NewReaderSize(netConn, do.readBufSize)
+	}
+	if do.writeBufSize > 0 {
+		c.bw = bufio.NewWriterSize(netConn, do.writeBufSize)
+	}
+	err = c.withContext(ctx, func() error {
+		return do.setup(ctx, c)
+	})
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	var result Conn = c
+	if do.loadingWait > 0 {
+		result = &loadingConn{connWrapper: connWrapper{result}, maxWait: do.loadingWait}
+	}
+	if do.audit != nil {
+		result = &auditConn{connWrapper: connWrapper{result}, a: do.audit, user: do.username}
+	}
+	if do.oom != nil {
+		result = &oomConn{connWrapper: connWrapper{result}, h: do.oom}
+	}
+	if do.proxy {
+		result = NewProxyConn(result)
+	}
+	if do.strictRESP2 {
+		result = &resp2Conn{connWrapper{result}}
+	}
+	if do.readOnly {
+		result = NewReadOnlyConn(result)
+	}
+	if do.hook != nil {
+		result = NewHookConn(result, do.hook)
+	}
+	return result, nil
 }
 
-// DialTimeout acts like Dial but takes timeouts for establishing the
-// connection to the server, writing a command and reading a reply.
-func DialTimeout(network, address string, connectTimeout, readTimeout, writeTimeout time.Duration) (Conn, error) {
-	var c net.Conn
-	var err error
-	if connectTimeout > 0 {
-		c, err = net.DialTimeout(network, address, connectTimeout)
+// tlsClientConfig returns the TLS config for a connection to address.
+func (do *dialOptions) tlsClientConfig(address string) *tls.Config {
+	var config *tls.Config
+	if do.tlsConfig == nil {
+		config = &tls.Config{}
 	} else {
-		c, err = net.Dial(network, address)
+		config = do.tlsConfig.Clone()
 	}
-	if err != nil {
-		return nil, errors.New("Could not connect to Redis server: " + err.Error())
+	if do.skipVerify {
+		config.InsecureSkipVerify = true
 	}
-	return NewConn(c, readTimeout, writeTimeout), nil
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		config.ServerName = host
+	}
+	return config
 }
 
-// NewConn returns a new Redigo connection for the given net connection.
+// setup prepares a newly dialed connection for use.
+func (do *dialOptions) setup(ctx context.Context, c *conn) error {
+	token := do.token
+	if token == nil && do.password != "" {
+		token = func(ctx context.Context) (Credentials, error) {
+			return Credentials{Username: do.username, Password: do.password}, nil
+		}
+	}
+	if token != nil {
+		cred, err := token(ctx)
+		if err != nil {
+			return err
+		}
+		if cred.Username != "" {
+			_, err = c.Do("AUTH", cred.Username, cred.Password)
+			if e, ok := err.(Error); ok && strings.HasPrefix(string(e), "ERR wrong number of arguments") {
+				// The server is older than Redis 6.
+				_, err = c.Do("AUTH", cred.Password)
+			}
+		} else if cred.Password != "" {
+			_, err = c.Do("AUTH", cred.Password)
+		}
+		if err != nil {
+			return err
+		}
+		c.expiration = cred.Expiration
+	}
+	if do.clientName != "" {
+		if _, err := c.Do("CLIENT", "SETNAME", do.clientName); err != nil {
+			return err
+		}
+	}
+	if do.db != 0 {
+		if _, err := c.Do("SELECT", do.db); err != nil {
+			return err
+		}
+	}
+	if do.replica {
+		if _, err := c.Do("READONLY"); err != nil {
+			return err
+		}
+	}
+	for _, cmd := range []struct {
+		enabled bool
+		args    []interface{}
+	}{
+		{do.noEvict, []interface{}{"NO-EVICT", "on"}},
+		{do.noTouch, []interface{}{"NO-TOUCH", "on"}},
+	} {
+		if !cmd.enabled {
+			continue
+		}
+		if do.strictRESP2 {
+			if err := resp2Check("CLIENT", cmd.args); err != nil {
+				return err
+			}
+		}
+		if _, err := c.Do("CLIENT", cmd.args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expiringConn is implemented by connections with credentials that expire.
+type expiringConn interface {
+	expired(now time.Time) bool
+}
+
+func (c *conn) expired(now time.Time) bool {
+	return !c.expiration.IsZero() && !now.Before(c.expiration)
+}
+
+// DialTimeout acts like Dial but takes timeouts for establishing the
+// connection to the server, writing a command and reading a reply.
+func DialTimeout(network, address string, connectTimeout, readTimeout, writeTimeout time.Duration) (Conn, error) {
+	return Dial(network, address,
+		DialConnectTimeout(connectTimeout),
+		DialReadTimeout(readTimeout),
+		DialWriteTimeout(writeTimeout))
+}
+
+// NewConn returns a new Redigo connection for the given net connection. Use
+// NewConn to run the protocol over connections established by other means
+// such as SSH tunnels, custom proxies and in-memory pipes created with
+// net.Pipe. The connection takes ownership of netConn and closes netConn when
+// the connection is closed. A read or write timeout of zero disables the
+// timeout. NewConn does not send any commands to the server. Authenticate and
+// select the database with the AUTH and SELECT commands as needed.
 func NewConn(netConn net.Conn, readTimeout, writeTimeout time.Duration) Conn {
 	return &conn{
 		conn:         netConn,
@@ -103,6 +526,9 @@ func (c *conn) fatal(err error) error {
 func (c *conn) Err() error {
 	c.mu.Lock()
 	err := c.err
+	if err == nil && c.raw {
+		err = errRawConn
+	}
 	c.mu.Unlock()
 	return err
 }
@@ -129,6 +555,24 @@ func (c *conn) writeBytes(p []byte) error {
 	return err
 }
 
+// writeReader writes n bytes from r as a bulk string. The bytes are streamed
+// through the write buffer instead of being read into memory first.
+func (c *conn) writeReader(r io.Reader, n int64) error {
+	if n < 0 {
+		return fmt.Errorf("redigo: reader argument has negative length %d", n)
+	}
+	c.writeN('$', int(n))
+	m, err := io.CopyN(c.bw, r, n)
+	if err == io.EOF {
+		err = fmt.Errorf("redigo: reader argument returned %d of %d bytes", m, n)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = c.bw.WriteString("\r\n")
+	return err
+}
+
 func (c *conn) writeCommand(cmd string, args []interface{}) (err error) {
 	c.writeN('*', 1+len(args))
 	err = c.writeString(cmd)
@@ -136,32 +580,50 @@ func (c *conn) writeCommand(cmd string, args []interface{}) (err error) {
 		if err != nil {
 			break
 		}
-		switch arg := arg.(type) {
-		case string:
-			err = c.writeString(arg)
-		case []byte:
-			err = c.writeBytes(arg)
-		case bool:
-			if arg {
-				err = c.writeString("1")
-			} else {
-				err = c.writeString("0")
-			}
-		case nil:
-			err = c.writeString("")
-		default:
-			var buf bytes.Buffer
-			fmt.Fprint(&buf, arg)
-			err = c.writeBytes(buf.Bytes())
-		}
+		err = c.writeArg(arg)
 	}
 	return err
 }
 
+func (c *conn) writeArg(arg interface{}) error {
+	switch arg := arg.(type) {
+	case Argument:
+		return c.writeArg(arg.RedisArg())
+	case string:
+		return c.writeString(arg)
+	case []byte:
+		return c.writeBytes(arg)
+	case bool:
+		if arg {
+			return c.writeString("1")
+		}
+		return c.writeString("0")
+	case nil:
+		return c.writeString("")
+	case *io.LimitedReader:
+		return c.writeReader(arg.R, arg.N)
+	case *bytes.Reader:
+		return c.writeReader(arg, int64(arg.Len()))
+	case *strings.Reader:
+		return c.writeReader(arg, int64(arg.Len()))
+	default:
+		var buf bytes.Buffer
+		fmt.Fprint(&buf, arg)
+		return c.writeBytes(buf.Bytes())
+	}
+}
+
 func (c *conn) readLine() ([]byte, error) {
 	p, err := c.br.ReadSlice('\n')
 	if err == bufio.ErrBufferFull {
-		return nil, errors.New("redigo: long response line")
+		// The line does not fit in the read buffer. Fall back to allocating
+		// a buffer for the line.
+		buf := append([]byte{}, p...)
+		for err == bufio.ErrBufferFull {
+			p, err = c.br.ReadSlice('\n')
+			buf = append(buf, p...)
+		}
+		p = buf
 	}
 	if err != nil {
 		return nil, err
@@ -178,6 +640,11 @@ func (c *conn) readReply() (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	return c.readReplyLine(line)
+}
+
+// readReplyLine reads the remainder of the reply that starts with line.
+func (c *conn) readReplyLine(line []byte) (interface{}, error) {
 	if len(line) == 0 {
 		return nil, errors.New("redigo: short response line")
 	}
@@ -192,7 +659,14 @@ func (c *conn) readReply() (interface{}, error) {
 			return nil, err
 		}
 		return n, nil
-	case '$':
+	case '(':
+		n, ok := new(big.Int).SetString(string(line[1:]), 10)
+		if !ok {
+			return nil, errors.New("redigo: bad big number format")
+		}
+		return n, nil
+	case '$', '=':
+		verbatim := line[0] == '='
 		n, err := strconv.Atoi(string(line[1:]))
 		if err != nil || n < 0 {
 			return nil, err
@@ -209,6 +683,13 @@ func (c *conn) readReply() (interface{}, error) {
 		if len(line) != 0 {
 			return nil, errors.New("redigo: bad bulk format")
 		}
+		if verbatim {
+			// Remove the format of the verbatim string, for example "txt:".
+			if len(p) < 4 || p[3] != ':' {
+				return nil, errors.New("redigo: bad verbatim string format")
+			}
+			p = p[4:]
+		}
 		return p, nil
 	case '*':
 		n, err := strconv.Atoi(string(line[1:]))
@@ -228,11 +709,19 @@ func (c *conn) readReply() (interface{}, error) {
 }
 
 func (c *conn) Send(cmd string, args ...interface{}) error {
+	if c.raw {
+		return errRawConn
+	}
+	if err := checkKeyType(cmd, args); err != nil {
+		return err
+	}
 	c.mu.Lock()
-	c.pending += 1
+	if c.expectReply(cmd, args) {
+		c.pending += 1
+	}
 	c.mu.Unlock()
-	if c.writeTimeout != 0 {
-		c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	if d := c.deadline(c.writeTimeout); !d.IsZero() {
+		c.conn.SetWriteDeadline(d)
 	}
 	if err := c.writeCommand(cmd, args); err != nil {
 		return c.fatal(err)
@@ -240,9 +729,64 @@ func (c *conn) Send(cmd string, args ...interface{}) error {
 	return nil
 }
 
+// expectReply updates the CLIENT REPLY mode for command cmd and returns true
+// if the server replies to the command. The caller must hold c.mu.
+func (c *conn) expectReply(cmd string, args []interface{}) bool {
+	switch clientReplyMode(cmd, args) {
+	case "ON":
+		c.replyOff = false
+		c.replySkip = false
+		return true
+	case "OFF":
+		c.replyOff = true
+		return false
+	case "SKIP":
+		if !c.replyOff {
+			c.replySkip = true
+		}
+		return false
+	}
+	if c.replyOff {
+		return false
+	}
+	if c.replySkip {
+		c.replySkip = false
+		return false
+	}
+	return true
+}
+
+// clientReplyMode returns the mode argument of a CLIENT REPLY command or ""
+// if the command is not CLIENT REPLY.
+func clientReplyMode(cmd string, args []interface{}) string {
+	if len(args) != 2 || !strings.EqualFold(cmd, "CLIENT") {
+		return ""
+	}
+	var sub, mode string
+	switch arg := args[0].(type) {
+	case string:
+		sub = arg
+	case []byte:
+		sub = string(arg)
+	}
+	switch arg := args[1].(type) {
+	case string:
+		mode = arg
+	case []byte:
+		mode = string(arg)
+	}
+	if !strings.EqualFold(sub, "REPLY") {
+		return ""
+	}
+	return strings.ToUpper(mode)
+}
+
 func (c *conn) Flush() error {
-	if c.writeTimeout != 0 {
-		c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	if c.raw {
+		return errRawConn
+	}
+	if d := c.deadline(c.writeTimeout); !d.IsZero() {
+		c.conn.SetWriteDeadline(d)
 	}
 	if err := c.bw.Flush(); err != nil {
 		return c.fatal(err)
@@ -250,7 +794,104 @@ func (c *conn) Flush() error {
 	return nil
 }
 
+// deadline returns the deadline for an I/O operation with the given timeout or
+// the zero time if there is no deadline.
+func (c *conn) deadline(timeout time.Duration) time.Time {
+	var d time.Time
+	if timeout != 0 {
+		d = time.Now().Add(timeout)
+	}
+	if !c.ctxDeadline.IsZero() && (d.IsZero() || c.ctxDeadline.Before(d)) {
+		d = c.ctxDeadline
+	}
+	return d
+}
+
+// setReadDeadline sets the read deadline for an operation with the given
+// timeout. A deadline set for a previous operation is cleared if the
+// operation does not have a deadline.
+func (c *conn) setReadDeadline(timeout time.Duration) {
+	d := c.deadline(timeout)
+	if !d.IsZero() || c.readDeadline {
+		c.conn.SetReadDeadline(d)
+		c.readDeadline = !d.IsZero()
+	}
+}
+
+// ConnWithTimeout is an optional interface that allows the caller to override
+// the connection's read timeout for a single call. Use the DoWithTimeout and
+// ReceiveWithTimeout functions to call the methods of the interface. The
+// connections returned by Dial, DialTimeout, NewConn and Pool implement the
+// interface.
+type ConnWithTimeout interface {
+	Conn
+
+	// DoWithTimeout is like Do, but uses the given read timeout in place of
+	// the connection's read timeout. A timeout of zero disables the read
+	// timeout.
+	DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (reply interface{}, err error)
+
+	// ReceiveWithTimeout is like Receive, but uses the given read timeout in
+	// place of the connection's read timeout. A timeout of zero disables the
+	// read timeout.
+	ReceiveWithTimeout(timeout time.Duration) (reply interface{}, err error)
+}
+
+var errTimeoutNotSupported = errors.New("redigo: connection does not support ConnWithTimeout")
+
+// DoWithTimeout executes a Redis command with the specified read timeout. Use
+// DoWithTimeout for blocking commands such as BLPOP that need a longer
+// timeout than the connection's read timeout. If the connection does not
+// satisfy the ConnWithTimeout interface, then an error is returned.
+func DoWithTimeout(c Conn, timeout time.Duration, commandName string, args ...interface{}) (reply interface{}, err error) {
+	cwt, ok := c.(ConnWithTimeout)
+	if !ok {
+		return nil, errTimeoutNotSupported
+	}
+	return cwt.DoWithTimeout(timeout, commandName, args...)
+}
+
+// ReceiveWithTimeout receives a reply with the specified read timeout. If the
+// connection does not satisfy the ConnWithTimeout interface, then an error is
+// returned.
+func ReceiveWithTimeout(c Conn, timeout time.Duration) (reply interface{}, err error) {
+	cwt, ok := c.(ConnWithTimeout)
+	if !ok {
+		return nil, errTimeoutNotSupported
+	}
+	return cwt.ReceiveWithTimeout(timeout)
+}
+
+// Ping sends a PING command to the server and returns an error if the reply
+// is not PONG. Use Ping to check that the server is responsive. Ping can
+// return an error reply such as NOAUTH for a usable connection. Check the
+// connection's Err method to determine if the connection is usable after
+// Ping fails.
+func Ping(c Conn) error {
+	s, err := String(c.Do("PING"))
+	if err != nil {
+		return err
+	}
+	if s != "PONG" {
+		return errors.New("redigo: unexpected PING reply " + strconv.Quote(s))
+	}
+	return nil
+}
+
+// Healthy returns true if the connection is usable and the server replies to
+// PING.
+func Healthy(c Conn) bool {
+	return c.Err() == nil && Ping(c) == nil
+}
+
 func (c *conn) Receive() (reply interface{}, err error) {
+	return c.ReceiveWithTimeout(c.readTimeout)
+}
+
+func (c *conn) ReceiveWithTimeout(timeout time.Duration) (reply interface{}, err error) {
+	if c.raw {
+		return nil, errRawConn
+	}
 	c.mu.Lock()
 	// There can be more receives than sends when using pub/sub. To allow
 	// normal use of the connection after unsubscribe from all channels, do not
@@ -259,9 +900,7 @@ func (c *conn) Receive() (reply interface{}, err error) {
 		c.pending -= 1
 	}
 	c.mu.Unlock()
-	if c.readTimeout != 0 {
-		c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
-	}
+	c.setReadDeadline(timeout)
 	if reply, err = c.readReply(); err != nil {
 		return nil, c.fatal(err)
 	}
@@ -272,12 +911,28 @@ func (c *conn) Receive() (reply interface{}, err error) {
 }
 
 func (c *conn) Do(cmd string, args ...interface{}) (interface{}, error) {
-	if c.writeTimeout != 0 {
-		c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	return c.DoWithTimeout(c.readTimeout, cmd, args...)
+}
+
+func (c *conn) DoWithTimeout(readTimeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	if c.raw {
+		return nil, errRawConn
 	}
+	if err := checkKeyType(cmd, args); err != nil {
+		return nil, err
+	}
+	if d := c.deadline(c.writeTimeout); !d.IsZero() {
+		c.conn.SetWriteDeadline(d)
+	}
+
+	c.mu.Lock()
+	expect := cmd != "" && c.expectReply(cmd, args)
+	c.mu.Unlock()
 
 	if cmd != "" {
-		c.writeCommand(cmd, args)
+		if err := c.writeCommand(cmd, args); err != nil {
+			return nil, c.fatal(err)
+		}
 	}
 
 	if err := c.bw.Flush(); err != nil {
@@ -289,9 +944,7 @@ func (c *conn) Do(cmd string, args ...interface{}) (interface{}, error) {
 	c.pending = 0
 	c.mu.Unlock()
 
-	if c.readTimeout != 0 {
-		c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
-	}
+	c.setReadDeadline(readTimeout)
 
 	if cmd == "" {
 		reply := make([]interface{}, pending)
@@ -305,9 +958,13 @@ func (c *conn) Do(cmd string, args ...interface{}) (interface{}, error) {
 		return reply, nil
 	}
 
+	if expect {
+		pending += 1
+	}
+
 	var err error
 	var reply interface{}
-	for i := 0; i <= pending; i++ {
+	for i := 0; i < pending; i++ {
 		var e error
 		if reply, e = c.readReply(); e != nil {
 			return nil, c.fatal(e)
@@ -316,5 +973,10 @@ func (c *conn) Do(cmd string, args ...interface{}) (interface{}, error) {
 			err = e
 		}
 	}
+	if !expect {
+		// The server does not reply to the command. Return the first error
+		// from the pending replies, if any.
+		return nil, err
+	}
 	return reply, err
 }
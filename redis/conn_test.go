@@ -17,15 +17,28 @@ package redis_test
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
 	"github.com/garyburd/redigo/redis"
+	"io"
+	"math/big"
 	"net"
+	"net/http/httptest"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
+// cents is a money value that is sent to the server as a decimal string.
+type cents int64
+
+func (c cents) RedisArg() interface{} { return fmt.Sprintf("%d.%02d", c/100, c%100) }
+
 var writeTests = []struct {
 	args     []interface{}
 	expected string
@@ -50,6 +63,22 @@ var writeTests = []struct {
 		[]interface{}{"SET", nil, []byte("foo")},
 		"*3\r\n$3\r\nSET\r\n$0\r\n\r\n$3\r\nfoo\r\n",
 	},
+	{
+		[]interface{}{"SET", "foo", strings.NewReader("hello")},
+		"*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$5\r\nhello\r\n",
+	},
+	{
+		[]interface{}{"SET", "foo", &io.LimitedReader{R: strings.NewReader("hello"), N: 4}},
+		"*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$4\r\nhell\r\n",
+	},
+	{
+		[]interface{}{"SET", "foo", bytes.NewBufferString("hello")},
+		"*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$5\r\nhello\r\n",
+	},
+	{
+		[]interface{}{"SET", "foo", cents(1250)},
+		"*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$5\r\n12.50\r\n",
+	},
 }
 
 func TestWrite(t *testing.T) {
@@ -70,6 +99,30 @@ func TestWrite(t *testing.T) {
 	}
 }
 
+func TestWriteShortReader(t *testing.T) {
+	var buf bytes.Buffer
+	c := redis.NewConnBufio(bufio.ReadWriter{Writer: bufio.NewWriter(&buf)})
+	err := c.Send("SET", "foo", &io.LimitedReader{R: strings.NewReader("hi"), N: 5})
+	if err == nil {
+		t.Fatal("Send with short reader did not return error")
+	}
+	if c.Err() == nil {
+		t.Error("connection not closed after short reader")
+	}
+}
+
+func TestWriteNegativeLengthReader(t *testing.T) {
+	var buf bytes.Buffer
+	c := redis.NewConnBufio(bufio.ReadWriter{Writer: bufio.NewWriter(&buf)})
+	err := c.Send("SET", "foo", &io.LimitedReader{R: strings.NewReader("hi"), N: -1})
+	if err == nil {
+		t.Fatal("Send with negative length reader did not return error")
+	}
+	if strings.Contains(buf.String(), "$-1") {
+		t.Errorf("Send with negative length reader wrote %q", buf.String())
+	}
+}
+
 var errorSentinel = &struct{}{}
 
 var readTests = []struct {
@@ -112,6 +165,27 @@ var readTests = []struct {
 		"*3\r\n$3\r\nfoo\r\n$-1\r\n$3\r\nbar\r\n",
 		[]interface{}{[]byte("foo"), nil, []byte("bar")},
 	},
+	{
+		"(3492890328409238509324850943850943825024385\r\n",
+		bigInt("3492890328409238509324850943850943825024385"),
+	},
+	{
+		"(12x\r\n",
+		errorSentinel,
+	},
+	{
+		"=15\r\ntxt:Some string\r\n",
+		[]byte("Some string"),
+	},
+	{
+		"=2\r\nab\r\n",
+		errorSentinel,
+	},
+}
+
+func bigInt(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 10)
+	return n
 }
 
 func TestRead(t *testing.T) {
@@ -382,3 +456,464 @@ func ExampleDial(x int) {
 	defer c.Close()
 
 }
+
+// serveFake starts a server that replies to each command with the raw reply
+// returned by handle. The server closes the connection when handle returns
+// closeReply. The server is stopped by closing the returned listener.
+func serveFake(t *testing.T, handle func(args []string) string) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen returned %v", err)
+	}
+	return serveListener(l, handle)
+}
+
+// serveListener serves connections accepted from l with the fake server
+// described in serveFake.
+func serveListener(l net.Listener, handle func(args []string) string) net.Listener {
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer nc.Close()
+				rw := bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc))
+				c := redis.NewConnBufio(*rw)
+				for {
+					values, err := redis.Values(c.Receive())
+					if err != nil {
+						return
+					}
+					var args []string
+					redis.ScanSlice(values, &args)
+					reply := handle(args)
+					if reply == closeReply {
+						return
+					}
+					rw.WriteString(reply)
+					rw.Flush()
+				}
+			}()
+		}
+	}()
+	return l
+}
+
+func TestNewConnPipe(t *testing.T) {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		br := bufio.NewReader(server)
+		for {
+			// Read the array header and the bulk strings of the command.
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+			var n int
+			fmt.Sscanf(line, "*%d", &n)
+			for i := 0; i < 2*n; i++ {
+				if _, err := br.ReadString('\n'); err != nil {
+					return
+				}
+			}
+			server.Write([]byte("$5\r\nhello\r\n"))
+		}
+	}()
+
+	c := redis.NewConn(client, time.Second, time.Second)
+	defer c.Close()
+	s, err := redis.String(c.Do("GET", "k"))
+	if err != nil || s != "hello" {
+		t.Errorf("Do returned %q, %v", s, err)
+	}
+	c.Send("GET", "a")
+	c.Send("GET", "b")
+	c.Flush()
+	for i := 0; i < 2; i++ {
+		if s, err := redis.String(c.Receive()); err != nil || s != "hello" {
+			t.Errorf("Receive returned %q, %v", s, err)
+		}
+	}
+}
+
+func TestDialCredentials(t *testing.T) {
+	var mu sync.Mutex
+	var auth [][]string
+	l := serveFake(t, func(args []string) string {
+		if args[0] == "AUTH" {
+			mu.Lock()
+			auth = append(auth, args)
+			mu.Unlock()
+		}
+		return "+OK\r\n"
+	})
+	defer l.Close()
+
+	user, password := "u1", "p1"
+	c, err := redis.Dial("tcp", l.Addr().String(), redis.DialCredentialsProvider(func(ctx context.Context) (string, string, error) {
+		return user, password, nil
+	}))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	c.Close()
+
+	c, err = redis.Dial("tcp", l.Addr().String(), redis.DialPassword("p2"))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	c.Close()
+
+	expected := [][]string{{"AUTH", "u1", "p1"}, {"AUTH", "p2"}}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(auth, expected) {
+		t.Errorf("AUTH commands = %v, want %v", auth, expected)
+	}
+}
+
+func TestDialUsername(t *testing.T) {
+	var mu sync.Mutex
+	var auth [][]string
+	l := serveFake(t, func(args []string) string {
+		if args[0] != "AUTH" {
+			return "+OK\r\n"
+		}
+		mu.Lock()
+		auth = append(auth, args)
+		mu.Unlock()
+		if len(args) == 3 && args[1] == "old" {
+			return "-ERR wrong number of arguments for 'auth' command\r\n"
+		}
+		if args[len(args)-1] != "secret" {
+			return "-WRONGPASS invalid username-password pair\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer l.Close()
+
+	for _, username := range []string{"app", "old"} {
+		c, err := redis.Dial("tcp", l.Addr().String(), redis.DialUsername(username), redis.DialPassword("secret"))
+		if err != nil {
+			t.Fatalf("Dial(%s) returned %v", username, err)
+		}
+		c.Close()
+	}
+	if _, err := redis.Dial("tcp", l.Addr().String(), redis.DialPassword("bad"), redis.DialUsername("app")); err == nil {
+		t.Errorf("Dial with bad password did not return error")
+	}
+
+	expected := [][]string{{"AUTH", "app", "secret"}, {"AUTH", "old", "secret"}, {"AUTH", "secret"}, {"AUTH", "app", "bad"}}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(auth, expected) {
+		t.Errorf("AUTH commands = %v, want %v", auth, expected)
+	}
+}
+
+func TestDialTokenProvider(t *testing.T) {
+	var mu sync.Mutex
+	var auth []string
+	l := serveFake(t, func(args []string) string {
+		if args[0] == "AUTH" {
+			mu.Lock()
+			auth = append(auth, args[2])
+			mu.Unlock()
+		}
+		return "+OK\r\n"
+	})
+	defer l.Close()
+
+	var n int
+	p := redis.CachedTokenProvider(func(ctx context.Context) (redis.Credentials, error) {
+		n += 1
+		expiration := time.Now().Add(time.Hour)
+		if n == 1 {
+			// Force a refresh on the next call.
+			expiration = time.Now().Add(time.Second)
+		}
+		return redis.Credentials{Username: "u", Password: fmt.Sprintf("token%d", n), Expiration: expiration}, nil
+	}, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		c, err := redis.Dial("tcp", l.Addr().String(), redis.DialTokenProvider(p))
+		if err != nil {
+			t.Fatalf("Dial returned %v", err)
+		}
+		c.Close()
+	}
+
+	expected := []string{"token1", "token2", "token2"}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(auth, expected) {
+		t.Errorf("AUTH tokens = %v, want %v", auth, expected)
+	}
+}
+
+func TestPing(t *testing.T) {
+	replies := []string{"+PONG\r\n", "-NOAUTH Authentication required.\r\n", closeReply}
+	var mu sync.Mutex
+	l := serveFake(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		reply := replies[0]
+		replies = replies[1:]
+		return reply
+	})
+	defer l.Close()
+	c, err := redis.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	if !redis.Healthy(c) {
+		t.Error("Healthy returned false for responsive server")
+	}
+	err = redis.Ping(c)
+	if _, ok := err.(redis.Error); !ok {
+		t.Errorf("Ping returned %v, want error reply", err)
+	}
+	if err := c.Err(); err != nil {
+		t.Errorf("Err after error reply returned %v", err)
+	}
+	if redis.Healthy(c) {
+		t.Error("Healthy returned true for closed connection")
+	}
+	if c.Err() == nil {
+		t.Error("Err after connection closed by server returned nil")
+	}
+}
+
+func TestDialClientFlags(t *testing.T) {
+	var mu sync.Mutex
+	var commands []string
+	l := serveFake(t, func(args []string) string {
+		mu.Lock()
+		commands = append(commands, strings.Join(args, " "))
+		mu.Unlock()
+		return "+OK\r\n"
+	})
+	defer l.Close()
+
+	c, err := redis.Dial("tcp", l.Addr().String(), redis.DialPassword("p"), redis.DialReplicaReads(), redis.DialClientNoEvict(), redis.DialClientNoTouch())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	c.Close()
+
+	expected := []string{"AUTH p", "READONLY", "CLIENT NO-EVICT on", "CLIENT NO-TOUCH on"}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(commands, expected) {
+		t.Errorf("commands = %v, want %v", commands, expected)
+	}
+
+	if _, err := redis.Dial("tcp", l.Addr().String(), redis.DialStrictRESP2(), redis.DialClientNoEvict()); err == nil {
+		t.Errorf("Dial with strict RESP2 and NO-EVICT did not return error")
+	}
+}
+
+func TestClientReply(t *testing.T) {
+	var off, skip bool
+	l := serveFake(t, func(args []string) string {
+		if len(args) == 3 && args[0] == "CLIENT" && args[1] == "REPLY" {
+			switch args[2] {
+			case "ON":
+				off, skip = false, false
+				return "+OK\r\n"
+			case "OFF":
+				off = true
+			case "SKIP":
+				skip = !off
+			}
+			return ""
+		}
+		if off {
+			return ""
+		}
+		if skip {
+			skip = false
+			return ""
+		}
+		return ":" + args[1] + "\r\n"
+	})
+	defer l.Close()
+
+	c, err := redis.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	c.Send("ECHO", "1")
+	c.Send("CLIENT", "REPLY", "OFF")
+	c.Send("ECHO", "2")
+	if v, err := c.Do("ECHO", "3"); v != nil || err != nil {
+		t.Errorf("Do with reply off returned %v, %v", v, err)
+	}
+	if s, err := redis.String(c.Do("CLIENT", "REPLY", "ON")); s != "OK" || err != nil {
+		t.Errorf("CLIENT REPLY ON returned %q, %v", s, err)
+	}
+	c.Send("CLIENT", "REPLY", "SKIP")
+	c.Send("ECHO", "4")
+	if v, err := redis.Int(c.Do("ECHO", "5")); v != 5 || err != nil {
+		t.Errorf("Do after CLIENT REPLY SKIP returned %v, %v", v, err)
+	}
+	if v, err := redis.Int(c.Do("ECHO", "6")); v != 6 || err != nil {
+		t.Errorf("Do returned %v, %v", v, err)
+	}
+}
+
+func TestDialBufferSize(t *testing.T) {
+	l := serveFake(t, func(args []string) string {
+		if args[0] == "ECHO" {
+			return "$" + fmt.Sprint(len(args[1])) + "\r\n" + args[1] + "\r\n"
+		}
+		return "+" + strings.Repeat("x", 64) + "\r\n"
+	})
+	defer l.Close()
+
+	c, err := redis.Dial("tcp", l.Addr().String(), redis.DialReadBufferSize(16), redis.DialWriteBufferSize(16))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	value := strings.Repeat("v", 1000)
+	if s, err := redis.String(c.Do("ECHO", value)); err != nil || s != value {
+		t.Errorf("Do(ECHO) returned %d bytes, %v", len(s), err)
+	}
+	if s, err := redis.String(c.Do("PING")); err != nil || s != strings.Repeat("x", 64) {
+		t.Errorf("Do(PING) with status longer than read buffer returned %q, %v", s, err)
+	}
+}
+
+func TestDialTLS(t *testing.T) {
+	// Borrow the test certificate from an httptest server.
+	ts := httptest.NewTLSServer(nil)
+	cert := ts.Certificate()
+	certs := ts.TLS.Certificates
+	ts.Close()
+
+	tl, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: certs})
+	if err != nil {
+		t.Fatalf("tls.Listen returned %v", err)
+	}
+	l := serveListener(tl, func(args []string) string { return "+PONG\r\n" })
+	defer l.Close()
+	addr := l.Addr().String()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	tests := []struct {
+		options []redis.DialOption
+		ok      bool
+	}{
+		{[]redis.DialOption{redis.DialUseTLS(true)}, false},
+		{[]redis.DialOption{redis.DialUseTLS(true), redis.DialTLSSkipVerify(true)}, true},
+		{[]redis.DialOption{redis.DialUseTLS(true), redis.DialTLSConfig(&tls.Config{RootCAs: roots})}, true},
+		{[]redis.DialOption{redis.DialTLSSkipVerify(true)}, false},
+	}
+	for i, tt := range tests {
+		c, err := redis.Dial("tcp", addr, tt.options...)
+		if err == nil {
+			_, err = c.Do("PING")
+			c.Close()
+		}
+		if (err == nil) != tt.ok {
+			t.Errorf("%d: got error %v, want ok=%v", i, err, tt.ok)
+		}
+	}
+
+	p := &redis.Pool{
+		Network:     "tcp",
+		Address:     addr,
+		DialOptions: []redis.DialOption{redis.DialUseTLS(true), redis.DialTLSSkipVerify(true)},
+	}
+	defer p.Close()
+	c := p.Get()
+	defer c.Close()
+	if s, err := redis.String(c.Do("PING")); err != nil || s != "PONG" {
+		t.Errorf("pool Do(PING) returned %q, %v", s, err)
+	}
+}
+
+func TestDoWithTimeout(t *testing.T) {
+	l := serveFake(t, func(args []string) string {
+		if args[0] == "BLPOP" {
+			time.Sleep(100 * time.Millisecond)
+			return "*2\r\n$1\r\nq\r\n$1\r\nx\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer l.Close()
+
+	c, err := redis.DialTimeout("tcp", l.Addr().String(), 0, 20*time.Millisecond, 0)
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	for _, timeout := range []time.Duration{time.Second, 0} {
+		if _, err := redis.Values(redis.DoWithTimeout(c, timeout, "BLPOP", "q", 0)); err != nil {
+			t.Errorf("DoWithTimeout(%v) returned %v", timeout, err)
+		}
+	}
+	c.Send("BLPOP", "q", 0)
+	c.Flush()
+	if _, err := redis.Values(redis.ReceiveWithTimeout(c, time.Second)); err != nil {
+		t.Errorf("ReceiveWithTimeout returned %v", err)
+	}
+	if _, err := c.Do("BLPOP", "q", 0); err == nil {
+		t.Errorf("Do(BLPOP) with short read timeout did not return error")
+	}
+
+	if _, err := redis.DoWithTimeout(redis.FromConnV2(nil), time.Second, "PING"); err == nil {
+		t.Errorf("DoWithTimeout on connection without timeout support did not return error")
+	}
+}
+
+func TestPoolDialOptions(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		commands []string
+	)
+	l := serveFake(t, func(args []string) string {
+		mu.Lock()
+		commands = append(commands, strings.Join(args, " "))
+		mu.Unlock()
+		return "+OK\r\n"
+	})
+	defer l.Close()
+
+	p := &redis.Pool{
+		Network:     "tcp",
+		Address:     l.Addr().String(),
+		DialOptions: []redis.DialOption{redis.DialDatabase(2), redis.DialPassword("secret"), redis.DialClientName("worker")},
+	}
+	defer p.Close()
+	c1, c2 := p.Get(), p.Get()
+	for _, c := range []redis.Conn{c1, c2} {
+		if _, err := c.Do("SET", "k", "v"); err != nil {
+			t.Fatalf("Do returned %v", err)
+		}
+	}
+	c1.Close()
+	c2.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{
+		"AUTH secret", "CLIENT SETNAME worker", "SELECT 2", "SET k v",
+		"AUTH secret", "CLIENT SETNAME worker", "SELECT 2", "SET k v",
+	}
+	if !reflect.DeepEqual(commands, expected) {
+		t.Errorf("server received %q, want %q", commands, expected)
+	}
+}
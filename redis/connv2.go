@@ -0,0 +1,208 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"time"
+)
+
+// ConnV2 is a connection with a context argument on every method that
+// performs I/O. The context deadline bounds the I/O performed by the call
+// and cancellation of the context interrupts the call. A connection
+// interrupted by cancellation is not usable because the state of the protocol
+// is not known.
+//
+// Use ToConnV2 and FromConnV2 to convert between Conn and ConnV2.
+type ConnV2 interface {
+	// Close closes the connection.
+	Close() error
+
+	// Err returns a non-nil value if the connection is broken.
+	Err() error
+
+	// Do sends a command to the server and returns the received reply.
+	Do(ctx context.Context, commandName string, args ...interface{}) (reply interface{}, err error)
+
+	// Send writes the command to the client's output buffer.
+	Send(ctx context.Context, commandName string, args ...interface{}) error
+
+	// Flush flushes the output buffer to the Redis server.
+	Flush(ctx context.Context) error
+
+	// Receive receives a single reply from the Redis server.
+	Receive(ctx context.Context) (reply interface{}, err error)
+}
+
+// contextConn is implemented by connections that apply the deadline and
+// cancellation of a context to the I/O performed by f.
+type contextConn interface {
+	withContext(ctx context.Context, f func() error) error
+}
+
+// withContext calls f with the context applied to c. If c does not support
+// contexts, then the context is only checked before calling f.
+func withContext(c Conn, ctx context.Context, f func() error) error {
+	if cc, ok := c.(contextConn); ok {
+		return cc.withContext(ctx, f)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return f()
+}
+
+// aLongTimeAgo is a deadline in the past used to interrupt blocked I/O.
+var aLongTimeAgo = time.Unix(1, 0)
+
+func (c *conn) withContext(ctx context.Context, f func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d, hasDeadline := ctx.Deadline()
+	if hasDeadline {
+		c.ctxDeadline = d
+	}
+	var stop, stopped chan struct{}
+	if done := ctx.Done(); done != nil {
+		stop = make(chan struct{})
+		stopped = make(chan struct{})
+		go func() {
+			defer close(stopped)
+			select {
+			case <-done:
+				c.conn.SetDeadline(aLongTimeAgo)
+			case <-stop:
+			}
+		}()
+	}
+	err := f()
+	if stop != nil {
+		close(stop)
+		<-stopped
+	}
+	if hasDeadline || ctx.Err() != nil {
+		c.ctxDeadline = time.Time{}
+		c.conn.SetDeadline(time.Time{})
+	}
+	if err != nil {
+		if e := ctx.Err(); e != nil {
+			err = e
+		} else if hasDeadline && !time.Now().Before(d) {
+			// The I/O deadline expired before the context timer fired.
+			err = context.DeadlineExceeded
+		}
+	}
+	return err
+}
+
+func (c *pooledConnection) withContext(ctx context.Context, f func() error) error {
+	if err := c.get(); err != nil {
+		return err
+	}
+	return withContext(c.c, ctx, f)
+}
+
+// DoContext sends a command to the server and returns the received reply.
+// The deadline of the context bounds the I/O performed by the call and
+// cancellation of the context interrupts the call. A connection interrupted
+// by cancellation is not usable because the state of the protocol is not
+// known.
+func DoContext(c Conn, ctx context.Context, commandName string, args ...interface{}) (reply interface{}, err error) {
+	err = withContext(c, ctx, func() error {
+		reply, err = c.Do(commandName, args...)
+		return err
+	})
+	return reply, err
+}
+
+// ReceiveContext receives a single reply from the server. The context is
+// applied as described for DoContext. Use ReceiveContext to abort blocking
+// commands such as BLPOP.
+func ReceiveContext(c Conn, ctx context.Context) (reply interface{}, err error) {
+	err = withContext(c, ctx, func() error {
+		reply, err = c.Receive()
+		return err
+	})
+	return reply, err
+}
+
+// ToConnV2 returns a ConnV2 for the connection. The deadline and cancellation
+// of the context are applied to connections created by Dial, DialTimeout,
+// NewConn and Pool. For other connections, the context is checked before
+// each call.
+func ToConnV2(c Conn) ConnV2 {
+	if c, ok := c.(connV1); ok {
+		return c.c
+	}
+	return connV2{c}
+}
+
+// FromConnV2 returns a Conn for the connection. The methods of the returned
+// connection call the ConnV2 methods with the background context.
+func FromConnV2(c ConnV2) Conn {
+	if c, ok := c.(connV2); ok {
+		return c.c
+	}
+	return connV1{c}
+}
+
+type connV2 struct {
+	c Conn
+}
+
+func (c connV2) Close() error { return c.c.Close() }
+func (c connV2) Err() error   { return c.c.Err() }
+
+func (c connV2) Do(ctx context.Context, commandName string, args ...interface{}) (reply interface{}, err error) {
+	return DoContext(c.c, ctx, commandName, args...)
+}
+
+func (c connV2) Send(ctx context.Context, commandName string, args ...interface{}) error {
+	return withContext(c.c, ctx, func() error {
+		return c.c.Send(commandName, args...)
+	})
+}
+
+func (c connV2) Flush(ctx context.Context) error {
+	return withContext(c.c, ctx, c.c.Flush)
+}
+
+func (c connV2) Receive(ctx context.Context) (reply interface{}, err error) {
+	return ReceiveContext(c.c, ctx)
+}
+
+type connV1 struct {
+	c ConnV2
+}
+
+func (c connV1) Close() error { return c.c.Close() }
+func (c connV1) Err() error   { return c.c.Err() }
+
+func (c connV1) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return c.c.Do(context.Background(), commandName, args...)
+}
+
+func (c connV1) Send(commandName string, args ...interface{}) error {
+	return c.c.Send(context.Background(), commandName, args...)
+}
+
+func (c connV1) Flush() error {
+	return c.c.Flush(context.Background())
+}
+
+func (c connV1) Receive() (interface{}, error) {
+	return c.c.Receive(context.Background())
+}
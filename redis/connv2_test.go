@@ -0,0 +1,110 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"context"
+	"github.com/garyburd/redigo/redis"
+	"testing"
+	"time"
+)
+
+func TestConnV2(t *testing.T) {
+	l := serveFake(t, func(args []string) string {
+		if args[0] == "SLOW" {
+			time.Sleep(time.Second)
+		}
+		return "+OK\r\n"
+	})
+	defer l.Close()
+
+	dial := func() redis.ConnV2 {
+		c, err := redis.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial returned %v", err)
+		}
+		return redis.ToConnV2(c)
+	}
+
+	c := dial()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	if s, err := redis.String(c.Do(ctx, "PING")); err != nil || s != "OK" {
+		t.Errorf("Do(PING) returned %q, %v", s, err)
+	}
+	cancel()
+	if _, err := c.Do(ctx, "PING"); err != context.Canceled {
+		t.Errorf("Do with canceled context returned %v", err)
+	}
+	if s, err := redis.String(c.Do(context.Background(), "PING")); err != nil || s != "OK" {
+		t.Errorf("Do(PING) after deadline returned %q, %v", s, err)
+	}
+	c.Close()
+
+	c = dial()
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	if _, err := c.Do(ctx, "SLOW"); err != context.DeadlineExceeded {
+		t.Errorf("Do(SLOW) returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	cancel()
+	c.Close()
+
+	c = dial()
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := c.Do(ctx, "SLOW"); err != context.Canceled {
+		t.Errorf("Do(SLOW) returned %v, want %v", err, context.Canceled)
+	}
+	c.Close()
+
+	v1 := redis.FromConnV2(c)
+	if redis.ToConnV2(v1) != c {
+		t.Errorf("ToConnV2(FromConnV2(c)) != c")
+	}
+}
+
+func TestDoContext(t *testing.T) {
+	l := serveFake(t, func(args []string) string {
+		if args[0] == "BLPOP" {
+			time.Sleep(time.Second)
+			return "*-1\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := redis.DialContext(ctx, "tcp", l.Addr().String()); err == nil {
+		t.Errorf("DialContext with canceled context did not return error")
+	}
+
+	c, err := redis.DialContext(context.Background(), "tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("DialContext returned %v", err)
+	}
+	defer c.Close()
+	if s, err := redis.String(redis.DoContext(c, context.Background(), "PING")); err != nil || s != "OK" {
+		t.Errorf("DoContext(PING) returned %q, %v", s, err)
+	}
+	if err := c.Send("BLPOP", "q", 0); err != nil {
+		t.Fatalf("Send returned %v", err)
+	}
+	c.Flush()
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := redis.ReceiveContext(c, ctx); err != context.DeadlineExceeded {
+		t.Errorf("ReceiveContext returned %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+//go:build redigo_debug && go1.18
+// +build redigo_debug,go1.18
+
+package redis_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"testing"
+)
+
+func TestDebugKeyTypes(t *testing.T) {
+	addr, stop := serveStore(t)
+	defer stop()
+	c, err := redis.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+	defer redis.ResetKeyTypes()
+
+	counter := redis.StringKey[int]{Key: "counter"}
+	if err := counter.Set(c, 1); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if _, err := c.Do("HGET", "counter", "f"); err == nil {
+		t.Error("HGET on string key did not return error")
+	}
+	if err := c.Send("SADD", "counter", "x"); err == nil {
+		t.Error("Send(SADD) on string key did not return error")
+	}
+	if _, err := (redis.HashKey[struct{}]{Key: "counter"}).Get(c); err == nil {
+		t.Error("HashKey on string key did not return error")
+	}
+	if n, err := counter.Get(c); err != nil || n != 1 {
+		t.Errorf("Get after rejected commands returned %d, %v", n, err)
+	}
+
+	redis.ResetKeyTypes()
+	if _, err := c.Do("SADD", "counter", "x"); err != nil {
+		t.Errorf("SADD after ResetKeyTypes returned %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrDialThrottled is returned by a pool when a DialLimiter rejects a dial.
+var ErrDialThrottled = errors.New("redigo: dial throttled")
+
+// DialLimiter limits the rate at which pools dial new connections. When a
+// server restarts, the connections of every application instance break at
+// the same time. The limiter and the random jitter spread the reconnects over
+// time so that the instances do not overwhelm the server. A limiter can be
+// shared by the pools in an application to limit the total dial rate.
+//
+// The limiter is a token bucket. The zero value of DialLimiter does not
+// limit the rate and adds no jitter.
+type DialLimiter struct {
+	// Rate is the number of dials per second. If Rate is zero, then the
+	// rate is not limited.
+	Rate float64
+
+	// Burst is the number of dials allowed at once. The default is 1.
+	Burst int
+
+	// Jitter is the maximum random delay added before each dial.
+	Jitter time.Duration
+
+	// MaxWait is the maximum time a dial waits for the limiter. Dials that
+	// would wait longer fail with ErrDialThrottled. If MaxWait is zero, then
+	// dials wait as long as needed.
+	MaxWait time.Duration
+
+	mu        sync.Mutex
+	tokens    float64
+	last      time.Time
+	waiting   int
+	dials     int64
+	throttled int64
+	rejected  int64
+}
+
+// DialLimiterStats contains dial limiter statistics.
+type DialLimiterStats struct {
+	// Dials is the number of dials allowed by the limiter.
+	Dials int64
+
+	// Throttled is the number of dials delayed by the rate limit.
+	Throttled int64
+
+	// Rejected is the number of dials that failed with ErrDialThrottled.
+	Rejected int64
+
+	// Waiting is the number of dials waiting for the limiter.
+	Waiting int
+}
+
+// Stats returns the limiter's statistics.
+func (l *DialLimiter) Stats() DialLimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return DialLimiterStats{
+		Dials:     l.dials,
+		Throttled: l.throttled,
+		Rejected:  l.rejected,
+		Waiting:   l.waiting,
+	}
+}
+
+// reserve reserves a dial and returns the delay before the dial.
+func (l *DialLimiter) reserve() (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var delay time.Duration
+	if l.Rate > 0 {
+		burst := float64(l.Burst)
+		if burst < 1 {
+			burst = 1
+		}
+		now := nowFunc()
+		if l.last.IsZero() {
+			l.tokens = burst
+		} else if l.tokens += now.Sub(l.last).Seconds() * l.Rate; l.tokens > burst {
+			l.tokens = burst
+		}
+		l.last = now
+		if l.tokens < 1 {
+			delay = time.Duration((1 - l.tokens) / l.Rate * float64(time.Second))
+			if l.MaxWait > 0 && delay > l.MaxWait {
+				l.rejected++
+				return 0, ErrDialThrottled
+			}
+			l.throttled++
+		}
+		l.tokens--
+	}
+	if l.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(l.Jitter)))
+	}
+	l.dials++
+	if delay > 0 {
+		l.waiting++
+	}
+	return delay, nil
+}
+
+// wait waits for the limiter to allow a dial or for the context to be done.
+func (l *DialLimiter) wait(ctx context.Context) error {
+	delay, err := l.reserve()
+	if err != nil || delay <= 0 {
+		return err
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	l.mu.Lock()
+	l.waiting--
+	l.mu.Unlock()
+	return err
+}
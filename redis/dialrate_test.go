@@ -0,0 +1,91 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDialLimiter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	nowFunc = func() time.Time { return now }
+	defer func() { nowFunc = time.Now }()
+
+	var open int
+	l := &DialLimiter{Rate: 100, Burst: 2, MaxWait: 5 * time.Millisecond}
+	p := &Pool{
+		Dial:        func() (Conn, error) { open += 1; return &fakeConn{open: &open}, nil },
+		DialLimiter: l,
+	}
+	defer p.Close()
+
+	var conns []Conn
+	get := func() error {
+		c := p.Get()
+		conns = append(conns, c)
+		_, err := c.Do("PING")
+		return err
+	}
+	for i := 0; i < 2; i++ {
+		if err := get(); err != nil {
+			t.Fatalf("dial %d returned %v", i, err)
+		}
+	}
+	if err := get(); err != ErrDialThrottled {
+		t.Errorf("dial over burst returned %v, want %v", err, ErrDialThrottled)
+	}
+	now = now.Add(5 * time.Millisecond)
+	if err := get(); err != nil {
+		t.Errorf("throttled dial returned %v", err)
+	}
+	for _, c := range conns {
+		c.Close()
+	}
+
+	expected := DialLimiterStats{Dials: 3, Throttled: 1, Rejected: 1}
+	if stats := l.Stats(); stats != expected {
+		t.Errorf("Stats() = %+v, want %+v", stats, expected)
+	}
+}
+
+func TestDialLimiterContext(t *testing.T) {
+	var open int
+	l := &DialLimiter{Rate: 0.1}
+	p := &Pool{
+		Dial:        func() (Conn, error) { open += 1; return &fakeConn{open: &open}, nil },
+		DialLimiter: l,
+	}
+	defer p.Close()
+
+	c, err := p.GetContext(context.Background())
+	if err != nil {
+		t.Fatalf("GetContext returned %v", err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := p.GetContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("throttled GetContext returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("throttled GetContext returned after %v", d)
+	}
+	if stats := l.Stats(); stats.Waiting != 0 {
+		t.Errorf("Stats().Waiting = %d, want 0", stats.Waiting)
+	}
+}
@@ -35,9 +35,13 @@
 //
 // Arguments of type string and []byte are sent to the server as is. The value
 // false is converted to "0" and the value true is converted to "1". The value
-// nil is converted to "". All other values are converted to a string using the
-// fmt.Fprint function. Command replies are represented using the following Go
-// types:
+// nil is converted to "". Values of types that implement the Argument
+// interface are replaced by the value returned from the RedisArg method.
+// Arguments of type *io.LimitedReader, *bytes.Reader and *strings.Reader are
+// streamed to the server as a bulk string of the known length, so large
+// values do not need to be held in memory. All other
+// values are converted to a string using the fmt.Fprint function. Command
+// replies are represented using the following Go types:
 //
 //  Redis type          Go type
 //  error               redis.Error
@@ -49,6 +53,10 @@
 // Applications can use type assertions or type switches to determine the type
 // of a reply.
 //
+// Use errors.As and errors.Is with the MovedError, AskError, LoadingError,
+// ReadOnlyError, WrongTypeError and NoScriptError types to test an error reply
+// for common kinds of errors.
+//
 // Pipelining
 //
 // Connections support pipelining using the Send, Flush and Receive methods.
@@ -82,6 +90,54 @@
 //  r, err := c.Do("EXEC")
 //  fmt.Println(r) // prints [1, 1]
 //
+// The Pipeline type matches replies to commands so that applications do not
+// count calls to Receive. Each queued command returns a result that holds the
+// reply after the pipeline is executed.
+//
+//  p := redis.NewPipeline(c)
+//  v := p.String("GET", "foo")
+//  err := p.Exec()
+//  s, err := v.Val()
+//
+// Connections returned by Dial track the CLIENT REPLY mode so that commands
+// the server does not reply to are not counted as pending replies. Use
+// CLIENT REPLY OFF or SKIP for fire-and-forget commands. Do returns a nil
+// reply for a command that the server does not reply to.
+//
+//  c.Send("CLIENT", "REPLY", "OFF")
+//  c.Send("SET", "foo", "bar")
+//  c.Do("CLIENT", "REPLY", "ON") // reply from CLIENT REPLY ON
+//
+// Restore the reply mode with CLIENT REPLY ON before returning a connection to
+// a pool.
+//
+// Contexts
+//
+// The DoContext and ReceiveContext functions apply the deadline and
+// cancellation of a context to a command. Use the functions to abort a
+// blocking command or a call to a slow server:
+//
+//  reply, err := redis.DoContext(c, ctx, "BLPOP", "queue", 0)
+//
+// A connection interrupted by cancellation is not usable. DialContext applies
+// a context to dialing and connection setup. The ConnV2 interface has a
+// context argument on every method that performs I/O.
+//
+// The DoWithTimeout and ReceiveWithTimeout functions override the read
+// timeout of the connection for a single call. Use the functions for blocking
+// commands on connections with a read timeout:
+//
+//  reply, err := redis.DoWithTimeout(c, time.Minute, "BLPOP", "queue", 30)
+//
+// Hooks
+//
+// A Hook is called before each command is sent and after the reply to the
+// command is received. Use hooks to add logging, metrics and tracing to
+// connections. Attach a hook to a connection with NewHookConn or the
+// DialHook option and to the connections in a pool with the pool Hook field.
+// The LatencyHook maintains rolling latency and error rate statistics per
+// command family.
+//
 // Thread Safety
 //
 // The connection Send and Flush methods cannot be called concurrently with
@@ -91,6 +147,8 @@
 // with Send, Flush, Receive or Do. Unless stated otherwise, all other
 // concurrent access is allowed.
 //
+// Use a MuxConn to share a single connection between goroutines calling Do.
+//
 // Publish and Subscribe
 //
 // Use the Send, Flush and Receive methods to implement Pub/Sub subscribers.
@@ -124,17 +182,24 @@
 //
 // Reply Helpers
 //
-// The Bool, Int, Bytes, String and Values functions convert a reply to a value
-// of a specific type. To allow convenient wrapping of calls to the connection
-// Do and Receive methods, the functions take a second argument of type error.
-// If the error is non-nil, then the helper function returns the error. If the
-// error is nil, the function converts the reply to the specified type:
+// The Bool, Int, Int64, Uint64, Float64, Bytes, String and Values functions
+// convert a reply to a value of a specific type. To allow convenient wrapping
+// of calls to the connection Do and Receive methods, the functions take a
+// second argument of type error. If the error is non-nil, then the helper
+// function returns the error. If the error is nil, the function converts the
+// reply to the specified type. The helpers return ErrNil for a nil reply:
 //
 //  exists, err := redis.Bool(c.Do("EXISTS", "foo"))
 //  if err != nil {
 //      // handle error return from c.Do or type conversion error.
 //  }
 //
+// The Strings, ByteSlices, Ints, Int64s and Float64s functions convert a
+// multi-bulk reply to a slice. The StringMap, IntMap and Int64Map functions
+// convert a multi-bulk reply containing alternating keys and values to a map:
+//
+//  fields, err := redis.StringMap(c.Do("HGETALL", "user:1"))
+//
 // The Scan function converts elements of a multi-bulk reply to Go types:
 //
 //  var value1 int
@@ -146,4 +211,28 @@
 //   if _, err := redis.Scan(reply, &value1, &value2); err != nil {
 //      // handle error
 //  }
+//
+// Legacy Behavior
+//
+// Applications that depend on the behavior of earlier versions of the package
+// can build with the redigo_legacy build tag while migrating:
+//
+//  go build -tags redigo_legacy
+//
+// In a legacy build, the reply helpers return the zero value and a nil error
+// for a nil reply instead of ErrNil, ScanStruct ignores the "required" field
+// tag flag and FlattenStruct panics on errors instead of returning the
+// arguments unchanged.
+//
+// Key Type Checks
+//
+// Build tests with the redigo_debug build tag to catch commands that use a
+// key as the wrong type:
+//
+//  go test -tags redigo_debug
+//
+// In a debug build, the typed key accessors such as StringKey and HashKey
+// record the type of their keys and connections return an error without
+// sending the command when a command operates on a recorded key as another
+// type. Use ResetKeyTypes to forget the recorded types between tests.
 package redis
@@ -0,0 +1,225 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DryRunConn is a connection that records commands instead of sending them
+// to a server. Use a DryRunConn to review the commands issued by a migration
+// or other destructive script before running the script for real.
+//
+// Commands that reply with a status reply on a server receive the reply "OK"
+// ("PONG" for PING). All other commands receive a nil reply. Commands sent
+// between MULTI and EXEC receive the reply "QUEUED" and EXEC replies with the
+// replies to the queued commands.
+type DryRunConn struct {
+	w        io.Writer
+	commands []string
+	pending  []interface{}
+	queued   []interface{}
+	multi    bool
+	err      error
+}
+
+// NewDryRunConn returns a new dry-run connection. If w is not nil, then each
+// command is also written to w on a line by itself. Credentials in AUTH and
+// HELLO commands are redacted.
+func NewDryRunConn(w io.Writer) *DryRunConn {
+	return &DryRunConn{w: w}
+}
+
+// Commands returns the commands recorded by the connection.
+func (c *DryRunConn) Commands() []string {
+	return c.commands
+}
+
+func (c *DryRunConn) Close() error {
+	if c.err == nil {
+		c.err = errors.New("redigo: closed")
+	}
+	return nil
+}
+
+func (c *DryRunConn) Err() error {
+	return c.err
+}
+
+func (c *DryRunConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if cmd == "" {
+		reply := c.pending
+		c.pending = nil
+		if reply == nil {
+			reply = []interface{}{}
+		}
+		return reply, c.err
+	}
+	if err := c.Send(cmd, args...); err != nil {
+		return nil, err
+	}
+	reply := c.pending[len(c.pending)-1]
+	c.pending = nil
+	return reply, nil
+}
+
+func (c *DryRunConn) Send(cmd string, args ...interface{}) error {
+	if c.err != nil {
+		return c.err
+	}
+	line := FormatCommand(cmd, args)
+	c.commands = append(c.commands, line)
+	if c.w != nil {
+		if _, err := io.WriteString(c.w, line+"\n"); err != nil {
+			c.err = err
+			return err
+		}
+	}
+	c.pending = append(c.pending, c.reply(strings.ToUpper(cmd)))
+	return nil
+}
+
+func (c *DryRunConn) reply(cmd string) interface{} {
+	switch {
+	case cmd == "MULTI":
+		c.multi = true
+		c.queued = nil
+		return okReply
+	case cmd == "EXEC" && c.multi:
+		c.multi = false
+		reply := c.queued
+		c.queued = nil
+		if reply == nil {
+			reply = []interface{}{}
+		}
+		return reply
+	case cmd == "DISCARD":
+		c.multi = false
+		c.queued = nil
+		return okReply
+	case c.multi:
+		c.queued = append(c.queued, dryRunReply(cmd))
+		return "QUEUED"
+	}
+	return dryRunReply(cmd)
+}
+
+func dryRunReply(cmd string) interface{} {
+	switch cmd {
+	case "PING":
+		return "PONG"
+	case "AUTH", "SELECT", "SET", "MSET", "HMSET", "RENAME", "RESTORE", "LSET", "LTRIM",
+		"FLUSHDB", "FLUSHALL", "WATCH", "UNWATCH", "SWAPDB", "CONFIG", "CLIENT":
+		return okReply
+	}
+	return nil
+}
+
+func (c *DryRunConn) Flush() error {
+	return c.err
+}
+
+func (c *DryRunConn) Receive() (interface{}, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if len(c.pending) == 0 {
+		return nil, errors.New("redigo: Receive called without pending command")
+	}
+	reply := c.pending[0]
+	c.pending = c.pending[1:]
+	return reply, nil
+}
+
+const (
+	okReply  = "OK"
+	redacted = "(redacted)"
+)
+
+// FormatCommand returns a command as a single line of text for logs and
+// review. Arguments are converted as they are when written to the server and
+// quoted when they are empty or contain spaces, quotes or unprintable
+// characters. Reader arguments are shown as their length and are not read.
+// Credentials in AUTH and HELLO commands are redacted.
+func FormatCommand(cmd string, args []interface{}) string {
+	// Arguments in args[hide:hide+n] are redacted.
+	hide, n := 0, 0
+	switch strings.ToUpper(cmd) {
+	case "AUTH":
+		n = len(args)
+	case "HELLO":
+		for i, arg := range args {
+			if s, ok := arg.(string); ok && strings.EqualFold(s, "AUTH") {
+				hide, n = i+1, 2
+				break
+			}
+		}
+	}
+	var buf bytes.Buffer
+	buf.WriteString(cmd)
+	for i, arg := range args {
+		buf.WriteByte(' ')
+		if i >= hide && i < hide+n {
+			buf.WriteString(redacted)
+		} else {
+			writeQuotedArg(&buf, arg)
+		}
+	}
+	return buf.String()
+}
+
+func writeQuotedArg(buf *bytes.Buffer, arg interface{}) {
+	var p []byte
+	switch arg := arg.(type) {
+	case Argument:
+		writeQuotedArg(buf, arg.RedisArg())
+		return
+	case string:
+		p = []byte(arg)
+	case []byte:
+		p = arg
+	case bool:
+		if arg {
+			p = []byte("1")
+		} else {
+			p = []byte("0")
+		}
+	case nil:
+	case *io.LimitedReader:
+		fmt.Fprintf(buf, "(%d bytes)", arg.N)
+		return
+	case *bytes.Reader:
+		fmt.Fprintf(buf, "(%d bytes)", arg.Len())
+		return
+	case *strings.Reader:
+		fmt.Fprintf(buf, "(%d bytes)", arg.Len())
+		return
+	default:
+		p = []byte(fmt.Sprint(arg))
+	}
+	if len(p) > 0 && utf8.Valid(p) && bytes.IndexFunc(p, func(r rune) bool {
+		return r == ' ' || r == '"' || r == '\'' || r == '\\' || !strconv.IsPrint(r)
+	}) < 0 {
+		buf.Write(p)
+		return
+	}
+	buf.WriteString(strconv.Quote(string(p)))
+}
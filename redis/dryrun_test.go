@@ -0,0 +1,69 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"bytes"
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDryRunConn(t *testing.T) {
+	var buf bytes.Buffer
+	c := redis.NewDryRunConn(&buf)
+
+	if _, err := c.Do("AUTH", "user", "secret"); err != nil {
+		t.Fatalf("Do(AUTH) returned %v", err)
+	}
+	if s, err := redis.String(c.Do("SET", "my key", []byte("v\n"))); err != nil || s != "OK" {
+		t.Errorf("Do(SET) returned %q, %v", s, err)
+	}
+	if _, err := redis.String(c.Do("GET", "k")); err != errNil {
+		t.Errorf("Do(GET) returned error %v, want ErrNil", err)
+	}
+	c.Send("MULTI")
+	c.Send("DEL", "a", 1, true, strings.NewReader("blob"))
+	c.Send("HELLO", 3, "AUTH", "user", "secret", "SETNAME", "x")
+	c.Send("EXEC")
+	c.Flush()
+	for _, expected := range []interface{}{"OK", "QUEUED", "QUEUED", []interface{}{nil, nil}} {
+		reply, err := c.Receive()
+		if err != nil || !reflect.DeepEqual(reply, expected) {
+			t.Errorf("Receive() returned %v, %v, want %v", reply, err, expected)
+		}
+	}
+
+	expected := []string{
+		"AUTH (redacted) (redacted)",
+		`SET "my key" "v\n"`,
+		"GET k",
+		"MULTI",
+		"DEL a 1 1 (4 bytes)",
+		"HELLO 3 AUTH (redacted) (redacted) SETNAME x",
+		"EXEC",
+	}
+	if !reflect.DeepEqual(c.Commands(), expected) {
+		t.Errorf("Commands() = %q, want %q", c.Commands(), expected)
+	}
+	var lines string
+	for _, s := range expected {
+		lines += s + "\n"
+	}
+	if buf.String() != lines {
+		t.Errorf("writer received %q, want %q", buf.String(), lines)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"strconv"
+	"strings"
+)
+
+// The following types are typed views of error replies. Connections return
+// error replies as values of type Error. Use errors.As to get the typed view
+// of an Error:
+//
+//  var moved *redis.MovedError
+//  if errors.As(err, &moved) {
+//      // retry the command on moved.Addr
+//  }
+//
+// Use errors.Is with a zero value of the type to test for the kind of error:
+//
+//  if errors.Is(err, &redis.ReadOnlyError{}) {
+//      // the server is a replica
+//  }
+
+// MovedError is the error returned by a cluster node for a key in a hash
+// slot served by another node.
+type MovedError struct {
+	Slot int
+	Addr string
+	Err  Error
+}
+
+func (err *MovedError) Error() string { return string(err.Err) }
+func (err *MovedError) Unwrap() error { return err.Err }
+
+// AskError is the error returned by a cluster node for a key in a hash slot
+// that is migrating to another node. Send ASKING and the command to Addr.
+type AskError struct {
+	Slot int
+	Addr string
+	Err  Error
+}
+
+func (err *AskError) Error() string { return string(err.Err) }
+func (err *AskError) Unwrap() error { return err.Err }
+
+// LoadingError is the error returned by a server loading the dataset.
+type LoadingError struct {
+	Err Error
+}
+
+func (err *LoadingError) Error() string { return string(err.Err) }
+func (err *LoadingError) Unwrap() error { return err.Err }
+
+// ReadOnlyError is the error returned by a replica for a write command.
+type ReadOnlyError struct {
+	Err Error
+}
+
+func (err *ReadOnlyError) Error() string { return string(err.Err) }
+func (err *ReadOnlyError) Unwrap() error { return err.Err }
+
+// WrongTypeError is the error returned for a command against a key holding a
+// value of the wrong type.
+type WrongTypeError struct {
+	Err Error
+}
+
+func (err *WrongTypeError) Error() string { return string(err.Err) }
+func (err *WrongTypeError) Unwrap() error { return err.Err }
+
+// NoScriptError is the error returned by EVALSHA when the script is not
+// loaded.
+type NoScriptError struct {
+	Err Error
+}
+
+func (err *NoScriptError) Error() string { return string(err.Err) }
+func (err *NoScriptError) Unwrap() error { return err.Err }
+
+// kind returns the error code at the start of an error reply, for example
+// "MOVED".
+func (err Error) kind() string {
+	s := string(err)
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// redirect parses the slot and address of a MOVED or ASK error.
+func (err Error) redirect() (int, string, bool) {
+	f := strings.Fields(string(err))
+	if len(f) != 3 {
+		return 0, "", false
+	}
+	slot, e := strconv.Atoi(f[1])
+	if e != nil {
+		return 0, "", false
+	}
+	return slot, f[2], true
+}
+
+// As implements the interface used by errors.As to convert an error reply to
+// a typed error.
+func (err Error) As(target interface{}) bool {
+	switch target := target.(type) {
+	case **MovedError:
+		if err.kind() == "MOVED" {
+			if slot, addr, ok := err.redirect(); ok {
+				*target = &MovedError{Slot: slot, Addr: addr, Err: err}
+				return true
+			}
+		}
+	case **AskError:
+		if err.kind() == "ASK" {
+			if slot, addr, ok := err.redirect(); ok {
+				*target = &AskError{Slot: slot, Addr: addr, Err: err}
+				return true
+			}
+		}
+	case **LoadingError:
+		if err.kind() == "LOADING" {
+			*target = &LoadingError{Err: err}
+			return true
+		}
+	case **ReadOnlyError:
+		if err.kind() == "READONLY" {
+			*target = &ReadOnlyError{Err: err}
+			return true
+		}
+	case **WrongTypeError:
+		if err.kind() == "WRONGTYPE" {
+			*target = &WrongTypeError{Err: err}
+			return true
+		}
+	case **NoScriptError:
+		if err.kind() == "NOSCRIPT" {
+			*target = &NoScriptError{Err: err}
+			return true
+		}
+	}
+	return false
+}
+
+// Is implements the interface used by errors.Is to test an error reply for
+// the kind of error represented by a typed error.
+func (err Error) Is(target error) bool {
+	switch target.(type) {
+	case *MovedError:
+		return err.kind() == "MOVED"
+	case *AskError:
+		return err.kind() == "ASK"
+	case *LoadingError:
+		return err.kind() == "LOADING"
+	case *ReadOnlyError:
+		return err.kind() == "READONLY"
+	case *WrongTypeError:
+		return err.kind() == "WRONGTYPE"
+	case *NoScriptError:
+		return err.kind() == "NOSCRIPT"
+	}
+	return false
+}
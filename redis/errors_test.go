@@ -0,0 +1,74 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"testing"
+)
+
+func TestTypedErrors(t *testing.T) {
+	l := serveFake(t, func(args []string) string { return "-MOVED 3999 127.0.0.1:6381\r\n" })
+	defer l.Close()
+	c, err := redis.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+	_, err = c.Do("GET", "k")
+
+	var moved *redis.MovedError
+	if !errors.As(fmt.Errorf("wrapped: %w", err), &moved) {
+		t.Fatalf("errors.As(%v, *MovedError) returned false", err)
+	}
+	if moved.Slot != 3999 || moved.Addr != "127.0.0.1:6381" || moved.Error() != err.Error() {
+		t.Errorf("MovedError = %+v", moved)
+	}
+	if !errors.Is(moved, &redis.MovedError{}) || !errors.Is(moved, err) {
+		t.Errorf("errors.Is(MovedError) returned false")
+	}
+
+	var ask *redis.AskError
+	if !errors.As(redis.Error("ASK 12 10.0.0.1:7000"), &ask) || ask.Slot != 12 || ask.Addr != "10.0.0.1:7000" {
+		t.Errorf("errors.As(ASK) returned %+v", ask)
+	}
+	if errors.As(redis.Error("MOVED bad"), &moved) {
+		t.Errorf("errors.As(malformed MOVED) returned true")
+	}
+
+	tests := []struct {
+		err    redis.Error
+		target error
+	}{
+		{"LOADING Redis is loading the dataset in memory", &redis.LoadingError{}},
+		{"READONLY You can't write against a read only replica.", &redis.ReadOnlyError{}},
+		{"WRONGTYPE Operation against a key holding the wrong kind of value", &redis.WrongTypeError{}},
+		{"NOSCRIPT No matching script. Please use EVAL.", &redis.NoScriptError{}},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.target) {
+			t.Errorf("errors.Is(%q, %T) returned false", tt.err, tt.target)
+		}
+		if errors.Is(redis.Error("ERR other"), tt.target) {
+			t.Errorf("errors.Is(ERR, %T) returned true", tt.target)
+		}
+	}
+	var ro *redis.ReadOnlyError
+	if !errors.As(tests[1].err, &ro) || ro.Err != tests[1].err {
+		t.Errorf("errors.As(READONLY) returned %+v", ro)
+	}
+}
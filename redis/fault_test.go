@@ -0,0 +1,131 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// closeReply is returned by a serveFake handler to close the connection
+// without replying.
+const closeReply = "\x00close"
+
+// fault is a scripted reply injected by injectFaults.
+type fault struct {
+	// Reply is the raw reply. If Reply is "", then the command is passed to
+	// the wrapped handler.
+	Reply string
+
+	// Delay is the time to wait before replying.
+	Delay time.Duration
+}
+
+var (
+	faultLoading = fault{Reply: "-LOADING Redis is loading the dataset in memory\r\n"}
+	faultOOM     = fault{Reply: "-OOM command not allowed when used memory > 'maxmemory'.\r\n"}
+	faultClose   = fault{Reply: closeReply}
+)
+
+func faultMoved(slot int, addr string) fault {
+	return fault{Reply: fmt.Sprintf("-MOVED %d %s\r\n", slot, addr)}
+}
+
+func faultAsk(slot int, addr string) fault {
+	return fault{Reply: fmt.Sprintf("-ASK %d %s\r\n", slot, addr)}
+}
+
+// faultScript is a serveFake handler that injects scripted faults for
+// commands. Faults for a command are used in order. Commands without a
+// remaining fault are passed to the wrapped handler.
+type faultScript struct {
+	handle func(args []string) string
+
+	mu     sync.Mutex
+	faults map[string][]fault
+	seen   []string
+}
+
+func newFaultScript(handle func(args []string) string) *faultScript {
+	return &faultScript{handle: handle, faults: make(map[string][]fault)}
+}
+
+// Add appends faults for the command name.
+func (s *faultScript) Add(commandName string, faults ...fault) *faultScript {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := strings.ToUpper(commandName)
+	s.faults[name] = append(s.faults[name], faults...)
+	return s
+}
+
+// Seen returns the commands received by the server.
+func (s *faultScript) Seen() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.seen...)
+}
+
+func (s *faultScript) Handle(args []string) string {
+	name := strings.ToUpper(args[0])
+	s.mu.Lock()
+	s.seen = append(s.seen, strings.Join(args, " "))
+	var f fault
+	if faults := s.faults[name]; len(faults) > 0 {
+		f = faults[0]
+		s.faults[name] = faults[1:]
+	}
+	s.mu.Unlock()
+	time.Sleep(f.Delay)
+	if f.Reply != "" {
+		return f.Reply
+	}
+	return s.handle(args)
+}
+
+func TestFaultScript(t *testing.T) {
+	s := newFaultScript(func(args []string) string { return "+OK\r\n" }).
+		Add("GET", faultMoved(3999, "127.0.0.1:6381"), faultAsk(3999, "127.0.0.1:6382"), fault{Delay: 20 * time.Millisecond}).
+		Add("SET", faultLoading, faultLoading, faultOOM).
+		Add("PING", faultClose)
+	l := serveFake(t, s.Handle)
+	defer l.Close()
+
+	c, err := redis.Dial("tcp", l.Addr().String(), redis.DialRetryLoading(time.Second))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	for _, expected := range []string{"MOVED 3999 127.0.0.1:6381", "ASK 3999 127.0.0.1:6382", ""} {
+		_, err := c.Do("GET", "k")
+		if expected == "" && err != nil || expected != "" && (err == nil || err.Error() != expected) {
+			t.Errorf("GET returned error %v, want %q", err, expected)
+		}
+	}
+	if _, err := c.Do("SET", "k", "v"); err == nil || !strings.HasPrefix(err.Error(), "OOM ") {
+		t.Errorf("SET returned %v, want OOM error after LOADING retries", err)
+	}
+	if _, err := c.Do("PING"); err == nil || c.Err() == nil {
+		t.Errorf("PING on closed connection returned %v, Err() %v", err, c.Err())
+	}
+	if n := len(s.Seen()); n != 7 {
+		t.Errorf("server received %d commands, want 7", n)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+)
+
+// defaultGuardedCommands is the set of commands guarded by a Guard with nil
+// Commands.
+var defaultGuardedCommands = map[string]bool{
+	"CONFIG SET":     true,
+	"DEBUG":          true,
+	"FLUSHALL":       true,
+	"FLUSHDB":        true,
+	"FUNCTION FLUSH": true,
+	"REPLICAOF":      true,
+	"SCRIPT FLUSH":   true,
+	"SHUTDOWN":       true,
+	"SLAVEOF":        true,
+	"SWAPDB":         true,
+}
+
+// Guard blocks dangerous commands unless the context of the call carries
+// the confirmation token. Set the Guard field of a Pool to guard the
+// connections dialed by the pool. Pass the token to DoContext with
+// WithConfirmation:
+//
+//  ctx := redis.WithConfirmation(ctx, token)
+//  _, err := redis.DoContext(c, ctx, "FLUSHDB")
+//
+// Commands executed without a context, for example with Do, are blocked. The
+// guard is a safety interlock for production environments. The guard is
+// independent of the server's ACL rules.
+type Guard struct {
+	// Commands is the set of upper case commands and subcommands that
+	// require confirmation. Subcommands are specified as the command and
+	// subcommand separated by a space. If Commands is nil, then FLUSHDB,
+	// FLUSHALL, CONFIG SET, SHUTDOWN and other commands that destroy data or
+	// change the server configuration are guarded.
+	Commands map[string]bool
+
+	// Token is the confirmation token. If Token is empty, then the guarded
+	// commands are always blocked.
+	Token string
+}
+
+// ConfirmationRequiredError is returned for a command blocked by a Guard.
+type ConfirmationRequiredError struct {
+	Command string
+}
+
+func (err *ConfirmationRequiredError) Error() string {
+	return "redigo: command " + err.Command + " requires confirmation"
+}
+
+type confirmationKey struct{}
+
+// WithConfirmation returns a copy of ctx with the confirmation token for a
+// Guard.
+func WithConfirmation(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, confirmationKey{}, token)
+}
+
+// check returns an error if the command is guarded and ctx does not carry
+// the token.
+func (g *Guard) check(ctx context.Context, commandName string, args []interface{}) error {
+	commands := g.Commands
+	if commands == nil {
+		commands = defaultGuardedCommands
+	}
+	cmd := strings.ToUpper(commandName)
+	if !commands[cmd] && len(args) > 0 {
+		if sub, ok := keyArg(args[0]); ok {
+			cmd += " " + strings.ToUpper(sub)
+		}
+	}
+	if !commands[cmd] {
+		return nil
+	}
+	if ctx != nil && g.Token != "" {
+		if token, _ := ctx.Value(confirmationKey{}).(string); token == g.Token {
+			return nil
+		}
+	}
+	return &ConfirmationRequiredError{Command: cmd}
+}
+
+type guardConn struct {
+	connWrapper
+	g   *Guard
+	ctx context.Context
+}
+
+func (c *guardConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if err := c.g.check(c.ctx, commandName, args); err != nil {
+		return nil, err
+	}
+	return c.Conn.Do(commandName, args...)
+}
+
+func (c *guardConn) Send(commandName string, args ...interface{}) error {
+	if err := c.g.check(c.ctx, commandName, args); err != nil {
+		return err
+	}
+	return c.Conn.Send(commandName, args...)
+}
+
+func (c *guardConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	if err := c.g.check(c.ctx, commandName, args); err != nil {
+		return nil, err
+	}
+	return DoWithTimeout(c.Conn, timeout, commandName, args...)
+}
+
+func (c *guardConn) doTo(w io.Writer, commandName string, args []interface{}) (int64, error) {
+	if err := c.g.check(c.ctx, commandName, args); err != nil {
+		return 0, err
+	}
+	return DoTo(c.Conn, w, commandName, args...)
+}
+
+func (c *guardConn) withContext(ctx context.Context, f func() error) error {
+	c.ctx = ctx
+	defer func() { c.ctx = nil }()
+	return withContext(c.Conn, ctx, f)
+}
+
+func (c *guardConn) rawConn() (*RawConn, error) {
+	return nil, errRawChecked
+}
@@ -0,0 +1,68 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"context"
+	"errors"
+	"github.com/garyburd/redigo/redis"
+	"testing"
+)
+
+func TestGuard(t *testing.T) {
+	l := serveFake(t, func(args []string) string {
+		return "+OK\r\n"
+	})
+	defer l.Close()
+	p := &redis.Pool{
+		Network: "tcp",
+		Address: l.Addr().String(),
+		Guard:   &redis.Guard{Token: "yes-really"},
+	}
+	defer p.Close()
+	c := p.Get()
+	defer c.Close()
+
+	ctx := context.Background()
+	for _, tt := range []struct {
+		ctx     context.Context
+		command string
+		args    []interface{}
+		blocked bool
+	}{
+		{nil, "flushdb", nil, true},
+		{ctx, "FLUSHDB", nil, true},
+		{redis.WithConfirmation(ctx, "no"), "FLUSHDB", nil, true},
+		{redis.WithConfirmation(ctx, "yes-really"), "FLUSHDB", nil, false},
+		{ctx, "CONFIG", []interface{}{"set", "maxmemory", "1"}, true},
+		{ctx, "CONFIG", []interface{}{[]byte("SET"), "maxmemory", "1"}, true},
+		{ctx, "CONFIG", []interface{}{"GET", "maxmemory"}, false},
+		{nil, "SET", []interface{}{"k", "v"}, false},
+	} {
+		var err error
+		if tt.ctx == nil {
+			_, err = c.Do(tt.command, tt.args...)
+		} else {
+			_, err = redis.DoContext(c, tt.ctx, tt.command, tt.args...)
+		}
+		var e *redis.ConfirmationRequiredError
+		if blocked := errors.As(err, &e); blocked != tt.blocked || (!blocked && err != nil) {
+			t.Errorf("%s %v returned %v, blocked = %v, want %v", tt.command, tt.args, err, blocked, tt.blocked)
+		}
+	}
+	if err := c.Send("SHUTDOWN"); err == nil {
+		t.Errorf("Send(SHUTDOWN) did not return error")
+	}
+}
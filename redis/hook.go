@@ -0,0 +1,174 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"io"
+	"time"
+)
+
+// Hook is called by a connection before a command is sent and after the
+// reply to the command is received. Hooks are used to add logging, metrics
+// and tracing to connections. The hook methods must not modify the command
+// arguments or the reply.
+type Hook interface {
+	// BeforeSend is called before the command is written to the connection.
+	BeforeSend(commandName string, args []interface{})
+
+	// AfterReceive is called with the reply to the command and the time
+	// elapsed since the call to BeforeSend.
+	AfterReceive(commandName string, args []interface{}, reply interface{}, elapsed time.Duration)
+
+	// OnError is called in place of AfterReceive when the command fails or
+	// the server replies with an error.
+	OnError(commandName string, args []interface{}, err error, elapsed time.Duration)
+}
+
+// DialHook specifies a hook for the commands executed on the connection. The
+// connection returned by Dial is wrapped with NewHookConn.
+func DialHook(h Hook) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.hook = h
+	}}
+}
+
+// NewHookConn returns a connection that calls h for each command executed on
+// conn. Replies to commands pipelined with Send are reported when received
+// with Receive or Do(""). A call to Do with a command name reports the
+// pipelined commands with a nil reply, or with the error if the connection
+// fails, and then reports the command.
+func NewHookConn(conn Conn, h Hook) Conn {
+	return &hookConn{connWrapper: connWrapper{conn}, h: h}
+}
+
+type hookCommand struct {
+	name  string
+	args  []interface{}
+	start time.Time
+}
+
+type hookConn struct {
+	connWrapper
+	h       Hook
+	pending []hookCommand
+}
+
+func (c *hookConn) report(cmd hookCommand, reply interface{}, err error) {
+	if cmd.name == "" {
+		return
+	}
+	elapsed := time.Since(cmd.start)
+	if err != nil {
+		c.h.OnError(cmd.name, cmd.args, err, elapsed)
+	} else {
+		c.h.AfterReceive(cmd.name, cmd.args, reply, elapsed)
+	}
+}
+
+func (c *hookConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return c.do(commandName, args, func() (interface{}, error) {
+		return c.Conn.Do(commandName, args...)
+	})
+}
+
+func (c *hookConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	return c.do(commandName, args, func() (interface{}, error) {
+		return DoWithTimeout(c.Conn, timeout, commandName, args...)
+	})
+}
+
+func (c *hookConn) doTo(w io.Writer, commandName string, args []interface{}) (int64, error) {
+	var n int64
+	_, err := c.do(commandName, args, func() (interface{}, error) {
+		var err error
+		n, err = DoTo(c.Conn, w, commandName, args...)
+		return nil, err
+	})
+	return n, err
+}
+
+// do calls f to execute the command and reports the command to the hook.
+func (c *hookConn) do(commandName string, args []interface{}, f func() (interface{}, error)) (interface{}, error) {
+	pending := c.pending
+	c.pending = nil
+	if commandName == "" {
+		reply, err := f()
+		replies, _ := reply.([]interface{})
+		for i, cmd := range pending {
+			if err != nil {
+				c.report(cmd, nil, err)
+			} else if i < len(replies) {
+				if e, ok := replies[i].(Error); ok {
+					c.report(cmd, nil, e)
+				} else {
+					c.report(cmd, replies[i], nil)
+				}
+			}
+		}
+		return reply, err
+	}
+	cmd := hookCommand{commandName, args, time.Now()}
+	c.h.BeforeSend(commandName, args)
+	reply, err := f()
+	// The replies to the pipelined commands are discarded by Do. Report
+	// the pipelined commands with a nil reply or with the connection error.
+	var pendingErr error
+	if _, ok := err.(Error); err != nil && !ok {
+		pendingErr = err
+	}
+	for _, p := range pending {
+		c.report(p, nil, pendingErr)
+	}
+	c.report(cmd, reply, err)
+	return reply, err
+}
+
+func (c *hookConn) Send(commandName string, args ...interface{}) error {
+	cmd := hookCommand{commandName, args, time.Now()}
+	c.h.BeforeSend(commandName, args)
+	if err := c.Conn.Send(commandName, args...); err != nil {
+		c.report(cmd, nil, err)
+		return err
+	}
+	c.pending = append(c.pending, cmd)
+	return nil
+}
+
+func (c *hookConn) next() hookCommand {
+	var cmd hookCommand
+	if len(c.pending) > 0 {
+		cmd = c.pending[0]
+		c.pending = c.pending[1:]
+	}
+	return cmd
+}
+
+func (c *hookConn) Receive() (interface{}, error) {
+	reply, err := c.Conn.Receive()
+	c.report(c.next(), reply, err)
+	return reply, err
+}
+
+func (c *hookConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	reply, err := ReceiveWithTimeout(c.Conn, timeout)
+	c.report(c.next(), reply, err)
+	return reply, err
+}
+
+func (c *hookConn) receiveTo(w io.Writer) (int64, error) {
+	n, err := ReceiveTo(c.Conn, w)
+	c.report(c.next(), nil, err)
+	return n, err
+}
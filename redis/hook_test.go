@@ -0,0 +1,96 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingHook struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (h *recordingHook) add(format string, args ...interface{}) {
+	h.mu.Lock()
+	h.events = append(h.events, fmt.Sprintf(format, args...))
+	h.mu.Unlock()
+}
+
+func (h *recordingHook) BeforeSend(commandName string, args []interface{}) {
+	h.add("send %s %v", commandName, args)
+}
+
+func (h *recordingHook) AfterReceive(commandName string, args []interface{}, reply interface{}, elapsed time.Duration) {
+	h.add("reply %s %v", commandName, reply)
+}
+
+func (h *recordingHook) OnError(commandName string, args []interface{}, err error, elapsed time.Duration) {
+	h.add("error %s %v", commandName, err)
+}
+
+func TestHook(t *testing.T) {
+	l := serveFake(t, func(args []string) string {
+		if args[0] == "BAD" {
+			return "-ERR bad\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer l.Close()
+
+	h := &recordingHook{}
+	c, err := redis.Dial("tcp", l.Addr().String(), redis.DialHook(h))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	c.Do("SET", "k", "v")
+	c.Do("BAD")
+	c.Send("SET", "a", 1)
+	c.Send("BAD")
+	c.Do("")
+	c.Send("GET", "a")
+	c.Flush()
+	c.Receive()
+	c.Send("DEL", "a")
+	c.Do("GET", "a")
+	c.Close()
+
+	expected := []string{
+		"send SET [k v]", "reply SET OK",
+		"send BAD []", "error BAD ERR bad",
+		"send SET [a 1]", "send BAD []", "reply SET OK", "error BAD ERR bad",
+		"send GET [a]", "reply GET OK",
+		"send DEL [a]", "send GET [a]", "reply DEL <nil>", "reply GET OK",
+	}
+	if !reflect.DeepEqual(h.events, expected) {
+		t.Errorf("hook events = %q, want %q", h.events, expected)
+	}
+
+	h = &recordingHook{}
+	p := &redis.Pool{Network: "tcp", Address: l.Addr().String(), Hook: h}
+	defer p.Close()
+	pc := p.Get()
+	pc.Do("PING")
+	pc.Close()
+	expected = []string{"send PING []", "reply PING OK"}
+	if !reflect.DeepEqual(h.events, expected) {
+		t.Errorf("pool hook events = %q, want %q", h.events, expected)
+	}
+}
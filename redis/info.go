@@ -0,0 +1,285 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Info is the parsed reply from the INFO command. Info maps lower case
+// section names to the fields in the section.
+type Info map[string]map[string]string
+
+// ParseInfo is a helper that parses the bulk reply from the INFO command. If
+// err is not equal to nil, then ParseInfo returns nil, err. Fields before the
+// first section header are stored in the section "".
+func ParseInfo(reply interface{}, err error) (Info, error) {
+	s, err := String(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	info := make(Info)
+	section := ""
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			// ignore
+		case line[0] == '#':
+			section = strings.ToLower(strings.TrimSpace(line[1:]))
+		default:
+			i := strings.IndexByte(line, ':')
+			if i <= 0 {
+				continue
+			}
+			fields := info[section]
+			if fields == nil {
+				fields = make(map[string]string)
+				info[section] = fields
+			}
+			fields[line[:i]] = line[i+1:]
+		}
+	}
+	return info, nil
+}
+
+// Get returns the value of the field in any section or "" if the field is
+// not found.
+func (info Info) Get(field string) string {
+	for _, fields := range info {
+		if v, ok := fields[field]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// Int64 returns the integer value of the field in any section. Int64 returns
+// false if the field is not found or if the value is not an integer.
+func (info Info) Int64(field string) (int64, bool) {
+	n, err := strconv.ParseInt(info.Get(field), 10, 64)
+	return n, err == nil
+}
+
+// UsedMemory returns the used_memory field or -1 if the field is not found.
+func (info Info) UsedMemory() int64 {
+	if n, ok := info.Int64("used_memory"); ok {
+		return n
+	}
+	return -1
+}
+
+// ConnectedClients returns the connected_clients field or -1 if the field is
+// not found.
+func (info Info) ConnectedClients() int {
+	if n, ok := info.Int64("connected_clients"); ok {
+		return int(n)
+	}
+	return -1
+}
+
+// Role returns the role field, "master" or "slave".
+func (info Info) Role() string {
+	return info.Get("role")
+}
+
+// MasterLinkStatus returns the master_link_status field of a replica, "up" or
+// "down".
+func (info Info) MasterLinkStatus() string {
+	return info.Get("master_link_status")
+}
+
+// Config is the parsed reply from the CONFIG GET command. Config maps
+// parameter names to values.
+type Config map[string]string
+
+// ParseConfig is a helper that parses the reply from CONFIG GET. If err is not
+// equal to nil, then ParseConfig returns nil, err.
+func ParseConfig(reply interface{}, err error) (Config, error) {
+	m, err := StringMap(reply, err)
+	return Config(m), err
+}
+
+// Int64 returns the integer value of the parameter. Int64 returns false if the
+// parameter is not found or if the value is not an integer.
+func (c Config) Int64(name string) (int64, bool) {
+	n, err := strconv.ParseInt(c[name], 10, 64)
+	return n, err == nil
+}
+
+// Bool returns the value of a "yes" or "no" parameter. Bool returns false
+// for the second result if the parameter is not found or if the value is
+// not "yes" or "no".
+func (c Config) Bool(name string) (value bool, ok bool) {
+	switch c[name] {
+	case "yes":
+		return true, true
+	case "no":
+		return false, true
+	}
+	return false, false
+}
+
+// ParseMemoryStats is a helper that parses the reply from MEMORY STATS to a
+// map. Integer values are converted to int64, bulk values containing a
+// number are converted to float64, other bulk values are converted to string
+// and nested replies such as the per database statistics are converted to
+// maps. If err is not equal to nil, then ParseMemoryStats returns nil, err.
+func ParseMemoryStats(reply interface{}, err error) (map[string]interface{}, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	return memoryStatsMap(values)
+}
+
+func memoryStatsMap(values []interface{}) (map[string]interface{}, error) {
+	if len(values)%2 != 0 {
+		return nil, errors.New("redigo: ParseMemoryStats expects even number of values in reply")
+	}
+	m := make(map[string]interface{}, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		key, err := String(values[i], nil)
+		if err != nil {
+			return nil, err
+		}
+		switch v := values[i+1].(type) {
+		case []byte:
+			if f, err := strconv.ParseFloat(string(v), 64); err == nil {
+				m[key] = f
+			} else {
+				m[key] = string(v)
+			}
+		case []interface{}:
+			nested, err := memoryStatsMap(v)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = nested
+		case Error:
+			return nil, v
+		default:
+			if p, ok := mapPairs(v); ok {
+				nested, err := memoryStatsMap(p)
+				if err != nil {
+					return nil, err
+				}
+				m[key] = nested
+			} else {
+				m[key] = v
+			}
+		}
+	}
+	return m, nil
+}
+
+// Role kinds in the reply from the ROLE command.
+const (
+	RoleMaster   = "master"
+	RoleReplica  = "slave"
+	RoleSentinel = "sentinel"
+)
+
+// Role is the parsed reply from the ROLE command. The Kind field specifies
+// which of the remaining fields are set.
+type Role struct {
+	// Kind is RoleMaster, RoleReplica or RoleSentinel.
+	Kind string
+
+	// ReplicationOffset is the replication offset of a master or the offset
+	// of the data received by a replica.
+	ReplicationOffset int64
+
+	// Replicas connected to a master.
+	Replicas []ReplicaInfo
+
+	// MasterHost, MasterPort and LinkState describe the master of a replica.
+	// LinkState is "connect", "connecting", "sync" or "connected".
+	MasterHost string
+	MasterPort int
+	LinkState  string
+
+	// Masters monitored by a sentinel.
+	Masters []string
+}
+
+// ReplicaInfo is a replica in the ROLE reply from a master.
+type ReplicaInfo struct {
+	Host              string
+	Port              int
+	ReplicationOffset int64
+}
+
+// ParseRole is a helper that parses the reply from the ROLE command. If err
+// is not equal to nil, then ParseRole returns nil, err.
+func ParseRole(reply interface{}, err error) (*Role, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, errors.New("redigo: ParseRole expects non-empty reply")
+	}
+	kind, err := String(values[0], nil)
+	if err != nil {
+		return nil, err
+	}
+	r := &Role{Kind: kind}
+	switch kind {
+	case RoleMaster:
+		if len(values) < 3 {
+			return nil, errors.New("redigo: ParseRole expects 3 values in master reply")
+		}
+		if r.ReplicationOffset, err = Int64(values[1], nil); err != nil {
+			return nil, err
+		}
+		replicas, err := Values(values[2], nil)
+		if err != nil {
+			return nil, err
+		}
+		r.Replicas = make([]ReplicaInfo, len(replicas))
+		for i, v := range replicas {
+			fields, err := Values(v, nil)
+			if err != nil {
+				return nil, err
+			}
+			rr := &r.Replicas[i]
+			if _, err := Scan(fields, &rr.Host, &rr.Port, &rr.ReplicationOffset); err != nil {
+				return nil, err
+			}
+		}
+	case RoleReplica:
+		if len(values) < 5 {
+			return nil, errors.New("redigo: ParseRole expects 5 values in replica reply")
+		}
+		if _, err := Scan(values[1:], &r.MasterHost, &r.MasterPort, &r.LinkState, &r.ReplicationOffset); err != nil {
+			return nil, err
+		}
+	case RoleSentinel:
+		if len(values) < 2 {
+			return nil, errors.New("redigo: ParseRole expects 2 values in sentinel reply")
+		}
+		if r.Masters, err = Strings(values[1], nil); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("redigo: ParseRole unexpected role " + kind)
+	}
+	return r, nil
+}
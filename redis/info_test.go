@@ -0,0 +1,126 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"testing"
+)
+
+const infoReply = "# Server\r\nredis_version:7.2.4\r\n\r\n# Clients\r\nconnected_clients:3\r\n\r\n" +
+	"# Memory\r\nused_memory:1024\r\nused_memory_human:1.00K\r\n\r\n" +
+	"# Replication\r\nrole:slave\r\nmaster_link_status:up\r\n"
+
+func TestParseInfo(t *testing.T) {
+	info, err := redis.ParseInfo([]byte(infoReply), nil)
+	if err != nil {
+		t.Fatalf("ParseInfo returned error %v", err)
+	}
+	if v := info["server"]["redis_version"]; v != "7.2.4" {
+		t.Errorf("server.redis_version = %q", v)
+	}
+	if v := info.Get("used_memory_human"); v != "1.00K" {
+		t.Errorf("used_memory_human = %q", v)
+	}
+	if n := info.UsedMemory(); n != 1024 {
+		t.Errorf("UsedMemory() = %d", n)
+	}
+	if n := info.ConnectedClients(); n != 3 {
+		t.Errorf("ConnectedClients() = %d", n)
+	}
+	if r, s := info.Role(), info.MasterLinkStatus(); r != "slave" || s != "up" {
+		t.Errorf("Role(), MasterLinkStatus() = %q, %q", r, s)
+	}
+	if _, ok := info.Int64("missing"); ok {
+		t.Errorf("Int64(missing) returned ok")
+	}
+	if _, err := redis.ParseInfo(nil, nil); err != errNil {
+		t.Errorf("ParseInfo(nil) returned %v", err)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	config, err := redis.ParseConfig([]interface{}{[]byte("maxmemory"), []byte("1048576"), []byte("appendonly"), []byte("yes")}, nil)
+	if err != nil {
+		t.Fatalf("ParseConfig returned error %v", err)
+	}
+	if n, ok := config.Int64("maxmemory"); !ok || n != 1048576 {
+		t.Errorf("Int64(maxmemory) = %d, %v", n, ok)
+	}
+	if b, ok := config.Bool("appendonly"); !ok || !b {
+		t.Errorf("Bool(appendonly) = %v, %v", b, ok)
+	}
+	if _, ok := config.Bool("maxmemory"); ok {
+		t.Errorf("Bool(maxmemory) returned ok")
+	}
+}
+
+func TestParseMemoryStats(t *testing.T) {
+	reply := []interface{}{
+		[]byte("peak.allocated"), int64(1000),
+		[]byte("db.0"), []interface{}{[]byte("overhead.hashtable.main"), int64(72), []byte("overhead.hashtable.expires"), int64(0)},
+		[]byte("peak.percentage"), []byte("99.5"),
+		[]byte("allocator.name"), []byte("jemalloc"),
+	}
+	stats, err := redis.ParseMemoryStats(reply, nil)
+	if err != nil {
+		t.Fatalf("ParseMemoryStats returned error %v", err)
+	}
+	expected := map[string]interface{}{
+		"peak.allocated":  int64(1000),
+		"db.0":            map[string]interface{}{"overhead.hashtable.main": int64(72), "overhead.hashtable.expires": int64(0)},
+		"peak.percentage": 99.5,
+		"allocator.name":  "jemalloc",
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("ParseMemoryStats returned %v, want %v", stats, expected)
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	tests := []struct {
+		reply    interface{}
+		expected *redis.Role
+	}{
+		{
+			[]interface{}{[]byte("master"), int64(3129659), []interface{}{
+				[]interface{}{[]byte("127.0.0.1"), []byte("9001"), []byte("3129242")},
+			}},
+			&redis.Role{Kind: redis.RoleMaster, ReplicationOffset: 3129659, Replicas: []redis.ReplicaInfo{{Host: "127.0.0.1", Port: 9001, ReplicationOffset: 3129242}}},
+		},
+		{
+			[]interface{}{[]byte("slave"), []byte("127.0.0.1"), int64(9000), []byte("connected"), int64(3167038)},
+			&redis.Role{Kind: redis.RoleReplica, MasterHost: "127.0.0.1", MasterPort: 9000, LinkState: "connected", ReplicationOffset: 3167038},
+		},
+		{
+			[]interface{}{[]byte("sentinel"), []interface{}{[]byte("resque-master"), []byte("html-fragments-master")}},
+			&redis.Role{Kind: redis.RoleSentinel, Masters: []string{"resque-master", "html-fragments-master"}},
+		},
+	}
+	for _, tt := range tests {
+		r, err := redis.ParseRole(tt.reply, nil)
+		if err != nil {
+			t.Errorf("ParseRole(%v) returned error %v", tt.reply, err)
+			continue
+		}
+		if !reflect.DeepEqual(r, tt.expected) {
+			t.Errorf("ParseRole(%v) = %+v, want %+v", tt.reply, r, tt.expected)
+		}
+	}
+	if _, err := redis.ParseRole([]interface{}{[]byte("leader")}, nil); err == nil {
+		t.Errorf("ParseRole did not return error for unknown role")
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// KeyTemplate constructs keys from a pattern with "%s" placeholders. A
+// template centralizes the naming of a kind of key, validates the pattern once
+// and computes the cluster slot of the keys.
+//
+//  var users = redis.MustKeyTemplate("app:", "user:{%s}", 1)
+//
+//  key := users.Key(id)   // "app:user:{42}"
+//  slot := users.Slot(id) // slot of the hash tag "42"
+type KeyTemplate struct {
+	namespace string
+
+	// parts are the literal text between placeholders.
+	parts []string
+}
+
+// NewKeyTemplate returns a template for keys with the namespace prefix and
+// pattern. The placeholder "%s" is replaced by an argument and "%%" is a
+// literal percent sign. NewKeyTemplate returns an error if the pattern does
+// not have exactly n placeholders or contains another verb.
+func NewKeyTemplate(namespace, pattern string, n int) (*KeyTemplate, error) {
+	t := &KeyTemplate{namespace: namespace}
+	var part []byte
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' {
+			part = append(part, pattern[i])
+			continue
+		}
+		i++
+		switch {
+		case i == len(pattern):
+			return nil, errors.New("redigo: key pattern " + strconv.Quote(pattern) + " ends with %")
+		case pattern[i] == '%':
+			part = append(part, '%')
+		case pattern[i] == 's':
+			t.parts = append(t.parts, string(part))
+			part = nil
+		default:
+			return nil, errors.New("redigo: key pattern " + strconv.Quote(pattern) + " has unsupported verb %" + string(pattern[i]))
+		}
+	}
+	t.parts = append(t.parts, string(part))
+	if len(t.parts)-1 != n {
+		return nil, fmt.Errorf("redigo: key pattern %q has %d placeholders, want %d", pattern, len(t.parts)-1, n)
+	}
+	return t, nil
+}
+
+// MustKeyTemplate is like NewKeyTemplate, but panics if the pattern is not
+// valid. MustKeyTemplate simplifies the initialization of global variables
+// holding templates.
+func MustKeyTemplate(namespace, pattern string, n int) *KeyTemplate {
+	t, err := NewKeyTemplate(namespace, pattern, n)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Key returns the key for args. Arguments are converted to strings as they
+// are when sent to the server. Key panics if the number of arguments does not
+// match the number of placeholders.
+func (t *KeyTemplate) Key(args ...interface{}) string {
+	if len(args) != len(t.parts)-1 {
+		panic(fmt.Errorf("redigo: KeyTemplate.Key called with %d arguments, want %d", len(args), len(t.parts)-1))
+	}
+	var buf strings.Builder
+	buf.WriteString(t.namespace)
+	buf.WriteString(t.parts[0])
+	for i, arg := range args {
+		if a, ok := arg.(Argument); ok {
+			arg = a.RedisArg()
+		}
+		switch arg := arg.(type) {
+		case string:
+			buf.WriteString(arg)
+		case []byte:
+			buf.Write(arg)
+		case bool:
+			if arg {
+				buf.WriteByte('1')
+			} else {
+				buf.WriteByte('0')
+			}
+		case nil:
+		default:
+			fmt.Fprint(&buf, arg)
+		}
+		buf.WriteString(t.parts[i+1])
+	}
+	return buf.String()
+}
+
+// Slot returns the cluster slot of the key for args.
+func (t *KeyTemplate) Slot(args ...interface{}) int {
+	return Slot(t.Key(args...))
+}
+
+// Match returns a SCAN MATCH pattern for the keys constructed by the
+// template.
+func (t *KeyTemplate) Match() string {
+	var buf strings.Builder
+	writeGlobLiteral(&buf, t.namespace)
+	for i, part := range t.parts {
+		if i > 0 {
+			buf.WriteByte('*')
+		}
+		writeGlobLiteral(&buf, part)
+	}
+	return buf.String()
+}
+
+func writeGlobLiteral(buf *strings.Builder, s string) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', ']', '\\':
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(s[i])
+	}
+}
+
+// HashTag returns the hash tag of key or key if key does not have a hash tag.
+// The hash tag is the text between the first "{" and the following "}" when
+// the text is not empty. Keys with the same hash tag are stored in the same
+// cluster slot.
+func HashTag(key string) string {
+	if i := strings.IndexByte(key, '{'); i >= 0 {
+		if j := strings.IndexByte(key[i+1:], '}'); j > 0 {
+			return key[i+1 : i+1+j]
+		}
+	}
+	return key
+}
+
+// Slot returns the cluster slot of key.
+func Slot(key string) int {
+	return int(crc16(HashTag(key)) % 16384)
+}
+
+// crc16 computes the CRC16-XMODEM checksum used by Redis Cluster.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
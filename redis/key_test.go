@@ -0,0 +1,74 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"testing"
+)
+
+func TestKeyTemplate(t *testing.T) {
+	users := redis.MustKeyTemplate("app:", "user:{%s}:%s:100%%", 2)
+	if key := users.Key(42, "profile"); key != "app:user:{42}:profile:100%" {
+		t.Errorf("Key() = %q", key)
+	}
+	if slot, want := users.Slot(42, "x"), redis.Slot("42"); slot != want {
+		t.Errorf("Slot() = %d, want %d", slot, want)
+	}
+	if m := users.Match(); m != `app:user:{*}:*:100%` {
+		t.Errorf("Match() = %q", m)
+	}
+
+	for _, tt := range []struct {
+		pattern string
+		n       int
+	}{
+		{"user:%s", 2},
+		{"user:%d", 1},
+		{"user:%", 0},
+	} {
+		if _, err := redis.NewKeyTemplate("", tt.pattern, tt.n); err == nil {
+			t.Errorf("NewKeyTemplate(%q, %d) did not return error", tt.pattern, tt.n)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Key with wrong number of arguments did not panic")
+		}
+	}()
+	users.Key(1)
+}
+
+var slotTests = []struct {
+	key  string
+	slot int
+}{
+	{"", 0},
+	{"123456789", 12739},
+	{"foo", 12182},
+	{"{user1000}.following", 3443},
+	{"{user1000}.followers", 3443},
+	{"foo{}{bar}", 8363},
+	{"foo{{bar}}zap", 4015},
+}
+
+func TestSlot(t *testing.T) {
+	for _, tt := range slotTests {
+		if slot := redis.Slot(tt.key); slot != tt.slot {
+			t.Errorf("Slot(%q) = %d, want %d", tt.key, slot, tt.slot)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// commandKeyTypes maps commands to the type of the key in the first argument.
+var commandKeyTypes = map[string]string{
+	"APPEND":           "string",
+	"DECR":             "string",
+	"DECRBY":           "string",
+	"GET":              "string",
+	"GETDEL":           "string",
+	"GETEX":            "string",
+	"GETRANGE":         "string",
+	"GETSET":           "string",
+	"INCR":             "string",
+	"INCRBY":           "string",
+	"INCRBYFLOAT":      "string",
+	"PSETEX":           "string",
+	"SET":              "string",
+	"SETEX":            "string",
+	"SETNX":            "string",
+	"SETRANGE":         "string",
+	"STRLEN":           "string",
+	"HDEL":             "hash",
+	"HEXISTS":          "hash",
+	"HGET":             "hash",
+	"HGETALL":          "hash",
+	"HINCRBY":          "hash",
+	"HINCRBYFLOAT":     "hash",
+	"HKEYS":            "hash",
+	"HLEN":             "hash",
+	"HMGET":            "hash",
+	"HMSET":            "hash",
+	"HRANDFIELD":       "hash",
+	"HSCAN":            "hash",
+	"HSET":             "hash",
+	"HSETNX":           "hash",
+	"HSTRLEN":          "hash",
+	"HVALS":            "hash",
+	"LINDEX":           "list",
+	"LINSERT":          "list",
+	"LLEN":             "list",
+	"LPOP":             "list",
+	"LPUSH":            "list",
+	"LRANGE":           "list",
+	"LREM":             "list",
+	"LSET":             "list",
+	"LTRIM":            "list",
+	"RPOP":             "list",
+	"RPUSH":            "list",
+	"SADD":             "set",
+	"SCARD":            "set",
+	"SISMEMBER":        "set",
+	"SMEMBERS":         "set",
+	"SMISMEMBER":       "set",
+	"SPOP":             "set",
+	"SRANDMEMBER":      "set",
+	"SREM":             "set",
+	"SSCAN":            "set",
+	"ZADD":             "zset",
+	"ZCARD":            "zset",
+	"ZCOUNT":           "zset",
+	"ZINCRBY":          "zset",
+	"ZMSCORE":          "zset",
+	"ZPOPMAX":          "zset",
+	"ZPOPMIN":          "zset",
+	"ZRANGE":           "zset",
+	"ZRANGEBYSCORE":    "zset",
+	"ZRANK":            "zset",
+	"ZREM":             "zset",
+	"ZREMRANGEBYRANK":  "zset",
+	"ZREMRANGEBYSCORE": "zset",
+	"ZREVRANGE":        "zset",
+	"ZREVRANK":         "zset",
+	"ZSCAN":            "zset",
+	"ZSCORE":           "zset",
+}
+
+// keyTypes records the types of keys declared by the typed key accessors in
+// debug builds.
+var keyTypes struct {
+	mu sync.Mutex
+	m  map[string]string
+}
+
+// declareKeyType records the type of key and returns an error if the key was
+// declared with a different type.
+func declareKeyType(key, typ string) error {
+	if !debugKeyTypes {
+		return nil
+	}
+	keyTypes.mu.Lock()
+	defer keyTypes.mu.Unlock()
+	if keyTypes.m == nil {
+		keyTypes.m = make(map[string]string)
+	}
+	if declared, ok := keyTypes.m[key]; ok && declared != typ {
+		return keyTypeError(typ+" accessor", key, declared)
+	}
+	keyTypes.m[key] = typ
+	return nil
+}
+
+// checkKeyType returns an error if the command operates on a key of a type
+// other than the declared type of the key in the first argument.
+func checkKeyType(commandName string, args []interface{}) error {
+	if !debugKeyTypes || len(args) == 0 {
+		return nil
+	}
+	commandName = strings.ToUpper(commandName)
+	typ, ok := commandKeyTypes[commandName]
+	if !ok {
+		return nil
+	}
+	key, ok := keyArg(args[0])
+	if !ok {
+		return nil
+	}
+	keyTypes.mu.Lock()
+	defer keyTypes.mu.Unlock()
+	if declared, ok := keyTypes.m[key]; ok && declared != typ {
+		return keyTypeError(commandName, key, declared)
+	}
+	return nil
+}
+
+func keyArg(arg interface{}) (string, bool) {
+	switch arg := arg.(type) {
+	case string:
+		return arg, true
+	case []byte:
+		return string(arg), true
+	}
+	return "", false
+}
+
+func keyTypeError(use, key, declared string) error {
+	return errors.New("redigo: " + use + " used on key " + key + " declared as " + declared)
+}
+
+// ResetKeyTypes forgets the key types declared by the typed key accessors.
+// In builds with the redigo_debug build tag, the typed key accessors declare
+// the type of their keys and connections return an error for commands that
+// operate on a declared key as a different type. Tests that reuse key names
+// for different types call ResetKeyTypes between cases. ResetKeyTypes does
+// nothing in other builds.
+func ResetKeyTypes() {
+	if !debugKeyTypes {
+		return
+	}
+	keyTypes.mu.Lock()
+	keyTypes.m = nil
+	keyTypes.mu.Unlock()
+}
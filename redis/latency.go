@@ -0,0 +1,268 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// latencySlots is the number of slots in the rolling window.
+	latencySlots = 10
+
+	// latencyBins is the number of histogram bins. Bin i counts latencies
+	// less than 2^i microseconds and at least 2^(i-1) microseconds.
+	latencyBins = 32
+)
+
+// LatencyStats is a snapshot of the commands in a family over the rolling
+// window of a LatencyHook. Percentiles are approximated by the upper bound of
+// the histogram bin containing the percentile. The bins are powers of two
+// microseconds.
+type LatencyStats struct {
+	Family    string
+	Count     int64
+	Errors    int64
+	ErrorRate float64
+	P50       time.Duration
+	P90       time.Duration
+	P99       time.Duration
+}
+
+// LatencyThreshold is a condition on the statistics of a command family. The
+// threshold trips when the 99th percentile latency exceeds P99 or the error
+// rate exceeds ErrorRate. The threshold resets when neither limit is
+// exceeded.
+type LatencyThreshold struct {
+	// Family is the command family checked by the threshold. If Family is "",
+	// then the threshold is checked for all families.
+	Family string
+
+	// P99 is the limit for the 99th percentile latency. Zero disables the
+	// latency limit.
+	P99 time.Duration
+
+	// ErrorRate is the limit for the fraction of commands that fail. Zero
+	// disables the error rate limit.
+	ErrorRate float64
+
+	// MinCount is the number of commands in the window required to check the
+	// threshold. The default is 10.
+	MinCount int64
+
+	// OnChange is called when the threshold trips or resets. OnChange is
+	// called without holding locks in the hook, but may be called
+	// concurrently from the goroutines executing commands.
+	OnChange func(stats LatencyStats, tripped bool)
+}
+
+// LatencyHook is a Hook that maintains rolling latency histograms and error
+// rates per command family. Use Snapshot to export the statistics and
+// thresholds to react when latency degrades, for example by disabling an
+// expensive code path with a feature flag.
+//
+//  h := &redis.LatencyHook{
+//      Thresholds: []redis.LatencyThreshold{{
+//          Family: "EVALSHA",
+//          P99:    50 * time.Millisecond,
+//          OnChange: func(s redis.LatencyStats, tripped bool) {
+//              expensiveCache.SetEnabled(!tripped)
+//          },
+//      }},
+//  }
+//  pool := &redis.Pool{Hook: h, ...}
+//
+// A LatencyHook is safe for concurrent use.
+type LatencyHook struct {
+	// Window is the duration of the rolling window. The default is one
+	// minute.
+	Window time.Duration
+
+	// Family returns the family of a command. The default family of a command
+	// is the upper case command name.
+	Family func(commandName string) string
+
+	Thresholds []LatencyThreshold
+
+	// now returns the current time. Tests replace now.
+	now func() time.Time
+
+	mu       sync.Mutex
+	families map[string]*latencyFamily
+}
+
+type latencySlot struct {
+	index  int64
+	count  int64
+	errors int64
+	bins   [latencyBins]int64
+}
+
+type latencyFamily struct {
+	slots   [latencySlots]latencySlot
+	tripped []bool
+}
+
+func (h *LatencyHook) BeforeSend(commandName string, args []interface{}) {}
+
+func (h *LatencyHook) AfterReceive(commandName string, args []interface{}, reply interface{}, elapsed time.Duration) {
+	h.record(commandName, elapsed, false)
+}
+
+func (h *LatencyHook) OnError(commandName string, args []interface{}, err error, elapsed time.Duration) {
+	h.record(commandName, elapsed, true)
+}
+
+func (h *LatencyHook) slotDuration() time.Duration {
+	w := h.Window
+	if w <= 0 {
+		w = time.Minute
+	}
+	return w / latencySlots
+}
+
+func (h *LatencyHook) currentSlot() int64 {
+	now := time.Now
+	if h.now != nil {
+		now = h.now
+	}
+	return now().UnixNano() / int64(h.slotDuration())
+}
+
+func latencyBin(d time.Duration) int {
+	us := d / time.Microsecond
+	i := 0
+	for us > 0 && i < latencyBins-1 {
+		us >>= 1
+		i++
+	}
+	return i
+}
+
+func (h *LatencyHook) record(commandName string, elapsed time.Duration, failed bool) {
+	var family string
+	if h.Family != nil {
+		family = h.Family(commandName)
+	} else {
+		family = strings.ToUpper(commandName)
+	}
+	current := h.currentSlot()
+
+	h.mu.Lock()
+	if h.families == nil {
+		h.families = make(map[string]*latencyFamily)
+	}
+	f := h.families[family]
+	if f == nil {
+		f = &latencyFamily{tripped: make([]bool, len(h.Thresholds))}
+		h.families[family] = f
+	}
+	s := &f.slots[current%latencySlots]
+	if s.index != current {
+		*s = latencySlot{index: current}
+	}
+	s.count++
+	if failed {
+		s.errors++
+	}
+	s.bins[latencyBin(elapsed)]++
+
+	var changes []func()
+	if len(h.Thresholds) > 0 {
+		stats := f.stats(family, current)
+		for i, t := range h.Thresholds {
+			if t.Family != "" && t.Family != family {
+				continue
+			}
+			minCount := t.MinCount
+			if minCount <= 0 {
+				minCount = 10
+			}
+			if stats.Count < minCount {
+				continue
+			}
+			tripped := (t.P99 > 0 && stats.P99 > t.P99) ||
+				(t.ErrorRate > 0 && stats.ErrorRate > t.ErrorRate)
+			if tripped != f.tripped[i] {
+				f.tripped[i] = tripped
+				if t.OnChange != nil {
+					onChange := t.OnChange
+					changes = append(changes, func() { onChange(stats, tripped) })
+				}
+			}
+		}
+	}
+	h.mu.Unlock()
+
+	for _, change := range changes {
+		change()
+	}
+}
+
+// stats returns the statistics of the slots in the window ending with the
+// current slot.
+func (f *latencyFamily) stats(family string, current int64) LatencyStats {
+	stats := LatencyStats{Family: family}
+	var bins [latencyBins]int64
+	for i := range f.slots {
+		s := &f.slots[i]
+		if s.index <= current-latencySlots || s.index > current {
+			continue
+		}
+		stats.Count += s.count
+		stats.Errors += s.errors
+		for j, n := range s.bins {
+			bins[j] += n
+		}
+	}
+	if stats.Count == 0 {
+		return stats
+	}
+	stats.ErrorRate = float64(stats.Errors) / float64(stats.Count)
+	percentile := func(p int64) time.Duration {
+		rank := (stats.Count*p + 99) / 100
+		var n int64
+		for i, b := range bins {
+			n += b
+			if n >= rank {
+				return time.Duration(1<<uint(i)) * time.Microsecond
+			}
+		}
+		return time.Duration(1<<uint(latencyBins-1)) * time.Microsecond
+	}
+	stats.P50 = percentile(50)
+	stats.P90 = percentile(90)
+	stats.P99 = percentile(99)
+	return stats
+}
+
+// Snapshot returns the statistics of the command families with commands in
+// the rolling window sorted by family.
+func (h *LatencyHook) Snapshot() []LatencyStats {
+	current := h.currentSlot()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var result []LatencyStats
+	for family, f := range h.families {
+		if stats := f.stats(family, current); stats.Count > 0 {
+			result = append(result, stats)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Family < result[j].Family })
+	return result
+}
@@ -0,0 +1,91 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLatencyHook(t *testing.T) {
+	now := time.Unix(1000, 0)
+	type change struct {
+		family  string
+		tripped bool
+	}
+	var changes []change
+	h := &LatencyHook{
+		Window: 10 * time.Second,
+		now:    func() time.Time { return now },
+		Thresholds: []LatencyThreshold{{
+			Family:   "GET",
+			P99:      10 * time.Millisecond,
+			MinCount: 5,
+			OnChange: func(s LatencyStats, tripped bool) {
+				changes = append(changes, change{s.Family, tripped})
+			},
+		}, {
+			ErrorRate: 0.5,
+			MinCount:  2,
+			OnChange: func(s LatencyStats, tripped bool) {
+				changes = append(changes, change{s.Family, tripped})
+			},
+		}},
+	}
+
+	for i := 0; i < 10; i++ {
+		h.AfterReceive("get", nil, nil, time.Millisecond)
+	}
+	h.OnError("SET", nil, errors.New("x"), time.Millisecond)
+	h.OnError("SET", nil, errors.New("x"), time.Millisecond)
+
+	snapshot := h.Snapshot()
+	if len(snapshot) != 2 || snapshot[0].Family != "GET" || snapshot[1].Family != "SET" {
+		t.Fatalf("Snapshot() = %+v", snapshot)
+	}
+	if s := snapshot[0]; s.Count != 10 || s.Errors != 0 || s.P99 != 1024*time.Microsecond {
+		t.Errorf("GET stats = %+v", s)
+	}
+	if s := snapshot[1]; s.Count != 2 || s.ErrorRate != 1 {
+		t.Errorf("SET stats = %+v", s)
+	}
+
+	// Slow GETs raise the 99th percentile above the threshold.
+	now = now.Add(time.Second)
+	for i := 0; i < 10; i++ {
+		h.AfterReceive("GET", nil, nil, 50*time.Millisecond)
+	}
+
+	// The slow commands leave the window.
+	now = now.Add(10 * time.Second)
+	for i := 0; i < 10; i++ {
+		h.AfterReceive("GET", nil, nil, time.Millisecond)
+	}
+	now = now.Add(time.Second)
+	h.AfterReceive("SET", nil, nil, time.Millisecond)
+	h.AfterReceive("SET", nil, nil, time.Millisecond)
+
+	expected := []change{{"SET", true}, {"GET", true}, {"GET", false}, {"SET", false}}
+	if len(changes) != len(expected) {
+		t.Fatalf("changes = %v, want %v", changes, expected)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("changes = %v, want %v", changes, expected)
+			break
+		}
+	}
+}
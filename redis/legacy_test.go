@@ -0,0 +1,47 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+//go:build redigo_legacy
+// +build redigo_legacy
+
+package redis_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"testing"
+)
+
+// errNil is the error returned by the reply helpers for a nil reply.
+var errNil error
+
+// legacyBuild is true when the tests are built with the redigo_legacy tag.
+const legacyBuild = true
+
+func TestLegacy(t *testing.T) {
+	if s, err := redis.String(nil, nil); s != "" || err != nil {
+		t.Errorf("String(nil) returned %q, %v", s, err)
+	}
+	if n, err := redis.Int(nil, nil); n != 0 || err != nil {
+		t.Errorf("Int(nil) returned %d, %v", n, err)
+	}
+
+	var v s3
+	if err := redis.ScanStruct([]interface{}{[]byte("count"), []byte("3")}, &v); err != nil {
+		t.Errorf("ScanStruct with missing required field returned %v", err)
+	}
+
+	if _, err := redis.AppendStruct(nil, 1); err == nil {
+		t.Errorf("AppendStruct did not return error")
+	}
+}
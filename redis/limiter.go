@@ -0,0 +1,293 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AdaptiveLimiter limits the number of commands in flight on the connections
+// in a pool. The limiter lowers the limit when the 99th percentile latency of
+// the commands rises and raises the limit when the latency is stable and the
+// limit is reached. The limiter protects an overloaded server from additional
+// load without manual tuning of the pool size.
+//
+// A command is in flight from the time the command is sent until the reply
+// is received. Commands pipelined on a connection are counted as one command.
+// Blocking commands such as BLPOP increase the measured latency and should
+// not be executed on connections from a pool with a limiter. A command
+// executed with DoContext stops waiting for the limit when the context is
+// done.
+type AdaptiveLimiter struct {
+	// MinLimit and MaxLimit bound the limit. The defaults are 1 and 256.
+	MinLimit int
+	MaxLimit int
+
+	// InitialLimit is the limit before the first adjustment. The default is
+	// 16.
+	InitialLimit int
+
+	// Window is the number of latency samples used to compute each
+	// adjustment. The default is 100.
+	Window int
+
+	// Tolerance is the ratio of the 99th percentile latency to the baseline
+	// latency at which the limit is lowered. The baseline latency is the
+	// lowest 99th percentile latency observed by the limiter. The default is
+	// 2.
+	Tolerance float64
+
+	mu        sync.Mutex
+	wake      chan struct{} // closed when a command is released
+	limit     int
+	inFlight  int
+	saturated bool
+	baseline  time.Duration
+	samples   []time.Duration
+}
+
+func (l *AdaptiveLimiter) initLocked() {
+	if l.wake != nil {
+		return
+	}
+	l.wake = make(chan struct{})
+	l.limit = l.InitialLimit
+	if l.limit <= 0 {
+		l.limit = 16
+	}
+	l.limit = l.clamp(l.limit)
+}
+
+func (l *AdaptiveLimiter) clamp(n int) int {
+	min, max := l.MinLimit, l.MaxLimit
+	if min <= 0 {
+		min = 1
+	}
+	if max <= 0 {
+		max = 256
+	}
+	switch {
+	case n < min:
+		return min
+	case n > max:
+		return max
+	}
+	return n
+}
+
+// Limit returns the current limit.
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.initLocked()
+	return l.limit
+}
+
+// InFlight returns the number of commands in flight.
+func (l *AdaptiveLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// acquire waits until the number of commands in flight is below the limit
+// or the context is done.
+func (l *AdaptiveLimiter) acquire(ctx context.Context) error {
+	l.mu.Lock()
+	l.initLocked()
+	for l.inFlight >= l.limit {
+		l.saturated = true
+		wake := l.wake
+		l.mu.Unlock()
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		l.mu.Lock()
+	}
+	l.inFlight++
+	if l.inFlight >= l.limit {
+		l.saturated = true
+	}
+	l.mu.Unlock()
+	return nil
+}
+
+// release records the latency of a command and wakes waiting commands.
+func (l *AdaptiveLimiter) release(latency time.Duration) {
+	l.mu.Lock()
+	l.inFlight--
+	l.samples = append(l.samples, latency)
+	window := l.Window
+	if window <= 0 {
+		window = 100
+	}
+	if len(l.samples) >= window {
+		l.adjustLocked()
+	}
+	close(l.wake)
+	l.wake = make(chan struct{})
+	l.mu.Unlock()
+}
+
+func (l *AdaptiveLimiter) adjustLocked() {
+	sort.Slice(l.samples, func(i, j int) bool { return l.samples[i] < l.samples[j] })
+	p99 := l.samples[len(l.samples)*99/100]
+	l.samples = l.samples[:0]
+
+	tolerance := l.Tolerance
+	if tolerance <= 0 {
+		tolerance = 2
+	}
+	if l.baseline == 0 || p99 < l.baseline {
+		l.baseline = p99
+	}
+	if float64(p99) > tolerance*float64(l.baseline) {
+		n := l.limit * 9 / 10
+		if n == l.limit {
+			n--
+		}
+		l.limit = l.clamp(n)
+		// Move the baseline toward the observed latency so that a server
+		// that is permanently slower is not throttled forever.
+		l.baseline += (p99 - l.baseline) / 10
+	} else if l.saturated {
+		l.limit = l.clamp(l.limit + 1)
+	}
+	l.saturated = false
+}
+
+type limitConn struct {
+	connWrapper
+	l       *AdaptiveLimiter
+	ctx     context.Context // context applied by withContext or nil
+	pending int
+	held    bool
+	start   time.Time
+}
+
+func (c *limitConn) begin() error {
+	if !c.held {
+		ctx := c.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if err := c.l.acquire(ctx); err != nil {
+			return err
+		}
+		c.held = true
+		c.start = time.Now()
+	}
+	return nil
+}
+
+func (c *limitConn) end() {
+	if c.held {
+		c.held = false
+		c.l.release(time.Since(c.start))
+	}
+}
+
+func (c *limitConn) Close() error {
+	c.end()
+	return c.Conn.Close()
+}
+
+func (c *limitConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if commandName != "" {
+		if err := c.begin(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.Conn.Do(commandName, args...)
+	c.pending = 0
+	c.end()
+	return reply, err
+}
+
+func (c *limitConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	if commandName != "" {
+		if err := c.begin(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := DoWithTimeout(c.Conn, timeout, commandName, args...)
+	c.pending = 0
+	c.end()
+	return reply, err
+}
+
+func (c *limitConn) doTo(w io.Writer, commandName string, args []interface{}) (int64, error) {
+	if commandName != "" {
+		if err := c.begin(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := DoTo(c.Conn, w, commandName, args...)
+	c.pending = 0
+	c.end()
+	return n, err
+}
+
+func (c *limitConn) Send(commandName string, args ...interface{}) error {
+	if err := c.begin(); err != nil {
+		return err
+	}
+	err := c.Conn.Send(commandName, args...)
+	if err == nil {
+		c.pending++
+	} else if c.pending == 0 {
+		c.end()
+	}
+	return err
+}
+
+func (c *limitConn) received() {
+	if c.pending > 0 {
+		c.pending--
+	}
+	if c.pending == 0 {
+		c.end()
+	}
+}
+
+func (c *limitConn) Receive() (interface{}, error) {
+	reply, err := c.Conn.Receive()
+	c.received()
+	return reply, err
+}
+
+func (c *limitConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	reply, err := ReceiveWithTimeout(c.Conn, timeout)
+	c.received()
+	return reply, err
+}
+
+func (c *limitConn) receiveTo(w io.Writer) (int64, error) {
+	n, err := ReceiveTo(c.Conn, w)
+	c.received()
+	return n, err
+}
+
+func (c *limitConn) withContext(ctx context.Context, f func() error) error {
+	c.ctx = ctx
+	defer func() { c.ctx = nil }()
+	return withContext(c.Conn, ctx, f)
+}
@@ -0,0 +1,112 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveLimiterAdjust(t *testing.T) {
+	l := &AdaptiveLimiter{InitialLimit: 4, MaxLimit: 5, Window: 10}
+	run := func(latency time.Duration) {
+		for i := 0; i < 10; i++ {
+			l.acquire(context.Background())
+			l.release(latency)
+		}
+	}
+
+	// The limit is not raised until the limit is reached.
+	run(time.Millisecond)
+	if n := l.Limit(); n != 4 {
+		t.Fatalf("Limit() = %d after unsaturated window, want 4", n)
+	}
+	for i := 0; i < 4; i++ {
+		l.acquire(context.Background())
+	}
+	for i := 0; i < 10; i++ {
+		l.release(time.Millisecond)
+		l.acquire(context.Background())
+	}
+	if n := l.Limit(); n != 5 {
+		t.Fatalf("Limit() = %d after saturated window, want 5", n)
+	}
+	for i := 0; i < 4; i++ {
+		l.release(time.Millisecond)
+	}
+
+	// Rising latency lowers the limit.
+	run(10 * time.Millisecond)
+	if n := l.Limit(); n != 4 {
+		t.Fatalf("Limit() = %d after slow window, want 4", n)
+	}
+	for i := 0; i < 3; i++ {
+		run(time.Second)
+	}
+	if n := l.Limit(); n != 1 {
+		t.Fatalf("Limit() = %d after slow windows, want 1", n)
+	}
+
+	// The baseline moves toward a latency that does not improve.
+	for i := 0; i < 20; i++ {
+		run(time.Second)
+	}
+	if n := l.Limit(); n == 1 {
+		t.Fatalf("Limit() = %d after sustained latency, want > 1", n)
+	}
+	if n := l.InFlight(); n != 0 {
+		t.Fatalf("InFlight() = %d, want 0", n)
+	}
+}
+
+func TestPoolLimiter(t *testing.T) {
+	l := &AdaptiveLimiter{InitialLimit: 1, MaxLimit: 1}
+	p := &Pool{
+		Dial:    func() (Conn, error) { return &fakeConn{open: new(int)}, nil },
+		Limiter: l,
+	}
+	defer p.Close()
+
+	c1 := p.Get()
+	c1.Send("PING")
+	if n := l.InFlight(); n != 1 {
+		t.Fatalf("InFlight() = %d after Send, want 1", n)
+	}
+	done := make(chan struct{})
+	go func() {
+		c2 := p.Get()
+		c2.Do("PING")
+		c2.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("command executed while limit reached")
+	case <-time.After(10 * time.Millisecond):
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	c3 := p.Get()
+	if _, err := DoContext(c3, ctx, "PING"); err != context.DeadlineExceeded {
+		t.Errorf("DoContext while limit reached returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	c3.Close()
+	c1.Close()
+	<-done
+	if n := l.InFlight(); n != 0 {
+		t.Fatalf("InFlight() = %d after Close, want 0", n)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+const (
+	loadingMinBackoff = 10 * time.Millisecond
+	loadingMaxBackoff = time.Second
+)
+
+// DialRetryLoading specifies that Do waits and retries a command when the
+// server replies with a LOADING error while the server loads the dataset
+// after a restart. The retries stop after maxWait or at the deadline of the
+// context passed to a ConnV2 method, whichever is first. The wait between
+// retries starts at 10 milliseconds and doubles to a maximum of one second.
+//
+// Commands pipelined with Send are not retried because the position of the
+// failed command in the pipeline is not known to the connection.
+func DialRetryLoading(maxWait time.Duration) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.loadingWait = maxWait
+	}}
+}
+
+// isLoading returns true if err is the error returned by a server loading
+// the dataset.
+func isLoading(err error) bool {
+	e, ok := err.(Error)
+	return ok && e.kind() == "LOADING"
+}
+
+type loadingConn struct {
+	connWrapper
+	maxWait time.Duration
+	ctx     context.Context
+	pending int
+}
+
+func (c *loadingConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return c.do(func() (interface{}, error) {
+		return c.Conn.Do(commandName, args...)
+	})
+}
+
+func (c *loadingConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	return c.do(func() (interface{}, error) {
+		return DoWithTimeout(c.Conn, timeout, commandName, args...)
+	})
+}
+
+func (c *loadingConn) doTo(w io.Writer, commandName string, args []interface{}) (int64, error) {
+	// An error reply is not copied to w, so the command can be retried.
+	var n int64
+	_, err := c.do(func() (interface{}, error) {
+		var err error
+		n, err = DoTo(c.Conn, w, commandName, args...)
+		return nil, err
+	})
+	return n, err
+}
+
+// do calls f and retries while the server is loading the dataset.
+func (c *loadingConn) do(f func() (interface{}, error)) (interface{}, error) {
+	retry := c.pending == 0
+	c.pending = 0
+	reply, err := f()
+	if !retry || !isLoading(err) {
+		return reply, err
+	}
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	deadline := nowFunc().Add(c.maxWait)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	backoff := loadingMinBackoff
+	for isLoading(err) {
+		if nowFunc().Add(backoff).After(deadline) {
+			break
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+		if backoff *= 2; backoff > loadingMaxBackoff {
+			backoff = loadingMaxBackoff
+		}
+		reply, err = f()
+	}
+	return reply, err
+}
+
+func (c *loadingConn) Send(commandName string, args ...interface{}) error {
+	err := c.Conn.Send(commandName, args...)
+	if err == nil {
+		c.pending++
+	}
+	return err
+}
+
+func (c *loadingConn) Receive() (interface{}, error) {
+	if c.pending > 0 {
+		c.pending--
+	}
+	return c.Conn.Receive()
+}
+
+func (c *loadingConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	if c.pending > 0 {
+		c.pending--
+	}
+	return ReceiveWithTimeout(c.Conn, timeout)
+}
+
+func (c *loadingConn) receiveTo(w io.Writer) (int64, error) {
+	if c.pending > 0 {
+		c.pending--
+	}
+	return ReceiveTo(c.Conn, w)
+}
+
+func (c *loadingConn) withContext(ctx context.Context, f func() error) error {
+	c.ctx = ctx
+	defer func() { c.ctx = nil }()
+	return withContext(c.Conn, ctx, f)
+}
@@ -0,0 +1,53 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"context"
+	"github.com/garyburd/redigo/redis"
+	"testing"
+	"time"
+)
+
+func TestDialRetryLoading(t *testing.T) {
+	s := newFaultScript(func(args []string) string { return "+OK\r\n" }).
+		Add("SET", faultLoading, faultLoading)
+	l := serveFake(t, s.Handle)
+	defer l.Close()
+
+	c, err := redis.Dial("tcp", l.Addr().String(), redis.DialRetryLoading(time.Second))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+	if reply, err := redis.String(c.Do("SET", "k", "v")); err != nil || reply != "OK" {
+		t.Fatalf("Do returned %q, %v", reply, err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		s.Add("SET", faultLoading)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = redis.ToConnV2(c).Do(ctx, "SET", "k", "v")
+	if e, ok := err.(redis.Error); !ok || e[:8] != "LOADING " {
+		t.Fatalf("Do returned %v, want LOADING error", err)
+	}
+
+	c.Send("SET", "k", "v")
+	if _, err := c.Do("GET", "k"); err == nil {
+		t.Fatalf("pipelined commands were retried")
+	}
+}
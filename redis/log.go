@@ -17,7 +17,9 @@ package redis
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"log"
+	"strings"
 )
 
 // NewLoggingConn returns a logging wrapper around a connection.
@@ -45,6 +47,8 @@ func (c *loggingConn) Close() error {
 func (c *loggingConn) printValue(buf *bytes.Buffer, v interface{}) {
 	const chop = 32
 	switch v := v.(type) {
+	case Argument:
+		c.printValue(buf, v.RedisArg())
 	case []byte:
 		if len(v) > chop {
 			fmt.Fprintf(buf, "%q...", v[:chop])
@@ -74,6 +78,9 @@ func (c *loggingConn) printValue(buf *bytes.Buffer, v interface{}) {
 			}
 			buf.WriteString(fin)
 		}
+	case *io.LimitedReader, *bytes.Reader, *strings.Reader:
+		// The reader is consumed when the command is written.
+		fmt.Fprintf(buf, "%T", v)
 	default:
 		fmt.Fprint(buf, v)
 	}
@@ -0,0 +1,447 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Mapper saves and loads structs to and from Redis hashes. The fields of the
+// struct are mapped to hash fields as described in ScanStruct and
+// AppendStruct. Slice fields with the "set=" tag flag are stored in a
+// companion set. The time to live of the hash and the companion sets is
+// loaded to and saved from a time.Duration field with the "ttl" tag flag. A
+// zero duration specifies that the keys do not expire. The zero value of
+// Mapper is ready to use.
+//
+// SoftDelete marks an object as deleted and moves the keys of the object to
+// an archive namespace. Load ignores objects marked as deleted. Use
+// LoadDeleted to load an object from the archive namespace.
+//
+// Update writes the fields that changed between two versions of an object
+// and adds a ChangeEvent to ChangeStream.
+//
+// LoadWithRefs also loads the objects referenced by string fields with the
+// "ref=" tag flag.
+type Mapper struct {
+	// DeletedField is the hash field where SoftDelete stores the time of
+	// deletion as Unix time in seconds. The default is "deleted_at".
+	DeletedField string
+
+	// ArchivePrefix is prepended to the keys of soft-deleted objects. The
+	// default is "archive:".
+	ArchivePrefix string
+
+	// ArchiveTTL is the time to live of soft-deleted objects. If zero, then
+	// soft-deleted objects do not expire.
+	ArchiveTTL time.Duration
+
+	// ChangeStream, if set, is the key of a stream where Update adds a
+	// ChangeEvent in the same MULTI/EXEC transaction as the update.
+	ChangeStream string
+
+	// ChangeStreamMaxLen, if greater than zero, approximately trims the
+	// change stream to the length using the MAXLEN ~ option of XADD.
+	ChangeStreamMaxLen int64
+}
+
+// FieldChange describes the change of a hash field. Old is nil if the field
+// was added. New is nil if the field was deleted.
+type FieldChange struct {
+	Field string  `json:"field"`
+	Old   *string `json:"old"`
+	New   *string `json:"new"`
+}
+
+// ChangeEvent is an entry in the change stream of a Mapper. Decode an entry
+// read from the stream with StreamEntry.ScanStruct.
+type ChangeEvent struct {
+	Key     string        `redis:"key"`
+	Actor   string        `redis:"actor"`
+	Changes []FieldChange `redis:"changes,json"`
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx with the actor recorded in change events.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set with WithActor or the empty string.
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+func (m *Mapper) deletedField() string {
+	if m.DeletedField == "" {
+		return "deleted_at"
+	}
+	return m.DeletedField
+}
+
+func (m *Mapper) archivePrefix() string {
+	if m.ArchivePrefix == "" {
+		return "archive:"
+	}
+	return m.ArchivePrefix
+}
+
+// Save stores the fields of struct src in the hash at key using HSET and
+// replaces the companion sets of the struct. The commands are executed in a
+// MULTI/EXEC transaction. Empty set fields with the "omitempty" tag flag do
+// not modify the companion set.
+func (m *Mapper) Save(c Conn, key string, src interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(src))
+	if v.Kind() != reflect.Struct {
+		return errors.New("redigo: Mapper.Save argument must be a struct or pointer to a struct")
+	}
+	args, err := AppendStruct([]interface{}{key}, src)
+	if err != nil {
+		return err
+	}
+	if err := c.Send("MULTI"); err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		c.Send("HSET", args...)
+	}
+	ss := structSpecForType(v.Type())
+	keys := []string{key}
+	for _, fs := range ss.sets {
+		f := v.FieldByIndex(fs.index)
+		setKey := key + fs.setSuffix
+		keys = append(keys, setKey)
+		if fs.omitEmpty && isEmptyValue(f) {
+			continue
+		}
+		c.Send("DEL", setKey)
+		if f.Len() > 0 {
+			members := []interface{}{setKey}
+			for _, s := range formatElements(f) {
+				members = append(members, s)
+			}
+			c.Send("SADD", members...)
+		}
+	}
+	if ss.ttl != nil {
+		ttl := time.Duration(v.FieldByIndex(ss.ttl.index).Int())
+		for _, k := range keys {
+			if ttl > 0 {
+				c.Send("PEXPIRE", k, int64(ttl/time.Millisecond))
+			} else {
+				c.Send("PERSIST", k)
+			}
+		}
+	}
+	return execError(c.Do("EXEC"))
+}
+
+// execError returns err or the first error in the reply to EXEC. Errors in
+// the commands of a transaction are returned as elements of the reply.
+func execError(reply interface{}, err error) error {
+	if err != nil {
+		return err
+	}
+	replies, _ := reply.([]interface{})
+	for _, r := range replies {
+		if e, ok := r.(Error); ok {
+			return e
+		}
+	}
+	return nil
+}
+
+// Update updates the hash at key from struct old to struct new as
+// UpdateStruct does. If ChangeStream is set, then Update adds a ChangeEvent
+// with the actor from ctx and the changed fields to the stream. The commands
+// are executed in a MULTI/EXEC transaction. Update does not send a command
+// if no fields changed. Companion sets and the time to live are not
+// updated.
+func (m *Mapper) Update(ctx context.Context, c Conn, key string, old, new interface{}) error {
+	if m.ChangeStream == "" {
+		return UpdateStruct(c, key, old, new)
+	}
+	set, del, changes, err := diffStruct(old, new)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+	event, err := AppendStruct(nil, &ChangeEvent{Key: key, Actor: ActorFromContext(ctx), Changes: changes})
+	if err != nil {
+		return err
+	}
+	if err := c.Send("MULTI"); err != nil {
+		return err
+	}
+	if len(set) > 0 {
+		c.Send("HSET", append([]interface{}{key}, set...)...)
+	}
+	if len(del) > 0 {
+		c.Send("HDEL", append([]interface{}{key}, del...)...)
+	}
+	args := []interface{}{m.ChangeStream}
+	if m.ChangeStreamMaxLen > 0 {
+		args = append(args, "MAXLEN", "~", m.ChangeStreamMaxLen)
+	}
+	args = append(args, "*")
+	c.Send("XADD", append(args, event...)...)
+	return execError(c.Do("EXEC"))
+}
+
+// Load loads the hash at key and the companion sets of struct dest to dest.
+// The commands are executed in a MULTI/EXEC transaction. The order of the
+// elements loaded from a set is not specified. Load returns ErrNil if the
+// hash does not exist or if the object is marked as deleted.
+func (m *Mapper) Load(c Conn, key string, dest interface{}) error {
+	return m.load(c, key, dest, false)
+}
+
+// LoadDeleted loads an object deleted by SoftDelete from the archive
+// namespace. LoadDeleted returns ErrNil if the archived object does not
+// exist.
+func (m *Mapper) LoadDeleted(c Conn, key string, dest interface{}) error {
+	return m.load(c, m.archivePrefix()+key, dest, true)
+}
+
+func (m *Mapper) load(c Conn, key string, dest interface{}, deleted bool) error {
+	d := reflect.ValueOf(dest)
+	if d.Kind() != reflect.Ptr || d.IsNil() || d.Elem().Kind() != reflect.Struct {
+		return errors.New("redigo: Mapper.Load dest must be non-nil pointer to a struct")
+	}
+	d = d.Elem()
+	ss := structSpecForType(d.Type())
+	if err := c.Send("MULTI"); err != nil {
+		return err
+	}
+	n := sendLoad(c, key, ss)
+	replies, err := Values(c.Do("EXEC"))
+	if err != nil {
+		return err
+	}
+	if len(replies) != n {
+		return errors.New("redigo: Mapper.Load unexpected number of replies")
+	}
+	return m.decodeLoad(replies, d, ss, deleted)
+}
+
+// sendLoad sends the commands to load the object at key and returns the
+// number of commands sent.
+func sendLoad(c Conn, key string, ss *structSpec) int {
+	c.Send("HGETALL", key)
+	for _, fs := range ss.sets {
+		c.Send("SMEMBERS", key+fs.setSuffix)
+	}
+	n := len(ss.sets) + 1
+	if ss.ttl != nil {
+		c.Send("PTTL", key)
+		n++
+	}
+	return n
+}
+
+// decodeLoad decodes the replies to the commands sent by sendLoad to struct
+// d.
+func (m *Mapper) decodeLoad(replies []interface{}, d reflect.Value, ss *structSpec, deleted bool) error {
+	values, err := Values(replies[0], nil)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return ErrNil
+	}
+	if !deleted {
+		field := m.deletedField()
+		for i := 0; i+1 < len(values); i += 2 {
+			if name, _ := String(values[i], nil); name == field {
+				return ErrNil
+			}
+		}
+	}
+	if err := ScanStruct(values, d.Addr().Interface()); err != nil {
+		return err
+	}
+	for i, fs := range ss.sets {
+		members, err := Values(replies[i+1], nil)
+		if err != nil {
+			return err
+		}
+		if err := assignElements(d.FieldByIndex(fs.index), len(members), func(i int) interface{} { return members[i] }); err != nil {
+			return err
+		}
+	}
+	if ss.ttl != nil {
+		ms, err := Int64(replies[len(replies)-1], nil)
+		if err != nil {
+			return err
+		}
+		var ttl time.Duration
+		if ms > 0 {
+			ttl = time.Duration(ms) * time.Millisecond
+		}
+		d.FieldByIndex(ss.ttl.index).SetInt(int64(ttl))
+	}
+	return nil
+}
+
+// LoadWithRefs loads the object at key to dest as Load does and then
+// resolves the references of the object to a depth of depth levels.
+//
+// A reference is a string field with the "ref=" tag flag. The value of the
+// field is the key of the referenced object. The flag names a pointer to
+// struct field in the same struct where LoadWithRefs stores the referenced
+// object. Tag the pointer field with "-" so that the field is not stored in
+// the hash:
+//
+//  type Post struct {
+//      Title    string `redis:"title"`
+//      OwnerKey string `redis:"owner,ref=Owner"`
+//      Owner    *User  `redis:"-"`
+//  }
+//
+// The objects at each level are loaded with a single pipeline. An object
+// referenced more than once, including an object referenced from a cycle,
+// is loaded once and the pointer fields share the value. Pointer fields for
+// missing and deleted objects are set to nil. References of the objects at
+// the last level are not resolved.
+func (m *Mapper) LoadWithRefs(c Conn, key string, dest interface{}, depth int) error {
+	if err := m.load(c, key, dest, false); err != nil {
+		return err
+	}
+	d := reflect.ValueOf(dest)
+	loaded := map[string]reflect.Value{key: d}
+	level := []reflect.Value{d.Elem()}
+	type pending struct {
+		key string
+		v   reflect.Value
+		n   int
+	}
+	for ; depth > 0 && len(level) > 0; depth-- {
+		var batch []pending
+		var fields []reflect.Value
+		var refKeys []string
+		for _, v := range level {
+			for _, fs := range structSpecForType(v.Type()).refs {
+				f := v.FieldByIndex(fs.ref)
+				f.Set(reflect.Zero(f.Type()))
+				k := v.FieldByIndex(fs.index).String()
+				if k == "" {
+					continue
+				}
+				if p, ok := loaded[k]; ok {
+					if p.IsValid() && p.Type() != f.Type() {
+						return errors.New("redigo: Mapper.LoadWithRefs key " + k + " referenced as " + f.Type().String() + " and " + p.Type().String())
+					}
+				} else {
+					p := reflect.New(f.Type().Elem())
+					loaded[k] = p
+					batch = append(batch, pending{key: k, v: p})
+				}
+				fields = append(fields, f)
+				refKeys = append(refKeys, k)
+			}
+		}
+		for i := range batch {
+			batch[i].n = sendLoad(c, batch[i].key, structSpecForType(batch[i].v.Type().Elem()))
+		}
+		if len(batch) > 0 {
+			if err := c.Flush(); err != nil {
+				return err
+			}
+		}
+		replies := make([][]interface{}, len(batch))
+		for i, p := range batch {
+			replies[i] = make([]interface{}, p.n)
+			for j := range replies[i] {
+				r, err := c.Receive()
+				if e, ok := err.(Error); ok {
+					r = e
+				} else if err != nil {
+					return err
+				}
+				replies[i][j] = r
+			}
+		}
+		level = level[:0]
+		for i, p := range batch {
+			err := m.decodeLoad(replies[i], p.v.Elem(), structSpecForType(p.v.Type().Elem()), false)
+			switch err {
+			case nil:
+				level = append(level, p.v.Elem())
+			case ErrNil:
+				loaded[p.key] = reflect.Value{}
+			default:
+				return err
+			}
+		}
+		for i, f := range fields {
+			if p := loaded[refKeys[i]]; p.IsValid() {
+				f.Set(p)
+			}
+		}
+	}
+	return nil
+}
+
+// SoftDelete marks the object at key as deleted and moves the hash and the
+// companion sets of the object to the archive namespace. The argument typ is
+// a struct or pointer to a struct of the type of the object. The archived
+// keys expire after ArchiveTTL. The commands are executed with Transact.
+// SoftDelete returns ErrNil if the hash does not exist.
+func (m *Mapper) SoftDelete(c Conn, key string, typ interface{}) error {
+	t := reflect.TypeOf(typ)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return errors.New("redigo: Mapper.SoftDelete argument must be a struct or pointer to a struct")
+	}
+	ss := structSpecForType(t)
+	keys := []string{key}
+	for _, fs := range ss.sets {
+		keys = append(keys, key+fs.setSuffix)
+	}
+
+	return Transact(c, func(tx *Tx) error {
+		exists := make([]bool, len(keys))
+		for i, k := range keys {
+			var err error
+			if exists[i], err = Bool(c.Do("EXISTS", k)); err != nil {
+				return err
+			}
+		}
+		if !exists[0] {
+			return ErrNil
+		}
+		tx.Do("HSET", key, m.deletedField(), strconv.FormatInt(nowFunc().Unix(), 10))
+		for i, k := range keys {
+			if !exists[i] {
+				continue
+			}
+			archiveKey := m.archivePrefix() + k
+			tx.Do("RENAME", k, archiveKey)
+			if m.ArchiveTTL > 0 {
+				tx.Do("PEXPIRE", archiveKey, int64(m.ArchiveTTL/time.Millisecond))
+			} else {
+				tx.Do("PERSIST", archiveKey)
+			}
+		}
+		return nil
+	}, keys...)
+}
@@ -0,0 +1,432 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// serveStore serves a minimal in-memory store supporting the string, hash,
+// set and sorted set commands used by the mapper and typed keys.
+func serveStore(t *testing.T) (addr string, stop func()) {
+	var (
+		mu     sync.Mutex
+		values = make(map[string]string)
+		hashes = make(map[string]map[string]string)
+		sets   = make(map[string]map[string]bool)
+		zsets  = make(map[string]map[string]string)
+		ttls   = make(map[string]string)
+		xs     = make(map[string][][]string)
+		queued []string
+		multi  bool
+	)
+	bulks := func(values []string) string {
+		s := fmt.Sprintf("*%d\r\n", len(values))
+		for _, v := range values {
+			s += fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+		}
+		return s
+	}
+	exec := func(args []string) string {
+		switch args[0] {
+		case "GET":
+			v, ok := values[args[1]]
+			if !ok {
+				return "$-1\r\n"
+			}
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+		case "SET":
+			values[args[1]] = args[2]
+			delete(ttls, args[1])
+			if len(args) == 5 && args[3] == "PX" {
+				ttls[args[1]] = args[4]
+			}
+			return "+OK\r\n"
+		case "ZADD":
+			z := zsets[args[1]]
+			if z == nil {
+				z = make(map[string]string)
+				zsets[args[1]] = z
+			}
+			_, found := z[args[3]]
+			z[args[3]] = args[2]
+			if found {
+				return ":0\r\n"
+			}
+			return ":1\r\n"
+		case "ZSCORE":
+			score, ok := zsets[args[1]][args[2]]
+			if !ok {
+				return "$-1\r\n"
+			}
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(score), score)
+		case "ZRANGE":
+			// Ranks are ignored. Members are sorted by score only.
+			var members []string
+			z := zsets[args[1]]
+			for m := range z {
+				members = append(members, m)
+			}
+			sort.Slice(members, func(i, j int) bool {
+				var a, b float64
+				fmt.Sscan(z[members[i]], &a)
+				fmt.Sscan(z[members[j]], &b)
+				return a < b
+			})
+			return bulks(members)
+		case "SISMEMBER":
+			if sets[args[1]][args[2]] {
+				return ":1\r\n"
+			}
+			return ":0\r\n"
+		case "HSET":
+			h := hashes[args[1]]
+			if h == nil {
+				h = make(map[string]string)
+				hashes[args[1]] = h
+			}
+			for i := 2; i < len(args); i += 2 {
+				h[args[i]] = args[i+1]
+			}
+			return ":1\r\n"
+		case "HDEL":
+			for _, f := range args[2:] {
+				delete(hashes[args[1]], f)
+			}
+			return ":1\r\n"
+		case "DEL":
+			delete(values, args[1])
+			delete(zsets, args[1])
+			delete(hashes, args[1])
+			delete(sets, args[1])
+			delete(ttls, args[1])
+			return ":1\r\n"
+		case "EXISTS":
+			if hashes[args[1]] != nil || sets[args[1]] != nil {
+				return ":1\r\n"
+			}
+			return ":0\r\n"
+		case "RENAME":
+			if h := hashes[args[1]]; h != nil {
+				hashes[args[2]] = h
+			} else if s := sets[args[1]]; s != nil {
+				sets[args[2]] = s
+			} else {
+				return "-ERR no such key\r\n"
+			}
+			if ttl, ok := ttls[args[1]]; ok {
+				ttls[args[2]] = ttl
+			}
+			delete(hashes, args[1])
+			delete(sets, args[1])
+			delete(ttls, args[1])
+			return "+OK\r\n"
+		case "WATCH", "UNWATCH":
+			return "+OK\r\n"
+		case "PEXPIRE":
+			ttls[args[1]] = args[2]
+			return ":1\r\n"
+		case "PERSIST":
+			delete(ttls, args[1])
+			return ":1\r\n"
+		case "PTTL":
+			if ttl, ok := ttls[args[1]]; ok {
+				return ":" + ttl + "\r\n"
+			}
+			return ":-1\r\n"
+		case "SADD":
+			s := sets[args[1]]
+			if s == nil {
+				s = make(map[string]bool)
+				sets[args[1]] = s
+			}
+			for _, m := range args[2:] {
+				s[m] = true
+			}
+			return ":1\r\n"
+		case "HGETALL":
+			var values []string
+			for k, v := range hashes[args[1]] {
+				values = append(values, k, v)
+			}
+			return bulks(values)
+		case "SMEMBERS":
+			var values []string
+			for m := range sets[args[1]] {
+				values = append(values, m)
+			}
+			sort.Strings(values)
+			return bulks(values)
+		case "XADD":
+			for i, arg := range args {
+				if arg == "*" {
+					xs[args[1]] = append(xs[args[1]], args[i+1:])
+					id := fmt.Sprintf("%d-0", len(xs[args[1]]))
+					return fmt.Sprintf("$%d\r\n%s\r\n", len(id), id)
+				}
+			}
+		case "XRANGE":
+			entries := xs[args[1]]
+			s := fmt.Sprintf("*%d\r\n", len(entries))
+			for i, fields := range entries {
+				id := fmt.Sprintf("%d-0", i+1)
+				s += fmt.Sprintf("*2\r\n$%d\r\n%s\r\n", len(id), id) + bulks(fields)
+			}
+			return s
+		}
+		return "-ERR unknown command\r\n"
+	}
+	l := serveFake(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case args[0] == "MULTI":
+			multi = true
+			queued = nil
+			return "+OK\r\n"
+		case args[0] == "EXEC":
+			multi = false
+			s := fmt.Sprintf("*%d\r\n", len(queued))
+			for _, cmd := range queued {
+				s += exec(strings.Split(cmd, "\x00"))
+			}
+			return s
+		case multi:
+			queued = append(queued, strings.Join(args, "\x00"))
+			return "+QUEUED\r\n"
+		}
+		return exec(args)
+	})
+	return l.Addr().String(), func() { l.Close() }
+}
+
+type mapperUser struct {
+	Name   string   `redis:"name"`
+	Tags   []string `redis:"tags,csv"`
+	Groups []int    `redis:"groups,set=:groups"`
+}
+
+func TestMapper(t *testing.T) {
+	addr, stop := serveStore(t)
+	defer stop()
+	c, err := redis.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	var m redis.Mapper
+	u := mapperUser{Name: "gopher", Tags: []string{"a", "b,c"}, Groups: []int{2, 1}}
+	if err := m.Save(c, "user:1", &u); err != nil {
+		t.Fatalf("Save returned %v", err)
+	}
+	var loaded mapperUser
+	if err := m.Load(c, "user:1", &loaded); err != nil {
+		t.Fatalf("Load returned %v", err)
+	}
+	sort.Ints(loaded.Groups)
+	expected := mapperUser{Name: "gopher", Tags: []string{"a", "b,c"}, Groups: []int{1, 2}}
+	if !reflect.DeepEqual(loaded, expected) {
+		t.Errorf("Load returned %+v, want %+v", loaded, expected)
+	}
+	if err := m.Load(c, "user:2", &loaded); err != redis.ErrNil {
+		t.Errorf("Load of missing key returned %v, want %v", err, redis.ErrNil)
+	}
+}
+
+type mapperSession struct {
+	User  string        `redis:"user"`
+	Roles []string      `redis:"roles,set=:roles"`
+	TTL   time.Duration `redis:",ttl"`
+}
+
+func TestMapperTTL(t *testing.T) {
+	addr, stop := serveStore(t)
+	defer stop()
+	c, err := redis.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	var m redis.Mapper
+	for _, ttl := range []time.Duration{90 * time.Second, 0} {
+		if err := m.Save(c, "session:1", &mapperSession{User: "u", Roles: []string{"admin"}, TTL: ttl}); err != nil {
+			t.Fatalf("Save returned %v", err)
+		}
+		for _, key := range []string{"session:1", "session:1:roles"} {
+			expected := int64(-1)
+			if ttl > 0 {
+				expected = int64(ttl / time.Millisecond)
+			}
+			if n, err := redis.Int64(c.Do("PTTL", key)); err != nil || n != expected {
+				t.Errorf("PTTL %s = %d, %v, want %d", key, n, err, expected)
+			}
+		}
+		var s mapperSession
+		if err := m.Load(c, "session:1", &s); err != nil {
+			t.Fatalf("Load returned %v", err)
+		}
+		if s.TTL != ttl {
+			t.Errorf("Load returned TTL %v, want %v", s.TTL, ttl)
+		}
+	}
+}
+
+type mapperPost struct {
+	Title     string   `redis:"title"`
+	Tags      []string `redis:"tags,set=:tags"`
+	DeletedAt int64    `redis:"deleted_at"`
+}
+
+func TestMapperSoftDelete(t *testing.T) {
+	addr, stop := serveStore(t)
+	defer stop()
+	c, err := redis.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	m := redis.Mapper{ArchiveTTL: time.Hour}
+	if err := m.Save(c, "post:1", &mapperPost{Title: "hello", Tags: []string{"go"}}); err != nil {
+		t.Fatalf("Save returned %v", err)
+	}
+	if err := m.SoftDelete(c, "post:1", mapperPost{}); err != nil {
+		t.Fatalf("SoftDelete returned %v", err)
+	}
+	var p mapperPost
+	if err := m.Load(c, "post:1", &p); err != redis.ErrNil {
+		t.Errorf("Load of deleted object returned %v, want %v", err, redis.ErrNil)
+	}
+	if err := m.LoadDeleted(c, "post:1", &p); err != nil {
+		t.Fatalf("LoadDeleted returned %v", err)
+	}
+	if p.Title != "hello" || !reflect.DeepEqual(p.Tags, []string{"go"}) || p.DeletedAt == 0 {
+		t.Errorf("LoadDeleted returned %+v", p)
+	}
+	for _, key := range []string{"archive:post:1", "archive:post:1:tags"} {
+		if n, err := redis.Int64(c.Do("PTTL", key)); err != nil || n != int64(time.Hour/time.Millisecond) {
+			t.Errorf("PTTL %s = %d, %v", key, n, err)
+		}
+	}
+	if err := m.SoftDelete(c, "post:1", &p); err != redis.ErrNil {
+		t.Errorf("SoftDelete of missing object returned %v, want %v", err, redis.ErrNil)
+	}
+
+	// Load ignores objects marked as deleted outside of the archive.
+	c.Do("HSET", "post:2", "title", "x", "deleted_at", "1")
+	if err := m.Load(c, "post:2", &p); err != redis.ErrNil {
+		t.Errorf("Load of marked object returned %v, want %v", err, redis.ErrNil)
+	}
+}
+
+type refUser struct {
+	Name      string   `redis:"name"`
+	FriendKey string   `redis:"friend,ref=Friend"`
+	Friend    *refUser `redis:"-"`
+}
+
+type refPost struct {
+	Title     string   `redis:"title"`
+	OwnerKey  string   `redis:"owner,ref=Owner"`
+	Owner     *refUser `redis:"-"`
+	EditorKey string   `redis:"editor,ref=Editor"`
+	Editor    *refUser `redis:"-"`
+}
+
+func TestMapperLoadWithRefs(t *testing.T) {
+	addr, stop := serveStore(t)
+	defer stop()
+	c, err := redis.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	var m redis.Mapper
+	for key, v := range map[string]interface{}{
+		"user:1": &refUser{Name: "a", FriendKey: "user:2"},
+		"user:2": &refUser{Name: "b", FriendKey: "user:1"},
+		"post:1": &refPost{Title: "x", OwnerKey: "user:1", EditorKey: "user:1"},
+		"post:2": &refPost{Title: "y", OwnerKey: "user:9"},
+	} {
+		if err := m.Save(c, key, v); err != nil {
+			t.Fatalf("Save returned %v", err)
+		}
+	}
+
+	var p refPost
+	if err := m.LoadWithRefs(c, "post:1", &p, 1); err != nil {
+		t.Fatalf("LoadWithRefs returned %v", err)
+	}
+	if p.Owner == nil || p.Owner.Name != "a" || p.Owner != p.Editor || p.Owner.Friend != nil {
+		t.Errorf("LoadWithRefs depth 1 returned %+v", p)
+	}
+
+	p = refPost{}
+	if err := m.LoadWithRefs(c, "post:1", &p, 10); err != nil {
+		t.Fatalf("LoadWithRefs returned %v", err)
+	}
+	if p.Owner == nil || p.Owner.Friend == nil || p.Owner.Friend.Name != "b" || p.Owner.Friend.Friend != p.Owner {
+		t.Errorf("LoadWithRefs did not resolve cycle: %+v", p)
+	}
+
+	p = refPost{}
+	if err := m.LoadWithRefs(c, "post:2", &p, 1); err != nil {
+		t.Fatalf("LoadWithRefs returned %v", err)
+	}
+	if p.Title != "y" || p.Owner != nil {
+		t.Errorf("LoadWithRefs with missing reference returned %+v", p)
+	}
+}
+
+// dialExecError returns a connection to a fake server that fails the first
+// command of every transaction with a WRONGTYPE error and a function to
+// stop the server.
+func dialExecError(t *testing.T) (redis.Conn, func()) {
+	l := serveFake(t, func(args []string) string {
+		switch args[0] {
+		case "MULTI":
+			return "+OK\r\n"
+		case "EXEC":
+			return "*2\r\n-WRONGTYPE Operation against a key holding the wrong kind of value\r\n:1\r\n"
+		}
+		return "+QUEUED\r\n"
+	})
+	c, err := redis.Dial("tcp", l.Addr().String())
+	if err != nil {
+		l.Close()
+		t.Fatalf("Dial returned %v", err)
+	}
+	return c, func() { c.Close(); l.Close() }
+}
+
+func TestMapperSaveExecError(t *testing.T) {
+	c, stop := dialExecError(t)
+	defer stop()
+	var m redis.Mapper
+	err := m.Save(c, "user:1", &mapperUser{Name: "gopher", Groups: []int{1}})
+	if _, ok := err.(redis.Error); !ok || !strings.HasPrefix(err.Error(), "WRONGTYPE") {
+		t.Errorf("Save returned %v, want WRONGTYPE error", err)
+	}
+}
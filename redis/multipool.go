@@ -0,0 +1,297 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrNoHealthyEndpoint is returned by connections from a MultiPool when the
+// breakers of all endpoints are open.
+var ErrNoHealthyEndpoint = errors.New("redigo: no healthy endpoint")
+
+// MultiPool stripes connections across pools for equivalent endpoints of the
+// same logical service, for example several instances of a proxy. Get picks
+// an endpoint at random, weighted by the health of the endpoint. The health
+// of an endpoint decreases on connection errors and timeouts and recovers on
+// successful commands.
+//
+// Each endpoint has a breaker. The breaker opens after FailureThreshold
+// consecutive failures. While the breaker is open, the endpoint is not used.
+// After BreakerTimeout, a single connection is allowed to try the endpoint.
+// The breaker closes when the trial succeeds.
+//
+//  m := &redis.MultiPool{Pools: []*redis.Pool{
+//      {Network: "tcp", Address: "proxy1:6379", MaxIdle: 10},
+//      {Network: "tcp", Address: "proxy2:6379", MaxIdle: 10},
+//  }}
+//  c := m.Get()
+//  defer c.Close()
+//
+// Errors replied by the server do not affect the health of an endpoint.
+type MultiPool struct {
+	// Pools is the pool for each endpoint. Do not modify Pools after the
+	// first call to Get.
+	Pools []*Pool
+
+	// FailureThreshold is the number of consecutive failures that opens the
+	// breaker of an endpoint. The default is 5.
+	FailureThreshold int
+
+	// BreakerTimeout is the time that a breaker remains open before the
+	// endpoint is tried again. The default is 10 seconds.
+	BreakerTimeout time.Duration
+
+	mu        sync.Mutex
+	endpoints []*multiEndpoint
+}
+
+type multiEndpoint struct {
+	pool *Pool
+
+	// health is in the range (0, 1]. Endpoints are picked with probability
+	// proportional to health.
+	health float64
+
+	// failures is the number of consecutive failures.
+	failures int
+
+	// openUntil is the time that an open breaker allows a trial.
+	openUntil time.Time
+
+	// trial is true when a trial connection is in use.
+	trial bool
+}
+
+// MultiPoolStats is a snapshot of the state of an endpoint in a MultiPool.
+type MultiPoolStats struct {
+	PoolStats
+
+	// Address is the address of the endpoint's pool.
+	Address string
+
+	// Health is in the range (0, 1]. A healthy endpoint has health 1.
+	Health float64
+
+	// Open is true if the endpoint's breaker is open.
+	Open bool
+}
+
+func (m *MultiPool) failureThreshold() int {
+	if m.FailureThreshold > 0 {
+		return m.FailureThreshold
+	}
+	return 5
+}
+
+func (m *MultiPool) breakerTimeout() time.Duration {
+	if m.BreakerTimeout > 0 {
+		return m.BreakerTimeout
+	}
+	return 10 * time.Second
+}
+
+func (m *MultiPool) endpointsLocked() []*multiEndpoint {
+	if m.endpoints == nil {
+		m.endpoints = make([]*multiEndpoint, len(m.Pools))
+		for i, p := range m.Pools {
+			m.endpoints[i] = &multiEndpoint{pool: p, health: 1}
+		}
+	}
+	return m.endpoints
+}
+
+// available returns true if connections can be taken from the endpoint.
+func (m *MultiPool) available(e *multiEndpoint, now time.Time) bool {
+	if e.failures < m.failureThreshold() {
+		return true
+	}
+	return !e.trial && !now.Before(e.openUntil)
+}
+
+// Get gets a connection from the pool of a healthy endpoint. The application
+// must close the returned connection. If all breakers are open, then the
+// connection's methods return ErrNoHealthyEndpoint.
+func (m *MultiPool) Get() Conn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := nowFunc()
+	var total float64
+	for _, e := range m.endpointsLocked() {
+		if m.available(e, now) {
+			total += e.health
+		}
+	}
+	if total == 0 {
+		return &pooledConnection{err: ErrNoHealthyEndpoint}
+	}
+	r := rand.Float64() * total
+	var pick *multiEndpoint
+	for _, e := range m.endpoints {
+		if !m.available(e, now) {
+			continue
+		}
+		pick = e
+		if r -= e.health; r < 0 {
+			break
+		}
+	}
+	if pick.failures >= m.failureThreshold() {
+		pick.trial = true
+	}
+	return &multiConn{Conn: pick.pool.Get(), m: m, e: pick}
+}
+
+// report updates the health and breaker of e with the outcome of a
+// connection.
+func (m *MultiPool) report(e *multiEndpoint, ok, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wasTrial := e.trial
+	e.trial = false
+	switch {
+	case failed:
+		e.failures += 1
+		e.health /= 2
+		if e.health < 0.01 {
+			e.health = 0.01
+		}
+		if e.failures >= m.failureThreshold() {
+			e.openUntil = nowFunc().Add(m.breakerTimeout())
+		}
+	case ok:
+		e.failures = 0
+		e.health += (1 - e.health) / 4
+		if wasTrial {
+			// Give the recovered endpoint a fair share of the load.
+			e.health = 1
+		}
+	}
+}
+
+// Stats returns a snapshot of each endpoint in the order of Pools.
+func (m *MultiPool) Stats() []MultiPoolStats {
+	m.mu.Lock()
+	now := nowFunc()
+	stats := make([]MultiPoolStats, len(m.endpointsLocked()))
+	for i, e := range m.endpoints {
+		stats[i] = MultiPoolStats{
+			Address: e.pool.Address,
+			Health:  e.health,
+			Open:    e.failures >= m.failureThreshold() && now.Before(e.openUntil),
+		}
+	}
+	m.mu.Unlock()
+	for i, p := range m.Pools {
+		stats[i].PoolStats = p.Stats()
+	}
+	return stats
+}
+
+// Close closes the pools of all endpoints.
+func (m *MultiPool) Close() error {
+	var err error
+	for _, p := range m.Pools {
+		if e := p.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// isEndpointFailure returns true if err indicates that the endpoint is not
+// healthy.
+func isEndpointFailure(err error) bool {
+	var ne net.Error
+	return IsTransient(err) || errors.As(err, &ne)
+}
+
+type multiConn struct {
+	Conn
+	m *MultiPool
+	e *multiEndpoint
+
+	// ok is true if a call succeeded. failed is true if a call failed with
+	// an endpoint failure.
+	ok, failed bool
+}
+
+func (c *multiConn) record(err error) error {
+	switch {
+	case err == nil:
+		c.ok = true
+	case isEndpointFailure(err):
+		c.failed = true
+	}
+	return err
+}
+
+func (c *multiConn) Close() error {
+	if c.e == nil {
+		return nil
+	}
+	err := c.Conn.Close()
+	c.m.report(c.e, c.ok, c.failed)
+	c.e = nil
+	return err
+}
+
+func (c *multiConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	reply, err := c.Conn.Do(commandName, args...)
+	return reply, c.record(err)
+}
+
+func (c *multiConn) Send(commandName string, args ...interface{}) error {
+	return c.record(c.Conn.Send(commandName, args...))
+}
+
+func (c *multiConn) Flush() error {
+	return c.record(c.Conn.Flush())
+}
+
+func (c *multiConn) Receive() (interface{}, error) {
+	reply, err := c.Conn.Receive()
+	return reply, c.record(err)
+}
+
+func (c *multiConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	reply, err := DoWithTimeout(c.Conn, timeout, commandName, args...)
+	return reply, c.record(err)
+}
+
+func (c *multiConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	reply, err := ReceiveWithTimeout(c.Conn, timeout)
+	return reply, c.record(err)
+}
+
+func (c *multiConn) doTo(w io.Writer, commandName string, args []interface{}) (int64, error) {
+	n, err := DoTo(c.Conn, w, commandName, args...)
+	return n, c.record(err)
+}
+
+func (c *multiConn) receiveTo(w io.Writer) (int64, error) {
+	n, err := ReceiveTo(c.Conn, w)
+	return n, c.record(err)
+}
+
+func (c *multiConn) withContext(ctx context.Context, f func() error) error {
+	return c.record(withContext(c.Conn, ctx, f))
+}
@@ -0,0 +1,93 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestMultiPool(t *testing.T) {
+	now := time.Unix(1000, 0)
+	nowFunc = func() time.Time { return now }
+	defer func() { nowFunc = time.Now }()
+
+	var open int
+	down := [2]bool{true, false}
+	dial := func(i int) func() (Conn, error) {
+		return func() (Conn, error) {
+			if down[i] {
+				return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+			}
+			open += 1
+			return &fakeConn{open: &open}, nil
+		}
+	}
+	m := &MultiPool{
+		Pools:            []*Pool{{Dial: dial(0), Address: "a"}, {Dial: dial(1), Address: "b"}},
+		FailureThreshold: 2,
+		BreakerTimeout:   time.Minute,
+	}
+	defer m.Close()
+
+	do := func() error {
+		c := m.Get()
+		defer c.Close()
+		_, err := c.Do("PING")
+		return err
+	}
+
+	failures := 0
+	for i := 0; i < 100; i++ {
+		if do() != nil {
+			failures += 1
+		}
+	}
+	if failures != 2 {
+		t.Errorf("failures = %d, want 2", failures)
+	}
+	stats := m.Stats()
+	if !stats[0].Open || stats[0].Health >= 1 || stats[0].Address != "a" {
+		t.Errorf("Stats()[0] = %+v, want open breaker", stats[0])
+	}
+	if stats[1].Open || stats[1].Health != 1 {
+		t.Errorf("Stats()[1] = %+v, want healthy", stats[1])
+	}
+
+	// Open the breaker of the second endpoint.
+	down[1] = true
+	for i := 0; i < 2; i++ {
+		do()
+	}
+	if err := do(); err != ErrNoHealthyEndpoint {
+		t.Errorf("do with open breakers returned %v, want %v", err, ErrNoHealthyEndpoint)
+	}
+
+	// A successful trial closes the breaker.
+	now = now.Add(time.Minute)
+	down[0] = false
+	for i := 0; i < 100; i++ {
+		do()
+	}
+	stats = m.Stats()
+	if stats[0].Open || stats[0].Health != 1 {
+		t.Errorf("Stats()[0] = %+v, want closed breaker", stats[0])
+	}
+	if !stats[1].Open {
+		t.Errorf("Stats()[1] = %+v, want open breaker", stats[1])
+	}
+}
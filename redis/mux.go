@@ -0,0 +1,234 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+const (
+	// muxMaxBatch is the maximum number of commands written before a flush.
+	muxMaxBatch = 128
+
+	// muxMaxPending is the maximum number of commands written to the server
+	// and waiting for a reply.
+	muxMaxPending = 1024
+)
+
+var errMuxPipeline = errors.New("redigo: MuxConn does not support Send, Flush and Receive")
+
+// muxUnsupported is the set of commands that change the state of the
+// connection for all callers. CLIENT REPLY OFF and SKIP are also rejected
+// because the replies would no longer match the callers.
+var muxUnsupported = map[string]bool{
+	"":             true,
+	"DISCARD":      true,
+	"EXEC":         true,
+	"HELLO":        true,
+	"MONITOR":      true,
+	"MULTI":        true,
+	"PSUBSCRIBE":   true,
+	"PUNSUBSCRIBE": true,
+	"QUIT":         true,
+	"RESET":        true,
+	"SELECT":       true,
+	"SSUBSCRIBE":   true,
+	"SUBSCRIBE":    true,
+	"SUNSUBSCRIBE": true,
+	"UNSUBSCRIBE":  true,
+	"UNWATCH":      true,
+	"WATCH":        true,
+}
+
+type muxRequest struct {
+	commandName string
+	args        []interface{}
+	reply       interface{}
+	err         error
+	done        chan struct{}
+}
+
+// MuxConn multiplexes commands from concurrent goroutines over a single
+// connection. Commands issued by concurrent calls to Do are written to the
+// connection in batches and the replies are matched to the callers in order.
+// A MuxConn reduces the number of connections to the server and avoids
+// waiting for a connection from a pool.
+//
+// The Do and DoContext methods are safe for concurrent use. The Send, Flush
+// and Receive methods return an error. Transactions, Pub/Sub and other
+// commands that change the state of the connection for all callers are
+// rejected. A blocking command such as BLPOP delays the replies to all
+// commands issued after it.
+//
+//  c, err := redis.Dial("tcp", ":6379")
+//  if err != nil {
+//      // handle error
+//  }
+//  m := redis.NewMuxConn(c)
+//  defer m.Close()
+type MuxConn struct {
+	c        Conn
+	requests chan *muxRequest
+	pending  chan *muxRequest
+	stopped  chan struct{}
+	readDone chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewMuxConn returns a multiplexed connection using c. The MuxConn takes
+// ownership of c and closes c when the MuxConn is closed.
+func NewMuxConn(c Conn) *MuxConn {
+	m := &MuxConn{
+		c:        c,
+		requests: make(chan *muxRequest),
+		pending:  make(chan *muxRequest, muxMaxPending),
+		stopped:  make(chan struct{}),
+		readDone: make(chan struct{}),
+	}
+	go m.writeLoop()
+	go m.readLoop()
+	return m
+}
+
+// fail sets the permanent error for the connection and stops the writer.
+func (m *MuxConn) fail(err error) {
+	m.mu.Lock()
+	if m.err == nil {
+		m.err = err
+		close(m.stopped)
+	}
+	m.mu.Unlock()
+}
+
+func (m *MuxConn) writeLoop() {
+	defer close(m.pending)
+	batch := make([]*muxRequest, 0, muxMaxBatch)
+	for {
+		select {
+		case r := <-m.requests:
+			batch = append(batch[:0], r)
+		case <-m.stopped:
+			return
+		}
+	drain:
+		for len(batch) < muxMaxBatch {
+			select {
+			case r := <-m.requests:
+				batch = append(batch, r)
+			default:
+				break drain
+			}
+		}
+		var err error
+		for _, r := range batch {
+			if err = m.c.Send(r.commandName, r.args...); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = m.c.Flush()
+		}
+		if err != nil {
+			m.fail(err)
+			for _, r := range batch {
+				r.err = err
+				close(r.done)
+			}
+			return
+		}
+		for _, r := range batch {
+			m.pending <- r
+		}
+	}
+}
+
+func (m *MuxConn) readLoop() {
+	defer close(m.readDone)
+	for r := range m.pending {
+		if err := m.Err(); err != nil {
+			r.err = err
+		} else {
+			r.reply, r.err = m.c.Receive()
+			if _, ok := r.err.(Error); r.err != nil && !ok {
+				m.fail(r.err)
+			}
+		}
+		close(r.done)
+	}
+}
+
+// Close closes the connection. Calls to Do waiting for a reply return an
+// error.
+func (m *MuxConn) Close() error {
+	m.fail(errors.New("redigo: closed"))
+	err := m.c.Close()
+	<-m.readDone
+	return err
+}
+
+// Err returns a non-nil value when the connection is not usable.
+func (m *MuxConn) Err() error {
+	m.mu.Lock()
+	err := m.err
+	m.mu.Unlock()
+	return err
+}
+
+// Do sends a command to the server and returns the received reply. Do is safe
+// for concurrent use.
+func (m *MuxConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return m.DoContext(context.Background(), commandName, args...)
+}
+
+// DoContext is like Do, but returns when the context is done. The command is
+// still executed by the server when the context is done after the command is
+// written.
+func (m *MuxConn) DoContext(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	mode := clientReplyMode(commandName, args)
+	if muxUnsupported[strings.ToUpper(commandName)] || mode == "OFF" || mode == "SKIP" {
+		return nil, errors.New("redigo: MuxConn does not support command " + strings.ToUpper(commandName))
+	}
+	r := &muxRequest{commandName: commandName, args: args, done: make(chan struct{})}
+	select {
+	case m.requests <- r:
+	case <-m.stopped:
+		return nil, m.Err()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case <-r.done:
+		return r.reply, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (m *MuxConn) Send(commandName string, args ...interface{}) error {
+	return errMuxPipeline
+}
+
+func (m *MuxConn) Flush() error {
+	return errMuxPipeline
+}
+
+func (m *MuxConn) Receive() (interface{}, error) {
+	return nil, errMuxPipeline
+}
@@ -0,0 +1,111 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMuxConn(t *testing.T) {
+	l := serveFake(t, func(args []string) string {
+		if args[0] == "ECHO" {
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(args[1]), args[1])
+		}
+		return "-ERR unknown command\r\n"
+	})
+	defer l.Close()
+	c, err := redis.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	m := redis.NewMuxConn(c)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				want := fmt.Sprintf("%d-%d", i, j)
+				if s, err := redis.String(m.Do("ECHO", want)); err != nil || s != want {
+					t.Errorf("Do(ECHO, %s) returned %q, %v", want, s, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if _, err := m.Do("FOO"); err == nil {
+		t.Error("Do(FOO) did not return error")
+	} else if _, ok := err.(redis.Error); !ok {
+		t.Errorf("Do(FOO) returned %v, want redis.Error", err)
+	}
+	if m.Err() != nil {
+		t.Fatalf("Err() after error reply = %v", m.Err())
+	}
+	for _, args := range [][]interface{}{{"MULTI"}, {"HELLO", 3}, {"CLIENT", "REPLY", "OFF"}, {"client", []byte("reply"), []byte("skip")}} {
+		if _, err := m.Do(args[0].(string), args[1:]...); err == nil {
+			t.Errorf("Do(%v) did not return error", args)
+		}
+	}
+	if err := m.Send("ECHO", "x"); err == nil {
+		t.Error("Send did not return error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := m.DoContext(ctx, "ECHO", "x"); err != context.Canceled {
+		t.Errorf("DoContext with canceled context returned %v", err)
+	}
+
+	m.Close()
+	if _, err := m.Do("ECHO", "x"); err == nil {
+		t.Error("Do after Close did not return error")
+	}
+}
+
+func TestMuxConnCloseInFlight(t *testing.T) {
+	l := serveFake(t, func(args []string) string {
+		time.Sleep(time.Second)
+		return "+OK\r\n"
+	})
+	defer l.Close()
+	c, err := redis.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	m := redis.NewMuxConn(c)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := m.Do("PING")
+		errs <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	m.Close()
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("in-flight Do returned nil error after Close")
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Error("in-flight Do did not return after Close")
+	}
+}
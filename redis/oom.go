@@ -0,0 +1,176 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// OOMHandler handles OOM error replies from a server that reached the
+// maxmemory limit. An OOMHandler is shared by the connections dialed with
+// the DialOOMHandler option.
+type OOMHandler struct {
+	// OnOOM is an optional function called with the command name and the
+	// error when the server replies with an OOM error. Applications use the
+	// function to shed cache entries or to raise an alert. The command name
+	// is "" for replies to commands pipelined before a call to Do.
+	OnOOM func(commandName string, err Error)
+
+	// BestEffort is an optional function that reports whether writes to key
+	// can be dropped when the server is out of memory. Do and Receive return
+	// a nil reply and a nil error instead of the OOM error for commands with
+	// a best-effort key as the first argument.
+	BestEffort func(key string) bool
+
+	count int64
+}
+
+// Count returns the number of OOM error replies handled by h.
+func (h *OOMHandler) Count() int64 {
+	return atomic.LoadInt64(&h.count)
+}
+
+// DialOOMHandler specifies a handler for OOM error replies.
+func DialOOMHandler(h *OOMHandler) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.oom = h
+	}}
+}
+
+// isOOM returns true if err is the error returned by a server that reached
+// the maxmemory limit.
+func isOOM(err error) bool {
+	e, ok := err.(Error)
+	return ok && e.kind() == "OOM"
+}
+
+// handle calls the OOM callback and returns the error to return to the
+// application.
+func (h *OOMHandler) handle(commandName string, key interface{}, err error) error {
+	if !isOOM(err) {
+		return err
+	}
+	atomic.AddInt64(&h.count, 1)
+	if h.OnOOM != nil {
+		h.OnOOM(commandName, err.(Error))
+	}
+	if h.BestEffort != nil && commandName != "" {
+		switch key := key.(type) {
+		case string:
+			if h.BestEffort(key) {
+				return nil
+			}
+		case []byte:
+			if h.BestEffort(string(key)) {
+				return nil
+			}
+		}
+	}
+	return err
+}
+
+type oomCommand struct {
+	name string
+	key  interface{}
+}
+
+type oomConn struct {
+	connWrapper
+	h       *OOMHandler
+	pending []oomCommand
+}
+
+func firstArg(args []interface{}) interface{} {
+	if len(args) == 0 {
+		return nil
+	}
+	return args[0]
+}
+
+func (c *oomConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return c.do(commandName, args, func() (interface{}, error) {
+		return c.Conn.Do(commandName, args...)
+	})
+}
+
+func (c *oomConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	return c.do(commandName, args, func() (interface{}, error) {
+		return DoWithTimeout(c.Conn, timeout, commandName, args...)
+	})
+}
+
+func (c *oomConn) doTo(w io.Writer, commandName string, args []interface{}) (int64, error) {
+	var n int64
+	_, err := c.do(commandName, args, func() (interface{}, error) {
+		var err error
+		n, err = DoTo(c.Conn, w, commandName, args...)
+		return nil, err
+	})
+	return n, err
+}
+
+// do calls f to execute the command and handles OOM errors.
+func (c *oomConn) do(commandName string, args []interface{}, f func() (interface{}, error)) (interface{}, error) {
+	pending := len(c.pending)
+	c.pending = c.pending[:0]
+	reply, err := f()
+	if pending > 0 {
+		// The error may be from a pipelined command.
+		commandName = ""
+	}
+	if isOOM(err) {
+		if err = c.h.handle(commandName, firstArg(args), err); err == nil {
+			reply = nil
+		}
+	}
+	return reply, err
+}
+
+func (c *oomConn) Send(commandName string, args ...interface{}) error {
+	err := c.Conn.Send(commandName, args...)
+	if err == nil {
+		c.pending = append(c.pending, oomCommand{commandName, firstArg(args)})
+	}
+	return err
+}
+
+func (c *oomConn) next() oomCommand {
+	var cmd oomCommand
+	if len(c.pending) > 0 {
+		cmd = c.pending[0]
+		c.pending = c.pending[1:]
+	}
+	return cmd
+}
+
+func (c *oomConn) Receive() (interface{}, error) {
+	reply, err := c.Conn.Receive()
+	cmd := c.next()
+	return reply, c.h.handle(cmd.name, cmd.key, err)
+}
+
+func (c *oomConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	reply, err := ReceiveWithTimeout(c.Conn, timeout)
+	cmd := c.next()
+	return reply, c.h.handle(cmd.name, cmd.key, err)
+}
+
+func (c *oomConn) receiveTo(w io.Writer) (int64, error) {
+	n, err := ReceiveTo(c.Conn, w)
+	cmd := c.next()
+	return n, c.h.handle(cmd.name, cmd.key, err)
+}
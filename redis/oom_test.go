@@ -0,0 +1,72 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"strings"
+	"testing"
+)
+
+func TestDialOOMHandler(t *testing.T) {
+	l := serveFake(t, func(args []string) string {
+		if args[0] == "SET" {
+			return "-OOM command not allowed when used memory > 'maxmemory'.\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer l.Close()
+
+	var commands []string
+	h := &redis.OOMHandler{
+		OnOOM: func(commandName string, err redis.Error) {
+			commands = append(commands, commandName)
+		},
+		BestEffort: func(key string) bool {
+			return strings.HasPrefix(key, "cache:")
+		},
+	}
+	c, err := redis.Dial("tcp", l.Addr().String(), redis.DialOOMHandler(h))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	if _, err := c.Do("SET", "k", "v"); err == nil {
+		t.Errorf("Do(SET k) did not return error")
+	}
+	if reply, err := c.Do("SET", "cache:k", "v"); reply != nil || err != nil {
+		t.Errorf("Do(SET cache:k) returned %v, %v, want nil, nil", reply, err)
+	}
+	if _, err := c.Do("PING"); err != nil {
+		t.Errorf("Do(PING) returned %v", err)
+	}
+	c.Send("SET", "cache:k", "v")
+	c.Send("SET", "k", "v")
+	c.Flush()
+	if _, err := c.Receive(); err != nil {
+		t.Errorf("Receive for SET cache:k returned %v", err)
+	}
+	if _, err := c.Receive(); err == nil {
+		t.Errorf("Receive for SET k did not return error")
+	}
+
+	if n := h.Count(); n != 4 {
+		t.Errorf("Count() = %d, want 4", n)
+	}
+	if len(commands) != 4 || commands[0] != "SET" {
+		t.Errorf("OnOOM called with %q", commands)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Parallel runs the tasks concurrently, each with a connection from the pool,
+// and returns the errors from the tasks joined with errors.Join. The number
+// of tasks running at the same time is limited by the pool's MaxActive
+// field. The connection passed to a task applies ctx to Do and Receive as
+// described for DoContext. The connections are obtained with the pool's
+// GetContext method. Tasks that are not started before ctx is done or that
+// cannot get a connection are skipped and the error is included in the
+// result. The connections are returned to the pool before Parallel returns.
+//
+//  var a, b []string
+//  err := redis.Parallel(ctx, pool,
+//      func(c redis.Conn) (err error) {
+//          a, err = redis.Strings(c.Do("LRANGE", "a", 0, -1))
+//          return err
+//      },
+//      func(c redis.Conn) (err error) {
+//          b, err = redis.Strings(c.Do("LRANGE", "b", 0, -1))
+//          return err
+//      })
+func Parallel(ctx context.Context, p *Pool, tasks ...func(Conn) error) error {
+	n := len(tasks)
+	if max := p.Options().MaxActive; max > 0 && max < n {
+		n = max
+	}
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, n)
+		errs = make([]error, len(tasks))
+	)
+	for i, task := range tasks {
+		if err := ctx.Err(); err != nil {
+			errs[i] = err
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(i int, task func(Conn) error) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			c, err := p.GetContext(ctx)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer c.Close()
+			errs[i] = task(contextBoundConn{c, ctx})
+		}(i, task)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// contextBoundConn applies a context to the Do and Receive methods of a
+// connection.
+type contextBoundConn struct {
+	Conn
+	ctx context.Context
+}
+
+func (c contextBoundConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return DoContext(c.Conn, c.ctx, commandName, args...)
+}
+
+func (c contextBoundConn) Receive() (interface{}, error) {
+	return ReceiveContext(c.Conn, c.ctx)
+}
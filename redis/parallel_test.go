@@ -0,0 +1,88 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParallel(t *testing.T) {
+	p := &Pool{
+		MaxIdle:   2,
+		MaxActive: 2,
+		Dial:      func() (Conn, error) { return &fakeConn{open: new(int)}, nil },
+	}
+	defer p.Close()
+
+	var (
+		mu            sync.Mutex
+		running, peak int
+	)
+	task := func(c Conn) error {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		_, err := c.Do("PING")
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return err
+	}
+	errTask := errors.New("task failed")
+	err := Parallel(context.Background(), p, task, task, task, func(c Conn) error { return errTask })
+	if !errors.Is(err, errTask) {
+		t.Errorf("Parallel returned %v, want %v", err, errTask)
+	}
+	if peak != 2 {
+		t.Errorf("peak concurrency = %d, want 2", peak)
+	}
+	if stats := p.Stats(); stats.ActiveCount != stats.IdleCount {
+		t.Errorf("stats = %+v, want all connections returned", stats)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ran := false
+	err = Parallel(ctx, p, func(c Conn) error { ran = true; return nil })
+	if ran || !errors.Is(err, context.Canceled) {
+		t.Errorf("Parallel with canceled context returned %v, ran=%v", err, ran)
+	}
+
+	// Tasks wait for a connection until the context is done.
+	p1 := &Pool{
+		MaxActive: 1,
+		Dial:      func() (Conn, error) { return &fakeConn{open: new(int)}, nil },
+	}
+	defer p1.Close()
+	c, err := p1.GetContext(context.Background())
+	if err != nil {
+		t.Fatalf("GetContext returned %v", err)
+	}
+	defer c.Close()
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = Parallel(ctx, p1, func(c Conn) error { ran = true; return nil })
+	if ran || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Parallel with exhausted pool returned %v, ran=%v", err, ran)
+	}
+}
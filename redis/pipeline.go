@@ -0,0 +1,313 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+)
+
+var errResultNotReady = errors.New("redigo: pipeline result read before Exec")
+
+// Result is the deferred reply to a command queued in a Pipeline. The reply
+// is available after the pipeline is executed.
+type Result struct {
+	reply interface{}
+	err   error
+	ready bool
+}
+
+// Reply returns the reply to the command. Reply returns an error if the
+// pipeline has not been executed.
+func (r *Result) Reply() (interface{}, error) {
+	if !r.ready {
+		return nil, errResultNotReady
+	}
+	return r.reply, r.err
+}
+
+func (r *Result) set(reply interface{}, err error) {
+	r.reply, r.err, r.ready = reply, err, true
+}
+
+// IntResult is a deferred reply converted with Int.
+type IntResult struct{ Result }
+
+// Val returns the reply converted with Int.
+func (r *IntResult) Val() (int, error) { return Int(r.Reply()) }
+
+// Int64Result is a deferred reply converted with Int64.
+type Int64Result struct{ Result }
+
+// Val returns the reply converted with Int64.
+func (r *Int64Result) Val() (int64, error) { return Int64(r.Reply()) }
+
+// Float64Result is a deferred reply converted with Float64.
+type Float64Result struct{ Result }
+
+// Val returns the reply converted with Float64.
+func (r *Float64Result) Val() (float64, error) { return Float64(r.Reply()) }
+
+// StringResult is a deferred reply converted with String.
+type StringResult struct{ Result }
+
+// Val returns the reply converted with String.
+func (r *StringResult) Val() (string, error) { return String(r.Reply()) }
+
+// BytesResult is a deferred reply converted with Bytes.
+type BytesResult struct{ Result }
+
+// Val returns the reply converted with Bytes.
+func (r *BytesResult) Val() ([]byte, error) { return Bytes(r.Reply()) }
+
+// BoolResult is a deferred reply converted with Bool.
+type BoolResult struct{ Result }
+
+// Val returns the reply converted with Bool.
+func (r *BoolResult) Val() (bool, error) { return Bool(r.Reply()) }
+
+// StringsResult is a deferred reply converted with Strings.
+type StringsResult struct{ Result }
+
+// Val returns the reply converted with Strings.
+func (r *StringsResult) Val() ([]string, error) { return Strings(r.Reply()) }
+
+// ValuesResult is a deferred reply converted with Values.
+type ValuesResult struct{ Result }
+
+// Val returns the reply converted with Values.
+func (r *ValuesResult) Val() ([]interface{}, error) { return Values(r.Reply()) }
+
+type pipelineCommand struct {
+	commandName string
+	args        []interface{}
+	result      *Result
+}
+
+// Pipeline queues commands and executes the commands in a single round trip.
+// Each queued command returns a result that holds the reply after Exec
+// returns. A pipeline matches replies to commands, so applications do not
+// need to count calls to Receive.
+//
+//  p := redis.NewPipeline(c)
+//  n := p.Int("INCR", "counter")
+//  name := p.String("GET", "name")
+//  if err := p.Exec(); err != nil {
+//      // handle error
+//  }
+//  count, err := n.Val()
+//
+// A Pipeline is not safe for concurrent use.
+type Pipeline struct {
+	c        Conn
+	tx       bool
+	commands []pipelineCommand
+}
+
+// NewPipeline returns a pipeline that executes commands on c.
+func NewPipeline(c Conn) *Pipeline {
+	return &Pipeline{c: c}
+}
+
+// NewTxPipeline returns a pipeline that executes the queued commands in a
+// MULTI/EXEC transaction. The results of the commands are the elements of the
+// EXEC reply.
+func NewTxPipeline(c Conn) *Pipeline {
+	return &Pipeline{c: c, tx: true}
+}
+
+func (p *Pipeline) queue(r *Result, commandName string, args []interface{}) {
+	p.commands = append(p.commands, pipelineCommand{commandName, args, r})
+}
+
+// Do queues a command and returns the deferred reply.
+func (p *Pipeline) Do(commandName string, args ...interface{}) *Result {
+	r := &Result{}
+	p.queue(r, commandName, args)
+	return r
+}
+
+// Int queues a command with a reply converted with Int.
+func (p *Pipeline) Int(commandName string, args ...interface{}) *IntResult {
+	r := &IntResult{}
+	p.queue(&r.Result, commandName, args)
+	return r
+}
+
+// Int64 queues a command with a reply converted with Int64.
+func (p *Pipeline) Int64(commandName string, args ...interface{}) *Int64Result {
+	r := &Int64Result{}
+	p.queue(&r.Result, commandName, args)
+	return r
+}
+
+// Float64 queues a command with a reply converted with Float64.
+func (p *Pipeline) Float64(commandName string, args ...interface{}) *Float64Result {
+	r := &Float64Result{}
+	p.queue(&r.Result, commandName, args)
+	return r
+}
+
+// String queues a command with a reply converted with String.
+func (p *Pipeline) String(commandName string, args ...interface{}) *StringResult {
+	r := &StringResult{}
+	p.queue(&r.Result, commandName, args)
+	return r
+}
+
+// Bytes queues a command with a reply converted with Bytes.
+func (p *Pipeline) Bytes(commandName string, args ...interface{}) *BytesResult {
+	r := &BytesResult{}
+	p.queue(&r.Result, commandName, args)
+	return r
+}
+
+// Bool queues a command with a reply converted with Bool.
+func (p *Pipeline) Bool(commandName string, args ...interface{}) *BoolResult {
+	r := &BoolResult{}
+	p.queue(&r.Result, commandName, args)
+	return r
+}
+
+// Strings queues a command with a reply converted with Strings.
+func (p *Pipeline) Strings(commandName string, args ...interface{}) *StringsResult {
+	r := &StringsResult{}
+	p.queue(&r.Result, commandName, args)
+	return r
+}
+
+// Values queues a command with a reply converted with Values.
+func (p *Pipeline) Values(commandName string, args ...interface{}) *ValuesResult {
+	r := &ValuesResult{}
+	p.queue(&r.Result, commandName, args)
+	return r
+}
+
+// Len returns the number of queued commands.
+func (p *Pipeline) Len() int {
+	return len(p.commands)
+}
+
+// Exec sends the queued commands to the server and sets the results of the
+// commands. Exec returns the first connection error or error reply. The
+// results of the commands are set when Exec returns an error. The pipeline is
+// empty after Exec returns and can be reused.
+//
+// If the pipeline executes a transaction aborted by WATCH, then Exec returns
+// ErrNil and the results of the commands are ErrNil.
+func (p *Pipeline) Exec() error {
+	commands := p.commands
+	p.commands = nil
+	if len(commands) == 0 {
+		return nil
+	}
+	if p.tx {
+		return p.execTx(commands)
+	}
+	for _, cmd := range commands {
+		if err := p.c.Send(cmd.commandName, cmd.args...); err != nil {
+			return failCommands(commands, err)
+		}
+	}
+	if err := p.c.Flush(); err != nil {
+		return failCommands(commands, err)
+	}
+	var first error
+	for i, cmd := range commands {
+		reply, err := p.c.Receive()
+		cmd.result.set(reply, err)
+		if err != nil && first == nil {
+			first = err
+		}
+		if _, ok := err.(Error); err != nil && !ok {
+			return failCommands(commands[i+1:], err)
+		}
+	}
+	return first
+}
+
+func (p *Pipeline) execTx(commands []pipelineCommand) error {
+	p.c.Send("MULTI")
+	for _, cmd := range commands {
+		p.c.Send(cmd.commandName, cmd.args...)
+	}
+	if err := p.c.Send("EXEC"); err != nil {
+		return failCommands(commands, err)
+	}
+	if err := p.c.Flush(); err != nil {
+		return failCommands(commands, err)
+	}
+	if _, err := p.c.Receive(); err != nil {
+		// MULTI failed. Read the remaining replies to keep the
+		// connection in sync.
+		for range commands {
+			p.c.Receive()
+		}
+		p.c.Receive()
+		return failCommands(commands, err)
+	}
+	var first error
+	for i, cmd := range commands {
+		if _, err := p.c.Receive(); err != nil {
+			// The command was rejected when queued.
+			cmd.result.set(nil, err)
+			if first == nil {
+				first = err
+			}
+			if _, ok := err.(Error); !ok {
+				return failCommands(commands[i+1:], err)
+			}
+		}
+	}
+	reply, err := p.c.Receive()
+	if reply == nil && err == nil {
+		// The transaction was aborted. Report ErrNil in legacy builds too.
+		err = ErrNil
+	}
+	replies, err := Values(reply, err)
+	if err != nil {
+		for _, cmd := range commands {
+			if !cmd.result.ready {
+				cmd.result.set(nil, err)
+			}
+		}
+		if first != nil {
+			return first
+		}
+		return err
+	}
+	if len(replies) != len(commands) {
+		return failCommands(commands, errors.New("redigo: EXEC reply does not match queued commands"))
+	}
+	for i, cmd := range commands {
+		reply := replies[i]
+		if e, ok := reply.(Error); ok {
+			cmd.result.set(nil, e)
+			if first == nil {
+				first = e
+			}
+		} else {
+			cmd.result.set(reply, nil)
+		}
+	}
+	return first
+}
+
+// failCommands sets the result of the commands to err and returns err.
+func failCommands(commands []pipelineCommand, err error) error {
+	for _, cmd := range commands {
+		cmd.result.set(nil, err)
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	addr, stop := serveStore(t)
+	defer stop()
+	c, err := redis.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	for _, newPipeline := range []func(redis.Conn) *redis.Pipeline{redis.NewPipeline, redis.NewTxPipeline} {
+		p := newPipeline(c)
+		set := p.String("SET", "k", "hello")
+		get := p.String("GET", "k")
+		added := p.Bool("SADD", "s", "a")
+		missing := p.Bytes("GET", "missing")
+		if _, err := get.Val(); err == nil {
+			t.Error("Val before Exec did not return error")
+		}
+		if p.Len() != 4 {
+			t.Errorf("Len() = %d, want 4", p.Len())
+		}
+		if err := p.Exec(); err != nil {
+			t.Fatalf("Exec returned %v", err)
+		}
+		if s, err := set.Val(); err != nil || s != "OK" {
+			t.Errorf("SET returned %q, %v", s, err)
+		}
+		if s, err := get.Val(); err != nil || s != "hello" {
+			t.Errorf("GET returned %q, %v", s, err)
+		}
+		if ok, err := added.Val(); err != nil || !ok {
+			t.Errorf("SADD returned %v, %v", ok, err)
+		}
+		if _, err := missing.Val(); err != errNil {
+			t.Errorf("GET missing returned %v, want ErrNil", err)
+		}
+		if p.Len() != 0 {
+			t.Errorf("Len() after Exec = %d, want 0", p.Len())
+		}
+	}
+
+	p := redis.NewPipeline(c)
+	bad := p.Do("BOGUS")
+	get := p.String("GET", "k")
+	if err := p.Exec(); err == nil {
+		t.Error("Exec with unknown command did not return error")
+	}
+	if _, err := bad.Reply(); err == nil {
+		t.Error("BOGUS result does not have error")
+	}
+	if s, err := get.Val(); err != nil || s != "hello" {
+		t.Errorf("GET after error returned %q, %v", s, err)
+	}
+	if s, err := redis.String(c.Do("GET", "k")); err != nil || s != "hello" {
+		t.Errorf("connection out of sync: GET returned %q, %v", s, err)
+	}
+}
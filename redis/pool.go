@@ -16,7 +16,9 @@ package redis
 
 import (
 	"container/list"
+	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -25,6 +27,11 @@ var nowFunc = time.Now // for testing
 
 var errPoolClosed = errors.New("redigo: connection pool closed")
 
+// ErrPoolExhausted is returned from a pool connection method (Do, Send,
+// Receive, Flush, Err) when the maximum number of active connections in the
+// pool has been reached.
+var ErrPoolExhausted = errors.New("redigo: connection pool exhausted")
+
 // Pool maintains a pool of connections. The application calls the Get method
 // to get a connection from the pool and the connection's Close method to
 // return the connection's resources to the pool.
@@ -41,15 +48,7 @@ var errPoolClosed = errors.New("redigo: connection pool closed")
 //              MaxIdle: 3,
 //              IdleTimeout: 240 * time.Second,
 //              Dial: func () (redis.Conn, error) {
-//                  c, err := redis.Dial("tcp", server)
-//                  if err != nil {
-//                      return nil, err
-//                  }
-//                  if err := c.Do("AUTH", password); err != nil {
-//                      c.Close()
-//                      return nil, err
-//                  }
-//                  return c, err
+//                  return redis.Dial("tcp", server, redis.DialPassword(password))
 //              },
 //				TestOnBorrow: func(c redis.Conn, t time.Time) error {
 //				    _, err := c.Do("PING")
@@ -69,8 +68,35 @@ var errPoolClosed = errors.New("redigo: connection pool closed")
 type Pool struct {
 
 	// Dial is an application supplied function for creating new connections.
+	// If Dial is nil, then the pool dials Network and Address with
+	// DialOptions.
 	Dial func() (Conn, error)
 
+	// Network, Address and DialOptions are the arguments to the Dial
+	// function used by the pool when the Dial field is nil. Use DialOptions
+	// to specify TLS and authentication for connections dialed by the pool.
+	// Set Network to "unix" and Address to the socket path to connect to a
+	// Unix domain socket.
+	Network     string
+	Address     string
+	DialOptions []DialOption
+
+	// Hook is an optional hook called for the commands executed on
+	// connections dialed by the pool. See NewHookConn.
+	Hook Hook
+
+	// Limiter is an optional limit on the number of commands in flight on
+	// the connections dialed by the pool.
+	Limiter *AdaptiveLimiter
+
+	// DialLimiter optionally limits the rate at which the pool dials new
+	// connections. The limiter can be shared by pools.
+	DialLimiter *DialLimiter
+
+	// Guard optionally blocks dangerous commands on the connections dialed
+	// by the pool. See Guard.
+	Guard *Guard
+
 	// TestOnBorrow is an optional application supplied function for checking
 	// the health of an idle connection before the connection is used again by
 	// the application. Argument t is the time that the connection was returned
@@ -78,25 +104,61 @@ type Pool struct {
 	// closed.
 	TestOnBorrow func(c Conn, t time.Time) error
 
-	// Maximum number of idle connections in the pool.
+	// Maximum number of idle connections in the pool. Use Reconfigure to
+	// change MaxIdle, MaxActive, Wait, IdleTimeout and DialOptions after the
+	// pool is in use.
 	MaxIdle int
 
+	// Maximum number of connections allocated by the pool at a given time,
+	// including idle connections. When zero, there is no limit on the number
+	// of connections in the pool. Connections in a class are not counted.
+	MaxActive int
+
+	// If Wait is true and the pool is at the MaxActive limit, then Get waits
+	// for a connection to be returned to the pool before returning.
+	Wait bool
+
 	// Close connections after remaining idle for this duration. If the value
 	// is zero, then idle connections are not closed. Applications should set
 	// the timeout to a value less than the server's timeout.
 	IdleTimeout time.Duration
 
+	// ClassLimits is the maximum number of connections in each connection
+	// class. A class without a limit has no limit on the number of
+	// connections. See GetClass.
+	ClassLimits map[string]int
+
 	// mu protects fields defined below.
 	mu     sync.Mutex
 	closed bool
+	active int
+
+	// Number of active connections in each class.
+	classActive map[string]int
+
+	// Goroutines waiting for a connection. The values are of type
+	// chan struct{}.
+	waiters list.List
+
+	// gen is incremented by Recycle and RecycleAll. Connections from an
+	// older generation are closed instead of returned to the idle list unless
+	// RecycleAll is in progress.
+	gen int
+
+	// Number of active connections from an older generation.
+	stale int
+
+	// Number of calls to RecycleAll in progress.
+	recycling int
 
 	// Stack of idleConn with most recently used at the front.
 	idle list.List
 }
 
 type idleConn struct {
-	c Conn
-	t time.Time
+	c   Conn
+	t   time.Time
+	gen int
 }
 
 // NewPool returns a pool that uses newPool to create connections as needed.
@@ -105,89 +167,490 @@ func NewPool(newFn func() (Conn, error), maxIdle int) *Pool {
 	return &Pool{Dial: newFn, MaxIdle: maxIdle}
 }
 
+// dialFunc returns the function used to dial new connections. The context
+// bounds the wait for the DialLimiter and the dial of Network and Address.
+func (p *Pool) dialFunc() func(context.Context) (Conn, error) {
+	var dial func(context.Context) (Conn, error)
+	if f := p.Dial; f != nil {
+		dial = func(context.Context) (Conn, error) {
+			return f()
+		}
+	} else {
+		network, address, options := p.Network, p.Address, p.DialOptions
+		dial = func(ctx context.Context) (Conn, error) {
+			return DialContext(ctx, network, address, options...)
+		}
+	}
+	if dl := p.DialLimiter; dl != nil {
+		f := dial
+		dial = func(ctx context.Context) (Conn, error) {
+			if err := dl.wait(ctx); err != nil {
+				return nil, err
+			}
+			return f(ctx)
+		}
+	}
+	if limiter := p.Limiter; limiter != nil {
+		f := dial
+		dial = func(ctx context.Context) (Conn, error) {
+			c, err := f(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return &limitConn{connWrapper: connWrapper{c}, l: limiter}, nil
+		}
+	}
+	if guard := p.Guard; guard != nil {
+		f := dial
+		dial = func(ctx context.Context) (Conn, error) {
+			c, err := f(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return &guardConn{connWrapper: connWrapper{c}, g: guard}, nil
+		}
+	}
+	if hook := p.Hook; hook != nil {
+		f := dial
+		dial = func(ctx context.Context) (Conn, error) {
+			c, err := f(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return NewHookConn(c, hook), nil
+		}
+	}
+	return dial
+}
+
 // Get gets a connection from the pool.
 func (p *Pool) Get() Conn {
 	return &pooledConnection{p: p}
 }
 
+// GetContext gets a connection from the pool. If the pool is at the MaxActive
+// limit, then GetContext waits for a connection to be returned to the pool
+// until the context is done, independent of the Wait field. The context also
+// bounds the wait for the DialLimiter and, when the Dial field is nil, the
+// dial of a new connection. GetContext returns the context's error when the
+// context is done before a connection is available.
+func (p *Pool) GetContext(ctx context.Context) (Conn, error) {
+	c, gen, err := p.get(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return &pooledConnection{p: p, c: c, gen: gen}, nil
+}
+
+// GetClass returns a new connection in the named connection class. Use a
+// class for long-lived connections such as pub/sub and MONITOR connections
+// that should not count against the MaxActive limit for request traffic.
+// Connections in a class are not taken from or returned to the idle list. The
+// connection is closed when the application closes the connection. The number
+// of connections in the class is limited by ClassLimits.
+//
+//  psc := redis.PubSubConn{Conn: pool.GetClass("pubsub")}
+func (p *Pool) GetClass(name string) Conn {
+	return &pooledConnection{p: p, class: name}
+}
+
+// PoolStats contains pool statistics.
+type PoolStats struct {
+	// ActiveCount is the number of connections in the pool, including idle
+	// connections and excluding connections in a class.
+	ActiveCount int
+
+	// IdleCount is the number of idle connections in the pool.
+	IdleCount int
+
+	// ClassCounts is the number of connections in each connection class.
+	ClassCounts map[string]int
+
+	// StaleCount is the number of connections dialed before the last call to
+	// Recycle or RecycleAll that are not yet closed. Use StaleCount to report
+	// the progress of RecycleAll.
+	StaleCount int
+}
+
+// Stats returns pool statistics.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := PoolStats{
+		ActiveCount: p.active,
+		IdleCount:   p.idle.Len(),
+		StaleCount:  p.stale,
+		ClassCounts: make(map[string]int, len(p.classActive)),
+	}
+	for name, n := range p.classActive {
+		stats.ClassCounts[name] = n
+	}
+	return stats
+}
+
+// PoolOptions are the pool parameters that can be changed while the pool is
+// in use. See the Pool fields with the same names.
+type PoolOptions struct {
+	MaxIdle     int
+	MaxActive   int
+	Wait        bool
+	IdleTimeout time.Duration
+
+	// DialOptions are the options used to dial Network and Address. Use
+	// DialOptions to change the timeouts of new connections, for example
+	// with DialReadTimeout. DialOptions is not used when the pool's Dial
+	// field is set.
+	DialOptions []DialOption
+}
+
+// Options returns the current parameters of the pool.
+func (p *Pool) Options() PoolOptions {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolOptions{
+		MaxIdle:     p.MaxIdle,
+		MaxActive:   p.MaxActive,
+		Wait:        p.Wait,
+		IdleTimeout: p.IdleTimeout,
+		DialOptions: p.DialOptions,
+	}
+}
+
+// Reconfigure changes the parameters of a pool in use. It is not safe to
+// assign to the fields of a pool in use. The new parameters apply to
+// subsequent calls to Get. Idle connections in excess of MaxIdle are closed
+// and goroutines waiting for a connection are woken to check the new limits.
+// Connections in use are not changed, so new dial options apply to new
+// connections only. Modify the result of Options to change some parameters:
+//
+//  o := pool.Options()
+//  o.MaxActive = 200
+//  pool.Reconfigure(o)
+func (p *Pool) Reconfigure(o PoolOptions) {
+	p.mu.Lock()
+	p.MaxIdle = o.MaxIdle
+	p.MaxActive = o.MaxActive
+	p.Wait = o.Wait
+	p.IdleTimeout = o.IdleTimeout
+	p.DialOptions = o.DialOptions
+	var closing []Conn
+	for p.idle.Len() > p.MaxIdle {
+		ic := p.idle.Remove(p.idle.Back()).(idleConn)
+		p.removeLocked(ic.gen)
+		closing = append(closing, ic.c)
+	}
+	for p.waiters.Len() > 0 {
+		p.signalLocked()
+	}
+	p.mu.Unlock()
+	for _, c := range closing {
+		c.Close()
+	}
+}
+
+// Recycle closes the idle connections in the pool and arranges for the
+// connections in use to be closed when the application closes them. New
+// connections are dialed as needed. Call Recycle after rotating credentials
+// or changing the server address used by the Dial function.
+//
+// Connections authenticated with credentials that have an expiration time
+// are recycled automatically when the credentials expire.
+func (p *Pool) Recycle() {
+	p.mu.Lock()
+	idle := p.takeIdleLocked()
+	p.gen += 1
+	p.active -= len(idle)
+	p.stale = p.active
+	p.signalLocked()
+	p.mu.Unlock()
+	for _, c := range idle {
+		c.Close()
+	}
+}
+
+// RecycleAll gradually replaces the connections in the pool. RecycleAll
+// closes idle connections dialed before the call to RecycleAll one at a time
+// and dials a replacement for each closed connection. The rate argument is
+// the maximum number of connections replaced per second. If rate is not
+// positive, then the connections are replaced without delay. Connections in
+// use are replaced after the application returns them to the pool.
+//
+// RecycleAll returns when all connections are replaced, when dialing a
+// replacement fails or when the context is done. The context also bounds the
+// dial of each replacement. Connections that are not replaced when RecycleAll
+// returns are closed when the application returns them to the pool. Use the
+// StaleCount field of Stats to report progress.
+//
+// Call RecycleAll after a DNS change, a certificate rotation or a change to
+// the set of servers behind a load balancer.
+func (p *Pool) RecycleAll(ctx context.Context, rate float64) error {
+	var interval time.Duration
+	if rate > 0 {
+		interval = time.Duration(float64(time.Second) / rate)
+	}
+
+	p.mu.Lock()
+	p.gen += 1
+	p.stale = p.active
+	p.recycling += 1
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.recycling -= 1
+		p.mu.Unlock()
+	}()
+
+	for {
+		p.mu.Lock()
+		if p.stale == 0 || p.closed {
+			p.mu.Unlock()
+			return nil
+		}
+		var e *list.Element
+		for e = p.idle.Back(); e != nil; e = e.Prev() {
+			if e.Value.(idleConn).gen < p.gen {
+				break
+			}
+		}
+		if e != nil {
+			old := p.idle.Remove(e).(idleConn)
+			dial := p.dialFunc()
+			gen := p.gen
+			p.mu.Unlock()
+			old.c.Close()
+			c, err := dial(ctx)
+			p.mu.Lock()
+			p.removeLocked(old.gen)
+			if err != nil {
+				p.signalLocked()
+				p.mu.Unlock()
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return err
+			}
+			p.active += 1
+			if p.closed {
+				p.active -= 1
+				p.mu.Unlock()
+				c.Close()
+				return nil
+			}
+			p.idle.PushFront(idleConn{t: nowFunc(), c: c, gen: gen})
+			p.signalLocked()
+		}
+		p.mu.Unlock()
+
+		// Wait before replacing the next connection or, if all stale
+		// connections are in use, for a connection to be returned.
+		d := interval
+		if e == nil && d < 10*time.Millisecond {
+			d = 10 * time.Millisecond
+		}
+		if d > 0 {
+			t := time.NewTimer(d)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			case <-t.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
 // Close releases the resources used by the pool.
 func (p *Pool) Close() error {
 	p.mu.Lock()
-	idle := p.idle
-	p.idle.Init()
+	idle := p.takeIdleLocked()
 	p.closed = true
+	p.active -= len(idle)
+	for p.waiters.Len() > 0 {
+		p.signalLocked()
+	}
 	p.mu.Unlock()
-	for e := idle.Front(); e != nil; e = e.Next() {
-		e.Value.(idleConn).c.Close()
+	for _, c := range idle {
+		c.Close()
 	}
 	return nil
 }
 
+// takeIdleLocked removes all connections from the idle list and returns the
+// connections. The caller must hold p.mu.
+func (p *Pool) takeIdleLocked() []Conn {
+	idle := make([]Conn, 0, p.idle.Len())
+	for e := p.idle.Front(); e != nil; e = e.Next() {
+		idle = append(idle, e.Value.(idleConn).c)
+	}
+	p.idle.Init()
+	return idle
+}
+
+// signalLocked wakes the first goroutine waiting for a connection. The
+// caller must hold p.mu.
+func (p *Pool) signalLocked() {
+	if e := p.waiters.Front(); e != nil {
+		p.waiters.Remove(e)
+		e.Value.(chan struct{}) <- struct{}{}
+	}
+}
+
 // get prunes stale connections and returns a connection from the idle list or
-// creates a new connection.
-func (p *Pool) get() (Conn, error) {
+// creates a new connection. Connections in a class are always created. If ctx
+// is not nil, then get waits at the MaxActive limit until ctx is done.
+func (p *Pool) get(ctx context.Context, class string) (Conn, int, error) {
+	dialCtx := ctx
+	if dialCtx == nil {
+		dialCtx = context.Background()
+	}
+
 	p.mu.Lock()
 
 	if p.closed {
 		p.mu.Unlock()
-		return nil, errors.New("redigo: get on closed pool")
+		return nil, 0, errors.New("redigo: get on closed pool")
+	}
+
+	if class != "" {
+		if limit := p.ClassLimits[class]; limit > 0 && p.classActive[class] >= limit {
+			p.mu.Unlock()
+			return nil, 0, fmt.Errorf("redigo: connection class %s limit reached", class)
+		}
+		if p.classActive == nil {
+			p.classActive = make(map[string]int)
+		}
+		p.classActive[class] += 1
+		dial := p.dialFunc()
+		gen := p.gen
+		p.mu.Unlock()
+		c, err := dial(dialCtx)
+		if err != nil {
+			p.mu.Lock()
+			p.classActive[class] -= 1
+			p.mu.Unlock()
+		}
+		return c, gen, err
 	}
 
-	// Prune stale connections.
+	for {
+		// Prune stale connections.
+
+		if timeout := p.IdleTimeout; timeout > 0 {
+			for i, n := 0, p.idle.Len(); i < n; i++ {
+				e := p.idle.Back()
+				if e == nil {
+					break
+				}
+				ic := e.Value.(idleConn)
+				if ic.t.Add(timeout).After(nowFunc()) {
+					break
+				}
+				p.idle.Remove(e)
+				p.removeLocked(ic.gen)
+				p.mu.Unlock()
+				ic.c.Close()
+				p.mu.Lock()
+			}
+		}
+
+		// Get idle connection.
 
-	if timeout := p.IdleTimeout; timeout > 0 {
 		for i, n := 0, p.idle.Len(); i < n; i++ {
-			e := p.idle.Back()
+			e := p.idle.Front()
 			if e == nil {
 				break
 			}
 			ic := e.Value.(idleConn)
-			if ic.t.Add(timeout).After(nowFunc()) {
-				break
-			}
 			p.idle.Remove(e)
+			test := p.TestOnBorrow
 			p.mu.Unlock()
-			ic.c.Close()
+			if isExpired(ic.c) || test != nil && test(ic.c, ic.t) != nil {
+				ic.c.Close()
+			} else {
+				return ic.c, ic.gen, nil
+			}
 			p.mu.Lock()
+			p.removeLocked(ic.gen)
 		}
-	}
 
-	// Get idle connection.
+		// No idle connection, create new.
+
+		if p.MaxActive == 0 || p.active < p.MaxActive {
+			p.active += 1
+			dial := p.dialFunc()
+			gen := p.gen
+			p.mu.Unlock()
+			c, err := dial(dialCtx)
+			if err != nil {
+				p.mu.Lock()
+				p.removeLocked(gen)
+				p.signalLocked()
+				p.mu.Unlock()
+			}
+			return c, gen, err
+		}
 
-	for i, n := 0, p.idle.Len(); i < n; i++ {
-		e := p.idle.Front()
-		if e == nil {
-			break
+		if !p.Wait && ctx == nil {
+			p.mu.Unlock()
+			return nil, 0, ErrPoolExhausted
 		}
-		ic := e.Value.(idleConn)
-		p.idle.Remove(e)
-		test := p.TestOnBorrow
+
+		// Wait for a connection to be returned to the pool.
+
+		ch := make(chan struct{}, 1)
+		e := p.waiters.PushBack(ch)
 		p.mu.Unlock()
-		if test != nil && test(ic.c, ic.t) != nil {
-			ic.c.Close()
+		if ctx == nil {
+			<-ch
 		} else {
-			return ic.c, nil
+			select {
+			case <-ch:
+			case <-ctx.Done():
+				p.mu.Lock()
+				select {
+				case <-ch:
+					// Pass the wakeup on to the next waiter.
+					p.signalLocked()
+				default:
+					p.waiters.Remove(e)
+				}
+				p.mu.Unlock()
+				return nil, 0, ctx.Err()
+			}
 		}
 		p.mu.Lock()
-	}
-
-	// No idle connection, create new.
 
-	dial := p.Dial
-	p.mu.Unlock()
-	return dial()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, 0, errors.New("redigo: get on closed pool")
+		}
+	}
 }
 
-func (p *Pool) put(c Conn) error {
+// put returns a connection to the pool. The connection is closed if the
+// connection is broken, is in a class or is not reusable.
+func (p *Pool) put(c Conn, gen int, class string, broken bool) error {
 	p.mu.Lock()
-	if !p.closed {
-		p.idle.PushFront(idleConn{t: nowFunc(), c: c})
+	if class != "" {
+		p.classActive[class] -= 1
+		p.mu.Unlock()
+		return c.Close()
+	}
+	if !broken && !p.closed && (gen == p.gen || p.recycling > 0) && !isExpired(c) {
+		p.idle.PushFront(idleConn{t: nowFunc(), c: c, gen: gen})
 		if p.idle.Len() > p.MaxIdle {
-			c = p.idle.Remove(p.idle.Back()).(idleConn).c
+			ic := p.idle.Remove(p.idle.Back()).(idleConn)
+			c, gen = ic.c, ic.gen
 		} else {
 			c = nil
 		}
 	}
+	if c != nil {
+		p.removeLocked(gen)
+	}
+	p.signalLocked()
 	p.mu.Unlock()
 	if c != nil {
 		return c.Close()
@@ -195,15 +658,33 @@ func (p *Pool) put(c Conn) error {
 	return nil
 }
 
+// removeLocked updates the connection counts for a connection of generation
+// gen that is closed.
+func (p *Pool) removeLocked(gen int) {
+	p.active -= 1
+	if gen < p.gen {
+		p.stale -= 1
+	}
+}
+
+// isExpired returns true if the credentials used to authenticate c have
+// expired.
+func isExpired(c Conn) bool {
+	ec, ok := c.(expiringConn)
+	return ok && ec.expired(nowFunc())
+}
+
 type pooledConnection struct {
-	c   Conn
-	err error
-	p   *Pool
+	c     Conn
+	gen   int
+	class string
+	err   error
+	p     *Pool
 }
 
 func (c *pooledConnection) get() error {
 	if c.err == nil && c.c == nil {
-		c.c, c.err = c.p.get()
+		c.c, c.gen, c.err = c.p.get(nil, c.class)
 	}
 	return c.err
 }
@@ -211,11 +692,7 @@ func (c *pooledConnection) get() error {
 func (c *pooledConnection) Close() (err error) {
 	if c.c != nil {
 		c.c.Do("")
-		if c.c.Err() != nil {
-			err = c.c.Close()
-		} else {
-			err = c.p.put(c.c)
-		}
+		err = c.p.put(c.c, c.gen, c.class, c.c.Err() != nil)
 		c.c = nil
 		c.err = errPoolClosed
 	}
@@ -256,3 +733,17 @@ func (c *pooledConnection) Receive() (reply interface{}, err error) {
 	}
 	return c.c.Receive()
 }
+
+func (c *pooledConnection) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (reply interface{}, err error) {
+	if err := c.get(); err != nil {
+		return nil, err
+	}
+	return DoWithTimeout(c.c, timeout, commandName, args...)
+}
+
+func (c *pooledConnection) ReceiveWithTimeout(timeout time.Duration) (reply interface{}, err error) {
+	if err := c.get(); err != nil {
+		return nil, err
+	}
+	return ReceiveWithTimeout(c.c, timeout)
+}
@@ -15,7 +15,10 @@
 package redis
 
 import (
+	"context"
 	"io"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 )
@@ -186,6 +189,53 @@ func TestPoolTimeout(t *testing.T) {
 	}
 }
 
+func TestPoolGetContext(t *testing.T) {
+	var open int
+	p := &Pool{
+		MaxIdle:   1,
+		MaxActive: 1,
+		Dial:      func() (Conn, error) { open += 1; return &fakeConn{open: &open}, nil },
+	}
+	defer p.Close()
+
+	c, err := p.GetContext(context.Background())
+	if err != nil {
+		t.Fatalf("GetContext returned %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.GetContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("GetContext at limit returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	p.mu.Lock()
+	n := p.waiters.Len()
+	p.mu.Unlock()
+	if n != 0 {
+		t.Errorf("waiters = %d after canceled GetContext, want 0", n)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		c, err := p.GetContext(context.Background())
+		if err == nil {
+			_, err = c.Do("PING")
+			c.Close()
+		}
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	c.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("waiting GetContext returned %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiting GetContext did not return")
+	}
+}
+
 func TestBorrowCheck(t *testing.T) {
 	var open, dialed int
 	p := &Pool{
@@ -203,3 +253,313 @@ func TestBorrowCheck(t *testing.T) {
 		t.Errorf("want open=1, got %d; want dialed=10, got %d", open, dialed)
 	}
 }
+
+func TestPoolRecycle(t *testing.T) {
+	var open, dialed int
+	p := &Pool{
+		MaxIdle: 2,
+		Dial:    func() (Conn, error) { open += 1; dialed += 1; return &fakeConn{open: &open}, nil },
+	}
+
+	c1 := p.Get()
+	c1.Do("PING")
+	c2 := p.Get()
+	c2.Do("PING")
+	c1.Close()
+
+	p.Recycle()
+	if open != 1 {
+		t.Errorf("want open=1, got %d", open)
+	}
+
+	c2.Close()
+	if open != 0 {
+		t.Errorf("want open=0, got %d", open)
+	}
+
+	c3 := p.Get()
+	c3.Do("PING")
+	c3.Close()
+	if open != 1 || dialed != 3 {
+		t.Errorf("want open=1, got %d; want dialed=3, got %d", open, dialed)
+	}
+}
+
+func TestPoolRecycleAll(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		dialed int
+	)
+	p := &Pool{
+		MaxIdle: 3,
+		Dial: func() (Conn, error) {
+			mu.Lock()
+			dialed += 1
+			mu.Unlock()
+			return &fakeConn{open: new(int)}, nil
+		},
+	}
+	defer p.Close()
+
+	c1, c2, c3 := p.Get(), p.Get(), p.Get()
+	for _, c := range []Conn{c1, c2, c3} {
+		c.Do("PING")
+	}
+	c1.Close()
+	c2.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- p.RecycleAll(context.Background(), 1000) }()
+
+	// The idle connections are replaced while c3 is in use.
+	deadline := time.Now().Add(time.Second)
+	for p.Stats().StaleCount != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("stats = %+v, want StaleCount=1", p.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("RecycleAll returned %v with connection in use", err)
+	default:
+	}
+
+	c3.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("RecycleAll returned %v", err)
+	}
+	stats := p.Stats()
+	if stats.StaleCount != 0 || stats.ActiveCount != 3 || stats.IdleCount != 3 {
+		t.Errorf("stats = %+v, want StaleCount=0, ActiveCount=3, IdleCount=3", stats)
+	}
+	mu.Lock()
+	if dialed != 6 {
+		t.Errorf("dialed = %d, want 6", dialed)
+	}
+	mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := p.Get()
+	c.Do("PING")
+	cancel()
+	if err := p.RecycleAll(ctx, 1); err != context.Canceled {
+		t.Errorf("RecycleAll with canceled context returned %v", err)
+	}
+	c.Close()
+
+	// The context bounds the dial of a replacement.
+	p.DialLimiter = &DialLimiter{Rate: 0.001}
+	p.Get().Close()
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := p.RecycleAll(ctx, 0); err != context.DeadlineExceeded {
+		t.Errorf("RecycleAll with throttled dial returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("RecycleAll with throttled dial returned after %v", d)
+	}
+}
+
+type expiringFakeConn struct {
+	fakeConn
+	expiration time.Time
+}
+
+func (c *expiringFakeConn) expired(now time.Time) bool { return !now.Before(c.expiration) }
+
+func TestPoolExpiredCredentials(t *testing.T) {
+	now := time.Now()
+	nowFunc = func() time.Time { return now }
+	defer func() { nowFunc = time.Now }()
+
+	var open, dialed int
+	p := &Pool{
+		MaxIdle: 2,
+		Dial: func() (Conn, error) {
+			open += 1
+			dialed += 1
+			return &expiringFakeConn{fakeConn{open: &open}, now.Add(time.Minute)}, nil
+		},
+	}
+
+	c := p.Get()
+	c.Do("PING")
+	c.Close()
+
+	// Expired idle connection is closed on borrow.
+	now = now.Add(time.Minute)
+	c = p.Get()
+	c.Do("PING")
+	if open != 1 || dialed != 2 {
+		t.Errorf("want open=1, got %d; want dialed=2, got %d", open, dialed)
+	}
+
+	// Expired active connection is closed on return.
+	now = now.Add(time.Minute)
+	c.Close()
+	if open != 0 {
+		t.Errorf("want open=0, got %d", open)
+	}
+}
+
+func TestPoolMaxActive(t *testing.T) {
+	var open int
+	p := &Pool{
+		MaxIdle:   2,
+		MaxActive: 2,
+		Dial:      func() (Conn, error) { open += 1; return &fakeConn{open: &open}, nil },
+	}
+	c1 := p.Get()
+	c1.Do("PING")
+	c2 := p.Get()
+	c2.Do("PING")
+
+	c3 := p.Get()
+	if _, err := c3.Do("PING"); err != ErrPoolExhausted {
+		t.Errorf("expected pool exhausted, got %v", err)
+	}
+	c3.Close()
+
+	c2.Close()
+	c3 = p.Get()
+	if _, err := c3.Do("PING"); err != nil {
+		t.Errorf("expected good connection, got %v", err)
+	}
+	c3.Close()
+	c1.Close()
+
+	if stats := p.Stats(); stats.ActiveCount != 2 || stats.IdleCount != 2 || open != 2 {
+		t.Errorf("stats = %+v, open = %d", stats, open)
+	}
+}
+
+func TestPoolWait(t *testing.T) {
+	var mu sync.Mutex
+	var open int
+	p := &Pool{
+		MaxIdle:   1,
+		MaxActive: 1,
+		Wait:      true,
+		Dial: func() (Conn, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			open += 1
+			return &fakeConn{open: &open}, nil
+		},
+	}
+	c1 := p.Get()
+	c1.Do("PING")
+
+	done := make(chan error)
+	go func() {
+		c := p.Get()
+		_, err := c.Do("PING")
+		c.Close()
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Get did not wait for connection")
+	case <-time.After(20 * time.Millisecond):
+	}
+	c1.Close()
+	if err := <-done; err != nil {
+		t.Errorf("waiting Get returned %v", err)
+	}
+	p.Close()
+}
+
+func TestPoolReconfigure(t *testing.T) {
+	var mu sync.Mutex
+	var open int
+	p := &Pool{
+		MaxIdle:   3,
+		MaxActive: 1,
+		Wait:      true,
+		Dial: func() (Conn, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			open += 1
+			return &fakeConn{open: &open}, nil
+		},
+	}
+	defer p.Close()
+	c1 := p.Get()
+	c1.Do("PING")
+
+	done := make(chan Conn)
+	go func() {
+		c := p.Get()
+		c.Do("PING")
+		done <- c
+	}()
+	select {
+	case <-done:
+		t.Fatal("Get did not wait for connection")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	o := p.Options()
+	o.MaxActive = 2
+	p.Reconfigure(o)
+	c2 := <-done
+	if err := c2.Err(); err != nil {
+		t.Errorf("Get after raising MaxActive returned %v", err)
+	}
+	c1.Close()
+	c2.Close()
+	if stats := p.Stats(); stats.IdleCount != 2 {
+		t.Errorf("stats = %+v, want 2 idle connections", stats)
+	}
+
+	o.MaxIdle = 1
+	p.Reconfigure(o)
+	mu.Lock()
+	defer mu.Unlock()
+	if stats := p.Stats(); stats.IdleCount != 1 || stats.ActiveCount != 1 || open != 1 {
+		t.Errorf("stats = %+v, open = %d after lowering MaxIdle", stats, open)
+	}
+	if got := p.Options(); got.MaxIdle != 1 || got.MaxActive != 2 || !got.Wait {
+		t.Errorf("Options() = %+v", got)
+	}
+}
+
+func TestPoolClass(t *testing.T) {
+	var open int
+	p := &Pool{
+		MaxIdle:     1,
+		MaxActive:   1,
+		ClassLimits: map[string]int{"pubsub": 1},
+		Dial:        func() (Conn, error) { open += 1; return &fakeConn{open: &open}, nil },
+	}
+	c1 := p.Get()
+	c1.Do("PING")
+
+	s1 := p.GetClass("pubsub")
+	if _, err := s1.Do("SUBSCRIBE", "c"); err != nil {
+		t.Errorf("class connection returned %v", err)
+	}
+	s2 := p.GetClass("pubsub")
+	if _, err := s2.Do("SUBSCRIBE", "c"); err == nil {
+		t.Errorf("class connection over limit did not return error")
+	}
+	s2.Close()
+	m := p.GetClass("monitor")
+	m.Do("MONITOR")
+
+	expected := PoolStats{ActiveCount: 1, ClassCounts: map[string]int{"pubsub": 1, "monitor": 1}}
+	if stats := p.Stats(); !reflect.DeepEqual(stats, expected) {
+		t.Errorf("stats = %+v, want %+v", stats, expected)
+	}
+
+	s1.Close()
+	m.Close()
+	c1.Close()
+	expected = PoolStats{ActiveCount: 1, IdleCount: 1, ClassCounts: map[string]int{"pubsub": 0, "monitor": 0}}
+	if stats := p.Stats(); !reflect.DeepEqual(stats, expected) || open != 1 {
+		t.Errorf("stats = %+v, want %+v; open = %d", stats, expected, open)
+	}
+}
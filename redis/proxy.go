@@ -0,0 +1,165 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+// proxyUnsupportedCommands is the set of commands that are rejected by common
+// Redis proxies such as Twemproxy and Envoy or that do not work as expected
+// through a proxy because the proxy multiplexes connections to the servers.
+var proxyUnsupportedCommands = map[string]bool{
+	"DISCARD":      true,
+	"EXEC":         true,
+	"HELLO":        true,
+	"MONITOR":      true,
+	"MULTI":        true,
+	"PSUBSCRIBE":   true,
+	"PUNSUBSCRIBE": true,
+	"SELECT":       true,
+	"SUBSCRIBE":    true,
+	"UNSUBSCRIBE":  true,
+	"UNWATCH":      true,
+	"WATCH":        true,
+}
+
+// proxyErrorReplies are substrings of error replies generated by proxies when
+// the proxy cannot reach a server.
+var proxyErrorReplies = []string{
+	"no upstream host",
+	"upstream failure",
+	"upstream overflow",
+	"Connection refused",
+	"Connection reset by peer",
+	"Connection timed out",
+}
+
+// UnsupportedCommandError is returned by a proxy, strict RESP2 or read-only
+// connection for commands that are not supported by the connection.
+type UnsupportedCommandError struct {
+	Command string
+
+	// Reason describes the restriction, for example "through a proxy".
+	Reason string
+}
+
+func (err *UnsupportedCommandError) Error() string {
+	return "redigo: command " + err.Command + " is not supported " + err.Reason
+}
+
+// ProxyError is returned by a proxy connection in place of an error reply
+// generated by the proxy when the proxy cannot reach a server. Unlike an
+// Error, a ProxyError does not indicate a problem with the command. The
+// command can be retried.
+type ProxyError struct {
+	Reply Error
+}
+
+func (err *ProxyError) Error() string {
+	return "redigo: proxy error: " + string(err.Reply)
+}
+
+// DialProxyCompat specifies that the server is a proxy such as Twemproxy or
+// Envoy. The connection returned by Dial is wrapped with NewProxyConn.
+func DialProxyCompat() DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.proxy = true
+	}}
+}
+
+// NewProxyConn returns a wrapper around a connection to a proxy such as
+// Twemproxy or Envoy. The wrapper returns an *UnsupportedCommandError for
+// transaction, pub/sub and database selection commands without sending the
+// command to the proxy and converts error replies generated by the proxy to
+// *ProxyError.
+func NewProxyConn(c Conn) Conn {
+	return &proxyConn{connWrapper{c}}
+}
+
+type proxyConn struct {
+	connWrapper
+}
+
+func (c *proxyConn) check(commandName string) error {
+	if cmd := strings.ToUpper(commandName); proxyUnsupportedCommands[cmd] {
+		return &UnsupportedCommandError{Command: cmd, Reason: "through a proxy"}
+	}
+	return nil
+}
+
+func (c *proxyConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if err := c.check(commandName); err != nil {
+		return nil, err
+	}
+	reply, err := c.Conn.Do(commandName, args...)
+	return reply, proxyErr(err)
+}
+
+func (c *proxyConn) Send(commandName string, args ...interface{}) error {
+	if err := c.check(commandName); err != nil {
+		return err
+	}
+	return c.Conn.Send(commandName, args...)
+}
+
+func (c *proxyConn) Receive() (interface{}, error) {
+	reply, err := c.Conn.Receive()
+	return reply, proxyErr(err)
+}
+
+func (c *proxyConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	if err := c.check(commandName); err != nil {
+		return nil, err
+	}
+	reply, err := DoWithTimeout(c.Conn, timeout, commandName, args...)
+	return reply, proxyErr(err)
+}
+
+func (c *proxyConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	reply, err := ReceiveWithTimeout(c.Conn, timeout)
+	return reply, proxyErr(err)
+}
+
+func (c *proxyConn) doTo(w io.Writer, commandName string, args []interface{}) (int64, error) {
+	if err := c.check(commandName); err != nil {
+		return 0, err
+	}
+	n, err := DoTo(c.Conn, w, commandName, args...)
+	return n, proxyErr(err)
+}
+
+func (c *proxyConn) receiveTo(w io.Writer) (int64, error) {
+	n, err := ReceiveTo(c.Conn, w)
+	return n, proxyErr(err)
+}
+
+// proxyErr converts error replies generated by a proxy to *ProxyError.
+func proxyErr(err error) error {
+	if e, ok := err.(Error); ok {
+		for _, s := range proxyErrorReplies {
+			if strings.Contains(string(e), s) {
+				return &ProxyError{Reply: e}
+			}
+		}
+	}
+	return err
+}
+
+func (c *proxyConn) rawConn() (*RawConn, error) {
+	return nil, errRawChecked
+}
@@ -0,0 +1,61 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"testing"
+)
+
+func TestProxyConn(t *testing.T) {
+	l := serveFake(t, func(args []string) string {
+		switch args[0] {
+		case "GET":
+			return "-ERR no upstream host\r\n"
+		case "SET":
+			return "-ERR wrong number of arguments\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer l.Close()
+
+	c, err := redis.Dial("tcp", l.Addr().String(), redis.DialProxyCompat())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	if _, err := c.Do("multi"); err == nil {
+		t.Errorf("MULTI did not return error")
+	} else if e, ok := err.(*redis.UnsupportedCommandError); !ok || e.Command != "MULTI" {
+		t.Errorf("MULTI returned %#v", err)
+	}
+	if err := c.Send("SUBSCRIBE", "c"); err == nil {
+		t.Errorf("Send SUBSCRIBE did not return error")
+	}
+	if _, err := c.Do("GET", "k"); err == nil {
+		t.Errorf("GET did not return error")
+	} else if _, ok := err.(*redis.ProxyError); !ok {
+		t.Errorf("GET returned %#v, want *redis.ProxyError", err)
+	}
+	if _, err := c.Do("SET", "k"); err == nil {
+		t.Errorf("SET did not return error")
+	} else if _, ok := err.(redis.Error); !ok {
+		t.Errorf("SET returned %#v, want redis.Error", err)
+	}
+	if s, err := redis.String(c.Do("PING")); err != nil || s != "OK" {
+		t.Errorf("PING returned %q, %v", s, err)
+	}
+}
@@ -15,7 +15,10 @@
 package redis
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"time"
 )
 
 // Subscribe represents a subscribe or unsubscribe notification.
@@ -90,11 +93,93 @@ func (c PubSubConn) PUnsubscribe(channel ...interface{}) error {
 	return c.Conn.Flush()
 }
 
+// SubscribeWait subscribes the connection to the specified channels and waits
+// until the server confirms every subscription. Publishers started after
+// SubscribeWait returns do not race with the subscription.
+//
+// Notifications other than the confirmations that are received while waiting
+// are returned in received for processing by the application. SubscribeWait
+// must not be called concurrently with Receive.
+//
+// If timeout is greater than zero and the connection supports read timeouts,
+// then SubscribeWait returns an error when the confirmations are not received
+// within the timeout. The connection is not usable after a timeout.
+func (c PubSubConn) SubscribeWait(timeout time.Duration, channel ...interface{}) (received []interface{}, err error) {
+	if err := c.Subscribe(channel...); err != nil {
+		return nil, err
+	}
+	return c.waitSubscribed("subscribe", timeout, channel)
+}
+
+// PSubscribeWait is like SubscribeWait for patterns.
+func (c PubSubConn) PSubscribeWait(timeout time.Duration, channel ...interface{}) (received []interface{}, err error) {
+	if err := c.PSubscribe(channel...); err != nil {
+		return nil, err
+	}
+	return c.waitSubscribed("psubscribe", timeout, channel)
+}
+
+func (c PubSubConn) waitSubscribed(kind string, timeout time.Duration, channels []interface{}) ([]interface{}, error) {
+	pending := make(map[string]int)
+	for _, channel := range channels {
+		switch channel := channel.(type) {
+		case []byte:
+			pending[string(channel)] += 1
+		default:
+			pending[fmt.Sprint(channel)] += 1
+		}
+	}
+	n := len(channels)
+
+	var deadline time.Time
+	cwt, _ := c.Conn.(ConnWithTimeout)
+	if timeout > 0 && cwt != nil {
+		deadline = time.Now().Add(timeout)
+	}
+
+	var received []interface{}
+	for n > 0 {
+		var reply interface{}
+		var err error
+		if deadline.IsZero() {
+			reply, err = c.Conn.Receive()
+		} else {
+			d := deadline.Sub(time.Now())
+			if d <= 0 {
+				return received, errors.New("redigo: timeout waiting for " + kind + " confirmation")
+			}
+			reply, err = cwt.ReceiveWithTimeout(d)
+		}
+		v := c.receive(reply, err)
+		if s, ok := v.(Subscription); ok && s.Kind == kind && pending[s.Channel] > 0 {
+			pending[s.Channel] -= 1
+			n -= 1
+			continue
+		}
+		if err, ok := v.(error); ok {
+			return received, err
+		}
+		received = append(received, v)
+	}
+	return received, nil
+}
+
 // Receive returns a pushed message as a Subscription, Message, PMessage or
 // error. The return value is intended to be used directly in a type switch as
 // illustrated in the PubSubConn example.
 func (c PubSubConn) Receive() interface{} {
-	reply, err := Values(c.Conn.Receive())
+	return c.receive(c.Conn.Receive())
+}
+
+// ReceiveContext is like Receive, but the context is applied to the call as
+// described for DoContext. The connection is not usable after cancellation
+// interrupts the call.
+func (c PubSubConn) ReceiveContext(ctx context.Context) interface{} {
+	return c.receive(ReceiveContext(c.Conn, ctx))
+}
+
+func (c PubSubConn) receive(r interface{}, err error) interface{} {
+	reply, err := Values(r, err)
 	if err != nil {
 		return err
 	}
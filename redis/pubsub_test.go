@@ -15,10 +15,14 @@
 package redis_test
 
 import (
+	"bufio"
 	"fmt"
 	"github.com/garyburd/redigo/redis"
+	"io"
+	"io/ioutil"
 	"net"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -136,3 +140,33 @@ func TestPushed(t *testing.T) {
 	pc.Do("PUBLISH", "c1", "hello")
 	expectPushed(t, c, "PUBLISH c1 hello", redis.Message{"c1", []byte("hello")})
 }
+
+func TestSubscribeWait(t *testing.T) {
+	rw := bufio.ReadWriter{
+		Reader: bufio.NewReader(strings.NewReader(
+			"*3\r\n$9\r\nsubscribe\r\n$2\r\nc1\r\n:2\r\n" +
+				"*3\r\n$7\r\nmessage\r\n$2\r\nc0\r\n$5\r\nhello\r\n" +
+				"*3\r\n$9\r\nsubscribe\r\n$2\r\nc2\r\n:3\r\n")),
+		Writer: bufio.NewWriter(ioutil.Discard),
+	}
+	c := redis.PubSubConn{redis.NewConnBufio(rw)}
+	received, err := c.SubscribeWait(0, "c1", []byte("c2"))
+	if err != nil {
+		t.Fatalf("SubscribeWait returned error %v", err)
+	}
+	expected := []interface{}{redis.Message{"c0", []byte("hello")}}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("SubscribeWait returned %v, want %v", received, expected)
+	}
+}
+
+func TestSubscribeWaitTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go io.Copy(ioutil.Discard, server)
+	c := redis.PubSubConn{redis.NewConn(client, 0, 0)}
+	defer c.Close()
+	if _, err := c.SubscribeWait(10*time.Millisecond, "c1"); err == nil {
+		t.Fatalf("SubscribeWait did not return error")
+	}
+}
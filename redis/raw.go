@@ -0,0 +1,158 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+)
+
+var errRawConn = errors.New("redigo: connection in use by RawConn")
+
+// RawConn provides direct access to the buffered reader and writer of a
+// connection for protocol extensions and commands that do not fit the Conn
+// interface. The connection's methods return an error until the RawConn is
+// released.
+type RawConn struct {
+	// Reader and Writer are the connection's buffered reader and writer.
+	Reader *bufio.Reader
+	Writer *bufio.Writer
+
+	c *conn
+}
+
+// rawConner is implemented by connections that support raw access.
+type rawConner interface {
+	rawConn() (*RawConn, error)
+}
+
+// Raw returns raw access to a connection created by Dial, DialTimeout,
+// NewConn or a Pool. The application must read the replies to commands sent
+// on the connection before calling Raw. Connections that check or record
+// every command, such as connections dialed with DialAuditLog,
+// DialRejectWrites, DialStrictRESP2 or DialProxyCompat or from a pool with a
+// Guard, do not support raw access.
+func Raw(c Conn) (*RawConn, error) {
+	rc, ok := c.(rawConner)
+	if !ok {
+		return nil, errors.New("redigo: connection does not support raw access")
+	}
+	return rc.rawConn()
+}
+
+func (c *conn) rawConn() (*RawConn, error) {
+	if c.raw {
+		return nil, errRawConn
+	}
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	c.raw = true
+	return &RawConn{Reader: c.br, Writer: c.bw, c: c}, nil
+}
+
+func (c *pooledConnection) rawConn() (*RawConn, error) {
+	if err := c.get(); err != nil {
+		return nil, err
+	}
+	return Raw(c.c)
+}
+
+// WriteCommand writes a command to the writer using the RESP protocol.
+func (rc *RawConn) WriteCommand(commandName string, args ...interface{}) error {
+	return rc.c.writeCommand(commandName, args)
+}
+
+// ReadReply reads a reply from the reader using the RESP protocol. Error
+// replies are returned as a reply of type Error and a nil error.
+func (rc *RawConn) ReadReply() (interface{}, error) {
+	return rc.c.readReply()
+}
+
+// WriteFrame writes a command encoded using the RESP protocol to the writer.
+// Use WriteFrame to forward a command received by a proxy or replay a
+// recorded command without decoding the command. The application must ensure
+// that p contains complete commands.
+func (rc *RawConn) WriteFrame(p []byte) error {
+	_, err := rc.Writer.Write(p)
+	return err
+}
+
+// ReadFrame reads the next reply from the reader and appends the reply
+// encoded using the RESP protocol to dst. The reply is not decoded. Error
+// replies are returned as frames and a nil error. RESP3 reply types are
+// supported.
+func (rc *RawConn) ReadFrame(dst []byte) ([]byte, error) {
+	line, err := rc.c.readLine()
+	if err != nil {
+		return dst, err
+	}
+	if len(line) == 0 {
+		return dst, errors.New("redigo: short response line")
+	}
+	dst = append(append(dst, line...), '\r', '\n')
+	switch line[0] {
+	case '+', '-', ':', ',', '_', '#', '(':
+		return dst, nil
+	case '$', '!', '=':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return dst, errors.New("redigo: bad bulk length")
+		}
+		if n < 0 {
+			return dst, nil
+		}
+		i := len(dst)
+		dst = append(dst, make([]byte, n+2)...)
+		if _, err := io.ReadFull(rc.Reader, dst[i:]); err != nil {
+			return dst, err
+		}
+		if dst[len(dst)-2] != '\r' || dst[len(dst)-1] != '\n' {
+			return dst, errors.New("redigo: bad bulk format")
+		}
+		return dst, nil
+	case '*', '~', '>', '%', '|':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return dst, errors.New("redigo: bad multi-bulk length")
+		}
+		if line[0] == '%' || line[0] == '|' {
+			n *= 2
+		}
+		for i := 0; i < n; i++ {
+			if dst, err = rc.ReadFrame(dst); err != nil {
+				return dst, err
+			}
+		}
+		if line[0] == '|' {
+			// An attribute precedes the reply it describes.
+			return rc.ReadFrame(dst)
+		}
+		return dst, nil
+	}
+	return dst, errors.New("redigo: unexpected response line")
+}
+
+// Release returns the connection to normal use. If broken is true, then the
+// connection is marked as broken. Applications set broken to true if the
+// state of the protocol is not known, for example after a read error.
+func (rc *RawConn) Release(broken bool) {
+	if broken {
+		rc.c.fatal(errors.New("redigo: connection broken by RawConn"))
+	}
+	rc.c.raw = false
+}
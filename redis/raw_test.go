@@ -0,0 +1,111 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestRawConn(t *testing.T) {
+	l := serveFake(t, func(args []string) string {
+		switch args[0] {
+		case "EXPERIMENT":
+			return "+" + strings.Join(args[1:], " ") + "\r\n"
+		case "NESTED":
+			return "*3\r\n$1\r\na\r\n*2\r\n:5\r\n$-1\r\n%1\r\n+k\r\n$2\r\nvv\r\n"
+		case "FAIL":
+			return "-ERR failed\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer l.Close()
+
+	c, err := redis.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	rc, err := redis.Raw(c)
+	if err != nil {
+		t.Fatalf("Raw returned %v", err)
+	}
+	if _, err := c.Do("PING"); err == nil {
+		t.Errorf("Do did not return error while raw")
+	}
+	if c.Err() == nil {
+		t.Errorf("Err did not return error while raw")
+	}
+	rc.WriteCommand("EXPERIMENT", "a", 1)
+	rc.Writer.Flush()
+	if s, err := redis.String(rc.ReadReply()); err != nil || s != "a 1" {
+		t.Errorf("ReadReply returned %q, %v", s, err)
+	}
+
+	rc.WriteFrame([]byte("*1\r\n$6\r\nNESTED\r\n*1\r\n$4\r\nFAIL\r\n"))
+	rc.Writer.Flush()
+	for _, expected := range []string{
+		"*3\r\n$1\r\na\r\n*2\r\n:5\r\n$-1\r\n%1\r\n+k\r\n$2\r\nvv\r\n",
+		"-ERR failed\r\n",
+	} {
+		if p, err := rc.ReadFrame(nil); err != nil || string(p) != expected {
+			t.Errorf("ReadFrame returned %q, %v, want %q", p, err, expected)
+		}
+	}
+	rc.Release(false)
+
+	if s, err := redis.String(c.Do("PING")); err != nil || s != "OK" {
+		t.Errorf("Do after Release returned %q, %v", s, err)
+	}
+
+	rc, _ = redis.Raw(c)
+	rc.Release(true)
+	if c.Err() == nil {
+		t.Errorf("Err did not return error after Release(true)")
+	}
+
+	if _, err := redis.Raw(redis.NewLoggingConn(c, log.New(ioutil.Discard, "", 0), "")); err == nil {
+		t.Errorf("Raw(NewLoggingConn) did not return error")
+	}
+}
+
+func TestRawWrappedConn(t *testing.T) {
+	l := serveFake(t, func(args []string) string { return "+OK\r\n" })
+	defer l.Close()
+
+	c, err := redis.Dial("tcp", l.Addr().String(), redis.DialHook(&recordingHook{}))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+	rc, err := redis.Raw(c)
+	if err != nil {
+		t.Fatalf("Raw(hook connection) returned %v", err)
+	}
+	rc.Release(false)
+
+	c, err = redis.Dial("tcp", l.Addr().String(), redis.DialRejectWrites())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+	if _, err := redis.Raw(c); err == nil {
+		t.Errorf("Raw(read-only connection) did not return error")
+	}
+}
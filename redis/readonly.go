@@ -0,0 +1,139 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+// readOnlyConnCommands is the set of commands other than the commands
+// reported by ReadOnlyCommand that are allowed on a read-only connection.
+// The commands change the state of the connection, not the data.
+var readOnlyConnCommands = map[string]bool{
+	"":             true,
+	"AUTH":         true,
+	"DISCARD":      true,
+	"EVALSHA_RO":   true,
+	"EVAL_RO":      true,
+	"EXEC":         true,
+	"FCALL_RO":     true,
+	"MULTI":        true,
+	"PSUBSCRIBE":   true,
+	"PUNSUBSCRIBE": true,
+	"QUIT":         true,
+	"READONLY":     true,
+	"SELECT":       true,
+	"SORT_RO":      true,
+	"SUBSCRIBE":    true,
+	"UNSUBSCRIBE":  true,
+	"UNWATCH":      true,
+	"WATCH":        true,
+}
+
+// readOnlyClientCommands is the set of CLIENT subcommands allowed on a
+// read-only connection. CLIENT KILL, PAUSE and UNBLOCK affect other clients
+// and are rejected.
+var readOnlyClientCommands = map[string]bool{
+	"GETNAME":      true,
+	"GETREDIR":     true,
+	"ID":           true,
+	"INFO":         true,
+	"LIST":         true,
+	"NO-EVICT":     true,
+	"NO-TOUCH":     true,
+	"REPLY":        true,
+	"SETINFO":      true,
+	"SETNAME":      true,
+	"TRACKING":     true,
+	"TRACKINGINFO": true,
+}
+
+// DialRejectWrites specifies that the connection rejects commands that can
+// modify data. The connection returned by Dial is wrapped with
+// NewReadOnlyConn.
+func DialRejectWrites() DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.readOnly = true
+	}}
+}
+
+// NewReadOnlyConn returns a wrapper around c that rejects commands that can
+// modify data. The wrapper returns an *UnsupportedCommandError for the
+// commands without sending the command to the server. Commands are
+// classified with ReadOnlyCommand. Commands that only change the state of
+// the connection, such as SELECT, MULTI, SUBSCRIBE and CLIENT SETNAME, and
+// the read-only variants of scripting commands, such as EVAL_RO, are also
+// allowed.
+//
+// Use NewReadOnlyConn in report generation and tools that must not modify
+// production data.
+func NewReadOnlyConn(c Conn) Conn {
+	return &readOnlyConn{connWrapper{c}}
+}
+
+type readOnlyConn struct {
+	connWrapper
+}
+
+func (c *readOnlyConn) check(commandName string, args []interface{}) error {
+	cmd := strings.ToUpper(commandName)
+	if readOnlyCommands[cmd] || readOnlyConnCommands[cmd] {
+		return nil
+	}
+	if cmd == "CLIENT" && len(args) > 0 {
+		if sub, ok := keyArg(args[0]); ok {
+			sub = strings.ToUpper(sub)
+			if readOnlyClientCommands[sub] {
+				return nil
+			}
+			cmd += " " + sub
+		}
+	}
+	return &UnsupportedCommandError{Command: cmd, Reason: "on a read-only connection"}
+}
+
+func (c *readOnlyConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if err := c.check(commandName, args); err != nil {
+		return nil, err
+	}
+	return c.Conn.Do(commandName, args...)
+}
+
+func (c *readOnlyConn) Send(commandName string, args ...interface{}) error {
+	if err := c.check(commandName, args); err != nil {
+		return err
+	}
+	return c.Conn.Send(commandName, args...)
+}
+
+func (c *readOnlyConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	if err := c.check(commandName, args); err != nil {
+		return nil, err
+	}
+	return DoWithTimeout(c.Conn, timeout, commandName, args...)
+}
+
+func (c *readOnlyConn) doTo(w io.Writer, commandName string, args []interface{}) (int64, error) {
+	if err := c.check(commandName, args); err != nil {
+		return 0, err
+	}
+	return DoTo(c.Conn, w, commandName, args...)
+}
+
+func (c *readOnlyConn) rawConn() (*RawConn, error) {
+	return nil, errRawChecked
+}
@@ -0,0 +1,59 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"errors"
+	"github.com/garyburd/redigo/redis"
+	"testing"
+)
+
+func TestReadOnlyConn(t *testing.T) {
+	l := serveFake(t, func(args []string) string {
+		return "+OK\r\n"
+	})
+	defer l.Close()
+
+	c, err := redis.Dial("tcp", l.Addr().String(), redis.DialDatabase(1), redis.DialRejectWrites())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	for _, cmd := range []string{"set", "DEL", "EVAL", "FLUSHALL"} {
+		var e *redis.UnsupportedCommandError
+		if _, err := c.Do(cmd, "k"); !errors.As(err, &e) {
+			t.Errorf("Do(%s) returned %v, want *redis.UnsupportedCommandError", cmd, err)
+		}
+	}
+	if err := c.Send("HSET", "k", "f", "v"); err == nil {
+		t.Errorf("Send(HSET) did not return error")
+	}
+	for _, cmd := range []string{"GET", "hgetall", "SELECT", "MULTI", "EXEC", "EVAL_RO"} {
+		if _, err := c.Do(cmd, "k"); err != nil {
+			t.Errorf("Do(%s) returned %v", cmd, err)
+		}
+	}
+	for _, sub := range []interface{}{"KILL", []byte("pause")} {
+		if _, err := c.Do("CLIENT", sub, "1"); err == nil {
+			t.Errorf("Do(CLIENT %s) did not return error", sub)
+		}
+	}
+	for _, sub := range []interface{}{"SETNAME", []byte("id")} {
+		if _, err := c.Do("CLIENT", sub, "x"); err != nil {
+			t.Errorf("Do(CLIENT %s) returned %v", sub, err)
+		}
+	}
+}
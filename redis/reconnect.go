@@ -0,0 +1,233 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+var errReconnectClosed = errors.New("redigo: closed")
+
+// setupCommands are the commands that change the state of a connection and
+// are replayed after a reconnect, in the order of replay.
+var setupCommands = []string{"AUTH", "SELECT", "CLIENT SETNAME"}
+
+// NewReconnectingConn dials a connection with dial and returns a connection
+// that dials a new connection before the next command after the current
+// connection is broken. Use the Dial options to specify the connection setup
+// such as DialPassword, DialDatabase and DialClientName. The setup is
+// repeated by dial for each new connection. The last AUTH, SELECT and CLIENT
+// SETNAME commands executed with Do on the returned connection that reply OK
+// are replayed after the options. Commands queued in a transaction are not
+// replayed.
+//
+// A command that fails because the connection broke is not retried. Replies
+// pending from Send on a broken connection are returned as errors by Receive
+// before a new connection is dialed. Err returns a non-nil value only after
+// the connection is closed or when a reconnect fails.
+//
+// Use NewReconnectingConn for long-lived single connections such as the
+// connection used by a scheduler or publisher:
+//
+//  c, err := redis.NewReconnectingConn(func() (redis.Conn, error) {
+//      return redis.Dial("tcp", addr, redis.DialDatabase(2))
+//  })
+func NewReconnectingConn(dial func() (Conn, error)) (Conn, error) {
+	c, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	return &reconnectingConn{dial: dial, c: c}, nil
+}
+
+type reconnectingConn struct {
+	dial    func() (Conn, error)
+	c       Conn
+	err     error
+	pending int
+	setup   map[string][]interface{}
+}
+
+// get returns the current connection or dials a new connection if the current
+// connection is broken and no replies are pending.
+func (c *reconnectingConn) get() (Conn, error) {
+	if c.err == errReconnectClosed {
+		return nil, c.err
+	}
+	if c.c.Err() == nil || c.pending > 0 {
+		return c.c, nil
+	}
+	nc, err := c.dial()
+	if err != nil {
+		c.err = err
+		return nil, err
+	}
+	for _, name := range setupCommands {
+		args, ok := c.setup[name]
+		if !ok {
+			continue
+		}
+		if _, err := nc.Do(setupCommandName(name), args...); err != nil {
+			nc.Close()
+			c.err = err
+			return nil, err
+		}
+	}
+	c.c.Close()
+	c.c = nc
+	c.err = nil
+	return nc, nil
+}
+
+// setupCommandName returns the command name of setup command name.
+func setupCommandName(name string) string {
+	if i := strings.IndexByte(name, ' '); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// record saves a setup command that replied OK.
+func (c *reconnectingConn) record(commandName string, args []interface{}) {
+	name := strings.ToUpper(commandName)
+	if name == "CLIENT" && len(args) > 0 {
+		if sub, ok := keyArg(args[0]); ok && strings.ToUpper(sub) == "SETNAME" {
+			name = "CLIENT SETNAME"
+		}
+	}
+	for _, s := range setupCommands {
+		if s == name {
+			if c.setup == nil {
+				c.setup = make(map[string][]interface{})
+			}
+			c.setup[name] = append([]interface{}(nil), args...)
+			return
+		}
+	}
+}
+
+func (c *reconnectingConn) Close() error {
+	if c.err == errReconnectClosed {
+		return nil
+	}
+	c.err = errReconnectClosed
+	return c.c.Close()
+}
+
+func (c *reconnectingConn) Err() error {
+	return c.err
+}
+
+func (c *reconnectingConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return c.do(commandName, args, func(cc Conn) (interface{}, error) {
+		return cc.Do(commandName, args...)
+	})
+}
+
+func (c *reconnectingConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	return c.do(commandName, args, func(cc Conn) (interface{}, error) {
+		return DoWithTimeout(cc, timeout, commandName, args...)
+	})
+}
+
+func (c *reconnectingConn) doTo(w io.Writer, commandName string, args []interface{}) (int64, error) {
+	var n int64
+	_, err := c.do(commandName, args, func(cc Conn) (interface{}, error) {
+		var err error
+		n, err = DoTo(cc, w, commandName, args...)
+		return nil, err
+	})
+	return n, err
+}
+
+func (c *reconnectingConn) do(commandName string, args []interface{}, f func(Conn) (interface{}, error)) (interface{}, error) {
+	cc, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+	c.pending = 0
+	reply, err := f(cc)
+	if err == nil && reply == okReply {
+		// Commands queued in a transaction reply QUEUED and are not
+		// recorded.
+		c.record(commandName, args)
+	}
+	return reply, err
+}
+
+func (c *reconnectingConn) Send(commandName string, args ...interface{}) error {
+	cc, err := c.get()
+	if err != nil {
+		return err
+	}
+	if err := cc.Send(commandName, args...); err != nil {
+		return err
+	}
+	c.pending++
+	return nil
+}
+
+func (c *reconnectingConn) Flush() error {
+	if c.err == errReconnectClosed {
+		return c.err
+	}
+	return c.c.Flush()
+}
+
+func (c *reconnectingConn) Receive() (interface{}, error) {
+	return c.receive(func(cc Conn) (interface{}, error) {
+		return cc.Receive()
+	})
+}
+
+func (c *reconnectingConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return c.receive(func(cc Conn) (interface{}, error) {
+		return ReceiveWithTimeout(cc, timeout)
+	})
+}
+
+func (c *reconnectingConn) receiveTo(w io.Writer) (int64, error) {
+	var n int64
+	_, err := c.receive(func(cc Conn) (interface{}, error) {
+		var err error
+		n, err = ReceiveTo(cc, w)
+		return nil, err
+	})
+	return n, err
+}
+
+func (c *reconnectingConn) receive(f func(Conn) (interface{}, error)) (interface{}, error) {
+	if c.err == errReconnectClosed {
+		return nil, c.err
+	}
+	if c.pending > 0 {
+		c.pending--
+	}
+	return f(c.c)
+}
+
+func (c *reconnectingConn) withContext(ctx context.Context, f func() error) error {
+	if c.err == errReconnectClosed {
+		return c.err
+	}
+	if _, err := c.get(); err != nil {
+		return err
+	}
+	return withContext(c.c, ctx, f)
+}
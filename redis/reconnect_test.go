@@ -0,0 +1,110 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReconnectingConn(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		commands []string
+		broken   bool
+		multi    bool
+	)
+	l := serveFake(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, strings.Join(args, " "))
+		switch {
+		case args[0] == "GET" && !broken:
+			broken = true
+			return closeReply
+		case args[0] == "GET":
+			return "$1\r\nv\r\n"
+		case args[0] == "MULTI":
+			multi = true
+		case args[0] == "DISCARD":
+			multi = false
+		case multi:
+			return "+QUEUED\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer l.Close()
+
+	dials := 0
+	c, err := redis.NewReconnectingConn(func() (redis.Conn, error) {
+		dials++
+		return redis.Dial("tcp", l.Addr().String(), redis.DialClientName("app"))
+	})
+	if err != nil {
+		t.Fatalf("NewReconnectingConn returned %v", err)
+	}
+	defer c.Close()
+
+	if _, err := c.Do("SELECT", 2); err != nil {
+		t.Fatalf("SELECT returned %v", err)
+	}
+	if _, err := c.Do("CLIENT", []byte("setname"), "worker"); err != nil {
+		t.Fatalf("CLIENT SETNAME returned %v", err)
+	}
+	c.Do("MULTI")
+	if _, err := c.Do("SELECT", 5); err != nil {
+		t.Fatalf("SELECT in transaction returned %v", err)
+	}
+	c.Do("DISCARD")
+	if _, err := c.Do("GET", "k"); err == nil {
+		t.Fatal("GET on broken connection did not return error")
+	}
+	if err := c.Err(); err != nil {
+		t.Errorf("Err() = %v after broken connection", err)
+	}
+	if v, err := redis.String(c.Do("GET", "k")); err != nil || v != "v" {
+		t.Fatalf("GET after reconnect returned %q, %v", v, err)
+	}
+	if dials != 2 {
+		t.Errorf("dialed %d times, want 2", dials)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{
+		"CLIENT SETNAME app",
+		"SELECT 2",
+		"CLIENT setname worker",
+		"MULTI",
+		"SELECT 5",
+		"DISCARD",
+		"GET k",
+		"CLIENT SETNAME app",
+		"SELECT 2",
+		"CLIENT setname worker",
+		"GET k",
+	}
+	if !reflect.DeepEqual(commands, expected) {
+		t.Errorf("server received %q, want %q", commands, expected)
+	}
+
+	c.Close()
+	if _, err := c.Do("GET", "k"); err == nil {
+		t.Error("Do after Close did not return error")
+	}
+}
@@ -24,7 +24,10 @@ type Conn interface {
 	// Close closes the connection.
 	Close() error
 
-	// Err returns the permanent error for this connection.
+	// Err returns the permanent error for this connection. A non-nil error
+	// means that the connection is not usable because of a network error,
+	// a protocol error, a timeout or a call to Close. Error replies from the
+	// server and errors converting replies do not set the permanent error.
 	Err() error
 
 	// Do sends a command to the server and returns the received reply.
@@ -39,3 +42,11 @@ type Conn interface {
 	// Receive receives a single reply from the Redis server
 	Receive() (reply interface{}, err error)
 }
+
+// Argument is implemented by types that convert themselves to a command
+// argument. Connections call RedisArg when writing the argument and write the
+// returned value in its place. Use Argument for IDs, money values and
+// enumerations that should not be converted using the fmt package.
+type Argument interface {
+	RedisArg() interface{}
+}
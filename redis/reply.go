@@ -17,11 +17,24 @@ package redis
 import (
 	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
+	"time"
 )
 
+// ErrNil indicates that a reply value is nil. The helpers return ErrNil for
+// a missing key to distinguish the key from a key with an empty value.
 var ErrNil = errors.New("redigo: nil returned")
 
+// nilError returns the error returned by the helpers for a nil reply. Legacy
+// builds return a nil error.
+func nilError() error {
+	if legacy {
+		return nil
+	}
+	return ErrNil
+}
+
 // Int is a helper that converts a command reply to an integer. If err is not
 // equal to nil, then Int returns 0, err. Otherwise, Int converts the
 // reply to an int as follows:
@@ -29,6 +42,7 @@ var ErrNil = errors.New("redigo: nil returned")
 //  Reply type    Result
 //  integer       int(reply), nil
 //  bulk          strconv.ParseInt(reply, 10, 0)
+//  big number    int(reply.Int64()), nil if the value fits
 //  nil           0, ErrNil
 //  other         0, error
 func Int(reply interface{}, err error) (int, error) {
@@ -45,14 +59,135 @@ func Int(reply interface{}, err error) (int, error) {
 	case []byte:
 		n, err := strconv.ParseInt(string(reply), 10, 0)
 		return int(n), err
+	case *big.Int:
+		x := int(reply.Int64())
+		if !reply.IsInt64() || int64(x) != reply.Int64() {
+			return 0, strconv.ErrRange
+		}
+		return x, nil
 	case nil:
-		return 0, ErrNil
+		return 0, nilError()
 	case Error:
 		return 0, reply
 	}
 	return 0, fmt.Errorf("redigo: unexpected type for Int, got type %T", reply)
 }
 
+// Int64 is a helper that converts a command reply to a 64 bit integer. If err
+// is not equal to nil, then Int64 returns 0, err. Otherwise, Int64 converts
+// the reply to an int64 as follows:
+//
+//  Reply type    Result
+//  integer       reply, nil
+//  bulk          strconv.ParseInt(reply, 10, 64)
+//  big number    reply.Int64(), nil if the value fits
+//  nil           0, ErrNil
+//  other         0, error
+func Int64(reply interface{}, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	return int64Value(reply)
+}
+
+// Uint64 is a helper that converts a command reply to a 64 bit unsigned
+// integer. If err is not equal to nil, then Uint64 returns 0, err. Otherwise,
+// Uint64 converts the reply to a uint64 as follows:
+//
+//  Reply type    Result
+//  integer       uint64(reply), nil
+//  bulk          strconv.ParseUint(reply, 10, 64)
+//  big number    reply.Uint64(), nil if the value fits
+//  nil           0, ErrNil
+//  other         0, error
+func Uint64(reply interface{}, err error) (uint64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch reply := reply.(type) {
+	case int64:
+		if reply < 0 {
+			return 0, strconv.ErrRange
+		}
+		return uint64(reply), nil
+	case []byte:
+		return strconv.ParseUint(string(reply), 10, 64)
+	case *big.Int:
+		if !reply.IsUint64() {
+			return 0, strconv.ErrRange
+		}
+		return reply.Uint64(), nil
+	case nil:
+		return 0, nilError()
+	case Error:
+		return 0, reply
+	}
+	return 0, fmt.Errorf("redigo: unexpected type for Uint64, got type %T", reply)
+}
+
+// BigInt is a helper that converts a command reply to a big integer. If err
+// is not equal to nil, then BigInt returns nil, err. Otherwise, BigInt
+// converts the reply to a *big.Int as follows:
+//
+//  Reply type    Result
+//  big number    reply, nil
+//  integer       big.NewInt(reply), nil
+//  bulk          the value parsed as a base 10 integer
+//  nil           nil, ErrNil
+//  other         nil, error
+func BigInt(reply interface{}, err error) (*big.Int, error) {
+	if err != nil {
+		return nil, err
+	}
+	switch reply := reply.(type) {
+	case *big.Int:
+		return reply, nil
+	case int64:
+		return big.NewInt(reply), nil
+	case []byte:
+		n, ok := new(big.Int).SetString(string(reply), 10)
+		if !ok {
+			return nil, fmt.Errorf("redigo: BigInt cannot parse %q", reply)
+		}
+		return n, nil
+	case nil:
+		return nil, nilError()
+	case Error:
+		return nil, reply
+	}
+	return nil, fmt.Errorf("redigo: unexpected type for BigInt, got type %T", reply)
+}
+
+// Float64 is a helper that converts a command reply to a 64 bit float. If err
+// is not equal to nil, then Float64 returns 0, err. Otherwise, Float64
+// converts the reply to a float64 as follows:
+//
+//  Reply type    Result
+//  integer       float64(reply), nil
+//  bulk          strconv.ParseFloat(reply, 64)
+//  big number    the nearest float64, nil
+//  nil           0, ErrNil
+//  other         0, error
+func Float64(reply interface{}, err error) (float64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch reply := reply.(type) {
+	case int64:
+		return float64(reply), nil
+	case []byte:
+		return strconv.ParseFloat(string(reply), 64)
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(reply).Float64()
+		return f, nil
+	case nil:
+		return 0, nilError()
+	case Error:
+		return 0, reply
+	}
+	return 0, fmt.Errorf("redigo: unexpected type for Float64, got type %T", reply)
+}
+
 // String is a helper that converts a command reply to a string. If err is not
 // equal to nil, then String returns "", err. Otherwise String converts the
 // reply to a string as follows:
@@ -60,6 +195,7 @@ func Int(reply interface{}, err error) (int, error) {
 //  Reply type      Result
 //  bulk            string(reply), nil
 //  string          reply, nil
+//  big number      reply.String(), nil
 //  nil             "",  ErrNil
 //  other           "",  error
 func String(reply interface{}, err error) (string, error) {
@@ -71,8 +207,10 @@ func String(reply interface{}, err error) (string, error) {
 		return string(reply), nil
 	case string:
 		return reply, nil
+	case *big.Int:
+		return reply.String(), nil
 	case nil:
-		return "", ErrNil
+		return "", nilError()
 	case Error:
 		return "", reply
 	}
@@ -86,6 +224,7 @@ func String(reply interface{}, err error) (string, error) {
 //  Reply type      Result
 //  bulk            reply, nil
 //  string          []byte(reply), nil
+//  big number      []byte(reply.String()), nil
 //  nil             nil, ErrNil
 //  other           nil, error
 func Bytes(reply interface{}, err error) ([]byte, error) {
@@ -97,8 +236,10 @@ func Bytes(reply interface{}, err error) ([]byte, error) {
 		return reply, nil
 	case string:
 		return []byte(reply), nil
+	case *big.Int:
+		return []byte(reply.String()), nil
 	case nil:
-		return nil, ErrNil
+		return nil, nilError()
 	case Error:
 		return nil, reply
 	}
@@ -124,7 +265,7 @@ func Bool(reply interface{}, err error) (bool, error) {
 	case []byte:
 		return strconv.ParseBool(string(reply))
 	case nil:
-		return false, ErrNil
+		return false, nilError()
 	case Error:
 		return false, reply
 	}
@@ -140,19 +281,416 @@ func MultiBulk(reply interface{}, err error) ([]interface{}, error) { return Val
 //
 //  Reply type      Result
 //  multi-bulk      reply, nil
+//  map             alternating keys and values, nil
 //  nil             nil, ErrNil
 //  other           nil, error
+//
+// Values converts map replies to the alternating key and value format returned
+// by HGETALL so that the result can be used with ScanStruct.
 func Values(reply interface{}, err error) ([]interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	if p, ok := mapPairs(reply); ok {
+		return p, nil
+	}
 	switch reply := reply.(type) {
 	case []interface{}:
 		return reply, nil
 	case nil:
-		return nil, ErrNil
+		return nil, nilError()
 	case Error:
 		return nil, reply
 	}
 	return nil, fmt.Errorf("redigo: unexpected type for Multi, got type %T", reply)
 }
+
+// sliceHelper converts a multi-bulk reply to a slice with n elements using
+// assign to convert each non-nil element. Nil elements are left as the zero
+// value.
+func sliceHelper(reply interface{}, err error, name string, makeSlice func(int), assign func(int, interface{}) error) error {
+	if err != nil {
+		return err
+	}
+	switch reply := reply.(type) {
+	case []interface{}:
+		makeSlice(len(reply))
+		for i := range reply {
+			if reply[i] == nil {
+				continue
+			}
+			if err := assign(i, reply[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case nil:
+		return nilError()
+	case Error:
+		return reply
+	}
+	return fmt.Errorf("redigo: unexpected type for %s, got type %T", name, reply)
+}
+
+// Strings is a helper that converts a multi-bulk command reply to a
+// []string. If err is not equal to nil, then Strings returns nil, err. Nil
+// elements are converted to "". Strings returns an error if an element is not
+// a bulk or status value.
+func Strings(reply interface{}, err error) ([]string, error) {
+	var result []string
+	err = sliceHelper(reply, err, "Strings", func(n int) { result = make([]string, n) }, func(i int, v interface{}) error {
+		switch v := v.(type) {
+		case []byte:
+			result[i] = string(v)
+			return nil
+		case string:
+			result[i] = v
+			return nil
+		case Error:
+			return v
+		}
+		return fmt.Errorf("redigo: unexpected element type for Strings, got type %T", v)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ByteSlices is a helper that converts a multi-bulk command reply to a
+// [][]byte. If err is not equal to nil, then ByteSlices returns nil, err. Nil
+// elements are converted to nil. ByteSlices returns an error if an element is
+// not a bulk or status value.
+func ByteSlices(reply interface{}, err error) ([][]byte, error) {
+	var result [][]byte
+	err = sliceHelper(reply, err, "ByteSlices", func(n int) { result = make([][]byte, n) }, func(i int, v interface{}) error {
+		switch v := v.(type) {
+		case []byte:
+			result[i] = v
+			return nil
+		case string:
+			result[i] = []byte(v)
+			return nil
+		case Error:
+			return v
+		}
+		return fmt.Errorf("redigo: unexpected element type for ByteSlices, got type %T", v)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Ints is a helper that converts a multi-bulk command reply to a []int. If
+// err is not equal to nil, then Ints returns nil, err. Nil elements are
+// converted to 0. Ints returns an error if an element is not an integer or a
+// bulk value containing an integer.
+func Ints(reply interface{}, err error) ([]int, error) {
+	var result []int
+	err = sliceHelper(reply, err, "Ints", func(n int) { result = make([]int, n) }, func(i int, v interface{}) error {
+		var err error
+		result[i], err = Int(v, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Int64s is a helper that converts a multi-bulk command reply to a []int64.
+// If err is not equal to nil, then Int64s returns nil, err. Nil elements are
+// converted to 0. Int64s returns an error if an element is not an integer or
+// a bulk value containing an integer.
+func Int64s(reply interface{}, err error) ([]int64, error) {
+	var result []int64
+	err = sliceHelper(reply, err, "Int64s", func(n int) { result = make([]int64, n) }, func(i int, v interface{}) error {
+		var err error
+		result[i], err = int64Value(v)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Float64s is a helper that converts a multi-bulk command reply to a
+// []float64. If err is not equal to nil, then Float64s returns nil, err. Nil
+// elements are converted to 0. Float64s returns an error if an element is not
+// an integer or a bulk value containing a floating point number.
+func Float64s(reply interface{}, err error) ([]float64, error) {
+	var result []float64
+	err = sliceHelper(reply, err, "Float64s", func(n int) { result = make([]float64, n) }, func(i int, v interface{}) error {
+		switch v := v.(type) {
+		case int64:
+			result[i] = float64(v)
+			return nil
+		case []byte:
+			var err error
+			result[i], err = strconv.ParseFloat(string(v), 64)
+			return err
+		case Error:
+			return v
+		}
+		return fmt.Errorf("redigo: unexpected element type for Float64s, got type %T", v)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Positions is a helper that converts the reply from GEOPOS or from GEOSEARCH
+// and GEORADIUS with the WITHCOORD option to a slice of longitude and
+// latitude pairs. Missing members are converted to nil. If err is not equal to
+// nil, then Positions returns nil, err.
+func Positions(reply interface{}, err error) ([]*[2]float64, error) {
+	var result []*[2]float64
+	err = sliceHelper(reply, err, "Positions", func(n int) { result = make([]*[2]float64, n) }, func(i int, v interface{}) error {
+		p, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("redigo: unexpected element type for Positions, got type %T", v)
+		}
+		// The coordinates are the last item in a WITHCOORD reply.
+		if len(p) > 0 {
+			if coord, ok := p[len(p)-1].([]interface{}); ok {
+				p = coord
+			}
+		}
+		if len(p) != 2 {
+			return fmt.Errorf("redigo: unexpected number of values for a position, got %d", len(p))
+		}
+		pos, err := Float64s(p, nil)
+		if err != nil {
+			return err
+		}
+		result[i] = &[2]float64{pos[0], pos[1]}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// StringMap is a helper that converts a multi-bulk reply containing
+// alternating keys and values to a map[string]string. The HGETALL and CONFIG
+// GET commands return replies in this format. Map replies are also accepted.
+// If err is not equal to nil, then StringMap returns nil, err.
+func StringMap(reply interface{}, err error) (map[string]string, error) {
+	values, err := Strings(Values(reply, err))
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, errors.New("redigo: StringMap expects even number of values in reply")
+	}
+	m := make(map[string]string, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		m[values[i]] = values[i+1]
+	}
+	return m, nil
+}
+
+// IntMap is a helper that converts a multi-bulk reply containing alternating
+// keys and integer values to a map[string]int. Map replies are also accepted.
+// If err is not equal to nil, then IntMap returns nil, err. Nil values are
+// converted to 0.
+func IntMap(reply interface{}, err error) (map[string]int, error) {
+	var m map[string]int
+	err = mapHelper(reply, err, "IntMap", func(n int) { m = make(map[string]int, n) }, func(k string, v interface{}) error {
+		x, err := int64Value(v)
+		if err == nil {
+			m[k] = int(x)
+			if int64(m[k]) != x {
+				err = strconv.ErrRange
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Int64Map is a helper that converts a multi-bulk reply containing
+// alternating keys and integer values to a map[string]int64. Map replies are
+// also accepted. If err is not equal to nil, then Int64Map returns nil, err.
+// Nil values are converted to 0.
+func Int64Map(reply interface{}, err error) (map[string]int64, error) {
+	var m map[string]int64
+	err = mapHelper(reply, err, "Int64Map", func(n int) { m = make(map[string]int64, n) }, func(k string, v interface{}) error {
+		var err error
+		m[k], err = int64Value(v)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// mapHelper calls assign for each key and integer value in a multi-bulk reply
+// containing alternating keys and values or in a map reply. Nil values are
+// passed to assign as 0.
+func mapHelper(reply interface{}, err error, name string, makeMap func(int), assign func(string, interface{}) error) error {
+	values, err := Values(reply, err)
+	if err != nil {
+		return err
+	}
+	if len(values)%2 != 0 {
+		return errors.New("redigo: " + name + " expects even number of values in reply")
+	}
+	makeMap(len(values) / 2)
+	for i := 0; i < len(values); i += 2 {
+		key, err := String(values[i], nil)
+		if err != nil {
+			return err
+		}
+		v := values[i+1]
+		if v == nil {
+			v = int64(0)
+		}
+		if err := assign(key, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// int64Value converts an integer or bulk reply to an int64.
+func int64Value(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case []byte:
+		return strconv.ParseInt(string(v), 10, 64)
+	case *big.Int:
+		if !v.IsInt64() {
+			return 0, strconv.ErrRange
+		}
+		return v.Int64(), nil
+	case nil:
+		return 0, nilError()
+	case Error:
+		return 0, v
+	}
+	return 0, fmt.Errorf("redigo: unexpected type for integer, got type %T", v)
+}
+
+// ScoredMember is a sorted set member and its score.
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// ScoredMembers is a helper that converts the reply from ZRANGE and related
+// commands with the WITHSCORES option to a slice of ScoredMember. The members
+// are in reply order. If err is not equal to nil, then ScoredMembers returns
+// nil, err.
+func ScoredMembers(reply interface{}, err error) ([]ScoredMember, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, errors.New("redigo: ScoredMembers expects even number of values in reply")
+	}
+	result := make([]ScoredMember, len(values)/2)
+	for i := range result {
+		if result[i].Member, err = String(values[2*i], nil); err != nil {
+			return nil, err
+		}
+		if result[i].Score, err = Float64(values[2*i+1], nil); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// ScoreMap is a helper that converts the reply from ZRANGE and related
+// commands with the WITHSCORES option to a map from member to score. If err
+// is not equal to nil, then ScoreMap returns nil, err.
+func ScoreMap(reply interface{}, err error) (map[string]float64, error) {
+	members, err := ScoredMembers(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]float64, len(members))
+	for _, sm := range members {
+		m[sm.Member] = sm.Score
+	}
+	return m, nil
+}
+
+// SlowLog represents a redis SLOWLOG entry.
+type SlowLog struct {
+	// ID is a unique progressive identifier for the entry.
+	ID int64
+
+	// Time is the time when the command was executed.
+	Time time.Time
+
+	// ExecutionTime is the time spent executing the command.
+	ExecutionTime time.Duration
+
+	// Args is the command and its arguments.
+	Args []string
+
+	// ClientAddr is the address of the client. The field is empty for
+	// servers older than Redis 4.0.
+	ClientAddr string
+
+	// ClientName is the name set by CLIENT SETNAME. The field is empty for
+	// servers older than Redis 4.0.
+	ClientName string
+}
+
+// SlowLogs is a helper that converts the reply from SLOWLOG GET to a slice of
+// SlowLog. If err is not equal to nil, then SlowLogs returns nil, err.
+func SlowLogs(reply interface{}, err error) ([]SlowLog, error) {
+	var result []SlowLog
+	err = sliceHelper(reply, err, "SlowLogs", func(n int) { result = make([]SlowLog, n) }, func(i int, v interface{}) error {
+		e, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("redigo: unexpected element type for SlowLogs, got type %T", v)
+		}
+		if len(e) < 4 {
+			return fmt.Errorf("redigo: unexpected number of values for a slowlog entry, got %d", len(e))
+		}
+		log := &result[i]
+		var err error
+		if log.ID, err = int64Value(e[0]); err != nil {
+			return err
+		}
+		timestamp, err := int64Value(e[1])
+		if err != nil {
+			return err
+		}
+		log.Time = time.Unix(timestamp, 0)
+		micros, err := int64Value(e[2])
+		if err != nil {
+			return err
+		}
+		log.ExecutionTime = time.Duration(micros) * time.Microsecond
+		if log.Args, err = Strings(e[3], nil); err != nil {
+			return err
+		}
+		if len(e) >= 6 {
+			if log.ClientAddr, err = String(e[4], nil); err != nil {
+				return err
+			}
+			if log.ClientName, err = String(e[5], nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -15,8 +15,14 @@
 package redis_test
 
 import (
+	"errors"
 	"fmt"
 	"github.com/garyburd/redigo/redis"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
 )
 
 func ExampleBool() {
@@ -63,3 +69,172 @@ func ExampleString() {
 	// Output:
 	// "world"
 }
+
+var replyTests = []struct {
+	name     interface{}
+	actual   valueError
+	expected valueError
+}{
+	{
+		"int64(bulk)",
+		ve(redis.Int64([]byte("-9223372036854775808"), nil)),
+		ve(int64(-9223372036854775808), nil),
+	},
+	{
+		"int64(nil)",
+		ve(redis.Int64(nil, nil)),
+		ve(int64(0), errNil),
+	},
+	{
+		"int64(big)",
+		ve(redis.Int64(bigInt("-42"), nil)),
+		ve(int64(-42), nil),
+	},
+	{
+		"int64(big overflow)",
+		ve(redis.Int64(bigInt("9223372036854775808"), nil)),
+		ve(int64(0), strconv.ErrRange),
+	},
+	{
+		"bigint(bulk)",
+		ve(redis.BigInt([]byte("9223372036854775808"), nil)),
+		ve(bigInt("9223372036854775808"), nil),
+	},
+	{
+		"string(big)",
+		ve(redis.String(bigInt("9223372036854775808"), nil)),
+		ve("9223372036854775808", nil),
+	},
+	{
+		"uint64(bulk)",
+		ve(redis.Uint64([]byte("18446744073709551615"), nil)),
+		ve(uint64(18446744073709551615), nil),
+	},
+	{
+		"uint64(-1)",
+		ve(redis.Uint64(int64(-1), nil)),
+		ve(uint64(0), strconv.ErrRange),
+	},
+	{
+		"float64(bulk)",
+		ve(redis.Float64([]byte("1.25"), nil)),
+		ve(float64(1.25), nil),
+	},
+	{
+		"float64(nil)",
+		ve(redis.Float64(nil, nil)),
+		ve(float64(0), errNil),
+	},
+	{
+		"string(empty)",
+		ve(redis.String([]byte(""), nil)),
+		ve("", nil),
+	},
+	{
+		"string(nil)",
+		ve(redis.String(nil, nil)),
+		ve("", errNil),
+	},
+	{
+		"ints([v1, v2])",
+		ve(redis.Ints([]interface{}{[]byte("4"), int64(5)}, nil)),
+		ve([]int{4, 5}, nil),
+	},
+	{
+		"ints(nil)",
+		ve(redis.Ints(nil, nil)),
+		ve([]int(nil), errNil),
+	},
+	{
+		"int64s([v1, nil])",
+		ve(redis.Int64s([]interface{}{[]byte("4"), nil}, nil)),
+		ve([]int64{4, 0}, nil),
+	},
+	{
+		"float64s([v1, v2])",
+		ve(redis.Float64s([]interface{}{[]byte("1.5"), int64(2)}, nil)),
+		ve([]float64{1.5, 2}, nil),
+	},
+	{
+		"strings([v1, v2])",
+		ve(redis.Strings([]interface{}{[]byte("v1"), "v2"}, nil)),
+		ve([]string{"v1", "v2"}, nil),
+	},
+	{
+		"strings([v1, 1])",
+		ve(redis.Strings([]interface{}{[]byte("v1"), int64(1)}, nil)),
+		ve([]string(nil), errors.New("redigo: unexpected element type for Strings, got type int64")),
+	},
+	{
+		"byteslices([v1, nil])",
+		ve(redis.ByteSlices([]interface{}{[]byte("v1"), nil}, nil)),
+		ve([][]byte{[]byte("v1"), nil}, nil),
+	},
+	{
+		"positions([[1.5, 2.5], nil])",
+		ve(redis.Positions([]interface{}{[]interface{}{[]byte("1.5"), []byte("2.5")}, nil}, nil)),
+		ve([]*[2]float64{{1.5, 2.5}, nil}, nil),
+	},
+	{
+		"positions([[m, d, [1.5, 2.5]]])",
+		ve(redis.Positions([]interface{}{[]interface{}{[]byte("m"), []byte("0.1"), []interface{}{[]byte("1.5"), []byte("2.5")}}}, nil)),
+		ve([]*[2]float64{{1.5, 2.5}}, nil),
+	},
+	{
+		"slowlogs(v4)",
+		ve(redis.SlowLogs([]interface{}{[]interface{}{int64(1), int64(1500000000), int64(25), []interface{}{[]byte("GET"), []byte("k")}, []byte("127.0.0.1:5000"), []byte("app")}}, nil)),
+		ve([]redis.SlowLog{{ID: 1, Time: time.Unix(1500000000, 0), ExecutionTime: 25 * time.Microsecond, Args: []string{"GET", "k"}, ClientAddr: "127.0.0.1:5000", ClientName: "app"}}, nil),
+	},
+	{
+		"slowlogs(v2)",
+		ve(redis.SlowLogs([]interface{}{[]interface{}{int64(2), int64(1500000000), int64(10), []interface{}{[]byte("PING")}}}, nil)),
+		ve([]redis.SlowLog{{ID: 2, Time: time.Unix(1500000000, 0), ExecutionTime: 10 * time.Microsecond, Args: []string{"PING"}}}, nil),
+	},
+	{
+		"scoredmembers([a, 1.5, b, -inf])",
+		ve(redis.ScoredMembers([]interface{}{[]byte("a"), []byte("1.5"), []byte("b"), []byte("-inf")}, nil)),
+		ve([]redis.ScoredMember{{"a", 1.5}, {"b", math.Inf(-1)}}, nil),
+	},
+	{
+		"scoremap([a, 1.5])",
+		ve(redis.ScoreMap([]interface{}{[]byte("a"), []byte("1.5")}, nil)),
+		ve(map[string]float64{"a": 1.5}, nil),
+	},
+	{
+		"stringmap([k, v])",
+		ve(redis.StringMap([]interface{}{[]byte("k"), []byte("v")}, nil)),
+		ve(map[string]string{"k": "v"}, nil),
+	},
+	{
+		"intmap([k, 1])",
+		ve(redis.IntMap([]interface{}{[]byte("k"), []byte("1")}, nil)),
+		ve(map[string]int{"k": 1}, nil),
+	},
+	{
+		"int64map({k: 2})",
+		ve(redis.Int64Map(map[string]interface{}{"k": int64(2)}, nil)),
+		ve(map[string]int64{"k": 2}, nil),
+	},
+	{
+		"int64map([k])",
+		ve(redis.Int64Map([]interface{}{[]byte("k")}, nil)),
+		ve(map[string]int64(nil), errors.New("redigo: Int64Map expects even number of values in reply")),
+	},
+}
+
+type valueError struct {
+	v   interface{}
+	err error
+}
+
+func ve(v interface{}, err error) valueError {
+	return valueError{v, err}
+}
+
+func TestReply(t *testing.T) {
+	for _, rt := range replyTests {
+		if !reflect.DeepEqual(rt.actual, rt.expected) {
+			t.Errorf("%s=%+v, want %+v", rt.name, rt.actual, rt.expected)
+		}
+	}
+}
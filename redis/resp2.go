@@ -0,0 +1,166 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// resp3Commands is the set of commands and subcommands added in Redis 6 and
+// later. Subcommands are specified as the command and subcommand separated by
+// a space.
+var resp3Commands = map[string]bool{
+	// Redis 6
+	"ACL":                 true,
+	"BLMOVE":              true,
+	"CLIENT CACHING":      true,
+	"CLIENT GETREDIR":     true,
+	"CLIENT INFO":         true,
+	"CLIENT TRACKING":     true,
+	"CLIENT TRACKINGINFO": true,
+	"COPY":                true,
+	"FAILOVER":            true,
+	"GEOSEARCH":           true,
+	"GEOSEARCHSTORE":      true,
+	"GETDEL":              true,
+	"GETEX":               true,
+	"HELLO":               true,
+	"HRANDFIELD":          true,
+	"LMOVE":               true,
+	"LPOS":                true,
+	"RESET":               true,
+	"SMISMEMBER":          true,
+	"STRALGO":             true,
+	"XAUTOCLAIM":          true,
+	"ZDIFF":               true,
+	"ZDIFFSTORE":          true,
+	"ZINTER":              true,
+	"ZRANDMEMBER":         true,
+	"ZRANGESTORE":         true,
+	"ZUNION":              true,
+
+	// Redis 7
+	"BLMPOP":          true,
+	"BZMPOP":          true,
+	"CLIENT NO-EVICT": true,
+	"CLIENT NO-TOUCH": true,
+	"COMMAND DOCS":    true,
+	"COMMAND LIST":    true,
+	"EVALSHA_RO":      true,
+	"EVAL_RO":         true,
+	"EXPIRETIME":      true,
+	"FCALL":           true,
+	"FCALL_RO":        true,
+	"FUNCTION":        true,
+	"LCS":             true,
+	"LMPOP":           true,
+	"PEXPIRETIME":     true,
+	"SINTERCARD":      true,
+	"SORT_RO":         true,
+	"ZINTERCARD":      true,
+	"ZMPOP":           true,
+}
+
+// resp2Check returns an error if the command is not supported by servers
+// older than Redis 6.
+func resp2Check(commandName string, args []interface{}) error {
+	cmd := strings.ToUpper(commandName)
+	if resp3Commands[cmd] {
+		return &UnsupportedCommandError{Command: cmd, Reason: "in strict RESP2 mode"}
+	}
+	if len(args) > 0 {
+		if sub, ok := args[0].(string); ok {
+			cmd += " " + strings.ToUpper(sub)
+			if resp3Commands[cmd] {
+				return &UnsupportedCommandError{Command: cmd, Reason: "in strict RESP2 mode"}
+			}
+		}
+	}
+	return nil
+}
+
+// CheckRESP2Commands returns an error listing the commands that are rejected
+// by a connection in strict RESP2 mode. Subcommands are specified as the
+// command and subcommand separated by a space. Applications call
+// CheckRESP2Commands at startup with the commands that the application uses
+// to report unsupported usages before the commands are sent.
+func CheckRESP2Commands(commandNames ...string) error {
+	var unsupported []string
+	for _, name := range commandNames {
+		p := strings.SplitN(name, " ", 2)
+		var args []interface{}
+		if len(p) == 2 {
+			args = []interface{}{p[1]}
+		}
+		if resp2Check(p[0], args) != nil {
+			unsupported = append(unsupported, name)
+		}
+	}
+	if len(unsupported) > 0 {
+		return errors.New("redigo: commands not supported in strict RESP2 mode: " + strings.Join(unsupported, ", "))
+	}
+	return nil
+}
+
+// DialStrictRESP2 specifies that the connection uses the RESP2 protocol only
+// and does not send commands added in Redis 6 or later. The option is for
+// applications that target old servers or servers forked from old versions
+// of Redis. The connection returns an *UnsupportedCommandError for the
+// commands without sending the command to the server. Use CheckRESP2Commands
+// to check the commands used by an application at startup.
+func DialStrictRESP2() DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.strictRESP2 = true
+	}}
+}
+
+type resp2Conn struct {
+	connWrapper
+}
+
+func (c *resp2Conn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if err := resp2Check(commandName, args); err != nil {
+		return nil, err
+	}
+	return c.Conn.Do(commandName, args...)
+}
+
+func (c *resp2Conn) Send(commandName string, args ...interface{}) error {
+	if err := resp2Check(commandName, args); err != nil {
+		return err
+	}
+	return c.Conn.Send(commandName, args...)
+}
+
+func (c *resp2Conn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	if err := resp2Check(commandName, args); err != nil {
+		return nil, err
+	}
+	return DoWithTimeout(c.Conn, timeout, commandName, args...)
+}
+
+func (c *resp2Conn) doTo(w io.Writer, commandName string, args []interface{}) (int64, error) {
+	if err := resp2Check(commandName, args); err != nil {
+		return 0, err
+	}
+	return DoTo(c.Conn, w, commandName, args...)
+}
+
+func (c *resp2Conn) rawConn() (*RawConn, error) {
+	return nil, errRawChecked
+}
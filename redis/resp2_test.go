@@ -0,0 +1,49 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"testing"
+)
+
+func TestStrictRESP2(t *testing.T) {
+	l := serveFake(t, func(args []string) string { return "+OK\r\n" })
+	defer l.Close()
+
+	c, err := redis.Dial("tcp", l.Addr().String(), redis.DialStrictRESP2())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	for _, cmd := range [][]interface{}{{"hello", "3"}, {"GETDEL", "k"}, {"CLIENT", "tracking", "on"}} {
+		_, err := c.Do(cmd[0].(string), cmd[1:]...)
+		if _, ok := err.(*redis.UnsupportedCommandError); !ok {
+			t.Errorf("Do(%v) returned %v, want *redis.UnsupportedCommandError", cmd, err)
+		}
+	}
+	if _, err := c.Do("CLIENT", "SETNAME", "x"); err != nil {
+		t.Errorf("CLIENT SETNAME returned %v", err)
+	}
+
+	if err := redis.CheckRESP2Commands("GET", "CLIENT SETNAME"); err != nil {
+		t.Errorf("CheckRESP2Commands returned %v", err)
+	}
+	err = redis.CheckRESP2Commands("GET", "LMOVE", "client tracking")
+	if err == nil || err.Error() != "redigo: commands not supported in strict RESP2 mode: LMOVE, client tracking" {
+		t.Errorf("CheckRESP2Commands returned %v", err)
+	}
+}
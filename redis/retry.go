@@ -0,0 +1,118 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Retrier executes commands using connections from a pool and retries
+// commands that fail with a transient error as reported by IsTransient. Each
+// attempt uses a new connection from the pool.
+//
+// Only commands accepted by Retryable are retried. A command that fails with
+// a connection error might have been executed by the server, so retrying a
+// command that is not idempotent can execute the command twice.
+type Retrier struct {
+	Pool *Pool
+
+	// MaxAttempts is the maximum number of attempts to execute a command.
+	// The default is 3.
+	MaxAttempts int
+
+	// MinBackoff is the delay before the first retry. The delay doubles with
+	// each retry to a maximum of MaxBackoff and includes random jitter. The
+	// defaults are 10 milliseconds and one second.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Retryable reports whether the command can be retried. If Retryable is
+	// nil, then read-only commands as reported by ReadOnlyCommand are
+	// retried. Accept write commands that are idempotent, such as SET
+	// without options, to retry the commands on READONLY errors during a
+	// failover.
+	Retryable func(commandName string, args []interface{}) bool
+}
+
+// IsTransient returns true if err is a connection reset, an unexpected end
+// of file, a timeout or a LOADING or READONLY error reply.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	var ne net.Error
+	switch {
+	case errors.Is(err, &LoadingError{}), errors.Is(err, &ReadOnlyError{}):
+		return true
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return true
+	case errors.As(err, &ne) && ne.Timeout():
+		return true
+	}
+	return false
+}
+
+// Do executes the command and retries the command on transient errors. The
+// connection for each attempt is obtained with the pool's GetContext method.
+// The retries stop when ctx is done.
+func (r *Retrier) Do(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	maxAttempts := r.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+	minBackoff := r.MinBackoff
+	if minBackoff <= 0 {
+		minBackoff = 10 * time.Millisecond
+	}
+	maxBackoff := r.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = time.Second
+	}
+	retryable := r.Retryable
+	if retryable == nil {
+		retryable = func(commandName string, args []interface{}) bool { return ReadOnlyCommand(commandName) }
+	}
+
+	backoff := minBackoff
+	for attempt := 1; ; attempt++ {
+		var reply interface{}
+		c, err := r.Pool.GetContext(ctx)
+		if err == nil {
+			reply, err = DoContext(c, ctx, commandName, args...)
+			c.Close()
+		}
+		if attempt >= maxAttempts || !IsTransient(err) || !retryable(commandName, args) {
+			return reply, err
+		}
+		t := time.NewTimer(backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return reply, err
+		case <-t.C:
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
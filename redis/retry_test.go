@@ -0,0 +1,100 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"context"
+	"errors"
+	"github.com/garyburd/redigo/redis"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetrier(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		replies []string
+	)
+	l := serveFake(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		reply := replies[0]
+		replies = replies[1:]
+		return reply
+	})
+	defer l.Close()
+	r := &redis.Retrier{
+		Pool: redis.NewPool(func() (redis.Conn, error) {
+			return redis.Dial("tcp", l.Addr().String())
+		}, 1),
+		MinBackoff: time.Millisecond,
+	}
+	defer r.Pool.Close()
+
+	tests := []struct {
+		command string
+		replies []string
+		reply   interface{}
+		err     bool
+	}{
+		{"GET", []string{"-LOADING Redis is loading the dataset in memory\r\n", closeReply, "$1\r\nx\r\n"}, []byte("x"), false},
+		{"GET", []string{"-LOADING\r\n", "-LOADING\r\n", "-LOADING\r\n", "$1\r\nx\r\n"}, nil, true},
+		{"GET", []string{"-ERR bad\r\n", "$1\r\nx\r\n"}, nil, true},
+		{"SET", []string{"-READONLY You can't write against a read only replica.\r\n", "+OK\r\n"}, nil, true},
+	}
+	for _, tt := range tests {
+		mu.Lock()
+		replies = append([]string(nil), tt.replies...)
+		mu.Unlock()
+		reply, err := r.Do(context.Background(), tt.command, "k")
+		if (err != nil) != tt.err || (!tt.err && string(reply.([]byte)) != string(tt.reply.([]byte))) {
+			t.Errorf("Do(%s) with replies %q returned %v, %v", tt.command, tt.replies, reply, err)
+		}
+	}
+
+	// The connection for an attempt is obtained with the context.
+	r.Pool = &redis.Pool{MaxActive: 1, Network: "tcp", Address: l.Addr().String()}
+	defer r.Pool.Close()
+	c, err := r.Pool.GetContext(context.Background())
+	if err != nil {
+		t.Fatalf("GetContext returned %v", err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := r.Do(ctx, "GET", "k"); err != context.DeadlineExceeded {
+		t.Errorf("Do with exhausted pool returned %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	for _, tt := range []struct {
+		err       error
+		transient bool
+	}{
+		{nil, false},
+		{io.EOF, true},
+		{redis.Error("LOADING Redis is loading"), true},
+		{redis.Error("READONLY replica"), true},
+		{redis.Error("ERR syntax error"), false},
+		{errors.New("other"), false},
+	} {
+		if got := redis.IsTransient(tt.err); got != tt.transient {
+			t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.transient)
+		}
+	}
+}
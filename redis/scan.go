@@ -15,12 +15,18 @@
 package redis
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
+	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 func cannotConvert(d reflect.Value, s interface{}) error {
@@ -28,7 +34,47 @@ func cannotConvert(d reflect.Value, s interface{}) error {
 		reflect.TypeOf(s), d.Type())
 }
 
+var (
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+)
+
+// bigValue returns a *big.Int or *big.Float for d or nil if d is not one of
+// these types. A nil pointer in d is set to a new value.
+func bigValue(d reflect.Value) interface{} {
+	t := d.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t != bigIntType && t != bigFloatType {
+		return nil
+	}
+	if d.Kind() != reflect.Ptr {
+		return d.Addr().Interface()
+	}
+	if d.IsNil() {
+		d.Set(reflect.New(t))
+	}
+	return d.Interface()
+}
+
 func convertAssignBytes(d reflect.Value, s []byte) (err error) {
+	switch x := bigValue(d).(type) {
+	case *big.Int:
+		if _, ok := x.SetString(string(s), 10); !ok {
+			err = fmt.Errorf("redigo: Scan cannot parse %q as big.Int", s)
+		}
+		return
+	case *big.Float:
+		_, _, err = x.Parse(string(s), 10)
+		return
+	}
+	if d.Kind() == reflect.Ptr {
+		if d.IsNil() {
+			d.Set(reflect.New(d.Type().Elem()))
+		}
+		return convertAssignBytes(d.Elem(), s)
+	}
 	switch d.Type().Kind() {
 	case reflect.Float32, reflect.Float64:
 		var x float64
@@ -61,6 +107,20 @@ func convertAssignBytes(d reflect.Value, s []byte) (err error) {
 }
 
 func convertAssignInt(d reflect.Value, s int64) (err error) {
+	switch x := bigValue(d).(type) {
+	case *big.Int:
+		x.SetInt64(s)
+		return
+	case *big.Float:
+		x.SetInt64(s)
+		return
+	}
+	if d.Kind() == reflect.Ptr {
+		if d.IsNil() {
+			d.Set(reflect.New(d.Type().Elem()))
+		}
+		return convertAssignInt(d.Elem(), s)
+	}
 	switch d.Type().Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		d.SetInt(s)
@@ -102,6 +162,8 @@ func convertAssignValues(d reflect.Value, s []interface{}) (err error) {
 			err = convertAssignBytes(d.Index(i), s)
 		case int64:
 			err = convertAssignInt(d.Index(i), s)
+		case *big.Int:
+			err = convertAssignBytes(d.Index(i), []byte(s.String()))
 		default:
 			err = cannotConvert(d, s)
 		}
@@ -112,7 +174,96 @@ func convertAssignValues(d reflect.Value, s []interface{}) (err error) {
 	return
 }
 
+// convertAssignValue converts s to the value d.
+func convertAssignValue(d reflect.Value, s interface{}) (err error) {
+	if _, isError := s.(Error); !isError && s != nil && d.Kind() == reflect.Interface {
+		d.Set(reflect.ValueOf(s))
+		return
+	}
+	switch s := s.(type) {
+	case nil:
+		// ignore
+	case []byte:
+		err = convertAssignBytes(d, s)
+	case string:
+		err = convertAssignBytes(d, []byte(s))
+	case int64:
+		err = convertAssignInt(d, s)
+	case *big.Int:
+		err = convertAssignBytes(d, []byte(s.String()))
+	case []interface{}:
+		err = convertAssignValues(d, s)
+	case Error:
+		err = s
+	default:
+		err = cannotConvert(d, s)
+	}
+	return
+}
+
+// mapPairs returns the keys and values of a map reply as alternating elements
+// of a slice. The order of the pairs is not specified.
+func mapPairs(s interface{}) ([]interface{}, bool) {
+	switch s := s.(type) {
+	case map[string]interface{}:
+		p := make([]interface{}, 0, 2*len(s))
+		for k, v := range s {
+			p = append(p, k, v)
+		}
+		return p, true
+	case map[interface{}]interface{}:
+		p := make([]interface{}, 0, 2*len(s))
+		for k, v := range s {
+			p = append(p, k, v)
+		}
+		return p, true
+	}
+	return nil, false
+}
+
+// convertAssignMap converts the alternating keys and values in p to the map or
+// struct pointed to by d.
+func convertAssignMap(d reflect.Value, s interface{}, p []interface{}) error {
+	if d.Kind() != reflect.Ptr || d.IsNil() {
+		return cannotConvert(d, s)
+	}
+	e := d.Elem()
+	switch e.Kind() {
+	case reflect.Struct:
+		return ScanStruct(p, d.Interface())
+	case reflect.Map:
+		t := e.Type()
+		m := reflect.MakeMap(t)
+		for i := 0; i < len(p); i += 2 {
+			k := reflect.New(t.Key()).Elem()
+			if err := convertAssignValue(k, p[i]); err != nil {
+				return err
+			}
+			v := reflect.New(t.Elem()).Elem()
+			if err := convertAssignValue(v, p[i+1]); err != nil {
+				return err
+			}
+			m.SetMapIndex(k, v)
+		}
+		e.Set(m)
+		return nil
+	}
+	return cannotConvert(e, s)
+}
+
 func convertAssign(d interface{}, s interface{}) (err error) {
+	if p, ok := mapPairs(s); ok {
+		switch d := d.(type) {
+		case *interface{}:
+			*d = s
+		case nil:
+			// skip value
+		default:
+			err = convertAssignMap(reflect.ValueOf(d), s, p)
+		}
+		return
+	}
+
 	// Handle the most common destination types using type switches and
 	// fall back to reflection for all other types.
 	switch s := s.(type) {
@@ -176,6 +327,15 @@ func convertAssign(d interface{}, s interface{}) (err error) {
 				err = convertAssignValues(d.Elem(), s)
 			}
 		}
+	case *big.Int:
+		switch d := d.(type) {
+		case *interface{}:
+			*d = s
+		case nil:
+			// skip value
+		default:
+			err = convertAssign(d, []byte(s.String()))
+		}
 	case Error:
 		err = s
 	default:
@@ -190,11 +350,21 @@ func convertAssign(d interface{}, s interface{}) (err error) {
 // []byte, interface{} or a slice of these types. Scan uses the standard
 // strconv package to convert bulk values to numeric and boolean types.
 //
+// Bulk and integer values can also be scanned to big.Int and big.Float
+// values or pointers to these types. A big.Float with zero precision is set
+// to 64 bits of precision; set the precision before scanning to retain more
+// digits. RESP3 big number replies are scanned as bulk values containing the
+// decimal representation of the number.
+//
 // If a dest value is nil, then the corresponding src value is skipped.
 //
 // If the multi-bulk value is nil, then the corresponding dest value is not
 // modified.
 //
+// A src value of type map[string]interface{} or map[interface{}]interface{}
+// can be scanned to a pointer to a map or a pointer to a struct. Map replies
+// are scanned to structs using ScanStruct.
+//
 // To enable easy use of Scan in a loop, Scan returns the slice of src
 // following the copied values.
 func Scan(src []interface{}, dest ...interface{}) ([]interface{}, error) {
@@ -212,14 +382,169 @@ func Scan(src []interface{}, dest ...interface{}) ([]interface{}, error) {
 }
 
 type fieldSpec struct {
-	name  string
-	index []int
-	//omitEmpty bool
+	name      string
+	index     []int
+	json      bool
+	strMap    bool
+	csv       bool
+	setSuffix string
+	checksum  bool
+	ttl       bool
+	required  bool
+	def       *string
+	omitEmpty bool
+
+	// ref is the index of the pointer field populated by
+	// Mapper.LoadWithRefs for a field with the "ref=" flag.
+	ref []int
+}
+
+var (
+	stringMapType = reflect.TypeOf(map[string]string(nil))
+	durationType  = reflect.TypeOf(time.Duration(0))
+)
+
+// encoded returns true if the field is encoded as a single value using the
+// "json", "map" or "csv" field flags.
+func (fs *fieldSpec) encoded() bool {
+	return fs.json || fs.strMap || fs.csv
+}
+
+// unmarshal decodes p to field f using the encoding specified by the field
+// flags.
+func (fs *fieldSpec) unmarshal(f reflect.Value, p []byte) error {
+	if fs.csv {
+		var record []string
+		if len(p) > 0 {
+			var err error
+			record, err = csv.NewReader(bytes.NewReader(p)).Read()
+			if err != nil {
+				return err
+			}
+		}
+		return assignElements(f, len(record), func(i int) interface{} { return []byte(record[i]) })
+	}
+	if !fs.strMap {
+		return json.Unmarshal(p, f.Addr().Interface())
+	}
+	m := make(map[string]string)
+	if len(p) > 0 && p[0] == '{' {
+		if err := json.Unmarshal(p, &m); err != nil {
+			return err
+		}
+	} else {
+		values, err := url.ParseQuery(string(p))
+		if err != nil {
+			return err
+		}
+		for k, v := range values {
+			m[k] = v[0]
+		}
+	}
+	f.Set(reflect.ValueOf(m))
+	return nil
+}
+
+// marshal encodes field value fv using the encoding specified by the field
+// flags.
+func (fs *fieldSpec) marshal(fv reflect.Value) ([]byte, error) {
+	if fs.csv {
+		if fv.Len() == 0 {
+			return []byte{}, nil
+		}
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write(formatElements(fv))
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return nil, err
+		}
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+	}
+	if !fs.strMap {
+		return json.Marshal(fv.Interface())
+	}
+	values := make(url.Values)
+	for k, v := range fv.Interface().(map[string]string) {
+		values.Set(k, v)
+	}
+	return []byte(values.Encode()), nil
+}
+
+// formatElements formats the elements of slice v as command arguments are
+// formatted.
+func formatElements(v reflect.Value) []string {
+	result := make([]string, v.Len())
+	for i := range result {
+		result[i] = formatValue(v.Index(i).Interface())
+	}
+	return result
+}
+
+// formatValue formats v as a command argument is formatted.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// verify verifies and removes the checksum trailer from value s of a field
+// with the "checksum" flag.
+func (fs *fieldSpec) verify(s interface{}) (interface{}, error) {
+	if !fs.checksum || s == nil {
+		return s, nil
+	}
+	p, ok := s.([]byte)
+	if !ok {
+		return nil, ErrChecksum
+	}
+	p, err := VerifyChecksum(p)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// assignElements sets slice d to n elements with the values returned by
+// value.
+func assignElements(d reflect.Value, n int, value func(i int) interface{}) error {
+	s := reflect.MakeSlice(d.Type(), n, n)
+	for i := 0; i < n; i++ {
+		if err := convertAssignValue(s.Index(i), value(i)); err != nil {
+			return err
+		}
+	}
+	d.Set(s)
+	return nil
 }
 
 type structSpec struct {
 	m map[string]*fieldSpec
 	l []*fieldSpec
+
+	// sets are the fields with the "set=" flag and ttl is the field with the
+	// "ttl" flag. The fields are not included in m and l because the values
+	// are not stored in the hash.
+	sets []*fieldSpec
+	ttl  *fieldSpec
+
+	// refs are the fields with the "ref=" flag.
+	refs []*fieldSpec
+
+	// nilPolicy is true if a field in the struct is required or has a default
+	// value.
+	nilPolicy bool
 }
 
 func (ss *structSpec) fieldSpec(name []byte) *fieldSpec {
@@ -250,13 +575,54 @@ func compileStructSpec(t reflect.Type, depth map[string]int, index []int, ss *st
 					fs.name = p[0]
 				}
 				for _, s := range p[1:] {
-					switch s {
-					//case "omitempty":
-					//  fs.omitempty = true
+					switch {
+					case s == "omitempty":
+						fs.omitEmpty = true
+					case s == "json":
+						fs.json = true
+					case s == "csv":
+						if f.Type.Kind() != reflect.Slice || f.Type.Elem().Kind() == reflect.Uint8 {
+							panic(errors.New("redigo: csv field flag requires a slice for field " + f.Name + " of type " + t.Name()))
+						}
+						fs.csv = true
+					case strings.HasPrefix(s, "set="):
+						if f.Type.Kind() != reflect.Slice || f.Type.Elem().Kind() == reflect.Uint8 {
+							panic(errors.New("redigo: set field flag requires a slice for field " + f.Name + " of type " + t.Name()))
+						}
+						fs.setSuffix = s[len("set="):]
+					case s == "ttl":
+						if f.Type != durationType {
+							panic(errors.New("redigo: ttl field flag requires time.Duration for field " + f.Name + " of type " + t.Name()))
+						}
+						fs.ttl = true
+					case s == "checksum":
+						fs.checksum = true
+					case s == "map":
+						if f.Type != stringMapType {
+							panic(errors.New("redigo: map field flag requires map[string]string for field " + f.Name + " of type " + t.Name()))
+						}
+						fs.strMap = true
+					case s == "required":
+						fs.required = true
+					case strings.HasPrefix(s, "default="):
+						def := s[len("default="):]
+						fs.def = &def
+					case strings.HasPrefix(s, "ref="):
+						name := s[len("ref="):]
+						rf, ok := t.FieldByName(name)
+						if f.Type.Kind() != reflect.String || !ok || rf.Type.Kind() != reflect.Ptr || rf.Type.Elem().Kind() != reflect.Struct {
+							panic(errors.New("redigo: ref field flag requires a string field " + f.Name + " and a pointer to struct field " + name + " in type " + t.Name()))
+						}
+						fs.ref = append(append([]int(nil), index...), rf.Index...)
 					default:
 						panic(errors.New("redigo: unknown field flag " + s + " for type " + t.Name()))
 					}
 				}
+				if fs.def != nil {
+					if err := fs.setDefault(reflect.New(f.Type).Elem()); err != nil {
+						panic(errors.New("redigo: bad default for field " + f.Name + " of type " + t.Name() + ": " + err.Error()))
+					}
+				}
 			}
 			d, found := depth[fs.name]
 			if !found {
@@ -281,6 +647,9 @@ func compileStructSpec(t reflect.Type, depth map[string]int, index []int, ss *st
 				depth[fs.name] = len(index)
 				ss.m[fs.name] = fs
 				ss.l = append(ss.l, fs)
+				if fs.required || fs.def != nil {
+					ss.nilPolicy = true
+				}
 			}
 		}
 	}
@@ -310,10 +679,42 @@ func structSpecForType(t reflect.Type) *structSpec {
 
 	ss = &structSpec{m: make(map[string]*fieldSpec)}
 	compileStructSpec(t, make(map[string]int), nil, ss)
+	l := ss.l[:0]
+	for _, fs := range ss.l {
+		if fs.setSuffix != "" {
+			delete(ss.m, fs.name)
+			ss.sets = append(ss.sets, fs)
+		} else if fs.ttl {
+			delete(ss.m, fs.name)
+			ss.ttl = fs
+		} else {
+			if fs.ref != nil {
+				ss.refs = append(ss.refs, fs)
+			}
+			l = append(l, fs)
+		}
+	}
+	ss.l = l
 	structSpecCache[t] = ss
 	return ss
 }
 
+// StructScanner is implemented by types that scan alternating names and
+// values without reflection. ScanStruct calls RedisScanStruct when dest
+// implements StructScanner. The redigo-gen command generates implementations
+// of this interface.
+type StructScanner interface {
+	RedisScanStruct(src []interface{}) error
+}
+
+// StructAppender is implemented by types that append alternating names and
+// values without reflection. AppendStruct calls RedisAppendStruct when src
+// implements StructAppender. The redigo-gen command generates implementations
+// of this interface.
+type StructAppender interface {
+	RedisAppendStruct(args []interface{}) ([]interface{}, error)
+}
+
 // ScanStruct scans a multi-bulk src containing alternating names and values to
 // a struct. The HGETALL and CONFIG GET commands return replies in this format.
 //
@@ -323,21 +724,76 @@ func structSpecForType(t reflect.Type) *structSpec {
 //      Field int `redis:"myName"`
 //
 // Fields with the tag redis:"-" are ignored.
+//
+// Fields with the "json" tag flag are decoded from the value using the
+// encoding/json package. Use this flag to store nested structs, slices and
+// maps in a single hash field:
+//
+//      Field []string `redis:"myName,json"`
+//
+// Fields of type map[string]string with the "map" tag flag are decoded from a
+// URL-encoded value such as "a=1&b=2" or from a JSON object:
+//
+//      Labels map[string]string `redis:"labels,map"`
+//
+// Slice fields with the "csv" tag flag are decoded from a single value
+// containing the elements as a CSV record. Slice fields with the "set=" tag
+// flag are stored in a set at the hash key with the text following the equals
+// sign appended. ScanStruct ignores these fields; use Mapper to load and save
+// the sets with the hash:
+//
+//      Tags    []string `redis:"tags,csv"`
+//      Members []string `redis:"members,set=:members"`
+//
+// A time.Duration field with the "ttl" tag flag holds the time to live of the
+// hash. ScanStruct ignores the field; Mapper loads and saves the field using
+// the PTTL and PEXPIRE commands.
+//
+// Fields with the "checksum" tag flag are stored with a checksum trailer as
+// described in AppendChecksum. ScanStruct returns ErrChecksum if the value
+// of the field does not match the checksum:
+//
+//      Body []byte `redis:"body,checksum"`
+//
+// By default, a field is not modified when the value is nil or the field is
+// missing from src. Use the "required" tag flag to return an error naming the
+// field in this case. Use the "default=" tag flag to set the field from the
+// text following the equals sign. The default is decoded the same way as a
+// bulk value; an invalid default causes a panic when the struct type is
+// first used:
+//
+//      Name  string `redis:"name,required"`
+//      Count int    `redis:"count,default=10"`
 func ScanStruct(src []interface{}, dest interface{}) error {
+	if s, ok := dest.(StructScanner); ok {
+		return s.RedisScanStruct(src)
+	}
 	d := reflect.ValueOf(dest)
 	if d.Kind() != reflect.Ptr || d.IsNil() {
 		return errors.New("redigo: ScanStruct value must be non-nil pointer")
 	}
 	d = d.Elem()
-	ss := structSpecForType(d.Type())
+	return scanStruct(structSpecForType(d.Type()), src, d)
+}
 
+func scanStruct(ss *structSpec, src []interface{}, d reflect.Value) error {
 	if len(src)%2 != 0 {
 		return errors.New("redigo: ScanStruct expects even number of values in values")
 	}
 
+	var seen map[*fieldSpec]bool
+	if ss.nilPolicy {
+		seen = make(map[*fieldSpec]bool)
+	}
+
 	for i := 0; i < len(src); i += 2 {
-		name, ok := src[i].([]byte)
-		if !ok {
+		var name []byte
+		switch s := src[i].(type) {
+		case []byte:
+			name = s
+		case string:
+			name = []byte(s)
+		default:
 			return errors.New("redigo: ScanStruct key not a bulk value")
 		}
 		fs := ss.fieldSpec(name)
@@ -346,23 +802,168 @@ func ScanStruct(src []interface{}, dest interface{}) error {
 		}
 		f := d.FieldByIndex(fs.index)
 		var err error
-		switch s := src[i+1].(type) {
-		case nil:
-			// ignore
-		case []byte:
-			err = convertAssignBytes(f, s)
-		case int64:
-			err = convertAssignInt(f, s)
-		default:
-			err = cannotConvert(f, s)
+		if src[i+1] != nil {
+			err = fs.scan(f, src[i+1])
+		} else if seen != nil {
+			err = fs.applyNilPolicy(d, f, "is nil")
 		}
 		if err != nil {
 			return err
 		}
+		if seen != nil {
+			seen[fs] = true
+		}
+	}
+
+	if seen != nil {
+		for _, fs := range ss.l {
+			if seen[fs] {
+				continue
+			}
+			if err := fs.applyNilPolicy(d, d.FieldByIndex(fs.index), "is missing"); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
+// scan assigns the non-nil value s of the field to f.
+func (fs *fieldSpec) scan(f reflect.Value, s interface{}) error {
+	s, err := fs.verify(s)
+	if err != nil {
+		return err
+	}
+	switch s := s.(type) {
+	case []byte:
+		if fs.encoded() {
+			return fs.unmarshal(f, s)
+		}
+		return convertAssignBytes(f, s)
+	case int64:
+		return convertAssignInt(f, s)
+	}
+	return cannotConvert(f, s)
+}
+
+// applyNilPolicy handles a nil or missing value for field f of struct d.
+func (fs *fieldSpec) applyNilPolicy(d, f reflect.Value, what string) error {
+	switch {
+	case fs.required && !legacy:
+		return fmt.Errorf("redigo: required field %q of %s %s", fs.name, d.Type(), what)
+	case fs.def != nil:
+		return fs.setDefault(f)
+	}
+	return nil
+}
+
+// setDefault sets field f to the default value declared in the field tag. The
+// default is decoded on every call so that slice, map and pointer fields do
+// not share storage.
+func (fs *fieldSpec) setDefault(f reflect.Value) error {
+	if fs.encoded() {
+		return fs.unmarshal(f, []byte(*fs.def))
+	}
+	return convertAssignBytes(f, []byte(*fs.def))
+}
+
+var errScanSliceValue = errors.New("redigo: ScanSlice dest must be non-nil pointer to a slice")
+
+// ScanSlice scans src to the slice pointed to by dest. The elements of the dest
+// slice must be integer, float, boolean, string, struct or pointer to struct
+// values.
+//
+// Struct fields must be integer, float, boolean or string values. All struct
+// fields are used unless a subset is specified using fieldNames. The values of
+// the fields for each struct are read from consecutive elements of src, as
+// returned by the SORT command with multiple GET patterns. Nil values are
+// handled using the "required" and "default=" field flags as described in
+// ScanStruct.
+func ScanSlice(src []interface{}, dest interface{}, fieldNames ...string) error {
+	d := reflect.ValueOf(dest)
+	if d.Kind() != reflect.Ptr || d.IsNil() {
+		return errScanSliceValue
+	}
+	d = d.Elem()
+	if d.Kind() != reflect.Slice {
+		return errScanSliceValue
+	}
+
+	isPtr := false
+	t := d.Type().Elem()
+	if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
+		isPtr = true
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		ensureLen(d, len(src))
+		for i, s := range src {
+			if err := convertAssignValue(d.Index(i), s); err != nil {
+				return fmt.Errorf("redigo: ScanSlice cannot assign element %d: %v", i, err)
+			}
+		}
+		return nil
+	}
+
+	ss := structSpecForType(t)
+	fss := ss.l
+	if len(fieldNames) > 0 {
+		fss = make([]*fieldSpec, len(fieldNames))
+		for i, name := range fieldNames {
+			fss[i] = ss.m[name]
+			if fss[i] == nil {
+				return errors.New("redigo: ScanSlice bad field name " + name)
+			}
+		}
+	}
+
+	if len(fss) == 0 {
+		return errors.New("redigo: ScanSlice no struct fields")
+	}
+
+	n := len(src) / len(fss)
+	if n*len(fss) != len(src) {
+		return errors.New("redigo: ScanSlice length not a multiple of struct field count")
+	}
+
+	ensureLen(d, n)
+	for i := 0; i < n; i++ {
+		d := d.Index(i)
+		if isPtr {
+			if d.IsNil() {
+				d.Set(reflect.New(t))
+			}
+			d = d.Elem()
+		}
+		for j, fs := range fss {
+			f := d.FieldByIndex(fs.index)
+			s, err := fs.verify(src[i*len(fss)+j])
+			if err == nil {
+				if s == nil {
+					err = fs.applyNilPolicy(d, f, "is nil")
+				} else if p, ok := s.([]byte); ok && fs.encoded() {
+					err = fs.unmarshal(f, p)
+				} else {
+					err = convertAssignValue(f, s)
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("redigo: ScanSlice cannot assign element %d to field %s: %v", i*len(fss)+j, fs.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func ensureLen(d reflect.Value, n int) {
+	if n > d.Cap() {
+		d.Set(reflect.MakeSlice(d.Type(), n, n))
+	} else {
+		d.SetLen(n)
+	}
+}
+
 // AppendStruct scans a struct containing values and turns then into alternating
 // key and value pairs. The HMSET and CONFIG SET commands take arguments of this
 // type. AppendStruct is often used in conjuction with ScanStruct for saving
@@ -373,8 +974,22 @@ func ScanStruct(src []interface{}, dest interface{}) error {
 //
 //      Field int `redis:"myName"`
 //
-// Fields with the tag redis:"-" are ignored.
+// Fields with the tag redis:"-" are ignored. Fields with the "json" tag flag
+// are encoded using the encoding/json package. Fields with the "map" tag flag
+// are URL-encoded. Fields with the "csv" tag flag are encoded as a CSV record.
+// Fields with the "set=" and "ttl" tag flags are skipped. Fields with the
+// "checksum" tag flag are stored with a checksum trailer.
+//
+// Pointer fields are dereferenced. Nil pointer fields are skipped, so a struct
+// with pointer fields can describe a partial update to a hash. Fields with the
+// "omitempty" tag flag are skipped if the field value is false, 0, a nil
+// pointer, a nil interface value or an empty array, slice, map or string:
+//
+//      Field string `redis:"myName,omitempty"`
 func AppendStruct(args []interface{}, src interface{}) ([]interface{}, error) {
+	if a, ok := src.(StructAppender); ok {
+		return a.RedisAppendStruct(args)
+	}
 	v := reflect.ValueOf(src)
 	if v.Kind() == reflect.Ptr {
 		if v.IsNil() {
@@ -385,20 +1000,117 @@ func AppendStruct(args []interface{}, src interface{}) ([]interface{}, error) {
 	if v.Kind() != reflect.Struct {
 		return nil, errors.New("redigo: AppendStruct argument must be a struct or pointer to a struct")
 	}
-	ss := structSpecForType(v.Type())
+	return appendStruct(structSpecForType(v.Type()), args, v)
+}
+
+func appendStruct(ss *structSpec, args []interface{}, v reflect.Value) ([]interface{}, error) {
 	for _, fs := range ss.l {
 		fv := v.FieldByIndex(fs.index)
+		if fs.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		if fs.encoded() {
+			p, err := fs.marshal(fv)
+			if err != nil {
+				return nil, err
+			}
+			if fs.checksum {
+				p = AppendChecksum(p)
+			}
+			args = append(args, fs.name, p)
+			continue
+		}
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			if fv.Elem().Kind() != reflect.Struct {
+				fv = fv.Elem()
+			}
+		}
+		if fs.checksum {
+			args = append(args, fs.name, AppendChecksum([]byte(formatValue(fv.Interface()))))
+			continue
+		}
 		args = append(args, fs.name, fv.Interface())
 	}
 	return args, nil
 }
 
-// FlattenStruct is the same as AppendStruct, but it panics on errors.
-// See AppendStruct for full explanation.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// StructCodec scans and appends values of a single struct type. A codec
+// compiled once with CompileStruct avoids the lookup of the struct metadata
+// performed by every call to ScanStruct and AppendStruct. A StructCodec is
+// safe for concurrent use.
+type StructCodec struct {
+	t  reflect.Type
+	ss *structSpec
+}
+
+// CompileStruct returns a codec for the struct type t or the struct type
+// pointed to by t. CompileStruct panics if t is not a struct type or a pointer
+// to a struct type.
+func CompileStruct(t reflect.Type) *StructCodec {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		panic(errors.New("redigo: CompileStruct type " + t.String() + " is not a struct"))
+	}
+	return &StructCodec{t: t, ss: structSpecForType(t)}
+}
+
+// Scan is like ScanStruct. Dest must be a non-nil pointer to a value of the
+// codec's struct type.
+func (c *StructCodec) Scan(src []interface{}, dest interface{}) error {
+	d := reflect.ValueOf(dest)
+	if d.Kind() != reflect.Ptr || d.IsNil() || d.Type().Elem() != c.t {
+		return errors.New("redigo: StructCodec.Scan value must be non-nil pointer to " + c.t.String())
+	}
+	return scanStruct(c.ss, src, d.Elem())
+}
+
+// Append is like AppendStruct. Src must be a value of the codec's struct type
+// or a non-nil pointer to a value of the codec's struct type.
+func (c *StructCodec) Append(args []interface{}, src interface{}) ([]interface{}, error) {
+	v := reflect.ValueOf(src)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.Type() != c.t {
+		return nil, errors.New("redigo: StructCodec.Append argument must be a " + c.t.String() + " or pointer to a " + c.t.String())
+	}
+	return appendStruct(c.ss, args, v)
+}
+
+// FlattenStruct is the same as AppendStruct, but it does not return an error.
+// If AppendStruct returns an error, then FlattenStruct returns args unchanged.
+// Use AppendStruct to detect invalid arguments. FlattenStruct panics on
+// errors in a legacy build. See AppendStruct for full explanation.
 func FlattenStruct(args []interface{}, src interface{}) []interface{} {
 	res, err := AppendStruct(args, src)
 	if err != nil {
-		panic(err)
+		if legacy {
+			panic(err)
+		}
+		return args
 	}
 	return res
 }
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"github.com/garyburd/redigo/redis"
 	"math"
+	"math/big"
 	"reflect"
 	"testing"
 )
@@ -54,6 +55,12 @@ var scanConversionTests = []struct {
 	{[]interface{}{[]byte("1"), []byte("2")}, []int{1, 2}},
 	{[]interface{}{[]byte("1")}, []byte{1}},
 	{[]interface{}{[]byte("1")}, []bool{true}},
+	{bigInt("109"), int(109)},
+	{bigInt("18446744073709551616"), "18446744073709551616"},
+	{bigInt("18446744073709551616"), *bigInt("18446744073709551616")},
+	{[]interface{}{bigInt("110")}, []int64{110}},
+	{map[string]interface{}{"a": []byte("1")}, map[string]int{"a": 1}},
+	{map[interface{}]interface{}{"a": int64(2), "b": nil}, map[string]interface{}{"a": int64(2), "b": nil}},
 }
 
 var scanConversionErrorTests = []struct {
@@ -66,6 +73,7 @@ var scanConversionErrorTests = []struct {
 	{int64(-1), byte(0)},
 	{[]byte("junk"), false},
 	{redis.Error("blah"), false},
+	{bigInt("18446744073709551616"), uint64(0)},
 }
 
 func TestScanConversion(t *testing.T) {
@@ -94,6 +102,39 @@ func TestScanConversionError(t *testing.T) {
 	}
 }
 
+func TestScanBig(t *testing.T) {
+	var (
+		i  big.Int
+		pi *big.Int
+		f  *big.Float
+		n  *big.Int
+	)
+	values := []interface{}{
+		[]byte("340282366920938463463374607431768211455"),
+		[]byte("-170141183460469231731687303715884105728"),
+		[]byte("3.5"),
+		int64(42),
+	}
+	if _, err := redis.Scan(values, &i, &pi, &f, &n); err != nil {
+		t.Fatalf("Scan returned error %v", err)
+	}
+	if s := i.String(); s != "340282366920938463463374607431768211455" {
+		t.Errorf("big.Int = %s", s)
+	}
+	if s := pi.String(); s != "-170141183460469231731687303715884105728" {
+		t.Errorf("*big.Int = %s", s)
+	}
+	if x, _ := f.Float64(); x != 3.5 {
+		t.Errorf("*big.Float = %v", x)
+	}
+	if n.Int64() != 42 {
+		t.Errorf("*big.Int from integer = %s", n)
+	}
+	if _, err := redis.Scan([]interface{}{[]byte("junk")}, &i); err == nil {
+		t.Errorf("Scan of junk to big.Int did not return error")
+	}
+}
+
 func ExampleScan() {
 	c, err := dial()
 	if err != nil {
@@ -182,3 +223,278 @@ func TestScanStruct(t *testing.T) {
 		}
 	}
 }
+
+type s2 struct {
+	N int
+	L []string            `redis:"l,json"`
+	M map[string]int      `redis:"m,json"`
+	P *struct{ A, B int } `redis:"p,json"`
+}
+
+func TestScanStructJSON(t *testing.T) {
+	reply := []interface{}{
+		[]byte("N"), []byte("1"),
+		[]byte("l"), []byte(`["a","b"]`),
+		[]byte("m"), []byte(`{"x":2}`),
+		[]byte("p"), []byte(`{"A":3,"B":4}`),
+	}
+	var v s2
+	if err := redis.ScanStruct(reply, &v); err != nil {
+		t.Fatalf("ScanStruct returned error %v", err)
+	}
+	expected := s2{N: 1, L: []string{"a", "b"}, M: map[string]int{"x": 2}, P: &struct{ A, B int }{3, 4}}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("ScanStruct returned %+v, want %+v", v, expected)
+	}
+
+	reply[3] = []byte("junk")
+	if err := redis.ScanStruct(reply, &v); err == nil {
+		t.Fatalf("ScanStruct did not return error for invalid JSON")
+	}
+}
+
+func TestAppendStructJSON(t *testing.T) {
+	v := s2{N: 1, L: []string{"a"}, M: map[string]int{"x": 2}}
+	args, err := redis.AppendStruct([]interface{}{"key"}, &v)
+	if err != nil {
+		t.Fatalf("AppendStruct returned error %v", err)
+	}
+	expected := []interface{}{"key", "N", 1, "l", []byte(`["a"]`), "m", []byte(`{"x":2}`), "p", []byte("null")}
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("AppendStruct returned %v, want %v", args, expected)
+	}
+}
+
+type s4 struct {
+	Name  *string `redis:"name"`
+	Count *int    `redis:"count"`
+	Tags  []int   `redis:"tags,json,omitempty"`
+	Flag  bool    `redis:"flag,omitempty"`
+	Seen  bool    `redis:"seen"`
+}
+
+func TestAppendStructOmitEmpty(t *testing.T) {
+	count := 3
+	args, err := redis.AppendStruct([]interface{}{"key"}, s4{Count: &count})
+	if err != nil {
+		t.Fatalf("AppendStruct returned error %v", err)
+	}
+	expected := []interface{}{"key", "count", 3, "seen", false}
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("AppendStruct returned %v, want %v", args, expected)
+	}
+
+	var v s4
+	if err := redis.ScanStruct([]interface{}{[]byte("name"), []byte("x"), []byte("count"), int64(4)}, &v); err != nil {
+		t.Fatalf("ScanStruct returned error %v", err)
+	}
+	if v.Name == nil || *v.Name != "x" || v.Count == nil || *v.Count != 4 {
+		t.Errorf("ScanStruct returned %+v", v)
+	}
+}
+
+func TestFlattenStructError(t *testing.T) {
+	args := []interface{}{"key"}
+	defer func() {
+		if r := recover(); (r != nil) != legacyBuild {
+			t.Errorf("FlattenStruct with invalid argument recovered %v, legacy build %v", r, legacyBuild)
+		}
+	}()
+	if got := redis.FlattenStruct(args, 1); !reflect.DeepEqual(got, args) {
+		t.Errorf("FlattenStruct with invalid argument returned %v, want %v", got, args)
+	}
+}
+
+type s5 struct {
+	Labels map[string]string `redis:"labels,map"`
+	Meta   map[string]string `redis:"meta,map"`
+}
+
+func TestScanStructMap(t *testing.T) {
+	var v s5
+	src := []interface{}{[]byte("labels"), []byte("a=1&b=x+y"), []byte("meta"), []byte(`{"c":"3"}`)}
+	if err := redis.ScanStruct(src, &v); err != nil {
+		t.Fatalf("ScanStruct returned error %v", err)
+	}
+	expected := s5{Labels: map[string]string{"a": "1", "b": "x y"}, Meta: map[string]string{"c": "3"}}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("ScanStruct returned %+v, want %+v", v, expected)
+	}
+
+	args, err := redis.AppendStruct(nil, &v)
+	if err != nil {
+		t.Fatalf("AppendStruct returned error %v", err)
+	}
+	expectedArgs := []interface{}{"labels", []byte("a=1&b=x+y"), "meta", []byte("c=3")}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("AppendStruct returned %q, want %q", args, expectedArgs)
+	}
+}
+
+type s6 struct {
+	Tags    []string `redis:"tags,csv"`
+	IDs     []int    `redis:"ids,csv"`
+	Members []string `redis:"members,set=:m"`
+}
+
+func TestScanStructCSV(t *testing.T) {
+	var v s6
+	src := []interface{}{[]byte("tags"), []byte(`a,"b,c"`), []byte("ids"), []byte(""), []byte("members"), []byte("x")}
+	if err := redis.ScanStruct(src, &v); err != nil {
+		t.Fatalf("ScanStruct returned error %v", err)
+	}
+	expected := s6{Tags: []string{"a", "b,c"}, IDs: []int{}}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("ScanStruct returned %+v, want %+v", v, expected)
+	}
+
+	v.IDs = []int{1, 2}
+	v.Members = []string{"x"}
+	args, err := redis.AppendStruct(nil, &v)
+	if err != nil {
+		t.Fatalf("AppendStruct returned error %v", err)
+	}
+	expectedArgs := []interface{}{"tags", []byte(`a,"b,c"`), "ids", []byte("1,2")}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("AppendStruct returned %q, want %q", args, expectedArgs)
+	}
+}
+
+type s3 struct {
+	Name  string `redis:"name,required"`
+	Count int    `redis:"count,default=10"`
+	Tags  []int  `redis:"tags,json,default=[1]"`
+	Other int
+}
+
+var scanStructNilPolicyTests = []struct {
+	title string
+	reply []interface{}
+	value *s3
+	err   bool
+}{
+	{"present", []interface{}{[]byte("name"), []byte("x"), []byte("count"), []byte("3"), []byte("tags"), []byte("[]")}, &s3{Name: "x", Count: 3, Tags: []int{}}, false},
+	{"defaults", []interface{}{[]byte("name"), []byte("x"), []byte("count"), nil}, &s3{Name: "x", Count: 10, Tags: []int{1}}, false},
+	{"missing required", []interface{}{[]byte("count"), []byte("3")}, nil, true},
+	{"nil required", []interface{}{[]byte("name"), nil}, nil, true},
+}
+
+func TestScanStructNilPolicy(t *testing.T) {
+	for _, tt := range scanStructNilPolicyTests {
+		var v s3
+		err := redis.ScanStruct(tt.reply, &v)
+		if tt.err {
+			if err == nil && !legacyBuild {
+				t.Errorf("ScanStruct(%s) did not return error", tt.title)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ScanStruct(%s) returned error %v", tt.title, err)
+			continue
+		}
+		if !reflect.DeepEqual(&v, tt.value) {
+			t.Errorf("ScanStruct(%s) returned %+v, want %+v", tt.title, v, tt.value)
+		}
+	}
+}
+
+func TestScanMap(t *testing.T) {
+	src := map[string]interface{}{"N": []byte("1"), "l": []byte(`["a"]`)}
+	var v s2
+	if _, err := redis.Scan([]interface{}{src}, &v); err != nil {
+		t.Fatalf("Scan returned error %v", err)
+	}
+	if !reflect.DeepEqual(v, s2{N: 1, L: []string{"a"}}) {
+		t.Errorf("Scan returned %+v", v)
+	}
+
+	values, err := redis.Values(src, nil)
+	if err != nil {
+		t.Fatalf("Values returned error %v", err)
+	}
+	var v2 s2
+	if err := redis.ScanStruct(values, &v2); err != nil {
+		t.Fatalf("ScanStruct returned error %v", err)
+	}
+	if !reflect.DeepEqual(v2, v) {
+		t.Errorf("ScanStruct returned %+v, want %+v", v2, v)
+	}
+}
+
+func TestScanSliceDefault(t *testing.T) {
+	var v []s3
+	err := redis.ScanSlice([]interface{}{[]byte("x"), nil, []byte("y"), []byte("2")}, &v, "name", "count")
+	if err != nil {
+		t.Fatalf("ScanSlice returned error %v", err)
+	}
+	expected := []s3{{Name: "x", Count: 10}, {Name: "y", Count: 2}}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("ScanSlice returned %+v, want %+v", v, expected)
+	}
+}
+
+func TestBadDefault(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("ScanStruct did not panic for bad default")
+		}
+	}()
+	var v struct {
+		N int `redis:"n,default=ten"`
+	}
+	redis.ScanStruct(nil, &v)
+}
+
+func TestStructCodec(t *testing.T) {
+	codec := redis.CompileStruct(reflect.TypeOf(&s2{}))
+	v := s2{N: 1, L: []string{"a"}}
+	args, err := codec.Append(nil, v)
+	if err != nil {
+		t.Fatalf("Append returned error %v", err)
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case string:
+			values[i] = []byte(arg)
+		case int:
+			values[i] = []byte(fmt.Sprint(arg))
+		default:
+			values[i] = arg
+		}
+	}
+	var v2 s2
+	if err := codec.Scan(values, &v2); err != nil {
+		t.Fatalf("Scan returned error %v", err)
+	}
+	if !reflect.DeepEqual(v2, v) {
+		t.Errorf("Scan returned %+v, want %+v", v2, v)
+	}
+	if err := codec.Scan(values, &s3{}); err == nil {
+		t.Errorf("Scan to wrong type did not return error")
+	}
+	if _, err := codec.Append(nil, nil); err == nil {
+		t.Errorf("Append of nil did not return error")
+	}
+}
+
+type generated struct{ src []interface{} }
+
+func (g *generated) RedisScanStruct(src []interface{}) error { g.src = src; return nil }
+
+func (g generated) RedisAppendStruct(args []interface{}) ([]interface{}, error) {
+	return append(args, "generated", 1), nil
+}
+
+func TestStructScannerAppender(t *testing.T) {
+	var g generated
+	src := []interface{}{[]byte("a"), []byte("b")}
+	if err := redis.ScanStruct(src, &g); err != nil || !reflect.DeepEqual(g.src, src) {
+		t.Errorf("ScanStruct did not call RedisScanStruct, src=%v, err=%v", g.src, err)
+	}
+	args, err := redis.AppendStruct(nil, &g)
+	if err != nil || !reflect.DeepEqual(args, []interface{}{"generated", 1}) {
+		t.Errorf("AppendStruct did not call RedisAppendStruct, args=%v, err=%v", args, err)
+	}
+}
@@ -18,7 +18,6 @@ import (
 	"crypto/sha1"
 	"encoding/hex"
 	"io"
-	"strings"
 )
 
 // Script encapsulates the source, hash and key count for a Lua script. See
@@ -61,7 +60,7 @@ func (s *Script) args(spec string, keysAndArgs []interface{}) []interface{} {
 // causing the script to load).
 func (s *Script) Do(c Conn, keysAndArgs ...interface{}) (interface{}, error) {
 	v, err := c.Do("EVALSHA", s.args(s.hash, keysAndArgs)...)
-	if e, ok := err.(Error); ok && strings.HasPrefix(string(e), "NOSCRIPT ") {
+	if e, ok := err.(Error); ok && e.kind() == "NOSCRIPT" {
 		v, err = c.Do("EVAL", s.args(s.src, keysAndArgs)...)
 	}
 	return v, err
@@ -0,0 +1,301 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StreamEntry is an entry in a stream.
+type StreamEntry struct {
+	ID string
+
+	// Fields contains the alternating field names and values of the entry.
+	// Fields is nil for entries deleted from the stream after the entry was
+	// delivered to a consumer group.
+	Fields []interface{}
+}
+
+// ScanStruct scans the fields of the entry to the struct pointed to by dest
+// using ScanStruct.
+func (e StreamEntry) ScanStruct(dest interface{}) error {
+	return ScanStruct(e.Fields, dest)
+}
+
+// Stream is a stream key and the entries read from the stream.
+type Stream struct {
+	Key     string
+	Entries []StreamEntry
+}
+
+// StreamEntries is a helper that converts the reply from XRANGE, XREVRANGE
+// and XCLAIM to a slice of StreamEntry. If err is not equal to nil, then
+// StreamEntries returns nil, err.
+func StreamEntries(reply interface{}, err error) ([]StreamEntry, error) {
+	var result []StreamEntry
+	err = sliceHelper(reply, err, "StreamEntries", func(n int) { result = make([]StreamEntry, n) }, func(i int, v interface{}) error {
+		p, ok := v.([]interface{})
+		if !ok || len(p) != 2 {
+			return fmt.Errorf("redigo: unexpected stream entry, got type %T", v)
+		}
+		var err error
+		if result[i].ID, err = String(p[0], nil); err != nil {
+			return err
+		}
+		if p[1] != nil {
+			if result[i].Fields, err = Values(p[1], nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Streams is a helper that converts the reply from XREAD and XREADGROUP to a
+// slice of Stream. Streams returns ErrNil when the command times out. If err
+// is not equal to nil, then Streams returns nil, err.
+func Streams(reply interface{}, err error) ([]Stream, error) {
+	if p, ok := mapPairs(reply); ok && err == nil {
+		// Convert map reply to a slice of key and entries pairs.
+		values := make([]interface{}, 0, len(p)/2)
+		for i := 0; i < len(p); i += 2 {
+			values = append(values, []interface{}{p[i], p[i+1]})
+		}
+		reply = values
+	}
+	var result []Stream
+	err = sliceHelper(reply, err, "Streams", func(n int) { result = make([]Stream, n) }, func(i int, v interface{}) error {
+		p, ok := v.([]interface{})
+		if !ok || len(p) != 2 {
+			return fmt.Errorf("redigo: unexpected stream, got type %T", v)
+		}
+		var err error
+		if result[i].Key, err = String(p[0], nil); err != nil {
+			return err
+		}
+		if p[1] != nil {
+			if result[i].Entries, err = StreamEntries(p[1], nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// StreamInfo is the reply from XINFO STREAM.
+type StreamInfo struct {
+	Length          int64
+	RadixTreeKeys   int64
+	RadixTreeNodes  int64
+	Groups          int64
+	LastGeneratedID string
+
+	// The following fields are set by Redis 7 and later.
+	MaxDeletedEntryID    string
+	EntriesAdded         int64
+	RecordedFirstEntryID string
+
+	// FirstEntry and LastEntry are nil if the stream is empty.
+	FirstEntry *StreamEntry
+	LastEntry  *StreamEntry
+}
+
+// StreamGroupInfo is an entry in the reply from XINFO GROUPS.
+type StreamGroupInfo struct {
+	Name            string
+	Consumers       int64
+	Pending         int64
+	LastDeliveredID string
+
+	// EntriesRead and Lag are set by Redis 7 and later. The fields are -1
+	// if the value is not reported by the server.
+	EntriesRead int64
+	Lag         int64
+}
+
+// StreamConsumerInfo is an entry in the reply from XINFO CONSUMERS.
+type StreamConsumerInfo struct {
+	Name    string
+	Pending int64
+
+	// Idle is the time since the consumer last attempted an interaction.
+	Idle time.Duration
+
+	// Inactive is the time since the last successful interaction. Inactive
+	// is set by Redis 7.2 and later. Inactive is -1 if the value is not
+	// reported by the server or if the consumer never had a successful
+	// interaction.
+	Inactive time.Duration
+}
+
+// scanPairs calls f for each name and value in a reply containing alternating
+// names and values or in a map reply.
+func scanPairs(reply interface{}, helper string, f func(name string, v interface{}) error) error {
+	p, err := Values(reply, nil)
+	if err != nil {
+		return err
+	}
+	if len(p)%2 != 0 {
+		return errors.New("redigo: " + helper + " expects even number of values in reply")
+	}
+	for i := 0; i < len(p); i += 2 {
+		name, err := String(p[i], nil)
+		if err != nil {
+			return err
+		}
+		if err := f(name, p[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamEntry converts a single stream entry reply. A nil reply is converted
+// to a nil entry.
+func streamEntry(v interface{}) (*StreamEntry, error) {
+	if v == nil {
+		return nil, nil
+	}
+	entries, err := StreamEntries([]interface{}{v}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+// optionalInt64 converts an integer reply that is nil on some servers. Nil
+// is converted to -1.
+func optionalInt64(v interface{}) (int64, error) {
+	if v == nil {
+		return -1, nil
+	}
+	return Int64(v, nil)
+}
+
+// StreamInfos is a helper that converts the reply from XINFO STREAM to a
+// StreamInfo. If err is not equal to nil, then StreamInfos returns nil, err.
+func StreamInfos(reply interface{}, err error) (*StreamInfo, error) {
+	if err != nil {
+		return nil, err
+	}
+	info := &StreamInfo{}
+	err = scanPairs(reply, "StreamInfos", func(name string, v interface{}) error {
+		var err error
+		switch name {
+		case "length":
+			info.Length, err = Int64(v, nil)
+		case "radix-tree-keys":
+			info.RadixTreeKeys, err = Int64(v, nil)
+		case "radix-tree-nodes":
+			info.RadixTreeNodes, err = Int64(v, nil)
+		case "groups":
+			info.Groups, err = Int64(v, nil)
+		case "last-generated-id":
+			info.LastGeneratedID, err = String(v, nil)
+		case "max-deleted-entry-id":
+			info.MaxDeletedEntryID, err = String(v, nil)
+		case "entries-added":
+			info.EntriesAdded, err = Int64(v, nil)
+		case "recorded-first-entry-id":
+			info.RecordedFirstEntryID, err = String(v, nil)
+		case "first-entry":
+			info.FirstEntry, err = streamEntry(v)
+		case "last-entry":
+			info.LastEntry, err = streamEntry(v)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// StreamGroupInfos is a helper that converts the reply from XINFO GROUPS to a
+// slice of StreamGroupInfo. If err is not equal to nil, then StreamGroupInfos
+// returns nil, err.
+func StreamGroupInfos(reply interface{}, err error) ([]StreamGroupInfo, error) {
+	var result []StreamGroupInfo
+	err = sliceHelper(reply, err, "StreamGroupInfos", func(n int) { result = make([]StreamGroupInfo, n) }, func(i int, v interface{}) error {
+		g := &result[i]
+		g.EntriesRead = -1
+		g.Lag = -1
+		return scanPairs(v, "StreamGroupInfos", func(name string, v interface{}) error {
+			var err error
+			switch name {
+			case "name":
+				g.Name, err = String(v, nil)
+			case "consumers":
+				g.Consumers, err = Int64(v, nil)
+			case "pending":
+				g.Pending, err = Int64(v, nil)
+			case "last-delivered-id":
+				g.LastDeliveredID, err = String(v, nil)
+			case "entries-read":
+				g.EntriesRead, err = optionalInt64(v)
+			case "lag":
+				g.Lag, err = optionalInt64(v)
+			}
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// StreamConsumerInfos is a helper that converts the reply from XINFO
+// CONSUMERS to a slice of StreamConsumerInfo. If err is not equal to nil,
+// then StreamConsumerInfos returns nil, err.
+func StreamConsumerInfos(reply interface{}, err error) ([]StreamConsumerInfo, error) {
+	var result []StreamConsumerInfo
+	err = sliceHelper(reply, err, "StreamConsumerInfos", func(n int) { result = make([]StreamConsumerInfo, n) }, func(i int, v interface{}) error {
+		c := &result[i]
+		c.Inactive = -1
+		return scanPairs(v, "StreamConsumerInfos", func(name string, v interface{}) error {
+			var err error
+			var ms int64
+			switch name {
+			case "name":
+				c.Name, err = String(v, nil)
+			case "pending":
+				c.Pending, err = Int64(v, nil)
+			case "idle":
+				ms, err = Int64(v, nil)
+				c.Idle = time.Duration(ms) * time.Millisecond
+			case "inactive":
+				if ms, err = Int64(v, nil); ms >= 0 {
+					c.Inactive = time.Duration(ms) * time.Millisecond
+				}
+			}
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
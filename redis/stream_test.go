@@ -0,0 +1,116 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStreams(t *testing.T) {
+	entries := []interface{}{
+		[]interface{}{[]byte("1-0"), []interface{}{[]byte("title"), []byte("Red"), []byte("rating"), []byte("5")}},
+		[]interface{}{[]byte("2-0"), nil},
+	}
+	reply := []interface{}{[]interface{}{[]byte("albums"), entries}}
+
+	streams, err := redis.Streams(reply, nil)
+	if err != nil {
+		t.Fatalf("Streams returned error %v", err)
+	}
+	expected := []redis.Stream{{
+		Key: "albums",
+		Entries: []redis.StreamEntry{
+			{ID: "1-0", Fields: []interface{}{[]byte("title"), []byte("Red"), []byte("rating"), []byte("5")}},
+			{ID: "2-0"},
+		},
+	}}
+	if !reflect.DeepEqual(streams, expected) {
+		t.Fatalf("Streams returned %v, want %v", streams, expected)
+	}
+
+	var a struct {
+		Title  string `redis:"title"`
+		Rating int    `redis:"rating"`
+	}
+	if err := streams[0].Entries[0].ScanStruct(&a); err != nil {
+		t.Fatalf("ScanStruct returned error %v", err)
+	}
+	if a.Title != "Red" || a.Rating != 5 {
+		t.Errorf("ScanStruct returned %+v", a)
+	}
+
+	mapped, err := redis.Streams(map[string]interface{}{"albums": entries}, nil)
+	if err != nil || !reflect.DeepEqual(mapped, expected) {
+		t.Errorf("Streams(map) returned %v, %v", mapped, err)
+	}
+
+	if _, err := redis.Streams(nil, nil); err != errNil {
+		t.Errorf("Streams(nil) returned %v, want ErrNil", err)
+	}
+}
+
+func TestStreamInfos(t *testing.T) {
+	entry := []interface{}{[]byte("1-0"), []interface{}{[]byte("f"), []byte("v")}}
+	info, err := redis.StreamInfos([]interface{}{
+		[]byte("length"), int64(2),
+		[]byte("radix-tree-keys"), int64(1),
+		[]byte("radix-tree-nodes"), int64(2),
+		[]byte("last-generated-id"), []byte("2-0"),
+		[]byte("groups"), int64(1),
+		[]byte("first-entry"), entry,
+		[]byte("last-entry"), nil,
+	}, nil)
+	if err != nil {
+		t.Fatalf("StreamInfos returned error %v", err)
+	}
+	expected := &redis.StreamInfo{
+		Length: 2, RadixTreeKeys: 1, RadixTreeNodes: 2, Groups: 1, LastGeneratedID: "2-0",
+		FirstEntry: &redis.StreamEntry{ID: "1-0", Fields: []interface{}{[]byte("f"), []byte("v")}},
+	}
+	if !reflect.DeepEqual(info, expected) {
+		t.Errorf("StreamInfos returned %+v, want %+v", info, expected)
+	}
+
+	groups, err := redis.StreamGroupInfos([]interface{}{
+		// Redis 6
+		[]interface{}{[]byte("name"), []byte("g1"), []byte("consumers"), int64(2), []byte("pending"), int64(3), []byte("last-delivered-id"), []byte("1-0")},
+		// Redis 7
+		[]interface{}{[]byte("name"), []byte("g2"), []byte("consumers"), int64(0), []byte("pending"), int64(0), []byte("last-delivered-id"), []byte("2-0"), []byte("entries-read"), int64(2), []byte("lag"), nil},
+	}, nil)
+	if err != nil {
+		t.Fatalf("StreamGroupInfos returned error %v", err)
+	}
+	expectedGroups := []redis.StreamGroupInfo{
+		{Name: "g1", Consumers: 2, Pending: 3, LastDeliveredID: "1-0", EntriesRead: -1, Lag: -1},
+		{Name: "g2", LastDeliveredID: "2-0", EntriesRead: 2, Lag: -1},
+	}
+	if !reflect.DeepEqual(groups, expectedGroups) {
+		t.Errorf("StreamGroupInfos returned %+v, want %+v", groups, expectedGroups)
+	}
+
+	consumers, err := redis.StreamConsumerInfos([]interface{}{
+		[]interface{}{[]byte("name"), []byte("c1"), []byte("pending"), int64(1), []byte("idle"), int64(1500), []byte("inactive"), int64(-1)},
+	}, nil)
+	if err != nil {
+		t.Fatalf("StreamConsumerInfos returned error %v", err)
+	}
+	expectedConsumers := []redis.StreamConsumerInfo{{Name: "c1", Pending: 1, Idle: 1500 * time.Millisecond, Inactive: -1}}
+	if !reflect.DeepEqual(consumers, expectedConsumers) {
+		t.Errorf("StreamConsumerInfos returned %+v, want %+v", consumers, expectedConsumers)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// ErrTxConflict is returned by Transact when the watched keys are modified by
+// other clients on every attempt.
+var ErrTxConflict = errors.New("redigo: transaction aborted, watched keys modified by other clients")
+
+// Tx is the transaction passed to the function executed by Transact. Use Conn
+// to read the watched keys and the embedded Pipeline to queue the commands
+// executed in the MULTI/EXEC transaction. The results of the queued commands
+// are set when Transact returns.
+type Tx struct {
+	// Conn executes commands immediately.
+	Conn Conn
+
+	*Pipeline
+}
+
+// TxOptions specifies the retry policy of a transaction.
+type TxOptions struct {
+	// MaxAttempts is the maximum number of times the transaction is
+	// attempted. The default is 5.
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry. The delay doubles with
+	// each retry and includes random jitter. The default is one millisecond.
+	Backoff time.Duration
+}
+
+// Transact executes an optimistic transaction with the default options. See
+// TxOptions.Transact for details.
+//
+//  err := redis.Transact(c, func(tx *redis.Tx) error {
+//      n, err := redis.Int(tx.Conn.Do("GET", "counter"))
+//      if err != nil && err != redis.ErrNil {
+//          return err
+//      }
+//      tx.Do("SET", "counter", n*2)
+//      return nil
+//  }, "counter")
+func Transact(c Conn, fn func(tx *Tx) error, keys ...string) error {
+	var o TxOptions
+	return o.Transact(c, fn, keys...)
+}
+
+// Transact watches keys with WATCH, calls fn and executes the commands queued
+// by fn in a MULTI/EXEC transaction. If a watched key is modified before EXEC,
+// then Transact calls fn again after a delay. Transact returns ErrTxConflict
+// when the attempts are exhausted.
+//
+// If fn returns an error or queues no commands, then Transact sends UNWATCH
+// and returns the error from fn. Otherwise, Transact returns the error from
+// executing the pipeline.
+func (o *TxOptions) Transact(c Conn, fn func(tx *Tx) error, keys ...string) error {
+	maxAttempts := o.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 5
+	}
+	backoff := o.Backoff
+	if backoff <= 0 {
+		backoff = time.Millisecond
+	}
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			d := backoff << uint(attempt-1)
+			time.Sleep(d/2 + time.Duration(rand.Int63n(int64(d/2)+1)))
+		}
+		if len(args) > 0 {
+			if _, err := c.Do("WATCH", args...); err != nil {
+				return err
+			}
+		}
+		tx := &Tx{Conn: c, Pipeline: NewTxPipeline(c)}
+		if err := fn(tx); err != nil || tx.Len() == 0 {
+			if len(args) > 0 {
+				c.Do("UNWATCH")
+			}
+			return err
+		}
+		if err := tx.Exec(); err != ErrNil {
+			return err
+		}
+		// A watched key was modified. Try again.
+	}
+	return ErrTxConflict
+}
@@ -0,0 +1,90 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"errors"
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTransact(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		commands []string
+		aborts   = 1
+	)
+	l := serveFake(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, strings.Join(args, " "))
+		switch args[0] {
+		case "GET":
+			return "$1\r\n2\r\n"
+		case "SET":
+			return "+QUEUED\r\n"
+		case "WATCH", "UNWATCH", "MULTI":
+			return "+OK\r\n"
+		case "EXEC":
+			if aborts > 0 {
+				aborts--
+				return "*-1\r\n"
+			}
+			return "*1\r\n+OK\r\n"
+		}
+		return "-ERR unknown command\r\n"
+	})
+	defer l.Close()
+	c, err := redis.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	double := func(tx *redis.Tx) error {
+		n, err := redis.Int(tx.Conn.Do("GET", "counter"))
+		if err != nil {
+			return err
+		}
+		tx.Do("SET", "counter", n*2)
+		return nil
+	}
+	if err := redis.Transact(c, double, "counter"); err != nil {
+		t.Fatalf("Transact returned %v", err)
+	}
+	attempt := []string{"WATCH counter", "GET counter", "MULTI", "SET counter 4", "EXEC"}
+	if expected := append(attempt, attempt...); !reflect.DeepEqual(commands, expected) {
+		t.Errorf("commands = %q, want %q", commands, expected)
+	}
+
+	errStop := errors.New("stop")
+	commands = nil
+	err = redis.Transact(c, func(tx *redis.Tx) error { return errStop }, "counter")
+	if err != errStop {
+		t.Errorf("Transact returned %v, want %v", err, errStop)
+	}
+	if expected := []string{"WATCH counter", "UNWATCH"}; !reflect.DeepEqual(commands, expected) {
+		t.Errorf("commands = %q, want %q", commands, expected)
+	}
+
+	aborts = 10
+	o := redis.TxOptions{MaxAttempts: 2}
+	if err := o.Transact(c, double, "counter"); err != redis.ErrTxConflict {
+		t.Errorf("Transact returned %v, want ErrTxConflict", err)
+	}
+}
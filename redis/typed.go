@@ -0,0 +1,59 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package redis
+
+import (
+	"reflect"
+)
+
+// StructOf is a helper that converts a command reply to a value of struct type
+// T using ScanStruct. If err is not equal to nil, then StructOf returns the
+// zero value of T and err.
+//
+//  u, err := redis.StructOf[User](c.Do("HGETALL", "user:1"))
+func StructOf[T any](reply interface{}, err error) (T, error) {
+	var v T
+	values, err := Values(reply, err)
+	if err != nil {
+		return v, err
+	}
+	err = ScanStruct(values, &v)
+	return v, err
+}
+
+// SliceOf is a helper that converts a multi-bulk command reply to a []T using
+// ScanSlice. If err is not equal to nil, then SliceOf returns nil, err.
+//
+//  albums, err := redis.SliceOf[Album](c.Do("SORT", "albums",
+//      "GET", "album:*->title", "GET", "album:*->rating"), "Title", "Rating")
+func SliceOf[T any](reply interface{}, err error, fieldNames ...string) ([]T, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	var s []T
+	if err := ScanSlice(values, &s, fieldNames...); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// CompileStructOf is like CompileStruct for the struct type T.
+func CompileStructOf[T any]() *StructCodec {
+	return CompileStruct(reflect.TypeOf((*T)(nil)).Elem())
+}
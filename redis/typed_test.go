@@ -0,0 +1,60 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package redis_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"testing"
+)
+
+type album struct {
+	Title  string
+	Rating int
+}
+
+func TestStructOf(t *testing.T) {
+	reply := []interface{}{[]byte("Title"), []byte("Red"), []byte("Rating"), []byte("5")}
+	v, err := redis.StructOf[album](reply, nil)
+	if err != nil {
+		t.Fatalf("StructOf returned error %v", err)
+	}
+	if v != (album{"Red", 5}) {
+		t.Errorf("StructOf returned %+v", v)
+	}
+	if _, err := redis.StructOf[album](nil, nil); err != errNil {
+		t.Errorf("StructOf(nil) returned %v, want ErrNil", err)
+	}
+}
+
+func TestSliceOf(t *testing.T) {
+	reply := []interface{}{[]byte("Red"), []byte("5"), []byte("Beat"), nil}
+	v, err := redis.SliceOf[*album](reply, nil)
+	if err != nil {
+		t.Fatalf("SliceOf returned error %v", err)
+	}
+	expected := []*album{{"Red", 5}, {"Beat", 0}}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("SliceOf returned %v, want %v", v, expected)
+	}
+
+	ratings, err := redis.SliceOf[int]([]interface{}{[]byte("1"), int64(2)}, nil)
+	if err != nil || !reflect.DeepEqual(ratings, []int{1, 2}) {
+		t.Errorf("SliceOf[int] returned %v, %v", ratings, err)
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package redis
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Codec converts values of type T to command arguments and converts reply
+// values to T. The typed key accessors use a Codec to encode values and set
+// members.
+type Codec[T any] interface {
+	Encode(v T) (interface{}, error)
+	Decode(src interface{}) (T, error)
+}
+
+// DefaultCodec is the codec used by the typed key accessors when the Codec
+// field is nil. DefaultCodec sends values as command arguments are sent and
+// converts replies as the Scan function does.
+type DefaultCodec[T any] struct{}
+
+func (DefaultCodec[T]) Encode(v T) (interface{}, error) { return v, nil }
+
+func (DefaultCodec[T]) Decode(src interface{}) (T, error) {
+	var v T
+	_, err := Scan([]interface{}{src}, &v)
+	return v, err
+}
+
+// JSONCodec encodes values as JSON.
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Encode(v T) (interface{}, error) { return json.Marshal(v) }
+
+func (JSONCodec[T]) Decode(src interface{}) (T, error) {
+	var v T
+	p, err := Bytes(src, nil)
+	if err != nil {
+		return v, err
+	}
+	err = json.Unmarshal(p, &v)
+	return v, err
+}
+
+func codecOf[T any](c Codec[T]) Codec[T] {
+	if c == nil {
+		return DefaultCodec[T]{}
+	}
+	return c
+}
+
+func decodeAll[T any](codec Codec[T], values []interface{}, err error) ([]T, error) {
+	if err != nil {
+		return nil, err
+	}
+	codec = codecOf(codec)
+	result := make([]T, len(values))
+	for i, v := range values {
+		if result[i], err = codec.Decode(v); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// StringKey is a string key holding a value of type T. The value is encoded
+// with Codec. If TTL is greater than zero, then Set sets the timeout of the
+// key to TTL.
+//
+//  visits := redis.StringKey[int]{Key: "visits", TTL: time.Hour}
+//  n, err := visits.Get(c)
+type StringKey[T any] struct {
+	Key   string
+	Codec Codec[T]
+	TTL   time.Duration
+}
+
+// Get returns the value of the key. Get returns ErrNil if the key does not
+// exist.
+func (k StringKey[T]) Get(c Conn) (T, error) {
+	var v T
+	if err := declareKeyType(k.Key, "string"); err != nil {
+		return v, err
+	}
+	reply, err := c.Do("GET", k.Key)
+	if err != nil {
+		return v, err
+	}
+	if reply == nil {
+		return v, ErrNil
+	}
+	return codecOf(k.Codec).Decode(reply)
+}
+
+// Set sets the value of the key.
+func (k StringKey[T]) Set(c Conn, v T) error {
+	if err := declareKeyType(k.Key, "string"); err != nil {
+		return err
+	}
+	arg, err := codecOf(k.Codec).Encode(v)
+	if err != nil {
+		return err
+	}
+	if k.TTL > 0 {
+		_, err = c.Do("SET", k.Key, arg, "PX", int64(k.TTL/time.Millisecond))
+	} else {
+		_, err = c.Do("SET", k.Key, arg)
+	}
+	return err
+}
+
+// HashKey is a hash key holding a struct of type T. The fields of the struct
+// are stored in the hash as described for AppendStruct and ScanStruct. If TTL
+// is greater than zero, then Set sets the timeout of the key to TTL.
+type HashKey[T any] struct {
+	Key string
+	TTL time.Duration
+}
+
+// Get returns the struct stored in the hash. Get returns ErrNil if the key
+// does not exist.
+func (k HashKey[T]) Get(c Conn) (T, error) {
+	var v T
+	err := k.Scan(c, &v)
+	return v, err
+}
+
+// Scan stores the fields of the hash in dest. Fields missing from the hash
+// are not modified. Scan returns ErrNil if the key does not exist.
+func (k HashKey[T]) Scan(c Conn, dest *T) error {
+	if err := declareKeyType(k.Key, "hash"); err != nil {
+		return err
+	}
+	values, err := Values(c.Do("HGETALL", k.Key))
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return ErrNil
+	}
+	return ScanStruct(values, dest)
+}
+
+// Set replaces the hash with the fields of v in a transaction.
+func (k HashKey[T]) Set(c Conn, v T) error {
+	if err := declareKeyType(k.Key, "hash"); err != nil {
+		return err
+	}
+	args, err := AppendStruct([]interface{}{k.Key}, &v)
+	if err != nil {
+		return err
+	}
+	c.Send("MULTI")
+	c.Send("DEL", k.Key)
+	if len(args) > 1 {
+		c.Send("HSET", args...)
+		if k.TTL > 0 {
+			c.Send("PEXPIRE", k.Key, int64(k.TTL/time.Millisecond))
+		}
+	}
+	_, err = c.Do("EXEC")
+	return err
+}
+
+// SetKey is a set key with members of type T. The members are encoded with
+// Codec. If TTL is greater than zero, then Add sets the timeout of the key to
+// TTL.
+type SetKey[T any] struct {
+	Key   string
+	Codec Codec[T]
+	TTL   time.Duration
+}
+
+func (k SetKey[T]) encode(args []interface{}, members []T) ([]interface{}, error) {
+	codec := codecOf(k.Codec)
+	for _, m := range members {
+		arg, err := codec.Encode(m)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// Add adds members to the set and returns the number of members added.
+func (k SetKey[T]) Add(c Conn, members ...T) (int, error) {
+	if err := declareKeyType(k.Key, "set"); err != nil {
+		return 0, err
+	}
+	args, err := k.encode([]interface{}{k.Key}, members)
+	if err != nil {
+		return 0, err
+	}
+	if k.TTL <= 0 {
+		return Int(c.Do("SADD", args...))
+	}
+	c.Send("MULTI")
+	c.Send("SADD", args...)
+	c.Send("PEXPIRE", k.Key, int64(k.TTL/time.Millisecond))
+	replies, err := Values(c.Do("EXEC"))
+	if err != nil {
+		return 0, err
+	}
+	return Int(replies[0], nil)
+}
+
+// Remove removes members from the set and returns the number of members
+// removed.
+func (k SetKey[T]) Remove(c Conn, members ...T) (int, error) {
+	if err := declareKeyType(k.Key, "set"); err != nil {
+		return 0, err
+	}
+	args, err := k.encode([]interface{}{k.Key}, members)
+	if err != nil {
+		return 0, err
+	}
+	return Int(c.Do("SREM", args...))
+}
+
+// IsMember returns true if member is a member of the set.
+func (k SetKey[T]) IsMember(c Conn, member T) (bool, error) {
+	if err := declareKeyType(k.Key, "set"); err != nil {
+		return false, err
+	}
+	args, err := k.encode([]interface{}{k.Key}, []T{member})
+	if err != nil {
+		return false, err
+	}
+	return Bool(c.Do("SISMEMBER", args...))
+}
+
+// Members returns the members of the set.
+func (k SetKey[T]) Members(c Conn) ([]T, error) {
+	if err := declareKeyType(k.Key, "set"); err != nil {
+		return nil, err
+	}
+	values, err := Values(c.Do("SMEMBERS", k.Key))
+	return decodeAll(k.Codec, values, err)
+}
+
+// ZSetKey is a sorted set key with members of type T. The members are encoded
+// with Codec. If TTL is greater than zero, then Add sets the timeout of the
+// key to TTL.
+type ZSetKey[T any] struct {
+	Key   string
+	Codec Codec[T]
+	TTL   time.Duration
+}
+
+// Add adds member with score to the sorted set or updates the score of an
+// existing member. Add returns true if the member was added.
+func (k ZSetKey[T]) Add(c Conn, score float64, member T) (bool, error) {
+	if err := declareKeyType(k.Key, "zset"); err != nil {
+		return false, err
+	}
+	arg, err := codecOf(k.Codec).Encode(member)
+	if err != nil {
+		return false, err
+	}
+	if k.TTL <= 0 {
+		return Bool(c.Do("ZADD", k.Key, score, arg))
+	}
+	c.Send("MULTI")
+	c.Send("ZADD", k.Key, score, arg)
+	c.Send("PEXPIRE", k.Key, int64(k.TTL/time.Millisecond))
+	replies, err := Values(c.Do("EXEC"))
+	if err != nil {
+		return false, err
+	}
+	return Bool(replies[0], nil)
+}
+
+// Score returns the score of member. Score returns ErrNil if member is not a
+// member of the sorted set.
+func (k ZSetKey[T]) Score(c Conn, member T) (float64, error) {
+	if err := declareKeyType(k.Key, "zset"); err != nil {
+		return 0, err
+	}
+	arg, err := codecOf(k.Codec).Encode(member)
+	if err != nil {
+		return 0, err
+	}
+	return Float64(c.Do("ZSCORE", k.Key, arg))
+}
+
+// Range returns the members with ranks start through stop in order of
+// increasing score. Negative ranks are offsets from the end of the sorted
+// set.
+func (k ZSetKey[T]) Range(c Conn, start, stop int) ([]T, error) {
+	if err := declareKeyType(k.Key, "zset"); err != nil {
+		return nil, err
+	}
+	values, err := Values(c.Do("ZRANGE", k.Key, start, stop))
+	return decodeAll(k.Codec, values, err)
+}
@@ -0,0 +1,100 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package redis_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type typedKeyUser struct {
+	Name string `redis:"name"`
+	Age  int    `redis:"age"`
+}
+
+func TestTypedKeys(t *testing.T) {
+	addr, stop := serveStore(t)
+	defer stop()
+	c, err := redis.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	visits := redis.StringKey[int]{Key: "visits", TTL: time.Minute}
+	if _, err := visits.Get(c); err != redis.ErrNil {
+		t.Errorf("Get of missing key returned %v, want ErrNil", err)
+	}
+	if err := visits.Set(c, 42); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if n, err := visits.Get(c); err != nil || n != 42 {
+		t.Errorf("Get returned %d, %v", n, err)
+	}
+	if ttl, _ := redis.Int(c.Do("PTTL", "visits")); ttl != 60000 {
+		t.Errorf("PTTL = %d, want 60000", ttl)
+	}
+
+	tags := redis.StringKey[[]string]{Key: "tags", Codec: redis.JSONCodec[[]string]{}}
+	if err := tags.Set(c, []string{"a", "b"}); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if v, err := tags.Get(c); err != nil || !reflect.DeepEqual(v, []string{"a", "b"}) {
+		t.Errorf("Get returned %v, %v", v, err)
+	}
+
+	user := redis.HashKey[typedKeyUser]{Key: "user:1", TTL: time.Hour}
+	if err := user.Set(c, typedKeyUser{Name: "gopher", Age: 12}); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if u, err := user.Get(c); err != nil || u != (typedKeyUser{Name: "gopher", Age: 12}) {
+		t.Errorf("Get returned %+v, %v", u, err)
+	}
+	if ttl, _ := redis.Int(c.Do("PTTL", "user:1")); ttl != 3600000 {
+		t.Errorf("PTTL = %d, want 3600000", ttl)
+	}
+
+	ids := redis.SetKey[int]{Key: "ids"}
+	if n, err := ids.Add(c, 3, 1, 2); err != nil || n != 1 {
+		t.Errorf("Add returned %d, %v", n, err)
+	}
+	if ok, err := ids.IsMember(c, 2); err != nil || !ok {
+		t.Errorf("IsMember returned %v, %v", ok, err)
+	}
+	if members, err := ids.Members(c); err != nil || !reflect.DeepEqual(members, []int{1, 2, 3}) {
+		t.Errorf("Members returned %v, %v", members, err)
+	}
+
+	scores := redis.ZSetKey[string]{Key: "scores", TTL: time.Second}
+	for _, m := range []struct {
+		score  float64
+		member string
+	}{{2, "b"}, {1, "a"}} {
+		if added, err := scores.Add(c, m.score, m.member); err != nil || !added {
+			t.Errorf("Add(%v, %s) returned %v, %v", m.score, m.member, added, err)
+		}
+	}
+	if score, err := scores.Score(c, "b"); err != nil || score != 2 {
+		t.Errorf("Score returned %v, %v", score, err)
+	}
+	if members, err := scores.Range(c, 0, -1); err != nil || !reflect.DeepEqual(members, []string{"a", "b"}) {
+		t.Errorf("Range returned %v, %v", members, err)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"reflect"
+)
+
+// UpdateStruct updates the hash at key from struct old to struct new. The
+// arguments old and new are structs or pointers to structs of the same type.
+// The fields are encoded as described in AppendStruct. UpdateStruct sets the
+// fields whose encoded values differ using HSET and deletes the fields that
+// are present in old and skipped in new, for example nil pointer fields,
+// using HDEL. The commands are executed in a MULTI/EXEC transaction when
+// both are needed. UpdateStruct does not send a command if the encoded
+// values are equal.
+//
+// Use UpdateStruct to reduce write amplification and replication traffic
+// for large hashes where few fields change between writes:
+//
+//  old := u
+//  u.Visits++
+//  err := redis.UpdateStruct(c, "user:1", &old, &u)
+func UpdateStruct(c Conn, key string, old, new interface{}) error {
+	set, del, _, err := diffStruct(old, new)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(set) > 0 && len(del) > 0:
+		c.Send("MULTI")
+		c.Send("HSET", append([]interface{}{key}, set...)...)
+		c.Send("HDEL", append([]interface{}{key}, del...)...)
+		err = execError(c.Do("EXEC"))
+	case len(set) > 0:
+		_, err = c.Do("HSET", append([]interface{}{key}, set...)...)
+	case len(del) > 0:
+		_, err = c.Do("HDEL", append([]interface{}{key}, del...)...)
+	}
+	return err
+}
+
+// diffStruct returns the alternating names and values of the fields to set,
+// the names of the fields to delete and the changes from struct old to
+// struct new.
+func diffStruct(old, new interface{}) (set, del []interface{}, changes []FieldChange, err error) {
+	if t := indirectType(reflect.TypeOf(new)); t == nil || t != indirectType(reflect.TypeOf(old)) {
+		return nil, nil, nil, errors.New("redigo: UpdateStruct arguments must have the same type")
+	}
+	oldArgs, err := AppendStruct(nil, old)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	newArgs, err := AppendStruct(nil, new)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	oldValues := make(map[string]string, len(oldArgs)/2)
+	for i := 0; i+1 < len(oldArgs); i += 2 {
+		oldValues[oldArgs[i].(string)] = argString(oldArgs[i+1])
+	}
+	for i := 0; i+1 < len(newArgs); i += 2 {
+		name := newArgs[i].(string)
+		s := argString(newArgs[i+1])
+		v, found := oldValues[name]
+		delete(oldValues, name)
+		if found && v == s {
+			continue
+		}
+		set = append(set, name, newArgs[i+1])
+		fc := FieldChange{Field: name, New: &s}
+		if found {
+			fc.Old = &v
+		}
+		changes = append(changes, fc)
+	}
+	for i := 0; i+1 < len(oldArgs); i += 2 {
+		name := oldArgs[i].(string)
+		if v, found := oldValues[name]; found {
+			del = append(del, name)
+			changes = append(changes, FieldChange{Field: name, Old: &v})
+		}
+	}
+	return set, del, changes, nil
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// argString returns the encoding of command argument v as a string.
+func argString(v interface{}) string {
+	if a, ok := v.(Argument); ok {
+		v = a.RedisArg()
+	}
+	return formatValue(v)
+}
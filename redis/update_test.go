@@ -0,0 +1,143 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"context"
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"testing"
+)
+
+type updateUser struct {
+	Name   string  `redis:"name"`
+	Visits int     `redis:"visits"`
+	Email  *string `redis:"email"`
+}
+
+func TestUpdateStruct(t *testing.T) {
+	addr, stop := serveStore(t)
+	defer stop()
+	c, err := redis.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	email := "g@example.com"
+	old := updateUser{Name: "gopher", Visits: 1, Email: &email}
+	if _, err := c.Do("HSET", "user:1", "name", "gopher", "visits", 1, "email", email); err != nil {
+		t.Fatal(err)
+	}
+	// Modify the hash behind the back of UpdateStruct to detect writes of
+	// unchanged fields.
+	if _, err := c.Do("HSET", "user:1", "name", "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	new := updateUser{Name: "gopher", Visits: 2}
+	if err := redis.UpdateStruct(c, "user:1", &old, new); err != nil {
+		t.Fatalf("UpdateStruct returned %v", err)
+	}
+	m, err := redis.StringMap(c.Do("HGETALL", "user:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"name": "other", "visits": "2"}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("hash is %v, want %v", m, expected)
+	}
+
+	if err := redis.UpdateStruct(c, "user:1", &old, struct{ Name string }{}); err == nil {
+		t.Error("UpdateStruct with different types did not return error")
+	}
+}
+
+func TestMapperUpdate(t *testing.T) {
+	addr, stop := serveStore(t)
+	defer stop()
+	c, err := redis.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	m := redis.Mapper{ChangeStream: "changes", ChangeStreamMaxLen: 1000}
+	email := "g@example.com"
+	old := updateUser{Name: "gopher", Visits: 1, Email: &email}
+	if err := m.Save(c, "user:1", &old); err != nil {
+		t.Fatalf("Save returned %v", err)
+	}
+	ctx := redis.WithActor(context.Background(), "admin")
+	if err := m.Update(ctx, c, "user:1", &old, &updateUser{Name: "gopher", Visits: 2}); err != nil {
+		t.Fatalf("Update returned %v", err)
+	}
+	if err := m.Update(ctx, c, "user:1", &old, &old); err != nil {
+		t.Fatalf("Update without changes returned %v", err)
+	}
+
+	entries, err := redis.StreamEntries(c.Do("XRANGE", "changes", "-", "+"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("stream has %d entries, want 1", len(entries))
+	}
+	var event redis.ChangeEvent
+	if err := entries[0].ScanStruct(&event); err != nil {
+		t.Fatalf("ScanStruct returned %v", err)
+	}
+	one, two := "1", "2"
+	expected := redis.ChangeEvent{
+		Key:   "user:1",
+		Actor: "admin",
+		Changes: []redis.FieldChange{
+			{Field: "visits", Old: &one, New: &two},
+			{Field: "email", Old: &email},
+		},
+	}
+	if !reflect.DeepEqual(event, expected) {
+		t.Errorf("event is %+v, want %+v", event, expected)
+	}
+	h, err := redis.StringMap(c.Do("HGETALL", "user:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := h["email"]; ok || h["visits"] != "2" {
+		t.Errorf("hash is %v after Update", h)
+	}
+}
+
+func TestMapperUpdateExecError(t *testing.T) {
+	c, stop := dialExecError(t)
+	defer stop()
+
+	m := redis.Mapper{ChangeStream: "changes"}
+	err := m.Update(context.Background(), c, "user:1", &updateUser{Visits: 1}, &updateUser{Visits: 2})
+	if _, ok := err.(redis.Error); !ok {
+		t.Errorf("Update returned %v, want redis.Error", err)
+	}
+}
+
+func TestUpdateStructExecError(t *testing.T) {
+	c, stop := dialExecError(t)
+	defer stop()
+
+	email := "g@example.com"
+	err := redis.UpdateStruct(c, "user:1", &updateUser{Email: &email}, &updateUser{Visits: 2})
+	if _, ok := err.(redis.Error); !ok {
+		t.Errorf("UpdateStruct returned %v, want redis.Error", err)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"reflect"
+)
+
+// Violation describes a hash field that does not conform to a struct type.
+type Violation struct {
+	// Field is the name of the hash field.
+	Field string
+
+	// Reason describes the violation. The reason is "missing" for a required
+	// field that is missing or nil. Otherwise, the reason is the error
+	// returned from decoding the value.
+	Reason string
+}
+
+// Validate audits the hash stored at key against the struct type of codec.
+// Validate reports required fields that are missing from the hash and fields
+// whose values cannot be decoded to the corresponding struct field. The
+// "required" flag is checked even when legacy scanning is enabled. Validate
+// returns ErrNil if key does not exist.
+//
+// Use Validate to find hashes that do not satisfy a schema before tightening
+// the field tags of the struct type:
+//
+//  codec := redis.CompileStruct(reflect.TypeOf(User{}))
+//  violations, err := redis.Validate(c, "user:1", codec)
+func Validate(c Conn, key string, codec *StructCodec) ([]Violation, error) {
+	src, err := Values(c.Do("HGETALL", key))
+	if err != nil {
+		return nil, err
+	}
+	if len(src) == 0 {
+		return nil, ErrNil
+	}
+	if len(src)%2 != 0 {
+		return nil, errors.New("redigo: Validate expects even number of values in reply")
+	}
+	values := make(map[string]interface{}, len(src)/2)
+	for i := 0; i < len(src); i += 2 {
+		name, err := String(src[i], nil)
+		if err != nil {
+			return nil, errors.New("redigo: Validate key not a bulk value")
+		}
+		values[name] = src[i+1]
+	}
+
+	var violations []Violation
+	d := reflect.New(codec.t).Elem()
+	for _, fs := range codec.ss.l {
+		v := values[fs.name]
+		if v == nil {
+			if fs.required {
+				violations = append(violations, Violation{Field: fs.name, Reason: "missing"})
+			}
+			continue
+		}
+		if err := fs.scan(d.FieldByIndex(fs.index), v); err != nil {
+			violations = append(violations, Violation{Field: fs.name, Reason: err.Error()})
+		}
+	}
+	return violations, nil
+}
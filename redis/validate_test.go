@@ -0,0 +1,73 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"testing"
+)
+
+type validateUser struct {
+	Name  string            `redis:"name,required"`
+	Age   int               `redis:"age,required"`
+	Email string            `redis:"email"`
+	Attrs map[string]string `redis:"attrs,json"`
+}
+
+func TestValidate(t *testing.T) {
+	addr, stop := serveStore(t)
+	defer stop()
+	c, err := redis.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	codec := redis.CompileStruct(reflect.TypeOf(validateUser{}))
+
+	if _, err := c.Do("HSET", "user:1", "name", "gopher", "age", "12", "attrs", `{"a":"b"}`); err != nil {
+		t.Fatal(err)
+	}
+	violations, err := redis.Validate(c, "user:1", codec)
+	if err != nil || len(violations) != 0 {
+		t.Errorf("Validate(user:1) returned %v, %v", violations, err)
+	}
+
+	if _, err := c.Do("HSET", "user:2", "age", "old", "attrs", "{", "other", "x"); err != nil {
+		t.Fatal(err)
+	}
+	violations, err = redis.Validate(c, "user:2", codec)
+	if err != nil {
+		t.Fatalf("Validate(user:2) returned error %v", err)
+	}
+	var fields []string
+	for _, v := range violations {
+		fields = append(fields, v.Field)
+		if v.Reason == "" {
+			t.Errorf("violation of %s has empty reason", v.Field)
+		}
+	}
+	if want := []string{"name", "age", "attrs"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("Validate(user:2) reported %v, want %v", fields, want)
+	}
+	if violations[0].Reason != "missing" {
+		t.Errorf("Validate(user:2) reason for name = %q, want missing", violations[0].Reason)
+	}
+
+	if _, err := redis.Validate(c, "user:3", codec); err != redis.ErrNil {
+		t.Errorf("Validate(user:3) returned %v, want ErrNil", err)
+	}
+}
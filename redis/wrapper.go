@@ -0,0 +1,60 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// connWrapper is embedded by the connections that wrap another connection
+// to add behavior to commands. The wrapper forwards the optional connection
+// interfaces to the wrapped connection. Connections that embed connWrapper
+// override the methods that must apply their behavior, including doTo and
+// receiveTo for streamed replies.
+type connWrapper struct {
+	Conn
+}
+
+func (c connWrapper) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return ReceiveWithTimeout(c.Conn, timeout)
+}
+
+func (c connWrapper) withContext(ctx context.Context, f func() error) error {
+	return withContext(c.Conn, ctx, f)
+}
+
+func (c connWrapper) expired(now time.Time) bool {
+	ec, ok := c.Conn.(expiringConn)
+	return ok && ec.expired(now)
+}
+
+func (c connWrapper) doTo(w io.Writer, commandName string, args []interface{}) (int64, error) {
+	return DoTo(c.Conn, w, commandName, args...)
+}
+
+func (c connWrapper) receiveTo(w io.Writer) (int64, error) {
+	return ReceiveTo(c.Conn, w)
+}
+
+func (c connWrapper) rawConn() (*RawConn, error) {
+	return Raw(c.Conn)
+}
+
+// errRawChecked is returned by Raw for connections that check or record
+// every command because raw access bypasses the connection.
+var errRawChecked = errors.New("redigo: raw access is not supported by a connection that checks commands")
@@ -0,0 +1,31 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package redisotel traces Redis commands with OpenTelemetry.
+//
+// Wrap a connection with NewConn to create a client span for each command:
+//
+//  c := redisotel.NewConn(redis.ToConnV2(pool.Get()))
+//  defer c.Close()
+//  reply, err := c.Do(ctx, "GET", "key")
+//
+// The span is a child of the span in the context passed to the command and
+// has the db.system, db.operation and db.statement attributes. The statement
+// is formatted with redis.FormatCommand.
+//
+// The package depends on go.opentelemetry.io/otel and is built only with
+// the redigo_otel build tag:
+//
+//  go build -tags redigo_otel
+package redisotel
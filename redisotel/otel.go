@@ -0,0 +1,162 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+//go:build redigo_otel
+// +build redigo_otel
+
+package redisotel
+
+import (
+	"context"
+	"github.com/garyburd/redigo/redis"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+)
+
+const instrumentationName = "github.com/garyburd/redigo/redisotel"
+
+// Option specifies an option for tracing a connection.
+type Option struct {
+	f func(*options)
+}
+
+type options struct {
+	provider trace.TracerProvider
+	attrs    []attribute.KeyValue
+}
+
+// WithTracerProvider specifies the tracer provider used to create spans. The
+// default is the global provider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return Option{func(o *options) {
+		o.provider = provider
+	}}
+}
+
+// WithAttributes specifies attributes to add to every span, for example the
+// net.peer.name and net.peer.port of the server.
+func WithAttributes(attrs ...attribute.KeyValue) Option {
+	return Option{func(o *options) {
+		o.attrs = append(o.attrs, attrs...)
+	}}
+}
+
+// NewConn returns a connection that creates a span for each command executed
+// on c. The span for a command pipelined with Send ends when the reply is
+// received with Receive or Do.
+func NewConn(c redis.ConnV2, opts ...Option) redis.ConnV2 {
+	var o options
+	for _, opt := range opts {
+		opt.f(&o)
+	}
+	if o.provider == nil {
+		o.provider = otel.GetTracerProvider()
+	}
+	return &conn{
+		c:      c,
+		tracer: o.provider.Tracer(instrumentationName),
+		attrs:  o.attrs,
+	}
+}
+
+type conn struct {
+	c       redis.ConnV2
+	tracer  trace.Tracer
+	attrs   []attribute.KeyValue
+	pending []trace.Span
+}
+
+func (c *conn) start(ctx context.Context, commandName string, args []interface{}) (context.Context, trace.Span) {
+	operation := strings.ToUpper(commandName)
+	attrs := append([]attribute.KeyValue{
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", operation),
+		attribute.String("db.statement", redis.FormatCommand(commandName, args)),
+	}, c.attrs...)
+	return c.tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+}
+
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// endPending ends the spans for pipelined commands. If replies is not nil,
+// then replies[i] is the reply to the i'th pipelined command.
+func (c *conn) endPending(replies []interface{}, err error) {
+	for i, span := range c.pending {
+		e := err
+		if i < len(replies) {
+			if re, ok := replies[i].(redis.Error); ok {
+				e = re
+			}
+		}
+		end(span, e)
+	}
+	c.pending = nil
+}
+
+func (c *conn) Close() error {
+	c.endPending(nil, nil)
+	return c.c.Close()
+}
+
+func (c *conn) Err() error {
+	return c.c.Err()
+}
+
+func (c *conn) Do(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	if commandName == "" {
+		reply, err := c.c.Do(ctx, "")
+		replies, _ := reply.([]interface{})
+		c.endPending(replies, err)
+		return reply, err
+	}
+	ctx, span := c.start(ctx, commandName, args)
+	reply, err := c.c.Do(ctx, commandName, args...)
+	c.endPending(nil, nil)
+	end(span, err)
+	return reply, err
+}
+
+func (c *conn) Send(ctx context.Context, commandName string, args ...interface{}) error {
+	ctx, span := c.start(ctx, commandName, args)
+	if err := c.c.Send(ctx, commandName, args...); err != nil {
+		end(span, err)
+		return err
+	}
+	c.pending = append(c.pending, span)
+	return nil
+}
+
+func (c *conn) Flush(ctx context.Context) error {
+	return c.c.Flush(ctx)
+}
+
+func (c *conn) Receive(ctx context.Context) (interface{}, error) {
+	reply, err := c.c.Receive(ctx)
+	if len(c.pending) > 0 {
+		end(c.pending[0], err)
+		c.pending = c.pending[1:]
+	}
+	return reply, err
+}
@@ -0,0 +1,98 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+//go:build redigo_otel
+// +build redigo_otel
+
+package redisotel_test
+
+import (
+	"context"
+	"github.com/garyburd/redigo/redis"
+	"github.com/garyburd/redigo/redisotel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"reflect"
+	"testing"
+)
+
+// fakeConn replies with the command name or an error for the ERR command.
+type fakeConn struct {
+	pending []string
+}
+
+func (c *fakeConn) reply(commandName string) (interface{}, error) {
+	if commandName == "ERR" {
+		return nil, redis.Error("ERR failed")
+	}
+	return commandName, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Err() error   { return nil }
+
+func (c *fakeConn) Do(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	c.pending = nil
+	return c.reply(commandName)
+}
+
+func (c *fakeConn) Send(ctx context.Context, commandName string, args ...interface{}) error {
+	c.pending = append(c.pending, commandName)
+	return nil
+}
+
+func (c *fakeConn) Flush(ctx context.Context) error { return nil }
+
+func (c *fakeConn) Receive(ctx context.Context) (interface{}, error) {
+	commandName := c.pending[0]
+	c.pending = c.pending[1:]
+	return c.reply(commandName)
+}
+
+func TestNewConn(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	c := redisotel.NewConn(&fakeConn{}, redisotel.WithTracerProvider(tp))
+	ctx := context.Background()
+
+	c.Do(ctx, "SET", "k", "v")
+	c.Send(ctx, "get", "k")
+	c.Send(ctx, "ERR")
+	c.Flush(ctx)
+	c.Receive(ctx)
+	c.Receive(ctx)
+	c.Close()
+
+	var names, statements []string
+	var statuses []codes.Code
+	for _, span := range sr.Ended() {
+		names = append(names, span.Name())
+		statuses = append(statuses, span.Status().Code)
+		for _, kv := range span.Attributes() {
+			if kv.Key == "db.statement" {
+				statements = append(statements, kv.Value.AsString())
+			}
+		}
+	}
+	if expected := []string{"SET", "GET", "ERR"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("span names = %q, want %q", names, expected)
+	}
+	if expected := []string{"SET k v", "get k", "ERR"}; !reflect.DeepEqual(statements, expected) {
+		t.Errorf("statements = %q, want %q", statements, expected)
+	}
+	if expected := []codes.Code{codes.Unset, codes.Unset, codes.Error}; !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("statuses = %v, want %v", statuses, expected)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx
+
+import (
+	"github.com/garyburd/redigo/redis"
+)
+
+// Bitmap is a string value manipulated with the Redis bit commands.
+type Bitmap struct {
+	Key string
+}
+
+// BitRange restricts BITCOUNT and BITPOS to a range of the bitmap. Start and
+// End are byte indexes unless Bit is true. Negative indexes count from the
+// end of the string. Bit requires Redis 7.0 or later.
+type BitRange struct {
+	Start, End int64
+	Bit        bool
+}
+
+func (r *BitRange) args(args []interface{}) []interface{} {
+	if r == nil {
+		return args
+	}
+	args = append(args, r.Start, r.End)
+	if r.Bit {
+		args = append(args, "BIT")
+	}
+	return args
+}
+
+// Set sets the bit at offset to value and returns the previous value of the
+// bit.
+func (b Bitmap) Set(c redis.Conn, offset int64, value bool) (bool, error) {
+	return redis.Bool(c.Do("SETBIT", b.Key, offset, value))
+}
+
+// Get returns the value of the bit at offset.
+func (b Bitmap) Get(c redis.Conn, offset int64) (bool, error) {
+	return redis.Bool(c.Do("GETBIT", b.Key, offset))
+}
+
+// Count returns the number of set bits in the range r. If r is nil, then the
+// whole bitmap is counted.
+func (b Bitmap) Count(c redis.Conn, r *BitRange) (int, error) {
+	return redis.Int(c.Do("BITCOUNT", r.args([]interface{}{b.Key})...))
+}
+
+// Pos returns the offset of the first bit set to bit in the range r, or -1 if
+// there is no such bit. If r is nil, then the whole bitmap is searched.
+func (b Bitmap) Pos(c redis.Conn, bit bool, r *BitRange) (int, error) {
+	return redis.Int(c.Do("BITPOS", r.args([]interface{}{b.Key, bit})...))
+}
+
+// Op stores the result of the bitwise operation op (AND, OR, XOR or NOT) on
+// the given keys in the bitmap and returns the size of the result in bytes.
+func (b Bitmap) Op(c redis.Conn, op string, keys ...string) (int, error) {
+	args := []interface{}{op, b.Key}
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	return redis.Int(c.Do("BITOP", args...))
+}
+
+// Offsets returns an iterator over the offsets of the set bits in the bitmap.
+// The iterator reads the bitmap with GETRANGE in chunks of chunkSize bytes.
+func (b Bitmap) Offsets(c redis.Conn, chunkSize int) *BitIterator {
+	if chunkSize <= 0 {
+		chunkSize = 4096
+	}
+	return &BitIterator{c: c, key: b.Key, chunkSize: chunkSize}
+}
+
+// BitIterator iterates over the set bits of a bitmap one chunk at a time.
+//
+//  it := bitmap.Offsets(c, 0)
+//  for it.Next() {
+//      for _, offset := range it.Offsets() {
+//          // process offset
+//      }
+//  }
+//  if err := it.Err(); err != nil {
+//      // handle error
+//  }
+type BitIterator struct {
+	c         redis.Conn
+	key       string
+	chunkSize int
+	pos       int64
+	done      bool
+	offsets   []int64
+	err       error
+}
+
+// Next reads the next chunk of the bitmap. Next returns false when the end of
+// the bitmap is reached or on error.
+func (it *BitIterator) Next() bool {
+	for !it.done {
+		p, err := redis.Bytes(it.c.Do("GETRANGE", it.key, it.pos, it.pos+int64(it.chunkSize)-1))
+		if err != nil {
+			it.err = err
+			it.done = true
+			return false
+		}
+		if len(p) < it.chunkSize {
+			it.done = true
+		}
+		it.offsets = it.offsets[:0]
+		for i, b := range p {
+			for j := uint(0); b != 0 && j < 8; j++ {
+				if b&(0x80>>j) != 0 {
+					it.offsets = append(it.offsets, (it.pos+int64(i))*8+int64(j))
+				}
+			}
+		}
+		it.pos += int64(len(p))
+		if len(it.offsets) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Offsets returns the offsets of the set bits in the current chunk.
+func (it *BitIterator) Offsets() []int64 {
+	return it.offsets
+}
+
+// Err returns the first error encountered by the iterator.
+func (it *BitIterator) Err() error {
+	return it.err
+}
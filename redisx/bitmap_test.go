@@ -0,0 +1,51 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx_test
+
+import (
+	"github.com/garyburd/redigo/redisx"
+	"reflect"
+	"testing"
+)
+
+func TestBitmapOffsets(t *testing.T) {
+	c := newScriptConn([]byte{0x80, 0x01}, []byte{0x00, 0x00}, []byte{0x40})
+	it := redisx.Bitmap{Key: "b"}.Offsets(c, 2)
+	var offsets []int64
+	for it.Next() {
+		offsets = append(offsets, it.Offsets()...)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+	if !reflect.DeepEqual(offsets, []int64{0, 15, 33}) {
+		t.Errorf("offsets = %v", offsets)
+	}
+	expected := []string{"GETRANGE b 0 1", "GETRANGE b 2 3", "GETRANGE b 4 5"}
+	if !reflect.DeepEqual(c.commands, expected) {
+		t.Errorf("commands = %q, want %q", c.commands, expected)
+	}
+}
+
+func TestBitmapCount(t *testing.T) {
+	c := newScriptConn(int64(3))
+	n, err := redisx.Bitmap{Key: "b"}.Count(c, &redisx.BitRange{Start: 1, End: 7, Bit: true})
+	if err != nil || n != 3 {
+		t.Fatalf("Count returned %d, %v", n, err)
+	}
+	if c.commands[0] != "BITCOUNT b 1 7 BIT" {
+		t.Errorf("Count sent %q", c.commands[0])
+	}
+}
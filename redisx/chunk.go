@@ -0,0 +1,224 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/garyburd/redigo/redis"
+	"hash/crc32"
+	"strconv"
+	"time"
+)
+
+// ErrChunkConflict is returned by ChunkedStore methods when the value is
+// modified by another client during the call.
+var ErrChunkConflict = errors.New("redigo: chunked value modified concurrently")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ChunkedStore stores large values split across multiple keys. A value larger
+// than ChunkSize is split into chunks stored at keys derived from the value's
+// key. The key of the value holds a hash with a manifest of the chunks: the
+// generation of the chunks, the number of chunks, the size of the value and
+// a CRC-32C checksum of the value. Smaller values are stored in the manifest.
+//
+// The chunks of a new value are written before the manifest is replaced, so
+// readers see the old value or the new value. The chunks of the old value are
+// deleted in the transaction that replaces the manifest.
+type ChunkedStore struct {
+	// ChunkSize is the maximum size of a chunk. The default is 1 MiB.
+	ChunkSize int
+
+	// TTL is the time to live of the value. If TTL is zero, then the value
+	// does not expire.
+	TTL time.Duration
+}
+
+func (s *ChunkedStore) chunkSize() int {
+	if s.ChunkSize <= 0 {
+		return 1 << 20
+	}
+	return s.ChunkSize
+}
+
+func chunkKey(key, generation string, i int) string {
+	return key + ":chunk:" + generation + ":" + strconv.Itoa(i)
+}
+
+type chunkManifest struct {
+	Generation string `redis:"generation,omitempty"`
+	Chunks     int    `redis:"chunks"`
+	Size       int    `redis:"size"`
+	CRC        uint32 `redis:"crc"`
+	Data       []byte `redis:"data,omitempty"`
+}
+
+func (m *chunkManifest) chunkKeys(key string) []interface{} {
+	keys := make([]interface{}, m.Chunks)
+	for i := range keys {
+		keys[i] = chunkKey(key, m.Generation, i)
+	}
+	return keys
+}
+
+func readManifest(c redis.Conn, key string) (*chunkManifest, error) {
+	values, err := redis.Values(c.Do("HGETALL", key))
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, redis.ErrNil
+	}
+	var m chunkManifest
+	if err := redis.ScanStruct(values, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Set sets the value at key. Set returns ErrChunkConflict if the value is
+// modified by another client during the call.
+func (s *ChunkedStore) Set(c redis.Conn, key string, value []byte) error {
+	m := chunkManifest{Size: len(value), CRC: crc32.Checksum(value, crcTable)}
+	var pttl []interface{}
+	if s.TTL > 0 {
+		pttl = []interface{}{"PX", int64(s.TTL / time.Millisecond)}
+	}
+
+	size := s.chunkSize()
+	if len(value) <= size {
+		m.Data = value
+	} else {
+		var p [8]byte
+		if _, err := rand.Read(p[:]); err != nil {
+			return err
+		}
+		m.Generation = hex.EncodeToString(p[:])
+		for i := 0; len(value) > 0; i++ {
+			n := size
+			if n > len(value) {
+				n = len(value)
+			}
+			c.Send("SET", append([]interface{}{chunkKey(key, m.Generation, i), value[:n]}, pttl...)...)
+			value = value[n:]
+			m.Chunks++
+		}
+		if err := c.Flush(); err != nil {
+			return err
+		}
+		var err error
+		for i := 0; i < m.Chunks; i++ {
+			if _, e := c.Receive(); e != nil && err == nil {
+				err = e
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := c.Do("WATCH", key); err != nil {
+		return err
+	}
+	old, err := readManifest(c, key)
+	if err != nil && err != redis.ErrNil {
+		c.Do("UNWATCH")
+		return err
+	}
+	c.Send("MULTI")
+	c.Send("DEL", key)
+	args, err := redis.AppendStruct([]interface{}{key}, &m)
+	if err != nil {
+		c.Do("DISCARD")
+		return err
+	}
+	c.Send("HSET", args...)
+	if s.TTL > 0 {
+		c.Send("PEXPIRE", key, int64(s.TTL/time.Millisecond))
+	}
+	if old != nil && old.Chunks > 0 {
+		c.Send("DEL", old.chunkKeys(key)...)
+	}
+	reply, err := c.Do("EXEC")
+	if err == nil && reply == nil {
+		err = ErrChunkConflict
+	}
+	if replies, ok := reply.([]interface{}); ok && err == nil {
+		// Errors in the queued commands are returned in the reply.
+		for _, r := range replies {
+			if e, ok := r.(redis.Error); ok {
+				err = e
+				break
+			}
+		}
+	}
+	if err != nil && m.Chunks > 0 {
+		c.Do("DEL", m.chunkKeys(key)...)
+	}
+	return err
+}
+
+// Get returns the value at key. Get returns redis.ErrNil if the key does not
+// exist and redis.ErrChecksum if the reassembled value does not match the
+// checksum in the manifest. Get returns ErrChunkConflict if the chunks are
+// repeatedly replaced by other clients during the call.
+func (s *ChunkedStore) Get(c redis.Conn, key string) ([]byte, error) {
+	// Retry if the chunks are deleted by a concurrent Set.
+	for attempt := 0; ; attempt++ {
+		m, err := readManifest(c, key)
+		if err != nil {
+			return nil, err
+		}
+		value := m.Data
+		if m.Chunks > 0 {
+			chunks, err := redis.ByteSlices(c.Do("MGET", m.chunkKeys(key)...))
+			if err != nil {
+				return nil, err
+			}
+			value = make([]byte, 0, m.Size)
+			for _, chunk := range chunks {
+				if chunk == nil {
+					value = nil
+					break
+				}
+				value = append(value, chunk...)
+			}
+			if value == nil {
+				if attempt < 3 {
+					continue
+				}
+				return nil, ErrChunkConflict
+			}
+		}
+		if len(value) != m.Size || crc32.Checksum(value, crcTable) != m.CRC {
+			return nil, redis.ErrChecksum
+		}
+		return value, nil
+	}
+}
+
+// Delete deletes the value at key and the chunks of the value.
+func (s *ChunkedStore) Delete(c redis.Conn, key string) error {
+	m, err := readManifest(c, key)
+	if err == redis.ErrNil {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	_, err = c.Do("DEL", append([]interface{}{key}, m.chunkKeys(key)...)...)
+	return err
+}
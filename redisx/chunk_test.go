@@ -0,0 +1,80 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"github.com/garyburd/redigo/redisx"
+	"hash/crc32"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestChunkedStore(t *testing.T) {
+	s := &redisx.ChunkedStore{ChunkSize: 4}
+	value := []byte("0123456789")
+
+	c := newScriptConn("OK", "OK", "OK", "OK", []interface{}{}, "OK", "OK", int64(1), []interface{}{int64(0), int64(4)})
+	if err := s.Set(c, "k", value); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	var prefixes []string
+	for _, cmd := range c.commands {
+		prefixes = append(prefixes, strings.Fields(cmd)[0])
+	}
+	if strings.Join(prefixes, " ") != "SET SET SET WATCH HGETALL MULTI DEL HSET EXEC" {
+		t.Errorf("Set sent %q", c.commands)
+	}
+	if !strings.HasSuffix(c.commands[2], " 89") || !strings.Contains(c.commands[7], " chunks 3 size 10 ") {
+		t.Errorf("Set sent %q", c.commands)
+	}
+
+	wrongType := redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+	c = newScriptConn("OK", "OK", "OK", "OK", []interface{}{}, "OK", "OK", int64(1), []interface{}{int64(0), wrongType}, int64(3))
+	if err := s.Set(c, "k", value); err != wrongType {
+		t.Errorf("Set with failed HSET returned %v, want %v", err, wrongType)
+	}
+	if last := c.commands[len(c.commands)-1]; !strings.HasPrefix(last, "DEL k:chunk:") {
+		t.Errorf("Set with failed HSET did not delete chunks, sent %q", c.commands)
+	}
+
+	crc := strconv.FormatUint(uint64(crc32.Checksum(value, crc32.MakeTable(crc32.Castagnoli))), 10)
+	manifest := []interface{}{
+		[]byte("generation"), []byte("g"), []byte("chunks"), []byte("3"),
+		[]byte("size"), []byte("10"), []byte("crc"), []byte(crc),
+	}
+	c = newScriptConn(manifest, []interface{}{[]byte("0123"), []byte("4567"), []byte("89")})
+	p, err := s.Get(c, "k")
+	if err != nil || string(p) != string(value) {
+		t.Fatalf("Get returned %q, %v", p, err)
+	}
+	if c.commands[1] != "MGET k:chunk:g:0 k:chunk:g:1 k:chunk:g:2" {
+		t.Errorf("Get sent %q", c.commands)
+	}
+
+	c = newScriptConn(manifest, []interface{}{[]byte("0123"), []byte("4567"), []byte("8")})
+	if _, err := s.Get(c, "k"); err != redis.ErrChecksum {
+		t.Errorf("Get of truncated value returned %v, want %v", err, redis.ErrChecksum)
+	}
+
+	c = newScriptConn(manifest, int64(4))
+	if err := s.Delete(c, "k"); err != nil {
+		t.Errorf("Delete returned %v", err)
+	}
+	if c.commands[1] != "DEL k k:chunk:g:0 k:chunk:g:1 k:chunk:g:2" {
+		t.Errorf("Delete sent %q", c.commands)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx_test
+
+import (
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+)
+
+// scriptConn is a fake connection that records commands and returns
+// scripted replies in order.
+type scriptConn struct {
+	commands []string
+	replies  []interface{}
+	pending  int
+}
+
+func newScriptConn(replies ...interface{}) *scriptConn {
+	return &scriptConn{replies: replies}
+}
+
+func (c *scriptConn) Close() error { return nil }
+func (c *scriptConn) Err() error   { return nil }
+func (c *scriptConn) Flush() error { return nil }
+
+func (c *scriptConn) record(commandName string, args []interface{}) {
+	s := commandName
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case []byte:
+			s += " " + string(arg)
+		default:
+			s += " " + fmt.Sprint(arg)
+		}
+	}
+	c.commands = append(c.commands, s)
+}
+
+func (c *scriptConn) Send(commandName string, args ...interface{}) error {
+	c.record(commandName, args)
+	c.pending += 1
+	return nil
+}
+
+func (c *scriptConn) Receive() (interface{}, error) {
+	if c.pending > 0 {
+		c.pending -= 1
+	}
+	if len(c.replies) == 0 {
+		return nil, fmt.Errorf("scriptConn: no reply for command")
+	}
+	reply := c.replies[0]
+	c.replies = c.replies[1:]
+	if err, ok := reply.(error); ok {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (c *scriptConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if commandName != "" {
+		c.Send(commandName, args...)
+	}
+	var reply interface{}
+	var err error
+	for c.pending > 0 {
+		r, e := c.Receive()
+		if e != nil && err == nil {
+			err = e
+		}
+		reply = r
+	}
+	return reply, err
+}
+
+var _ redis.Conn = &scriptConn{}
@@ -0,0 +1,85 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"time"
+)
+
+// CopyOptions specifies options for CopyKey.
+type CopyOptions struct {
+	// DestKey is the key in the destination. The default is the source key.
+	DestKey string
+
+	// Replace replaces an existing key in the destination.
+	Replace bool
+
+	// AdjustTTL is an optional function that returns the time to live of the
+	// copy given the remaining time to live of the source key. A time to
+	// live of zero specifies that the key does not expire. Use AdjustTTL to
+	// extend or cap the time to live of copies.
+	AdjustTTL func(ttl time.Duration) time.Duration
+}
+
+// CopyKey copies key from src to dst using DUMP and RESTORE. The copy expires
+// at the same time as the source key unless the time to live is adjusted
+// with the AdjustTTL option. The expiration is sent to dst as an absolute
+// time, so the time to live of the copy does not include the time spent
+// copying the key. CopyKey returns redis.ErrNil if the key does not exist.
+func CopyKey(src, dst redis.Conn, key string, options *CopyOptions) error {
+	if options == nil {
+		options = &CopyOptions{}
+	}
+	now := time.Now()
+	src.Send("DUMP", key)
+	src.Send("PTTL", key)
+	if err := src.Flush(); err != nil {
+		return err
+	}
+	p, err := redis.Bytes(src.Receive())
+	if err != nil {
+		src.Receive()
+		return err
+	}
+	ms, err := redis.Int64(src.Receive())
+	if err != nil {
+		return err
+	}
+	var ttl time.Duration
+	switch {
+	case ms == -2:
+		return redis.ErrNil
+	case ms > 0:
+		ttl = time.Duration(ms) * time.Millisecond
+	}
+	if options.AdjustTTL != nil {
+		ttl = options.AdjustTTL(ttl)
+	}
+
+	args := []interface{}{options.DestKey, int64(0), p}
+	if options.DestKey == "" {
+		args[0] = key
+	}
+	if ttl > 0 {
+		args[1] = now.Add(ttl).UnixNano() / int64(time.Millisecond)
+		args = append(args, "ABSTTL")
+	}
+	if options.Replace {
+		args = append(args, "REPLACE")
+	}
+	_, err = dst.Do("RESTORE", args...)
+	return err
+}
@@ -0,0 +1,70 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx_test
+
+import (
+	"github.com/garyburd/redigo/redisx"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCopyKey(t *testing.T) {
+	capTTL := func(ttl time.Duration) time.Duration {
+		if ttl == 0 || ttl > time.Second {
+			return time.Second
+		}
+		return ttl
+	}
+	tests := []struct {
+		pttl     int64
+		options  *redisx.CopyOptions
+		ttl      time.Duration
+		expected string
+	}{
+		{-1, nil, 0, "RESTORE k 0 data"},
+		{5000, nil, 5 * time.Second, "RESTORE k * data ABSTTL"},
+		{5000, &redisx.CopyOptions{DestKey: "k2", Replace: true}, 5 * time.Second, "RESTORE k2 * data ABSTTL REPLACE"},
+		{-1, &redisx.CopyOptions{AdjustTTL: capTTL}, time.Second, "RESTORE k * data ABSTTL"},
+	}
+	for _, tt := range tests {
+		src := newScriptConn([]byte("data"), tt.pttl)
+		dst := newScriptConn("OK")
+		before := time.Now()
+		if err := redisx.CopyKey(src, dst, "k", tt.options); err != nil {
+			t.Errorf("CopyKey returned %v", err)
+			continue
+		}
+		after := time.Now()
+		args := strings.Fields(dst.commands[0])
+		if tt.ttl > 0 {
+			ms, _ := strconv.ParseInt(args[2], 10, 64)
+			at := time.Unix(0, ms*int64(time.Millisecond))
+			if at.Before(before.Add(tt.ttl).Add(-time.Millisecond)) || at.After(after.Add(tt.ttl)) {
+				t.Errorf("CopyKey sent expiration %v, want %v from now", at, tt.ttl)
+			}
+			args[2] = "*"
+		}
+		if s := strings.Join(args, " "); s != tt.expected {
+			t.Errorf("CopyKey sent %q, want %q", s, tt.expected)
+		}
+	}
+
+	src := newScriptConn(nil, int64(-2))
+	if err := redisx.CopyKey(src, newScriptConn(), "k", nil); err == nil {
+		t.Errorf("CopyKey of missing key did not return error")
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// EventStore stores timestamped events in a sorted set. The score of each
+// member is the event time in milliseconds since the Unix epoch. The member is
+// the event time in nanoseconds followed by ':' and the encoded event. The
+// time prefix keeps identical payloads recorded at different times distinct.
+type EventStore struct {
+	// Key is the key of the sorted set.
+	Key string
+
+	// Events older than Retention are removed from the set when a new event
+	// is added. If Retention is zero, then events are not removed.
+	Retention time.Duration
+
+	// Marshal and Unmarshal encode and decode event payloads. The
+	// encoding/json functions are used if these fields are nil.
+	Marshal   func(v interface{}) ([]byte, error)
+	Unmarshal func(data []byte, v interface{}) error
+}
+
+func eventScore(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// Add adds an event with time t to the store and trims events older than the
+// store's retention relative to t.
+func (s *EventStore) Add(c redis.Conn, t time.Time, event interface{}) error {
+	marshal := s.Marshal
+	if marshal == nil {
+		marshal = json.Marshal
+	}
+	p, err := marshal(event)
+	if err != nil {
+		return err
+	}
+	member := strconv.AppendInt(nil, t.UnixNano(), 10)
+	member = append(member, ':')
+	member = append(member, p...)
+	if s.Retention <= 0 {
+		_, err = c.Do("ZADD", s.Key, eventScore(t), member)
+		return err
+	}
+	if err := c.Send("ZADD", s.Key, eventScore(t), member); err != nil {
+		return err
+	}
+	_, err = c.Do("ZREMRANGEBYSCORE", s.Key, "-inf", "("+strconv.FormatInt(eventScore(t.Add(-s.Retention)), 10))
+	return err
+}
+
+// Trim removes events older than the store's retention relative to now.
+func (s *EventStore) Trim(c redis.Conn, now time.Time) error {
+	if s.Retention <= 0 {
+		return nil
+	}
+	_, err := c.Do("ZREMRANGEBYSCORE", s.Key, "-inf", "("+strconv.FormatInt(eventScore(now.Add(-s.Retention)), 10))
+	return err
+}
+
+// Range decodes the events with times in the closed interval [from, to] to
+// the slice pointed to by dest. The events are stored in the slice in time
+// order.
+func (s *EventStore) Range(c redis.Conn, from, to time.Time, dest interface{}) error {
+	d := reflect.ValueOf(dest)
+	if d.Kind() != reflect.Ptr || d.IsNil() || d.Elem().Kind() != reflect.Slice {
+		return errors.New("redigo: EventStore.Range dest must be a non-nil pointer to a slice")
+	}
+	d = d.Elem()
+	unmarshal := s.Unmarshal
+	if unmarshal == nil {
+		unmarshal = json.Unmarshal
+	}
+	members, err := redis.Values(c.Do("ZRANGEBYSCORE", s.Key, eventScore(from), eventScore(to)))
+	if err != nil {
+		return err
+	}
+	result := reflect.MakeSlice(d.Type(), len(members), len(members))
+	for i, m := range members {
+		p, ok := m.([]byte)
+		if !ok {
+			return errors.New("redigo: EventStore.Range member not a bulk value")
+		}
+		j := bytes.IndexByte(p, ':')
+		if j < 0 {
+			return errors.New("redigo: EventStore.Range malformed member")
+		}
+		if err := unmarshal(p[j+1:], result.Index(i).Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	d.Set(result)
+	return nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx_test
+
+import (
+	"github.com/garyburd/redigo/redisx"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type event struct {
+	Name string
+}
+
+func TestEventStore(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := &redisx.EventStore{Key: "events", Retention: time.Minute}
+
+	c := newScriptConn(int64(1), int64(0))
+	if err := s.Add(c, now, event{"a"}); err != nil {
+		t.Fatalf("Add returned error %v", err)
+	}
+	expected := []string{
+		`ZADD events 1000000 1000000000000:{"Name":"a"}`,
+		`ZREMRANGEBYSCORE events -inf (940000`,
+	}
+	if !reflect.DeepEqual(c.commands, expected) {
+		t.Errorf("Add sent %q, want %q", c.commands, expected)
+	}
+
+	c = newScriptConn([]interface{}{
+		[]byte(`1000000000000:{"Name":"a"}`),
+		[]byte(`1001000000000:{"Name":"b"}`),
+	})
+	var events []event
+	if err := s.Range(c, now, now.Add(time.Second), &events); err != nil {
+		t.Fatalf("Range returned error %v", err)
+	}
+	if !reflect.DeepEqual(events, []event{{"a"}, {"b"}}) {
+		t.Errorf("Range returned %v", events)
+	}
+	if c.commands[0] != "ZRANGEBYSCORE events 1000000 1001000" {
+		t.Errorf("Range sent %q", c.commands[0])
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx
+
+import (
+	"errors"
+	"github.com/garyburd/redigo/redis"
+	"strings"
+	"time"
+)
+
+// ErrExpireOptionsUnsupported is returned by ExpireCmd.Do when the server
+// does not support the NX, XX, GT and LT options.
+var ErrExpireOptionsUnsupported = errors.New("redigo: EXPIRE options require Redis 7.0 or later")
+
+// ExpireCmd builds a PEXPIRE command with the Redis 7 NX, XX, GT and LT
+// options. Create an ExpireCmd with the Expire function:
+//
+//  ok, err := redisx.Expire("key", time.Minute).GT().Do(c)
+type ExpireCmd struct {
+	key            string
+	d              time.Duration
+	nx, xx, gt, lt bool
+}
+
+// Expire returns a command that sets the timeout of key to d.
+func Expire(key string, d time.Duration) *ExpireCmd {
+	return &ExpireCmd{key: key, d: d}
+}
+
+// NX sets the timeout only when the key has no timeout.
+func (e *ExpireCmd) NX() *ExpireCmd { e.nx = true; return e }
+
+// XX sets the timeout only when the key has a timeout.
+func (e *ExpireCmd) XX() *ExpireCmd { e.xx = true; return e }
+
+// GT sets the timeout only when the new timeout is greater than the current
+// timeout. A key without a timeout is treated as having an infinite timeout.
+func (e *ExpireCmd) GT() *ExpireCmd { e.gt = true; return e }
+
+// LT sets the timeout only when the new timeout is less than the current
+// timeout. A key without a timeout is treated as having an infinite timeout.
+func (e *ExpireCmd) LT() *ExpireCmd { e.lt = true; return e }
+
+// Args returns the arguments for the PEXPIRE command.
+func (e *ExpireCmd) Args() ([]interface{}, error) {
+	n := 0
+	for _, b := range []bool{e.nx, e.gt, e.lt} {
+		if b {
+			n += 1
+		}
+	}
+	if n > 1 || (e.nx && e.xx) {
+		return nil, errors.New("redigo: EXPIRE options NX, GT and LT are mutually exclusive and NX cannot be combined with XX")
+	}
+	args := []interface{}{e.key, int64(e.d / time.Millisecond)}
+	if e.nx {
+		args = append(args, "NX")
+	}
+	if e.xx {
+		args = append(args, "XX")
+	}
+	if e.gt {
+		args = append(args, "GT")
+	}
+	if e.lt {
+		args = append(args, "LT")
+	}
+	return args, nil
+}
+
+// Do executes the command. Do returns true if the timeout was set and false
+// if the key does not exist or the conditions specified by the options were
+// not met.
+func (e *ExpireCmd) Do(c redis.Conn) (bool, error) {
+	args, err := e.Args()
+	if err != nil {
+		return false, err
+	}
+	ok, err := redis.Bool(c.Do("PEXPIRE", args...))
+	if err, isError := err.(redis.Error); isError && len(args) > 2 {
+		s := string(err)
+		if strings.Contains(s, "wrong number of arguments") || strings.Contains(s, "syntax error") {
+			return false, ErrExpireOptionsUnsupported
+		}
+	}
+	return ok, err
+}
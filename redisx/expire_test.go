@@ -0,0 +1,42 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"github.com/garyburd/redigo/redisx"
+	"testing"
+	"time"
+)
+
+func TestExpire(t *testing.T) {
+	c := newScriptConn(int64(1))
+	ok, err := redisx.Expire("k", time.Second).XX().GT().Do(c)
+	if err != nil || !ok {
+		t.Fatalf("Do returned %v, %v", ok, err)
+	}
+	if c.commands[0] != "PEXPIRE k 1000 XX GT" {
+		t.Errorf("Do sent %q", c.commands[0])
+	}
+
+	if _, err := redisx.Expire("k", time.Second).NX().LT().Do(c); err == nil {
+		t.Errorf("Do with NX and LT did not return error")
+	}
+
+	c = newScriptConn(redis.Error("ERR wrong number of arguments for 'pexpire' command"))
+	if _, err := redisx.Expire("k", time.Second).NX().Do(c); err != redisx.ErrExpireOptionsUnsupported {
+		t.Errorf("Do returned %v, want ErrExpireOptionsUnsupported", err)
+	}
+}
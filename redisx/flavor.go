@@ -0,0 +1,214 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Flavor identifies the implementation of a RESP server.
+type Flavor string
+
+const (
+	FlavorRedis     Flavor = "redis"
+	FlavorValkey    Flavor = "valkey"
+	FlavorKeyDB     Flavor = "keydb"
+	FlavorDragonfly Flavor = "dragonfly"
+)
+
+// Quirks describes the features of a server flavor that differ between
+// implementations.
+type Quirks struct {
+	// RESP3 is true if the server supports the RESP3 protocol.
+	RESP3 bool
+
+	// ClientTracking is true if the server supports CLIENT TRACKING.
+	ClientTracking bool
+
+	// Unsupported is the set of upper case command names that the server does
+	// not implement.
+	Unsupported map[string]bool
+}
+
+type flavorSpec struct {
+	flavor Flavor
+	match  func(info map[string]string) bool
+	quirks Quirks
+}
+
+var (
+	flavorMu sync.RWMutex
+
+	// flavors is checked in order before falling back to fallbackFlavor.
+	flavors = []*flavorSpec{
+		{FlavorValkey, func(info map[string]string) bool {
+			return info["server_name"] == "valkey" || info["valkey_version"] != ""
+		}, Quirks{RESP3: true, ClientTracking: true}},
+		{FlavorDragonfly, func(info map[string]string) bool {
+			return info["dragonfly_version"] != ""
+		}, Quirks{RESP3: true, Unsupported: map[string]bool{"CLIENT TRACKING": true, "MODULE": true, "SWAPDB": true}}},
+		{FlavorKeyDB, func(info map[string]string) bool {
+			// KeyDB reports a Redis version. Look for the executable name.
+			return strings.Contains(info["executable"], "keydb")
+		}, Quirks{RESP3: true, ClientTracking: true}},
+	}
+
+	// fallbackFlavor is used for servers that do not match a flavor in
+	// flavors.
+	fallbackFlavor = &flavorSpec{FlavorRedis, nil, Quirks{RESP3: true, ClientTracking: true}}
+)
+
+// RegisterFlavor adds a server flavor or replaces the quirks of an existing
+// flavor. Match is called with the fields of the INFO reply and returns true
+// if the server is the flavor. Flavors registered by the application are
+// checked before the flavors defined in this package. If match is nil, then
+// the match function of an existing flavor is not changed.
+//
+// FlavorRedis is used for all servers that do not match another flavor.
+// Registering FlavorRedis replaces the quirks of the fallback and ignores
+// match.
+func RegisterFlavor(f Flavor, match func(info map[string]string) bool, q Quirks) {
+	flavorMu.Lock()
+	defer flavorMu.Unlock()
+	if f == fallbackFlavor.flavor {
+		fallbackFlavor = &flavorSpec{f, nil, q}
+		return
+	}
+	for i, fs := range flavors {
+		if fs.flavor == f {
+			if match == nil {
+				match = fs.match
+			}
+			flavors = append(flavors[:i:i], flavors[i+1:]...)
+			break
+		}
+	}
+	if match == nil {
+		match = func(map[string]string) bool { return false }
+	}
+	flavors = append([]*flavorSpec{{f, match, q}}, flavors...)
+}
+
+// matchFlavor returns the first flavor matching info or the fallback flavor.
+func matchFlavor(info map[string]string) *flavorSpec {
+	flavorMu.RLock()
+	defer flavorMu.RUnlock()
+	for _, fs := range flavors {
+		if fs.match(info) {
+			return fs
+		}
+	}
+	return fallbackFlavor
+}
+
+// Capability is a feature that is not available on all servers.
+type Capability string
+
+const (
+	// CapabilityRESP3 is the RESP3 protocol.
+	CapabilityRESP3 Capability = "resp3"
+
+	// CapabilityClientTracking is client side caching with CLIENT TRACKING.
+	CapabilityClientTracking Capability = "client-tracking"
+)
+
+// Server describes the server on a connection.
+type Server struct {
+	Flavor Flavor
+
+	// Version is the flavor's version of the server.
+	Version string
+
+	// Quirks of the server flavor.
+	Quirks Quirks
+
+	// Info is the INFO server section.
+	Info map[string]string
+}
+
+// Supports returns true if the server implements the command. Subcommands
+// are specified as the command and subcommand separated by a space.
+func (s *Server) Supports(commandName string) bool {
+	commandName = strings.ToUpper(commandName)
+	if commandName == "CLIENT TRACKING" && !s.Quirks.ClientTracking {
+		return false
+	}
+	return !s.Quirks.Unsupported[commandName]
+}
+
+// Has returns true if the server has the capability.
+func (s *Server) Has(c Capability) bool {
+	switch c {
+	case CapabilityRESP3:
+		return s.Quirks.RESP3
+	case CapabilityClientTracking:
+		return s.Quirks.ClientTracking && s.Supports("CLIENT TRACKING")
+	}
+	return false
+}
+
+// Capabilities returns the capabilities of the server.
+func (s *Server) Capabilities() []Capability {
+	var caps []Capability
+	for _, c := range []Capability{CapabilityRESP3, CapabilityClientTracking} {
+		if s.Has(c) {
+			caps = append(caps, c)
+		}
+	}
+	return caps
+}
+
+// Check returns a *redis.UnsupportedCommandError if the server does not
+// implement the command. Use Check to gate features before sending commands
+// that the server flavor does not implement.
+func (s *Server) Check(commandName string) error {
+	if s.Supports(commandName) {
+		return nil
+	}
+	return &redis.UnsupportedCommandError{Command: strings.ToUpper(commandName), Reason: "by " + string(s.Flavor) + " " + s.Version}
+}
+
+// DetectServer detects the flavor of the server on the connection using the
+// INFO command. DetectServer does not use HELLO because HELLO is not
+// supported by older servers and proxies.
+func DetectServer(c redis.Conn) (*Server, error) {
+	sections, err := redis.ParseInfo(c.Do("INFO", "server"))
+	if err != nil {
+		return nil, err
+	}
+	info := make(map[string]string)
+	for _, fields := range sections {
+		for name, value := range fields {
+			info[name] = value
+		}
+	}
+
+	fs := matchFlavor(info)
+	s := &Server{Flavor: fs.flavor, Quirks: fs.quirks, Info: info}
+	if s.Version = info[string(fs.flavor)+"_version"]; s.Version == "" {
+		s.Version = info["redis_version"]
+	}
+	if s.Flavor == FlavorRedis {
+		// RESP3 and client side caching were added in Redis 6.
+		if major, _ := strconv.Atoi(strings.SplitN(s.Version, ".", 2)[0]); major < 6 {
+			s.Quirks.RESP3 = false
+			s.Quirks.ClientTracking = false
+		}
+	}
+	return s, nil
+}
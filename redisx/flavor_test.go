@@ -0,0 +1,101 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx_test
+
+import (
+	"github.com/garyburd/redigo/redisx"
+	"testing"
+)
+
+var detectServerTests = []struct {
+	info           string
+	flavor         redisx.Flavor
+	version        string
+	clientTracking bool
+}{
+	{"# Server\r\nredis_version:7.2.4\r\nredis_mode:standalone\r\n", redisx.FlavorRedis, "7.2.4", true},
+	{"# Server\r\nredis_version:5.0.7\r\n", redisx.FlavorRedis, "5.0.7", false},
+	{"# Server\r\nredis_version:7.2.4\r\nserver_name:valkey\r\nvalkey_version:8.0.1\r\n", redisx.FlavorValkey, "8.0.1", true},
+	{"# Server\r\nredis_version:6.2.11\r\ndragonfly_version:df-v1.14.0\r\n", redisx.FlavorDragonfly, "df-v1.14.0", false},
+	{"# Server\r\nredis_version:6.3.4\r\nexecutable:/usr/local/bin/keydb-server\r\n", redisx.FlavorKeyDB, "6.3.4", true},
+}
+
+func TestDetectServer(t *testing.T) {
+	for _, tt := range detectServerTests {
+		c := newScriptConn([]byte(tt.info))
+		s, err := redisx.DetectServer(c)
+		if err != nil {
+			t.Fatalf("DetectServer returned error %v", err)
+		}
+		if s.Flavor != tt.flavor || s.Version != tt.version {
+			t.Errorf("DetectServer(%q) = %s %s, want %s %s", tt.info, s.Flavor, s.Version, tt.flavor, tt.version)
+		}
+		if s.Quirks.ClientTracking != tt.clientTracking {
+			t.Errorf("%s %s ClientTracking = %v, want %v", s.Flavor, s.Version, s.Quirks.ClientTracking, tt.clientTracking)
+		}
+		if c.commands[0] != "INFO server" {
+			t.Errorf("DetectServer sent %q", c.commands[0])
+		}
+	}
+}
+
+func TestRegisterFlavor(t *testing.T) {
+	const flavor = redisx.Flavor("test")
+	redisx.RegisterFlavor(flavor, func(info map[string]string) bool {
+		return info["test_version"] != ""
+	}, redisx.Quirks{Unsupported: map[string]bool{"EVAL": true}})
+	s, err := redisx.DetectServer(newScriptConn([]byte("redis_version:7.0.0\r\ntest_version:1.0\r\n")))
+	if err != nil {
+		t.Fatalf("DetectServer returned error %v", err)
+	}
+	if s.Flavor != flavor || s.Version != "1.0" || s.Supports("eval") {
+		t.Errorf("DetectServer returned %+v", s)
+	}
+}
+
+func TestRegisterFallbackFlavor(t *testing.T) {
+	q := redisx.Quirks{RESP3: true, ClientTracking: true}
+	defer redisx.RegisterFlavor(redisx.FlavorRedis, nil, q)
+	q.Unsupported = map[string]bool{"SWAPDB": true}
+	redisx.RegisterFlavor(redisx.FlavorRedis, nil, q)
+
+	s, err := redisx.DetectServer(newScriptConn([]byte(detectServerTests[2].info)))
+	if err != nil || s.Flavor != redisx.FlavorValkey {
+		t.Errorf("DetectServer after registering fallback returned %+v, %v, want valkey", s, err)
+	}
+	s, err = redisx.DetectServer(newScriptConn([]byte("# Server\r\nredis_version:7.2.4\r\n")))
+	if err != nil || s.Flavor != redisx.FlavorRedis || s.Supports("swapdb") {
+		t.Errorf("DetectServer returned %+v, %v, want redis without SWAPDB", s, err)
+	}
+}
+
+func TestServerCapabilities(t *testing.T) {
+	s, err := redisx.DetectServer(newScriptConn([]byte(detectServerTests[3].info)))
+	if err != nil {
+		t.Fatalf("DetectServer returned error %v", err)
+	}
+	if caps := s.Capabilities(); len(caps) != 1 || caps[0] != redisx.CapabilityRESP3 {
+		t.Errorf("Capabilities() = %v, want [resp3]", caps)
+	}
+	if s.Has(redisx.CapabilityClientTracking) {
+		t.Errorf("%s has client tracking", s.Flavor)
+	}
+	if err := s.Check("client tracking"); err == nil {
+		t.Errorf("Check(CLIENT TRACKING) did not return error for %s", s.Flavor)
+	}
+	if err := s.Check("GET"); err != nil {
+		t.Errorf("Check(GET) returned %v", err)
+	}
+}
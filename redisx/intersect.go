@@ -0,0 +1,105 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/garyburd/redigo/redis"
+	"io"
+	"time"
+)
+
+// SetIntersection pages through the intersection of sets. The intersection is
+// stored in a temporary key with a timeout on the first call to Page and the
+// pages are read from the temporary key in lexicographical order. Callers
+// computing the intersection of the same keys share the temporary key.
+type SetIntersection struct {
+	// Keys of the sets to intersect.
+	Keys []string
+
+	// Prefix for the temporary key. The default is "redigo:sinter:".
+	Prefix string
+
+	// Timeout of the temporary key. The default is one minute.
+	TTL time.Duration
+}
+
+func (s *SetIntersection) keyArgs(args []interface{}) []interface{} {
+	for _, key := range s.Keys {
+		args = append(args, key)
+	}
+	return args
+}
+
+// TempKey returns the name of the temporary key.
+func (s *SetIntersection) TempKey() string {
+	h := sha1.New()
+	for _, key := range s.Keys {
+		io.WriteString(h, key)
+		h.Write([]byte{0})
+	}
+	prefix := s.Prefix
+	if prefix == "" {
+		prefix = "redigo:sinter:"
+	}
+	return prefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// Count returns the number of members in the intersection using SINTERCARD.
+// If limit is greater than zero, then the count stops at limit.
+func (s *SetIntersection) Count(c redis.Conn, limit int) (int, error) {
+	args := s.keyArgs([]interface{}{len(s.Keys)})
+	if limit > 0 {
+		args = append(args, "LIMIT", limit)
+	}
+	return redis.Int(c.Do("SINTERCARD", args...))
+}
+
+// Page returns count members of the intersection starting at offset.
+func (s *SetIntersection) Page(c redis.Conn, offset, count int) ([]string, error) {
+	key := s.TempKey()
+	exists, err := redis.Bool(c.Do("EXISTS", key))
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		ttl := s.TTL
+		if ttl <= 0 {
+			ttl = time.Minute
+		}
+		if err := c.Send("SINTERSTORE", s.keyArgs([]interface{}{key})...); err != nil {
+			return nil, err
+		}
+		if err := c.Send("PEXPIRE", key, int64(ttl/time.Millisecond)); err != nil {
+			return nil, err
+		}
+	}
+	values, err := redis.Values(c.Do("SORT", key, "ALPHA", "LIMIT", offset, count))
+	if err != nil {
+		return nil, err
+	}
+	var members []string
+	if err := redis.ScanSlice(values, &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// Release deletes the temporary key.
+func (s *SetIntersection) Release(c redis.Conn) error {
+	_, err := c.Do("DEL", s.TempKey())
+	return err
+}
@@ -0,0 +1,52 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx_test
+
+import (
+	"github.com/garyburd/redigo/redisx"
+	"reflect"
+	"testing"
+)
+
+func TestSetIntersection(t *testing.T) {
+	s := &redisx.SetIntersection{Keys: []string{"a", "b"}, Prefix: "tmp:"}
+	key := s.TempKey()
+
+	c := newScriptConn(int64(0), int64(2), int64(1), []interface{}{[]byte("x"), []byte("y")})
+	members, err := s.Page(c, 0, 2)
+	if err != nil {
+		t.Fatalf("Page returned error %v", err)
+	}
+	if !reflect.DeepEqual(members, []string{"x", "y"}) {
+		t.Errorf("Page returned %v", members)
+	}
+	expected := []string{
+		"EXISTS " + key,
+		"SINTERSTORE " + key + " a b",
+		"PEXPIRE " + key + " 60000",
+		"SORT " + key + " ALPHA LIMIT 0 2",
+	}
+	if !reflect.DeepEqual(c.commands, expected) {
+		t.Errorf("Page sent %q, want %q", c.commands, expected)
+	}
+
+	c = newScriptConn(int64(10))
+	if n, err := s.Count(c, 10); err != nil || n != 10 {
+		t.Errorf("Count returned %d, %v", n, err)
+	}
+	if c.commands[0] != "SINTERCARD 2 a b LIMIT 10" {
+		t.Errorf("Count sent %q", c.commands[0])
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx
+
+import (
+	"errors"
+	"github.com/garyburd/redigo/redis"
+	"net"
+	"strconv"
+	"time"
+)
+
+// ManagedEndpoints are the endpoints of a managed service such as AWS
+// ElastiCache or MemoryDB. The managed services fail over by pointing the
+// DNS name of an endpoint at another node.
+type ManagedEndpoints struct {
+	// Writer is the host and port of the primary endpoint.
+	Writer string
+
+	// Reader is the host and port of the reader endpoint. If Reader is
+	// empty, then read commands are sent to Writer.
+	Reader string
+
+	// Cluster is true if the endpoints are nodes of a cluster. Connections
+	// to a cluster replica must send READONLY before reading.
+	Cluster bool
+}
+
+// DiscoverEndpoints returns the endpoints of the shard served by the cluster
+// configuration endpoint that c is connected to. The nodes are found with
+// CLUSTER SHARDS. Node endpoints are preferred over IP addresses so that DNS
+// based failover is followed, and the TLS port is preferred over the plain
+// port. Reader is set to the first online replica.
+//
+// DiscoverEndpoints supports clusters with a single shard. Clusters with more
+// than one shard require a cluster client.
+func DiscoverEndpoints(c redis.Conn) (ManagedEndpoints, error) {
+	shards, err := redis.ClusterShards(c.Do("CLUSTER", "SHARDS"))
+	if err != nil {
+		return ManagedEndpoints{}, err
+	}
+	if len(shards) != 1 {
+		return ManagedEndpoints{}, errors.New("redigo: DiscoverEndpoints requires a cluster with one shard, found " + strconv.Itoa(len(shards)))
+	}
+	e := ManagedEndpoints{Cluster: true}
+	for _, n := range shards[0].Nodes {
+		switch {
+		case n.Health != "" && n.Health != "online":
+		case n.Role == "master" && e.Writer == "":
+			e.Writer = nodeEndpoint(n)
+		case n.Role == "replica" && e.Reader == "":
+			e.Reader = nodeEndpoint(n)
+		}
+	}
+	if e.Writer == "" {
+		return ManagedEndpoints{}, errors.New("redigo: DiscoverEndpoints found no online primary")
+	}
+	return e, nil
+}
+
+func nodeEndpoint(n redis.ClusterNode) string {
+	host := n.Endpoint
+	if host == "" || host == "?" {
+		host = n.IP
+	}
+	port := n.Port
+	if n.TLSPort != 0 {
+		port = n.TLSPort
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// NewManagedRouter returns a Router for the endpoints. The connections use
+// TLS, which the managed services require for encryption in transit. Pass
+// redis.DialUseTLS(false) in options to connect without TLS. Cluster replicas
+// are dialed with redis.DialReplicaReads.
+//
+// The pools close idle connections after one minute and the router recycles
+// the primary pool on READONLY errors so that connections follow the primary
+// endpoint after a failover.
+func NewManagedRouter(e ManagedEndpoints, preference ReadPreference, options ...redis.DialOption) *Router {
+	options = append([]redis.DialOption{redis.DialUseTLS(true)}, options...)
+	r := &Router{
+		Primary:           managedPool(e.Writer, options),
+		Preference:        preference,
+		RecycleOnReadOnly: true,
+	}
+	switch {
+	case e.Reader == "":
+		r.Replica = r.Primary
+	case e.Cluster:
+		r.Replica = managedPool(e.Reader, append(options, redis.DialReplicaReads()))
+	default:
+		r.Replica = managedPool(e.Reader, options)
+	}
+	return r
+}
+
+func managedPool(address string, options []redis.DialOption) *redis.Pool {
+	return &redis.Pool{
+		Network:     "tcp",
+		Address:     address,
+		DialOptions: options,
+		MaxIdle:     10,
+		IdleTimeout: time.Minute,
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx_test
+
+import (
+	"context"
+	"github.com/garyburd/redigo/redis"
+	"github.com/garyburd/redigo/redisx"
+	"testing"
+)
+
+func TestDiscoverEndpoints(t *testing.T) {
+	node := func(role, endpoint, health string) interface{} {
+		return []interface{}{
+			[]byte("id"), []byte(role), []byte("port"), int64(6379), []byte("tls-port"), int64(6380),
+			[]byte("ip"), []byte("10.0.0.1"), []byte("endpoint"), []byte(endpoint),
+			[]byte("role"), []byte(role), []byte("health"), []byte(health),
+		}
+	}
+	c := newScriptConn([]interface{}{
+		[]interface{}{
+			[]byte("slots"), []interface{}{int64(0), int64(16383)},
+			[]byte("nodes"), []interface{}{
+				node("replica", "r1.example.com", "loading"),
+				node("master", "p.example.com", "online"),
+				node("replica", "r2.example.com", "online"),
+			},
+		},
+	})
+	e, err := redisx.DiscoverEndpoints(c)
+	if err != nil {
+		t.Fatalf("DiscoverEndpoints returned %v", err)
+	}
+	expected := redisx.ManagedEndpoints{Writer: "p.example.com:6380", Reader: "r2.example.com:6380", Cluster: true}
+	if e != expected {
+		t.Errorf("DiscoverEndpoints returned %+v, want %+v", e, expected)
+	}
+
+	r := redisx.NewManagedRouter(e, redisx.ReadReplica)
+	if r.Primary.Address != e.Writer || r.Replica.Address != e.Reader || !r.RecycleOnReadOnly {
+		t.Errorf("NewManagedRouter returned %+v", r)
+	}
+	r = redisx.NewManagedRouter(redisx.ManagedEndpoints{Writer: e.Writer}, redisx.ReadReplica)
+	if r.Replica != r.Primary {
+		t.Errorf("NewManagedRouter without reader did not use the primary for reads")
+	}
+}
+
+func TestRouterRecycleOnReadOnly(t *testing.T) {
+	var dialed int
+	c := newScriptConn(redis.Error("READONLY You can't write against a read only replica."), "OK")
+	p := redis.NewPool(func() (redis.Conn, error) { dialed += 1; return c, nil }, 1)
+	r := &redisx.Router{Primary: p, Replica: p, RecycleOnReadOnly: true}
+	ctx := context.Background()
+	if _, err := r.Do(ctx, "SET", "k", "v"); err == nil {
+		t.Fatal("SET on demoted primary did not return error")
+	}
+	if _, err := r.Do(ctx, "SET", "k", "v"); err != nil {
+		t.Fatalf("SET returned %v", err)
+	}
+	if dialed != 2 {
+		t.Errorf("dialed = %d, want 2", dialed)
+	}
+}
@@ -0,0 +1,407 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx
+
+import (
+	"context"
+	"errors"
+	"github.com/garyburd/redigo/redis"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Migration is a versioned change to the data stored in Redis.
+type Migration struct {
+	// Version orders the migrations. Versions must be positive and unique.
+	Version int
+
+	// Name describes the migration. The name is recorded with the version
+	// when the migration is applied.
+	Name string
+
+	// Match is the SCAN MATCH pattern of the keys passed to Func. If Match is
+	// "", then Func is called once with the key "".
+	Match string
+
+	// Type is the SCAN TYPE option. If Type is "", then keys of all types
+	// matching Match are passed to Func.
+	Type string
+
+	// Func migrates a single key. Func is called concurrently for different
+	// keys when the migrator has more than one worker. Func must be
+	// idempotent because SCAN can return a key more than once and because a
+	// failed migration is run again from the beginning.
+	Func func(c redis.Conn, key string) error
+}
+
+// MigrationError is returned by Migrator.Run when a migration fails.
+type MigrationError struct {
+	Version int
+	Key     string
+	Err     error
+}
+
+func (e *MigrationError) Error() string {
+	s := "redigo: migration " + strconv.Itoa(e.Version)
+	if e.Key != "" {
+		s += " key " + strconv.Quote(e.Key)
+	}
+	return s + ": " + e.Err.Error()
+}
+
+func (e *MigrationError) Unwrap() error { return e.Err }
+
+// Migrator applies registered migrations in version order. The applied
+// versions are recorded in a hash that maps each version to the name of the
+// migration. Keys matching a migration are found with SCAN and migrated by a
+// pool of workers.
+//
+// Run must not be called concurrently for the same hash.
+type Migrator struct {
+	Pool *redis.Pool
+
+	// Key is the key of the hash that records the applied versions. The
+	// default is "redisx:migrations".
+	Key string
+
+	// Workers is the number of keys migrated concurrently. The default is one.
+	Workers int
+
+	// KeysPerSecond limits the rate at which keys are migrated. If zero, the
+	// rate is not limited.
+	KeysPerSecond float64
+
+	// ScanCount is the SCAN COUNT hint. The default is 100.
+	ScanCount int
+
+	// If DryRun is not nil, then Run does not modify data. Read-only
+	// commands issued by migrations are sent to the server. All other
+	// commands are written to DryRun and receive the replies described for
+	// redis.DryRunConn. The applied versions are not recorded.
+	DryRun io.Writer
+
+	migrations []Migration
+}
+
+// Register adds a migration. Register panics if the version is not positive,
+// the version is already registered or the migration does not have a Func.
+func (m *Migrator) Register(mig Migration) {
+	if mig.Version <= 0 {
+		panic(errors.New("redigo: migration version must be positive"))
+	}
+	if mig.Func == nil {
+		panic(errors.New("redigo: migration " + strconv.Itoa(mig.Version) + " has nil Func"))
+	}
+	for _, r := range m.migrations {
+		if r.Version == mig.Version {
+			panic(errors.New("redigo: migration " + strconv.Itoa(mig.Version) + " registered twice"))
+		}
+	}
+	m.migrations = append(m.migrations, mig)
+	sort.Slice(m.migrations, func(i, j int) bool { return m.migrations[i].Version < m.migrations[j].Version })
+}
+
+func (m *Migrator) key() string {
+	if m.Key == "" {
+		return "redisx:migrations"
+	}
+	return m.Key
+}
+
+// Applied returns the applied versions in increasing order.
+func (m *Migrator) Applied(c redis.Conn) ([]int, error) {
+	fields, err := redis.Strings(c.Do("HKEYS", m.key()))
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int, 0, len(fields))
+	for _, f := range fields {
+		v, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, errors.New("redigo: invalid migration version " + strconv.Quote(f))
+		}
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+// Pending returns the registered migrations that are not applied in version
+// order.
+func (m *Migrator) Pending(c redis.Conn) ([]Migration, error) {
+	applied, err := m.Applied(c)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[int]bool, len(applied))
+	for _, v := range applied {
+		done[v] = true
+	}
+	var pending []Migration
+	for _, mig := range m.migrations {
+		if !done[mig.Version] {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// Run applies the pending migrations in version order. Run stops at the
+// first migration that fails and returns a *MigrationError. Versions applied
+// before the failure remain recorded.
+func (m *Migrator) Run(ctx context.Context) error {
+	c := m.Pool.Get()
+	pending, err := m.Pending(c)
+	c.Close()
+	if err != nil {
+		return err
+	}
+	var w io.Writer
+	if m.DryRun != nil {
+		w = &lockedWriter{w: m.DryRun}
+	}
+	for _, mig := range pending {
+		if err := m.apply(ctx, mig, w); err != nil {
+			return err
+		}
+		if w != nil {
+			continue
+		}
+		c := m.Pool.Get()
+		_, err := c.Do("HSET", m.key(), mig.Version, mig.Name)
+		c.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, mig Migration, w io.Writer) error {
+	if mig.Match == "" {
+		return m.migrateKey(mig, "", w)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu    sync.Mutex
+		first error
+		wg    sync.WaitGroup
+	)
+	keys := make(chan string)
+	workers := m.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range keys {
+				if err := m.migrateKey(mig, key, w); err != nil {
+					mu.Lock()
+					if first == nil {
+						first = err
+						cancel()
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	err := m.scan(ctx, mig, keys)
+	close(keys)
+	wg.Wait()
+	if first != nil {
+		return first
+	}
+	if err != nil {
+		return &MigrationError{Version: mig.Version, Err: err}
+	}
+	return nil
+}
+
+// scan sends the keys matching the migration to keys at the configured rate.
+func (m *Migrator) scan(ctx context.Context, mig Migration, keys chan<- string) error {
+	count := m.ScanCount
+	if count <= 0 {
+		count = 100
+	}
+	var interval time.Duration
+	if m.KeysPerSecond > 0 {
+		interval = time.Duration(float64(time.Second) / m.KeysPerSecond)
+	}
+	next := time.Now()
+	cursor := "0"
+	for {
+		args := []interface{}{cursor, "MATCH", mig.Match, "COUNT", count}
+		if mig.Type != "" {
+			args = append(args, "TYPE", mig.Type)
+		}
+		c := m.Pool.Get()
+		values, err := redis.Values(c.Do("SCAN", args...))
+		c.Close()
+		if err != nil {
+			return err
+		}
+		var batch []string
+		if _, err := redis.Scan(values, &cursor, &batch); err != nil {
+			return err
+		}
+		for _, key := range batch {
+			if interval > 0 {
+				if now := time.Now(); next.Before(now) {
+					next = now
+				}
+				if d := time.Until(next); d > 0 {
+					t := time.NewTimer(d)
+					select {
+					case <-t.C:
+					case <-ctx.Done():
+						t.Stop()
+						return ctx.Err()
+					}
+				}
+				next = next.Add(interval)
+			}
+			select {
+			case keys <- key:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if cursor == "0" {
+			return nil
+		}
+	}
+}
+
+func (m *Migrator) migrateKey(mig Migration, key string, w io.Writer) error {
+	var c redis.Conn = m.Pool.Get()
+	defer c.Close()
+	if w != nil {
+		c = &dryRunConn{Conn: c, dry: redis.NewDryRunConn(w)}
+	}
+	if err := mig.Func(c, key); err != nil {
+		return &MigrationError{Version: mig.Version, Key: key, Err: err}
+	}
+	return nil
+}
+
+// lockedWriter serializes writes from concurrent workers.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// dryRunConn sends read-only commands to the server and records all other
+// commands with a redis.DryRunConn. Commands in a transaction are recorded.
+type dryRunConn struct {
+	redis.Conn
+	dry     *redis.DryRunConn
+	multi   bool
+	pending []bool // true if the reply is from dry
+}
+
+func (c *dryRunConn) recorded(commandName string) bool {
+	switch strings.ToUpper(commandName) {
+	case "MULTI":
+		c.multi = true
+	case "EXEC", "DISCARD":
+		if c.multi {
+			c.multi = false
+			return true
+		}
+	}
+	return c.multi || !redis.ReadOnlyCommand(commandName)
+}
+
+func (c *dryRunConn) Send(commandName string, args ...interface{}) error {
+	dry := c.recorded(commandName)
+	c.pending = append(c.pending, dry)
+	if dry {
+		return c.dry.Send(commandName, args...)
+	}
+	return c.Conn.Send(commandName, args...)
+}
+
+func (c *dryRunConn) Receive() (interface{}, error) {
+	if len(c.pending) == 0 {
+		return c.Conn.Receive()
+	}
+	dry := c.pending[0]
+	c.pending = c.pending[1:]
+	if dry {
+		return c.dry.Receive()
+	}
+	return c.Conn.Receive()
+}
+
+// Do flushes the pipelined commands and receives their replies. As with the
+// connections returned by redis.Dial, Do with a command name returns the
+// reply to the command and the first error reply to the command or the
+// pipelined commands.
+func (c *dryRunConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if commandName != "" {
+		if err := c.Send(commandName, args...); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+	if commandName == "" {
+		replies := []interface{}{}
+		for len(c.pending) > 0 {
+			reply, err := c.Receive()
+			if e, ok := err.(redis.Error); ok {
+				reply, err = e, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			replies = append(replies, reply)
+		}
+		return replies, nil
+	}
+	var reply interface{}
+	var err error
+	for len(c.pending) > 0 {
+		var e error
+		reply, e = c.Receive()
+		if re, ok := e.(redis.Error); ok {
+			reply, e = re, nil
+			if err == nil {
+				err = re
+			}
+		}
+		if e != nil {
+			return nil, e
+		}
+	}
+	return reply, err
+}
@@ -0,0 +1,340 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"github.com/garyburd/redigo/redisx"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memStore is an in-memory store supporting the string, hash, set and key
+// commands used by migrations and namespace exports.
+type memStore struct {
+	mu     sync.Mutex
+	values map[string]string
+	hashes map[string]map[string]string
+	sets   map[string]map[string]bool
+	ttls   map[string]int64
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		values: make(map[string]string),
+		hashes: make(map[string]map[string]string),
+		sets:   make(map[string]map[string]bool),
+		ttls:   make(map[string]int64),
+	}
+}
+
+func bulks(values []string) []interface{} {
+	sort.Strings(values)
+	reply := []interface{}{}
+	for _, v := range values {
+		reply = append(reply, []byte(v))
+	}
+	return reply
+}
+
+func (s *memStore) do(commandName string, args []interface{}) (interface{}, error) {
+	var a []string
+	for _, arg := range args {
+		if p, ok := arg.([]byte); ok {
+			a = append(a, string(p))
+		} else {
+			a = append(a, fmt.Sprint(arg))
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch commandName {
+	case "GET":
+		v, ok := s.values[a[0]]
+		if !ok {
+			return nil, nil
+		}
+		return []byte(v), nil
+	case "SET":
+		s.values[a[0]] = a[1]
+		return "OK", nil
+	case "HSET":
+		h := s.hashes[a[0]]
+		if h == nil {
+			h = make(map[string]string)
+			s.hashes[a[0]] = h
+		}
+		for i := 1; i < len(a); i += 2 {
+			h[a[i]] = a[i+1]
+		}
+		return int64(len(a) / 2), nil
+	case "HKEYS":
+		var fields []string
+		for k := range s.hashes[a[0]] {
+			fields = append(fields, k)
+		}
+		return bulks(fields), nil
+	case "HGETALL":
+		reply := []interface{}{}
+		var fields []string
+		for k := range s.hashes[a[0]] {
+			fields = append(fields, k)
+		}
+		sort.Strings(fields)
+		for _, k := range fields {
+			reply = append(reply, []byte(k), []byte(s.hashes[a[0]][k]))
+		}
+		return reply, nil
+	case "SADD":
+		m := s.sets[a[0]]
+		if m == nil {
+			m = make(map[string]bool)
+			s.sets[a[0]] = m
+		}
+		for _, member := range a[1:] {
+			m[member] = true
+		}
+		return int64(len(a) - 1), nil
+	case "SMEMBERS":
+		var members []string
+		for member := range s.sets[a[0]] {
+			members = append(members, member)
+		}
+		return bulks(members), nil
+	case "TYPE":
+		switch {
+		case s.values[a[0]] != "":
+			return "string", nil
+		case s.hashes[a[0]] != nil:
+			return "hash", nil
+		case s.sets[a[0]] != nil:
+			return "set", nil
+		}
+		return "none", nil
+	case "DEL":
+		delete(s.values, a[0])
+		delete(s.hashes, a[0])
+		delete(s.sets, a[0])
+		delete(s.ttls, a[0])
+		return int64(1), nil
+	case "PEXPIRE":
+		var ms int64
+		fmt.Sscan(a[1], &ms)
+		s.ttls[a[0]] = ms
+		return int64(1), nil
+	case "PTTL":
+		if ms, ok := s.ttls[a[0]]; ok {
+			return ms, nil
+		}
+		return int64(-1), nil
+	case "SCAN":
+		var keys []string
+		for _, m := range []interface{}{s.values, s.hashes, s.sets} {
+			for _, k := range reflect.ValueOf(m).MapKeys() {
+				if ok, _ := path.Match(a[2], k.String()); ok {
+					keys = append(keys, k.String())
+				}
+			}
+		}
+		return []interface{}{[]byte("0"), bulks(keys)}, nil
+	}
+	return nil, redis.Error("ERR unknown command " + commandName)
+}
+
+type memCommand struct {
+	name string
+	args []interface{}
+}
+
+// memConn is a connection to a memStore.
+type memConn struct {
+	s       *memStore
+	pending []interface{}
+	queued  []memCommand
+	multi   bool
+}
+
+func (c *memConn) Close() error { return nil }
+func (c *memConn) Err() error   { return nil }
+func (c *memConn) Flush() error { return nil }
+
+func (c *memConn) Send(commandName string, args ...interface{}) error {
+	var reply interface{}
+	switch {
+	case commandName == "MULTI":
+		c.multi = true
+		reply = "OK"
+	case commandName == "EXEC":
+		c.multi = false
+		var replies []interface{}
+		for _, cmd := range c.queued {
+			r, err := c.s.do(cmd.name, cmd.args)
+			if err != nil {
+				r = err
+			}
+			replies = append(replies, r)
+		}
+		c.queued = nil
+		reply = replies
+	case c.multi:
+		c.queued = append(c.queued, memCommand{commandName, args})
+		reply = "QUEUED"
+	default:
+		var err error
+		if reply, err = c.s.do(commandName, args); err != nil {
+			reply = err
+		}
+	}
+	c.pending = append(c.pending, reply)
+	return nil
+}
+
+func (c *memConn) Receive() (interface{}, error) {
+	reply := c.pending[0]
+	c.pending = c.pending[1:]
+	if err, ok := reply.(error); ok {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (c *memConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	c.Send(commandName, args...)
+	c.pending = c.pending[len(c.pending)-1:]
+	return c.Receive()
+}
+
+func TestMigrator(t *testing.T) {
+	s := newMemStore()
+	s.values = map[string]string{"user:a": "alice", "user:b": "bob", "other": "x"}
+	var calls []int
+	var mu sync.Mutex
+	m := &redisx.Migrator{
+		Pool:    redis.NewPool(func() (redis.Conn, error) { return &memConn{s: s}, nil }, 4),
+		Workers: 2,
+	}
+	m.Register(redisx.Migration{
+		Version: 2,
+		Name:    "schema",
+		Func: func(c redis.Conn, key string) error {
+			mu.Lock()
+			calls = append(calls, 2)
+			mu.Unlock()
+			_, err := c.Do("SET", "schema", "2")
+			return err
+		},
+	})
+	m.Register(redisx.Migration{
+		Version: 1,
+		Name:    "upper",
+		Match:   "user:*",
+		Func: func(c redis.Conn, key string) error {
+			mu.Lock()
+			calls = append(calls, 1)
+			mu.Unlock()
+			v, err := redis.String(c.Do("GET", key))
+			if err != nil {
+				return err
+			}
+			_, err = c.Do("SET", key, strings.ToUpper(v))
+			return err
+		},
+	})
+
+	var buf bytes.Buffer
+	m.DryRun = &buf
+	if err := m.Run(context.Background()); err != nil {
+		t.Fatalf("dry run returned %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	sort.Strings(lines)
+	if expected := []string{"SET schema 2", "SET user:a ALICE", "SET user:b BOB"}; !reflect.DeepEqual(lines, expected) {
+		t.Errorf("dry run wrote %q, want %q", lines, expected)
+	}
+	if s.values["user:a"] != "alice" || len(s.hashes) != 0 {
+		t.Errorf("dry run modified store: %v, %v", s.values, s.hashes)
+	}
+
+	m.DryRun = nil
+	calls = nil
+	if err := m.Run(context.Background()); err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+	if expected := []int{1, 1, 2}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("calls = %v, want %v", calls, expected)
+	}
+	if s.values["user:a"] != "ALICE" || s.values["user:b"] != "BOB" || s.values["other"] != "x" || s.values["schema"] != "2" {
+		t.Errorf("store = %v", s.values)
+	}
+	c := m.Pool.Get()
+	defer c.Close()
+	if versions, err := m.Applied(c); err != nil || !reflect.DeepEqual(versions, []int{1, 2}) {
+		t.Errorf("Applied returned %v, %v", versions, err)
+	}
+
+	errFail := errors.New("fail")
+	m.Register(redisx.Migration{
+		Version: 3,
+		Match:   "user:*",
+		Func:    func(c redis.Conn, key string) error { return errFail },
+	})
+	calls = nil
+	err := m.Run(context.Background())
+	var merr *redisx.MigrationError
+	if !errors.As(err, &merr) || merr.Version != 3 || !errors.Is(err, errFail) {
+		t.Errorf("Run returned %v, want migration 3 error", err)
+	}
+	if len(calls) != 0 {
+		t.Errorf("Run reapplied migrations %v", calls)
+	}
+	if pending, err := m.Pending(c); err != nil || len(pending) != 1 || pending[0].Version != 3 {
+		t.Errorf("Pending returned %v, %v", pending, err)
+	}
+}
+
+func TestMigratorDryRunPipelineError(t *testing.T) {
+	s := newMemStore()
+	s.values = map[string]string{"user:a": "alice"}
+	m := &redisx.Migrator{
+		Pool:   redis.NewPool(func() (redis.Conn, error) { return &memConn{s: s}, nil }, 1),
+		DryRun: ioutil.Discard,
+	}
+	m.Register(redisx.Migration{
+		Version: 1,
+		Match:   "user:*",
+		Func: func(c redis.Conn, key string) error {
+			c.Send("STRLEN", key)
+			reply, err := c.Do("SET", key, "x")
+			if reply != "OK" {
+				t.Errorf("Do returned reply %v, want OK", reply)
+			}
+			return err
+		},
+	})
+	err := m.Run(context.Background())
+	var rerr redis.Error
+	if !errors.As(err, &rerr) || !strings.Contains(err.Error(), "STRLEN") {
+		t.Errorf("Run returned %v, want error from pipelined STRLEN", err)
+	}
+}
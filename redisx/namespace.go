@@ -0,0 +1,240 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/garyburd/redigo/redis"
+	"io"
+	"sort"
+	"strings"
+)
+
+// namespaceFormat identifies the format written by ExportNamespace.
+const namespaceFormat = "redisx.namespace"
+
+type namespaceHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+// namespaceRecord is the exported form of a key. Values are []byte to
+// preserve binary data. Encoding/json encodes []byte as base64.
+type namespaceRecord struct {
+	Key   string            `json:"key"`
+	Type  string            `json:"type"`
+	TTL   int64             `json:"ttl_ms,omitempty"`
+	Value []byte            `json:"value,omitempty"`
+	Hash  map[string][]byte `json:"hash,omitempty"`
+	Set   [][]byte          `json:"set,omitempty"`
+}
+
+// ExportNamespace writes the strings, hashes and sets with keys starting with
+// prefix to w. The output is a header line followed by one JSON object per
+// key. Keys are written in sorted order with prefix removed, hash fields are
+// sorted and set members are sorted, so exports of the same data are
+// identical. The remaining time to live of each key is included. Keys of
+// other types cause ExportNamespace to return an error.
+//
+// Use ExportNamespace with ImportNamespace to clone data between
+// environments or to capture test fixtures:
+//
+//  err := redisx.ExportNamespace(ctx, pool, "user:", f)
+func ExportNamespace(ctx context.Context, p *redis.Pool, prefix string, w io.Writer) error {
+	c := p.Get()
+	defer c.Close()
+
+	var keys []string
+	cursor := "0"
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		values, err := redis.Values(c.Do("SCAN", cursor, "MATCH", escapeGlob(prefix)+"*", "COUNT", 100))
+		if err != nil {
+			return err
+		}
+		var batch []string
+		if _, err := redis.Scan(values, &cursor, &batch); err != nil {
+			return err
+		}
+		keys = append(keys, batch...)
+		if cursor == "0" {
+			break
+		}
+	}
+	sort.Strings(keys)
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(namespaceHeader{Format: namespaceFormat, Version: 1}); err != nil {
+		return err
+	}
+	for i, key := range keys {
+		if i > 0 && key == keys[i-1] {
+			// SCAN can return a key more than once.
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rec, err := exportKey(c, key)
+		if err == redis.ErrNil {
+			// The key was deleted or expired after the scan.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		rec.Key = strings.TrimPrefix(key, prefix)
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportKey(c redis.Conn, key string) (*namespaceRecord, error) {
+	c.Send("TYPE", key)
+	c.Send("PTTL", key)
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+	typ, err := redis.String(c.Receive())
+	if err != nil {
+		c.Receive()
+		return nil, err
+	}
+	ttl, err := redis.Int64(c.Receive())
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &namespaceRecord{Type: typ}
+	if ttl > 0 {
+		rec.TTL = ttl
+	}
+	switch typ {
+	case "none":
+		return nil, redis.ErrNil
+	case "string":
+		rec.Value, err = redis.Bytes(c.Do("GET", key))
+		if rec.Value == nil && err == nil {
+			rec.Value = []byte{}
+		}
+	case "hash":
+		var values []interface{}
+		values, err = redis.Values(c.Do("HGETALL", key))
+		if err == nil && len(values) == 0 {
+			err = redis.ErrNil
+		}
+		if err == nil {
+			rec.Hash = make(map[string][]byte, len(values)/2)
+			for i := 0; i+1 < len(values); i += 2 {
+				field, _ := values[i].([]byte)
+				value, _ := values[i+1].([]byte)
+				rec.Hash[string(field)] = value
+			}
+		}
+	case "set":
+		rec.Set, err = redis.ByteSlices(c.Do("SMEMBERS", key))
+		if err == nil && len(rec.Set) == 0 {
+			err = redis.ErrNil
+		}
+		sort.Slice(rec.Set, func(i, j int) bool { return string(rec.Set[i]) < string(rec.Set[j]) })
+	default:
+		return nil, errors.New("redigo: cannot export key " + key + " of type " + typ)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// ImportNamespace reads the keys written by ExportNamespace from r and stores
+// the keys with prefix prepended. Each key replaces an existing key with the
+// same name and expires after the time to live recorded in the export.
+func ImportNamespace(ctx context.Context, p *redis.Pool, prefix string, r io.Reader) error {
+	c := p.Get()
+	defer c.Close()
+
+	dec := json.NewDecoder(r)
+	var h namespaceHeader
+	if err := dec.Decode(&h); err != nil {
+		return err
+	}
+	if h.Format != namespaceFormat || h.Version != 1 {
+		return errors.New("redigo: unsupported namespace export format")
+	}
+	for {
+		var rec namespaceRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		key := prefix + rec.Key
+		args := []interface{}{key}
+		var cmd string
+		switch rec.Type {
+		case "string":
+			cmd = "SET"
+			args = append(args, rec.Value)
+		case "hash":
+			cmd = "HSET"
+			for field, value := range rec.Hash {
+				args = append(args, field, value)
+			}
+		case "set":
+			cmd = "SADD"
+			for _, member := range rec.Set {
+				args = append(args, member)
+			}
+		default:
+			return errors.New("redigo: cannot import key " + key + " of type " + rec.Type)
+		}
+		if len(args) == 1 {
+			return errors.New("redigo: cannot import empty " + rec.Type + " " + key)
+		}
+		c.Send("MULTI")
+		c.Send("DEL", key)
+		c.Send(cmd, args...)
+		if rec.TTL > 0 {
+			c.Send("PEXPIRE", key, rec.TTL)
+		}
+		if _, err := c.Do("EXEC"); err != nil {
+			return err
+		}
+	}
+}
+
+// escapeGlob escapes the SCAN MATCH special characters in s.
+func escapeGlob(s string) string {
+	var buf strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			buf.WriteByte('\\')
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
@@ -0,0 +1,66 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/garyburd/redigo/redis"
+	"github.com/garyburd/redigo/redisx"
+	"reflect"
+	"testing"
+)
+
+func TestNamespace(t *testing.T) {
+	src := newMemStore()
+	src.values["app:greeting"] = "hello\x00world"
+	src.hashes["app:user:1"] = map[string]string{"name": "gopher", "age": "12"}
+	src.sets["app:tags"] = map[string]bool{"b": true, "a": true}
+	src.ttls["app:tags"] = 60000
+	src.values["other:x"] = "y"
+	srcPool := redis.NewPool(func() (redis.Conn, error) { return &memConn{s: src}, nil }, 1)
+
+	var buf bytes.Buffer
+	if err := redisx.ExportNamespace(context.Background(), srcPool, "app:", &buf); err != nil {
+		t.Fatalf("ExportNamespace returned %v", err)
+	}
+	var again bytes.Buffer
+	redisx.ExportNamespace(context.Background(), srcPool, "app:", &again)
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Errorf("exports differ:\n%s\n%s", buf.Bytes(), again.Bytes())
+	}
+	if n := bytes.Count(buf.Bytes(), []byte("\n")); n != 4 {
+		t.Errorf("export has %d lines, want 4:\n%s", n, buf.Bytes())
+	}
+
+	dst := newMemStore()
+	dst.values["test:greeting"] = "old"
+	dstPool := redis.NewPool(func() (redis.Conn, error) { return &memConn{s: dst}, nil }, 1)
+	if err := redisx.ImportNamespace(context.Background(), dstPool, "test:", &buf); err != nil {
+		t.Fatalf("ImportNamespace returned %v", err)
+	}
+	if v := dst.values["test:greeting"]; v != "hello\x00world" {
+		t.Errorf("imported string = %q", v)
+	}
+	if !reflect.DeepEqual(dst.hashes["test:user:1"], src.hashes["app:user:1"]) {
+		t.Errorf("imported hash = %v", dst.hashes["test:user:1"])
+	}
+	if !reflect.DeepEqual(dst.sets["test:tags"], src.sets["app:tags"]) || dst.ttls["test:tags"] != 60000 {
+		t.Errorf("imported set = %v, ttl %d", dst.sets["test:tags"], dst.ttls["test:tags"])
+	}
+	if len(dst.values)+len(dst.hashes)+len(dst.sets) != 3 {
+		t.Errorf("import stored extra keys: %v %v %v", dst.values, dst.hashes, dst.sets)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx
+
+import (
+	"context"
+	"github.com/garyburd/redigo/redis"
+	"sync/atomic"
+)
+
+// Publisher publishes messages using connections from a pool. Publish retries
+// on connection errors using a new connection from the pool.
+type Publisher struct {
+	Pool *redis.Pool
+
+	// MaxRetries is the maximum number of times a message is retried after a
+	// connection error.
+	MaxRetries int
+
+	published, delivered, undelivered, retries, errors int64
+}
+
+// PublisherStats is a snapshot of a publisher's counters.
+type PublisherStats struct {
+	// Messages published without error.
+	Published int64
+
+	// Sum of the number of subscribers that received each message.
+	Delivered int64
+
+	// Messages that were not received by any subscriber.
+	Undelivered int64
+
+	// Retries after connection errors.
+	Retries int64
+
+	// Messages that could not be published.
+	Errors int64
+}
+
+// Publish publishes payload to channel and returns the number of subscribers
+// that received the message. Publish stops retrying when ctx is done.
+func (p *Publisher) Publish(ctx context.Context, channel string, payload interface{}) (int, error) {
+	var err error
+	for i := 0; i <= p.MaxRetries; i++ {
+		if e := ctx.Err(); e != nil {
+			if err == nil {
+				err = e
+			}
+			break
+		}
+		if i > 0 {
+			atomic.AddInt64(&p.retries, 1)
+		}
+		var n int
+		n, err = p.publish(channel, payload)
+		if err == nil {
+			atomic.AddInt64(&p.published, 1)
+			atomic.AddInt64(&p.delivered, int64(n))
+			if n == 0 {
+				atomic.AddInt64(&p.undelivered, 1)
+			}
+			return n, nil
+		}
+		if _, ok := err.(redis.Error); ok {
+			// The server replied with an error. Retrying will not help.
+			break
+		}
+	}
+	atomic.AddInt64(&p.errors, 1)
+	return 0, err
+}
+
+func (p *Publisher) publish(channel string, payload interface{}) (int, error) {
+	c := p.Pool.Get()
+	defer c.Close()
+	return redis.Int(c.Do("PUBLISH", channel, payload))
+}
+
+// Stats returns a snapshot of the publisher's counters.
+func (p *Publisher) Stats() PublisherStats {
+	return PublisherStats{
+		Published:   atomic.LoadInt64(&p.published),
+		Delivered:   atomic.LoadInt64(&p.delivered),
+		Undelivered: atomic.LoadInt64(&p.undelivered),
+		Retries:     atomic.LoadInt64(&p.retries),
+		Errors:      atomic.LoadInt64(&p.errors),
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx_test
+
+import (
+	"context"
+	"errors"
+	"github.com/garyburd/redigo/redis"
+	"github.com/garyburd/redigo/redisx"
+	"io"
+	"testing"
+)
+
+func TestPublisher(t *testing.T) {
+	conns := []*scriptConn{newScriptConn(io.ErrUnexpectedEOF), newScriptConn(int64(2), int64(0))}
+	p := &redisx.Publisher{
+		MaxRetries: 1,
+		Pool: redis.NewPool(func() (redis.Conn, error) {
+			if len(conns) == 0 {
+				return nil, errors.New("dial error")
+			}
+			c := conns[0]
+			conns = conns[1:]
+			return c, nil
+		}, 0),
+	}
+	n, err := p.Publish(context.Background(), "c", "hello")
+	if err != nil || n != 2 {
+		t.Fatalf("Publish returned %d, %v", n, err)
+	}
+	if _, err := p.Publish(context.Background(), "c", "hello"); err == nil {
+		t.Fatalf("Publish did not return error with no connections")
+	}
+	expected := redisx.PublisherStats{Published: 1, Delivered: 2, Retries: 2, Errors: 1}
+	if stats := p.Stats(); stats != expected {
+		t.Errorf("Stats() = %+v, want %+v", stats, expected)
+	}
+}
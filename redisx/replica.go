@@ -0,0 +1,131 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx
+
+import (
+	"context"
+	"errors"
+	"github.com/garyburd/redigo/redis"
+)
+
+// ReplicaReader reads from a replica and falls back to the primary when the
+// replica replies with nil for a key that the primary is likely to have. The
+// fallback hides spurious cache misses during replication lag spikes.
+type ReplicaReader struct {
+	Primary *redis.Pool
+	Replica *redis.Pool
+
+	// ShouldFallback is an optional function that reports whether a nil
+	// reply from the replica to the command is read from the primary. If
+	// ShouldFallback is nil, then all nil replies are read from the primary.
+	ShouldFallback func(commandName string, args []interface{}) bool
+
+	// OnInconsistency is an optional function called when the replica
+	// replies with nil and the primary replies with a non-nil value.
+	OnInconsistency func(commandName string, args []interface{}, primaryReply interface{})
+}
+
+// Do executes a read command on the replica and falls back to the primary as
+// described in ReplicaReader.
+func (r *ReplicaReader) Do(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	reply, err := r.do(ctx, r.Replica, commandName, args)
+	if err != nil || reply != nil {
+		return reply, err
+	}
+	if r.ShouldFallback != nil && !r.ShouldFallback(commandName, args) {
+		return nil, nil
+	}
+	reply, err = r.do(ctx, r.Primary, commandName, args)
+	if err == nil && reply != nil && r.OnInconsistency != nil {
+		r.OnInconsistency(commandName, args, reply)
+	}
+	return reply, err
+}
+
+func (r *ReplicaReader) do(ctx context.Context, p *redis.Pool, commandName string, args []interface{}) (interface{}, error) {
+	c := p.Get()
+	defer c.Close()
+	return redis.DoContext(c, ctx, commandName, args...)
+}
+
+// ReadPreference specifies where a Router sends read commands.
+type ReadPreference int
+
+const (
+	// ReadPrimary sends all commands to the primary.
+	ReadPrimary ReadPreference = iota
+
+	// ReadReplica sends read commands to the replica.
+	ReadReplica
+
+	// ReadReplicaPreferred sends read commands to the replica and retries
+	// the command on the primary when the replica fails with a connection
+	// error or a transient error as reported by redis.IsTransient.
+	ReadReplicaPreferred
+)
+
+// Router sends read commands to a replica and all other commands to the
+// primary. Commands are classified with redis.ReadOnlyCommand. Dial cluster
+// replicas with the redis.DialReplicaReads option.
+type Router struct {
+	Primary *redis.Pool
+	Replica *redis.Pool
+
+	// Preference specifies where read commands are sent. The default is
+	// ReadPrimary.
+	Preference ReadPreference
+
+	// RecycleOnReadOnly specifies whether the primary pool is recycled when
+	// the primary replies with a READONLY error. The error indicates that
+	// the primary was demoted by a failover. Recycling the pool replaces the
+	// pool's connections with connections to the address that the primary
+	// endpoint resolves to after the failover.
+	RecycleOnReadOnly bool
+}
+
+// Do executes the command on the primary or the replica as specified by the
+// router's preference.
+func (r *Router) Do(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	if r.Preference == ReadPrimary || !redis.ReadOnlyCommand(commandName) {
+		return r.do(ctx, r.Primary, commandName, args)
+	}
+	reply, err := r.do(ctx, r.Replica, commandName, args)
+	if err == nil || r.Preference != ReadReplicaPreferred || ctx.Err() != nil {
+		return reply, err
+	}
+	if _, ok := err.(redis.Error); ok && !redis.IsTransient(err) {
+		return reply, err
+	}
+	return r.do(ctx, r.Primary, commandName, args)
+}
+
+// GetReader returns a connection for read commands. The connection is from
+// the replica pool unless the preference is ReadPrimary.
+func (r *Router) GetReader() redis.Conn {
+	if r.Preference == ReadPrimary {
+		return r.Primary.Get()
+	}
+	return r.Replica.Get()
+}
+
+func (r *Router) do(ctx context.Context, p *redis.Pool, commandName string, args []interface{}) (interface{}, error) {
+	c := p.Get()
+	defer c.Close()
+	reply, err := redis.DoContext(c, ctx, commandName, args...)
+	if r.RecycleOnReadOnly && p == r.Primary && errors.Is(err, &redis.ReadOnlyError{}) {
+		p.Recycle()
+	}
+	return reply, err
+}
@@ -0,0 +1,99 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx_test
+
+import (
+	"context"
+	"github.com/garyburd/redigo/redis"
+	"github.com/garyburd/redigo/redisx"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func scriptPool(c *scriptConn) *redis.Pool {
+	return redis.NewPool(func() (redis.Conn, error) { return c, nil }, 0)
+}
+
+func TestReplicaReader(t *testing.T) {
+	primary := newScriptConn([]byte("p1"), nil)
+	replica := newScriptConn([]byte("r1"), nil, nil, nil)
+	var inconsistent []string
+	r := &redisx.ReplicaReader{
+		Primary: scriptPool(primary),
+		Replica: scriptPool(replica),
+		ShouldFallback: func(commandName string, args []interface{}) bool {
+			return args[0] != "missing"
+		},
+		OnInconsistency: func(commandName string, args []interface{}, reply interface{}) {
+			inconsistent = append(inconsistent, args[0].(string))
+		},
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		key      string
+		expected interface{}
+	}{
+		{"a", "r1"},      // read from replica
+		{"b", "p1"},      // replica lagging
+		{"missing", nil}, // no fallback
+		{"c", nil},       // missing on both
+	}
+	for _, tt := range tests {
+		reply, err := redis.String(r.Do(ctx, "GET", tt.key))
+		if tt.expected == nil {
+			if err != nil && err != redis.ErrNil {
+				t.Errorf("GET %s returned %q, %v, want nil", tt.key, reply, err)
+			}
+		} else if err != nil || reply != tt.expected {
+			t.Errorf("GET %s returned %q, %v, want %q", tt.key, reply, err, tt.expected)
+		}
+	}
+	if len(inconsistent) != 1 || inconsistent[0] != "b" {
+		t.Errorf("OnInconsistency called for %q, want [b]", inconsistent)
+	}
+	if len(primary.commands) != 2 {
+		t.Errorf("primary received %q", primary.commands)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	primary := newScriptConn("OK", []byte("p"))
+	replica := newScriptConn([]byte("r"), io.ErrUnexpectedEOF, redis.Error("ERR bad"))
+	r := &redisx.Router{
+		Primary:    scriptPool(primary),
+		Replica:    scriptPool(replica),
+		Preference: redisx.ReadReplicaPreferred,
+	}
+	ctx := context.Background()
+
+	if _, err := r.Do(ctx, "SET", "k", "v"); err != nil {
+		t.Errorf("SET returned %v", err)
+	}
+	if v, err := redis.String(r.Do(ctx, "GET", "k")); err != nil || v != "r" {
+		t.Errorf("GET returned %q, %v, want r", v, err)
+	}
+	if v, err := redis.String(r.Do(ctx, "GET", "k")); err != nil || v != "p" {
+		t.Errorf("GET after replica error returned %q, %v, want p", v, err)
+	}
+	if _, err := r.Do(ctx, "GET", "k"); err != redis.Error("ERR bad") {
+		t.Errorf("GET with error reply returned %v", err)
+	}
+	expected := []string{"SET k v", "GET k"}
+	if !reflect.DeepEqual(primary.commands, expected) {
+		t.Errorf("primary received %q, want %q", primary.commands, expected)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx
+
+import (
+	"errors"
+	"github.com/garyburd/redigo/redis"
+)
+
+// Sort specifies the options for the SORT and SORT_RO commands.
+//
+//  var users []struct {
+//      Name string
+//      Age  int
+//  }
+//  s := redisx.Sort{
+//      By:  redisx.HashPattern("user:*", "age"),
+//      Get: []string{redisx.HashPattern("user:*", "name"), redisx.HashPattern("user:*", "age")},
+//  }
+//  err := s.Scan(c, "user_ids", &users, "Name", "Age")
+type Sort struct {
+	// By is the pattern of the external keys used to sort the elements. Use
+	// "nosort" to skip sorting.
+	By string
+
+	// Get is the list of patterns of the external keys returned for each
+	// element. The pattern "#" returns the element.
+	Get []string
+
+	// Offset and Count limit the elements returned. The limit is applied
+	// when Count is greater than zero.
+	Offset, Count int
+
+	// Desc sorts in descending order.
+	Desc bool
+
+	// Alpha sorts lexicographically instead of numerically.
+	Alpha bool
+
+	// ReadOnly uses the SORT_RO command. SORT_RO can be executed on
+	// replicas.
+	ReadOnly bool
+}
+
+// HashPattern returns a BY or GET pattern for field in the hashes matching
+// keyPattern.
+func HashPattern(keyPattern, field string) string {
+	return keyPattern + "->" + field
+}
+
+func (s Sort) args(key string) []interface{} {
+	args := []interface{}{key}
+	if s.By != "" {
+		args = append(args, "BY", s.By)
+	}
+	if s.Count > 0 {
+		args = append(args, "LIMIT", s.Offset, s.Count)
+	}
+	for _, pattern := range s.Get {
+		args = append(args, "GET", pattern)
+	}
+	if s.Desc {
+		args = append(args, "DESC")
+	}
+	if s.Alpha {
+		args = append(args, "ALPHA")
+	}
+	return args
+}
+
+func (s Sort) command() string {
+	if s.ReadOnly {
+		return "SORT_RO"
+	}
+	return "SORT"
+}
+
+// Values returns the sorted elements. If Get has more than one pattern, then
+// the values for each element are interleaved in the order of the patterns.
+func (s Sort) Values(c redis.Conn, key string) ([]interface{}, error) {
+	return redis.Values(c.Do(s.command(), s.args(key)...))
+}
+
+// Scan scans the sorted elements to dest using redis.ScanSlice. The field
+// names specify the struct field for each pattern in Get.
+func (s Sort) Scan(c redis.Conn, key string, dest interface{}, fieldNames ...string) error {
+	if len(fieldNames) != 0 && len(fieldNames) != len(s.Get) {
+		return errors.New("redigo: Sort expects one field name for each GET pattern")
+	}
+	values, err := s.Values(c, key)
+	if err != nil {
+		return err
+	}
+	return redis.ScanSlice(values, dest, fieldNames...)
+}
+
+// Store stores the sorted elements in the list at dest and returns the number
+// of elements in the list.
+func (s Sort) Store(c redis.Conn, key, dest string) (int, error) {
+	if s.ReadOnly {
+		return 0, errors.New("redigo: SORT_RO does not support STORE")
+	}
+	return redis.Int(c.Do("SORT", append(s.args(key), "STORE", dest)...))
+}
@@ -0,0 +1,62 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx_test
+
+import (
+	"github.com/garyburd/redigo/redisx"
+	"reflect"
+	"testing"
+)
+
+func TestSort(t *testing.T) {
+	type user struct {
+		Name string
+		Age  int
+	}
+	s := redisx.Sort{
+		By:       redisx.HashPattern("user:*", "age"),
+		Get:      []string{redisx.HashPattern("user:*", "name"), redisx.HashPattern("user:*", "age")},
+		Count:    2,
+		Desc:     true,
+		ReadOnly: true,
+	}
+	c := newScriptConn([]interface{}{[]byte("bob"), []byte("40"), []byte("alice"), []byte("30")}, int64(2))
+	var users []user
+	if err := s.Scan(c, "ids", &users, "Name", "Age"); err != nil {
+		t.Fatalf("Scan returned %v", err)
+	}
+	if expected := []user{{"bob", 40}, {"alice", 30}}; !reflect.DeepEqual(users, expected) {
+		t.Errorf("Scan returned %+v, want %+v", users, expected)
+	}
+	if err := s.Scan(c, "ids", &users, "Name"); err == nil {
+		t.Errorf("Scan with missing field name did not return error")
+	}
+	if _, err := s.Store(c, "ids", "dest"); err == nil {
+		t.Errorf("Store with ReadOnly did not return error")
+	}
+
+	s = redisx.Sort{Alpha: true, Offset: 10, Count: 5}
+	if n, err := s.Store(c, "ids", "dest"); err != nil || n != 2 {
+		t.Errorf("Store returned %d, %v", n, err)
+	}
+
+	expected := []string{
+		"SORT_RO ids BY user:*->age LIMIT 0 2 GET user:*->name GET user:*->age DESC",
+		"SORT ids LIMIT 10 5 ALPHA STORE dest",
+	}
+	if !reflect.DeepEqual(c.commands, expected) {
+		t.Errorf("commands = %q, want %q", c.commands, expected)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx
+
+import (
+	"errors"
+	"github.com/garyburd/redigo/redis"
+	"strconv"
+	"sync"
+)
+
+const invalidateChannel = "__redis__:invalidate"
+
+var errTrackerClosed = errors.New("redigo: tracker closed")
+
+// TrackingOptions specifies the mode of client side caching enabled by
+// Tracker.Enable. See the CLIENT TRACKING command for a description of the
+// modes.
+type TrackingOptions struct {
+	// Broadcast enables broadcasting mode. In broadcasting mode, the server
+	// sends invalidation messages for all keys matching Prefixes instead of
+	// the keys read by the connection.
+	Broadcast bool
+
+	// Prefixes restricts broadcasting mode to keys with the prefixes.
+	Prefixes []string
+
+	// OptIn tracks only the keys read after CLIENT CACHING yes. OptOut
+	// tracks all keys except the keys read after CLIENT CACHING no.
+	OptIn  bool
+	OptOut bool
+
+	// NoLoop disables invalidation messages for keys modified by the
+	// connection.
+	NoLoop bool
+}
+
+// Tracker receives client side caching invalidation messages on a dedicated
+// connection. Enable turns on tracking for other connections with the
+// invalidation messages redirected to the tracker's connection. The
+// connections use the RESP2 protocol and the server delivers the messages
+// using pub/sub.
+//
+// Use the pool's Dial function to enable tracking on pooled connections:
+//
+//  pool := redis.NewPool(func() (redis.Conn, error) {
+//      c, err := redis.Dial("tcp", addr)
+//      if err != nil {
+//          return nil, err
+//      }
+//      if err := tracker.Enable(c, redisx.TrackingOptions{}); err != nil {
+//          c.Close()
+//          return nil, err
+//      }
+//      return c, nil
+//  }, 10)
+//
+// The server stops sending invalidation messages when the tracker's
+// connection is closed. Applications must discard the local cache and the
+// connections redirected to the tracker after the tracker stops.
+type Tracker struct {
+	c            redis.Conn
+	id           int64
+	onInvalidate func(keys []string)
+	done         chan struct{}
+
+	mu     sync.Mutex
+	err    error
+	closed bool
+}
+
+// NewTracker subscribes to invalidation messages on connection c and starts
+// a goroutine to receive the messages. The tracker calls onInvalidate from
+// the goroutine with the invalidated keys. A nil slice of keys specifies
+// that all keys are invalidated. The server sends a nil slice on FLUSHALL
+// and FLUSHDB. The tracker also calls onInvalidate with a nil slice when the
+// tracker stops because the local cache can no longer be kept coherent.
+func NewTracker(c redis.Conn, onInvalidate func(keys []string)) (*Tracker, error) {
+	id, err := redis.Int64(c.Do("CLIENT", "ID"))
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Send("SUBSCRIBE", invalidateChannel); err != nil {
+		return nil, err
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+	switch r := (redis.PubSubConn{Conn: c}).Receive().(type) {
+	case error:
+		return nil, r
+	case redis.Subscription:
+		if r.Kind != "subscribe" {
+			return nil, errors.New("redigo: unexpected " + r.Kind + " notification")
+		}
+	default:
+		return nil, errors.New("redigo: unexpected reply to SUBSCRIBE")
+	}
+	t := &Tracker{c: c, id: id, onInvalidate: onInvalidate, done: make(chan struct{})}
+	go t.run()
+	return t, nil
+}
+
+func (t *Tracker) run() {
+	defer close(t.done)
+	err := t.receive()
+	t.mu.Lock()
+	if t.closed {
+		err = errTrackerClosed
+	}
+	t.err = err
+	t.mu.Unlock()
+	t.onInvalidate(nil)
+}
+
+func (t *Tracker) receive() error {
+	for {
+		reply, err := redis.Values(t.c.Receive())
+		if err != nil {
+			return err
+		}
+		var kind, channel string
+		var data interface{}
+		if _, err := redis.Scan(reply, &kind, &channel, &data); err != nil {
+			return err
+		}
+		if kind != "message" || channel != invalidateChannel {
+			continue
+		}
+		if data == nil {
+			t.onInvalidate(nil)
+			continue
+		}
+		keys, err := redis.Strings(data, nil)
+		if err != nil {
+			return err
+		}
+		t.onInvalidate(keys)
+	}
+}
+
+// ID returns the client ID of the tracker's connection.
+func (t *Tracker) ID() int64 {
+	return t.id
+}
+
+// Enable turns on tracking for connection c with the invalidation messages
+// redirected to the tracker.
+func (t *Tracker) Enable(c redis.Conn, opts TrackingOptions) error {
+	args := []interface{}{"TRACKING", "ON", "REDIRECT", strconv.FormatInt(t.id, 10)}
+	for _, p := range opts.Prefixes {
+		args = append(args, "PREFIX", p)
+	}
+	if opts.Broadcast {
+		args = append(args, "BCAST")
+	}
+	if opts.OptIn {
+		args = append(args, "OPTIN")
+	}
+	if opts.OptOut {
+		args = append(args, "OPTOUT")
+	}
+	if opts.NoLoop {
+		args = append(args, "NOLOOP")
+	}
+	_, err := c.Do("CLIENT", args...)
+	return err
+}
+
+// Done returns a channel that is closed when the tracker stops.
+func (t *Tracker) Done() <-chan struct{} {
+	return t.done
+}
+
+// Err returns the reason that the tracker stopped or nil if the tracker is
+// running.
+func (t *Tracker) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// Close closes the tracker's connection and waits for the receive goroutine
+// to exit.
+func (t *Tracker) Close() error {
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+	err := t.c.Close()
+	<-t.done
+	return err
+}
@@ -0,0 +1,62 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx_test
+
+import (
+	"github.com/garyburd/redigo/redisx"
+	"reflect"
+	"testing"
+)
+
+func TestTracker(t *testing.T) {
+	c := newScriptConn(
+		int64(7),
+		[]interface{}{[]byte("subscribe"), []byte("__redis__:invalidate"), int64(1)},
+		[]interface{}{[]byte("message"), []byte("__redis__:invalidate"), []interface{}{[]byte("a"), []byte("b")}},
+		[]interface{}{[]byte("message"), []byte("__redis__:invalidate"), nil},
+	)
+	var invalidated [][]string
+	tr, err := redisx.NewTracker(c, func(keys []string) {
+		invalidated = append(invalidated, keys)
+	})
+	if err != nil {
+		t.Fatalf("NewTracker returned %v", err)
+	}
+	if tr.ID() != 7 {
+		t.Errorf("ID() = %d, want 7", tr.ID())
+	}
+
+	dc := newScriptConn("OK")
+	if err := tr.Enable(dc, redisx.TrackingOptions{Broadcast: true, Prefixes: []string{"user:"}, NoLoop: true}); err != nil {
+		t.Fatalf("Enable returned %v", err)
+	}
+	if expected := "CLIENT TRACKING ON REDIRECT 7 PREFIX user: BCAST NOLOOP"; dc.commands[0] != expected {
+		t.Errorf("Enable sent %q, want %q", dc.commands[0], expected)
+	}
+
+	// The script connection returns an error when the replies are exhausted.
+	<-tr.Done()
+	if tr.Err() == nil {
+		t.Error("Err() returned nil after tracker stopped")
+	}
+	expected := [][]string{{"a", "b"}, nil, nil}
+	if !reflect.DeepEqual(invalidated, expected) {
+		t.Errorf("invalidated %q, want %q", invalidated, expected)
+	}
+	expectedCommands := []string{"CLIENT ID", "SUBSCRIBE __redis__:invalidate"}
+	if !reflect.DeepEqual(c.commands, expectedCommands) {
+		t.Errorf("NewTracker sent %q, want %q", c.commands, expectedCommands)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"time"
+)
+
+// NoTTL is the value returned by TTLs for keys that exist but do not have an
+// associated timeout.
+const NoTTL = time.Duration(-1)
+
+// pipelineKeys sends the command for each key and returns the replies and
+// errors in key order. The returned error is set only when the commands could
+// not be sent.
+func pipelineKeys(c redis.Conn, commandName string, keys []string, args ...interface{}) ([]interface{}, []error, error) {
+	for _, key := range keys {
+		if err := c.Send(commandName, append([]interface{}{key}, args...)...); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := c.Flush(); err != nil {
+		return nil, nil, err
+	}
+	replies := make([]interface{}, len(keys))
+	errs := make([]error, len(keys))
+	for i := range keys {
+		replies[i], errs[i] = c.Receive()
+	}
+	return replies, errs, nil
+}
+
+// TTLs returns the remaining time to live of the given keys using pipelined
+// PTTL commands. Keys that do not exist are not included in the result. Keys
+// that exist but do not have a timeout map to NoTTL. If the command fails
+// for some keys, then TTLs returns the results for the other keys and the
+// first error.
+func TTLs(c redis.Conn, keys ...string) (map[string]time.Duration, error) {
+	replies, errs, err := pipelineKeys(c, "PTTL", keys)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]time.Duration, len(keys))
+	for i, key := range keys {
+		n, e := redis.Int(replies[i], errs[i])
+		if e != nil {
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+		switch {
+		case n == -2:
+			// key does not exist
+		case n < 0:
+			m[key] = NoTTL
+		default:
+			m[key] = time.Duration(n) * time.Millisecond
+		}
+	}
+	return m, err
+}
+
+// ExpireMany sets the timeout of the given keys to d using pipelined PEXPIRE
+// commands. The result maps each key to true if the timeout was set and false
+// if the key does not exist. Errors are handled as described for TTLs.
+func ExpireMany(c redis.Conn, d time.Duration, keys ...string) (map[string]bool, error) {
+	return boolPerKey(c, "PEXPIRE", keys, int64(d/time.Millisecond))
+}
+
+// Touch updates the last access time of the given keys using pipelined TOUCH
+// commands. The result maps each key to true if the key exists. Errors are
+// handled as described for TTLs.
+func Touch(c redis.Conn, keys ...string) (map[string]bool, error) {
+	return boolPerKey(c, "TOUCH", keys)
+}
+
+func boolPerKey(c redis.Conn, commandName string, keys []string, args ...interface{}) (map[string]bool, error) {
+	replies, errs, err := pipelineKeys(c, commandName, keys, args...)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]bool, len(keys))
+	for i, key := range keys {
+		ok, e := redis.Bool(replies[i], errs[i])
+		if e != nil {
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+		m[key] = ok
+	}
+	return m, err
+}
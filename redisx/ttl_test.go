@@ -0,0 +1,55 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"github.com/garyburd/redigo/redisx"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTTLs(t *testing.T) {
+	c := newScriptConn(int64(1500), int64(-1), int64(-2))
+	m, err := redisx.TTLs(c, "a", "b", "c")
+	if err != nil {
+		t.Fatalf("TTLs returned error %v", err)
+	}
+	expected := map[string]time.Duration{"a": 1500 * time.Millisecond, "b": redisx.NoTTL}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("TTLs returned %v, want %v", m, expected)
+	}
+}
+
+func TestExpireMany(t *testing.T) {
+	c := newScriptConn(int64(1), int64(0))
+	m, err := redisx.ExpireMany(c, time.Second, "a", "b")
+	if err != nil {
+		t.Fatalf("ExpireMany returned error %v", err)
+	}
+	if !reflect.DeepEqual(m, map[string]bool{"a": true, "b": false}) {
+		t.Errorf("ExpireMany returned %v", m)
+	}
+	if !reflect.DeepEqual(c.commands, []string{"PEXPIRE a 1000", "PEXPIRE b 1000"}) {
+		t.Errorf("ExpireMany sent %q", c.commands)
+	}
+
+	c = newScriptConn(redis.Error("WRONGTYPE"), int64(1))
+	m, err = redisx.ExpireMany(c, time.Second, "a", "b")
+	if err == nil || !reflect.DeepEqual(m, map[string]bool{"b": true}) {
+		t.Errorf("ExpireMany with error reply returned %v, %v", m, err)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx
+
+import (
+	"errors"
+	"github.com/garyburd/redigo/redis"
+)
+
+// ZAdd specifies the options for the ZADD command. The Do and Incr methods
+// check for illegal combinations of options before sending the command and
+// decode the reply for the options.
+//
+//  n, err := redisx.ZAdd{GT: true, CH: true}.Do(c, "scores", 10, "alice", 20, "bob")
+type ZAdd struct {
+	// NX only adds new members. XX only updates existing members.
+	NX, XX bool
+
+	// GT and LT only update existing members when the new score is greater
+	// than or less than the current score. New members are added.
+	GT, LT bool
+
+	// CH changes the reply of Do to the number of members added or updated.
+	CH bool
+}
+
+func (z ZAdd) args(key string) ([]interface{}, error) {
+	switch {
+	case z.NX && z.XX:
+		return nil, errors.New("redigo: ZADD NX and XX options are not compatible")
+	case z.GT && z.LT:
+		return nil, errors.New("redigo: ZADD GT and LT options are not compatible")
+	case z.NX && (z.GT || z.LT):
+		return nil, errors.New("redigo: ZADD NX option is not compatible with GT or LT")
+	}
+	args := []interface{}{key}
+	for _, o := range []struct {
+		set  bool
+		name string
+	}{{z.NX, "NX"}, {z.XX, "XX"}, {z.GT, "GT"}, {z.LT, "LT"}, {z.CH, "CH"}} {
+		if o.set {
+			args = append(args, o.name)
+		}
+	}
+	return args, nil
+}
+
+// Do adds the score and member pairs to the sorted set at key. Do returns the
+// number of members added or, if the CH option is set, the number of members
+// added or updated.
+func (z ZAdd) Do(c redis.Conn, key string, scoreMembers ...interface{}) (int, error) {
+	if len(scoreMembers) == 0 || len(scoreMembers)%2 != 0 {
+		return 0, errors.New("redigo: ZADD expects score and member pairs")
+	}
+	args, err := z.args(key)
+	if err != nil {
+		return 0, err
+	}
+	return redis.Int(c.Do("ZADD", append(args, scoreMembers...)...))
+}
+
+// Incr increments the score of member using ZADD with the INCR option and
+// returns the new score. If the command is aborted by the NX, XX, GT or LT
+// option, then Incr returns false.
+func (z ZAdd) Incr(c redis.Conn, key string, increment float64, member interface{}) (float64, bool, error) {
+	args, err := z.args(key)
+	if err != nil {
+		return 0, false, err
+	}
+	reply, err := c.Do("ZADD", append(args, "INCR", increment, member)...)
+	if err == nil && reply == nil {
+		return 0, false, nil
+	}
+	score, err := redis.Float64(reply, err)
+	if err != nil {
+		return 0, false, err
+	}
+	return score, true, nil
+}
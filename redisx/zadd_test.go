@@ -0,0 +1,51 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redisx_test
+
+import (
+	"github.com/garyburd/redigo/redisx"
+	"testing"
+)
+
+func TestZAdd(t *testing.T) {
+	c := newScriptConn(int64(2), []byte("12.5"), nil)
+	if n, err := (redisx.ZAdd{GT: true, CH: true}).Do(c, "z", 1, "a", 2, "b"); err != nil || n != 2 {
+		t.Errorf("Do returned %d, %v", n, err)
+	}
+	if score, ok, err := (redisx.ZAdd{}).Incr(c, "z", 2.5, "a"); err != nil || !ok || score != 12.5 {
+		t.Errorf("Incr returned %v, %v, %v", score, ok, err)
+	}
+	if score, ok, err := (redisx.ZAdd{XX: true}).Incr(c, "z", 1, "c"); err != nil || ok {
+		t.Errorf("aborted Incr returned %v, %v, %v", score, ok, err)
+	}
+	expected := []string{"ZADD z GT CH 1 a 2 b", "ZADD z INCR 2.5 a", "ZADD z XX INCR 1 c"}
+	for i, cmd := range expected {
+		if i >= len(c.commands) || c.commands[i] != cmd {
+			t.Errorf("command %d = %q, want %q", i, c.commands, cmd)
+		}
+	}
+
+	for _, z := range []redisx.ZAdd{{NX: true, XX: true}, {GT: true, LT: true}, {NX: true, GT: true}} {
+		if _, err := z.Do(c, "z", 1, "a"); err == nil {
+			t.Errorf("Do with %+v did not return error", z)
+		}
+	}
+	if _, err := (redisx.ZAdd{}).Do(c, "z", 1); err == nil {
+		t.Errorf("Do with odd number of arguments did not return error")
+	}
+	if len(c.commands) != len(expected) {
+		t.Errorf("invalid commands were sent: %q", c.commands[len(expected):])
+	}
+}